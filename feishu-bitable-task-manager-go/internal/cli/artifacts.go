@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// artifact is one entry of the JSON manifest stored in the Artifacts field.
+type artifact struct {
+	Name      string `json:"name"`
+	FileToken string `json:"file_token"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+type AttachOptions struct {
+	TaskURL   string
+	RecordID  string
+	TaskID    int
+	BizTaskID string
+	Files     []string
+	Manifest  bool
+	Verify    bool
+}
+
+type DownloadOptions struct {
+	TaskURL      string
+	RecordID     string
+	TaskID       int
+	BizTaskID    string
+	Dir          string
+	Names        []string
+	AllArtifacts bool
+}
+
+type attachReport struct {
+	RecordID       string     `json:"record_id"`
+	Uploaded       int        `json:"uploaded"`
+	Artifacts      []artifact `json:"artifacts"`
+	ElapsedSeconds float64    `json:"elapsed_seconds"`
+}
+
+type downloadReport struct {
+	RecordID       string   `json:"record_id"`
+	Downloaded     []string `json:"downloaded"`
+	Mismatched     []string `json:"mismatched"`
+	Failed         int      `json:"failed"`
+	Errors         []string `json:"errors"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+}
+
+func parseArtifacts(raw string) ([]artifact, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var out []artifact
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return nil, fmt.Errorf("invalid artifacts manifest: %w", err)
+	}
+	return out, nil
+}
+
+// mergeArtifacts replaces entries with the same name and appends new ones,
+// keeping the original manifest order.
+func mergeArtifacts(existing, added []artifact) []artifact {
+	out := append([]artifact{}, existing...)
+	for _, a := range added {
+		replaced := false
+		for i := range out {
+			if out[i].Name == a.Name {
+				out[i] = a
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyArtifact checks downloaded content against the size and sha256
+// recorded at upload time. Entries without a checksum are accepted.
+func verifyArtifact(a artifact, data []byte) error {
+	if a.Size > 0 && int64(len(data)) != a.Size {
+		return fmt.Errorf("size mismatch: manifest=%d got=%d", a.Size, len(data))
+	}
+	if a.SHA256 != "" && !strings.EqualFold(a.SHA256, sha256Hex(data)) {
+		return fmt.Errorf("sha256 mismatch: manifest=%s got=%s", a.SHA256, sha256Hex(data))
+	}
+	return nil
+}
+
+func AttachArtifacts(ctx context.Context, opts AttachOptions) int {
+	if len(opts.Files) == 0 {
+		errLogger.Error("at least one --file is required")
+		return 2
+	}
+	if !opts.Manifest {
+		errLogger.Error("--manifest is required: files are recorded in the Artifacts manifest")
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	col := strings.TrimSpace(tc.fields["Artifacts"])
+	recordID, err := tc.resolveRecordID(ctx, opts.RecordID, opts.TaskID, opts.BizTaskID)
+	if err != nil {
+		errLogger.Error("resolve record failed", "err", err)
+		return 2
+	}
+	current, err := tc.getRecordFields(ctx, recordID)
+	if err != nil {
+		errLogger.Error("get record failed", "record_id", recordID, "err", err)
+		return 2
+	}
+	if l, ok := activeEditLock(current, tc.fields, time.Now()); ok {
+		errLogger.Error("record is locked for manual edits; not attaching", "record_id", recordID, "lock", l.String())
+		return exitConflict
+	}
+	existing, err := parseArtifacts(common.BitableValueToString(current[col]))
+	if err != nil {
+		errLogger.Error("read artifacts manifest failed", "record_id", recordID, "err", err)
+		return 2
+	}
+
+	start := time.Now()
+	added := make([]artifact, 0, len(opts.Files))
+	for _, path := range opts.Files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errLogger.Error("read artifact failed", "path", path, "err", err)
+			return 2
+		}
+		name := filepath.Base(path)
+		fileToken, err := common.UploadMedia(ctx, tc.baseURL, tc.token, common.MediaParentBitableFile, tc.ref.AppToken, name, data)
+		if err != nil {
+			errLogger.Error("upload artifact failed", "path", path, "err", err)
+			return 1
+		}
+		a := artifact{Name: name, FileToken: fileToken, Size: int64(len(data)), SHA256: sha256Hex(data)}
+		if opts.Verify {
+			remote, err := common.DownloadMedia(ctx, tc.baseURL, tc.token, fileToken, tc.ref.TableID)
+			if err != nil {
+				errLogger.Error("verify artifact failed", "path", path, "err", err)
+				return 1
+			}
+			if err := verifyArtifact(a, remote); err != nil {
+				errLogger.Error("uploaded artifact does not match local file", "path", path, "err", err)
+				return 1
+			}
+		}
+		added = append(added, a)
+	}
+	manifest := mergeArtifacts(existing, added)
+	if err := tc.updateRecord(ctx, recordID, map[string]any{col: marshalManifest(manifest)}); err != nil {
+		errLogger.Error("write artifacts manifest failed", "record_id", recordID, "err", err)
+		return 1
+	}
+	printJSON(attachReport{
+		RecordID:       recordID,
+		Uploaded:       len(added),
+		Artifacts:      manifest,
+		ElapsedSeconds: float64(int(time.Since(start).Seconds()*1000)) / 1000,
+	})
+	return 0
+}
+
+func DownloadArtifacts(ctx context.Context, opts DownloadOptions) int {
+	if !opts.AllArtifacts && len(opts.Names) == 0 {
+		errLogger.Error("--all-artifacts or --name is required")
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	col := strings.TrimSpace(tc.fields["Artifacts"])
+	recordID, err := tc.resolveRecordID(ctx, opts.RecordID, opts.TaskID, opts.BizTaskID)
+	if err != nil {
+		errLogger.Error("resolve record failed", "err", err)
+		return 2
+	}
+	current, err := tc.getRecordFields(ctx, recordID)
+	if err != nil {
+		errLogger.Error("get record failed", "record_id", recordID, "err", err)
+		return 2
+	}
+	manifest, err := parseArtifacts(common.BitableValueToString(current[col]))
+	if err != nil {
+		errLogger.Error("read artifacts manifest failed", "record_id", recordID, "err", err)
+		return 2
+	}
+
+	wanted := map[string]bool{}
+	for _, n := range opts.Names {
+		wanted[strings.TrimSpace(n)] = true
+	}
+	dir := opts.Dir
+	if strings.TrimSpace(dir) == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		errLogger.Error("create output dir failed", "dir", dir, "err", err)
+		return 2
+	}
+
+	start := time.Now()
+	report := downloadReport{RecordID: recordID, Downloaded: []string{}, Mismatched: []string{}, Errors: []string{}}
+	for _, a := range manifest {
+		if !opts.AllArtifacts && !wanted[a.Name] {
+			continue
+		}
+		data, err := common.DownloadMedia(ctx, tc.baseURL, tc.token, a.FileToken, tc.ref.TableID)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", a.Name, err))
+			continue
+		}
+		path := filepath.Join(dir, filepath.Base(a.Name))
+		verifyErr := verifyArtifact(a, data)
+		if verifyErr != nil {
+			// Keep the bytes for inspection but never under the expected name.
+			path += ".corrupt"
+			report.Mismatched = append(report.Mismatched, a.Name)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", a.Name, verifyErr))
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", a.Name, err))
+			continue
+		}
+		if verifyErr == nil {
+			report.Downloaded = append(report.Downloaded, path)
+		}
+	}
+	report.Failed = len(report.Errors)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func marshalManifest(manifest []artifact) string {
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return "[]"
+	}
+	return string(b)
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+type CanonicalizeOptions struct {
+	URLs      []string
+	NoResolve bool
+}
+
+type canonicalURL struct {
+	Input     string `json:"input"`
+	Canonical string `json:"canonical"`
+	Error     string `json:"error,omitempty"`
+}
+
+type canonicalizeReport struct {
+	URLs   []canonicalURL `json:"urls"`
+	Failed int            `json:"failed"`
+}
+
+// CanonicalizeURLs prints the canonical form of each URL. Without URLs it
+// reads one URL per line from stdin.
+func CanonicalizeURLs(ctx context.Context, opts CanonicalizeOptions) int {
+	urls := opts.URLs
+	if len(urls) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				urls = append(urls, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			errLogger.Error("read stdin failed", "err", err)
+			return 2
+		}
+	}
+	if len(urls) == 0 {
+		errLogger.Error("no URLs provided")
+		return 2
+	}
+	report := canonicalizeReport{URLs: make([]canonicalURL, 0, len(urls))}
+	for _, raw := range urls {
+		c, err := common.CanonicalizeURL(ctx, raw, !opts.NoResolve)
+		entry := canonicalURL{Input: raw, Canonical: c}
+		if err != nil {
+			entry.Error = err.Error()
+			report.Failed++
+		}
+		report.URLs = append(report.URLs, entry)
+	}
+	printJSON(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// canonicalizeItemURLs rewrites the url of each create item in place.
+// Resolution failures keep the locally canonicalized URL and are returned
+// as warnings.
+func canonicalizeItemURLs(ctx context.Context, items []map[string]any) []string {
+	warnings := []string{}
+	for _, item := range items {
+		raw := strings.TrimSpace(common.BitableValueToString(item["url"]))
+		if raw == "" {
+			continue
+		}
+		c, err := common.CanonicalizeURL(ctx, raw, true)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+		item["url"] = c
+	}
+	return warnings
+}
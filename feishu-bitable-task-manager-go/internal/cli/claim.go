@@ -0,0 +1,415 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+const claimedStatus = string(taskmodel.StatusDispatched)
+
+// claimScanPageSize is the smallest search page a claim reads, so skipping
+// locked or soft-deleted rows rarely takes more than one request.
+const claimScanPageSize = 50
+
+// Ways a batch claim spreads tasks over --devices.
+const (
+	// partitionDeviceSerial gives tasks pinned through DeviceSerial to that
+	// device and balances the rest.
+	partitionDeviceSerial = "device_serial"
+	// partitionRoundRobin ignores DeviceSerial and balances every task.
+	partitionRoundRobin = "round_robin"
+)
+
+type ClaimOptions struct {
+	TaskURL      string
+	App          string
+	Scene        string
+	Date         string
+	Limit        int
+	DeviceSerial string
+	IgnoreView   bool
+	ViewID       string
+	LeaseTimeout time.Duration
+	// IncludeDeleted also claims soft-deleted tasks.
+	IncludeDeleted bool
+	// Devices claims on behalf of several devices at once, spreading the
+	// batch over them according to PartitionBy; DeviceSerial must be empty.
+	Devices     []string
+	PartitionBy string
+	// MaxAge skips tasks older than this, see ageWindow; Expire also marks
+	// them expired.
+	MaxAge time.Duration
+	Expire bool
+	// ControlURL is the control table (TASK_CONTROL_BITABLE_URL); nothing is
+	// claimed while the app/scene is paused there.
+	ControlURL string
+
+	control *controlTable
+}
+
+// ClaimTasks acquires pending tasks for one device. Bitable has no
+// compare-and-swap, so the claim is optimistic: re-read the candidates and
+// drop any another worker took since the search, mark the rest dispatched
+// to this device with a fresh attempt token, re-read them again, and keep
+// only the records that still carry our token (or device serial when no
+// AttemptToken column is mapped). This narrows the race but cannot close
+// it: a worker that writes and re-reads before a rival writes keeps the
+// task, and so does the rival. The attempt token then decides which of
+// them may report on it; the other's updates are refused.
+func ClaimTasks(ctx context.Context, opts ClaimOptions) int {
+	if len(opts.Devices) > 0 {
+		if strings.TrimSpace(opts.DeviceSerial) != "" {
+			errLogger.Error("--device-serial and --devices are mutually exclusive")
+			return 2
+		}
+		if opts.PartitionBy != partitionDeviceSerial && opts.PartitionBy != partitionRoundRobin {
+			errLogger.Error("--partition-by must be device_serial or round_robin", "partition_by", opts.PartitionBy)
+			return 2
+		}
+	} else if strings.TrimSpace(opts.DeviceSerial) == "" {
+		errLogger.Error("--device-serial or --devices is required")
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	if controlURL := strings.TrimSpace(opts.ControlURL); controlURL != "" {
+		var err error
+		if opts.control, err = openControlTable(ctx, tc.baseURL, tc.token, controlURL); err != nil {
+			errLogger.Error("open control table failed", "err", err)
+			return 2
+		}
+	}
+	tasks, code := claimTasks(ctx, tc, opts)
+	for _, t := range tasks {
+		logger.Info("task", "task", t)
+	}
+	return code
+}
+
+// claimTasks runs one claim round and returns the tasks this device won,
+// with the exit code ClaimTasks would report.
+func claimTasks(ctx context.Context, tc *tableClient, opts ClaimOptions) ([]Task, int) {
+	statusCol := tc.fields["Status"]
+	deviceCol := tc.fields["DispatchedDevice"]
+	if strings.TrimSpace(statusCol) == "" || strings.TrimSpace(deviceCol) == "" {
+		errLogger.Error("Status and DispatchedDevice fields must be mapped to claim tasks")
+		return nil, 2
+	}
+
+	if opts.control != nil {
+		p, paused, err := opts.control.pausedScene(ctx, tc.baseURL, tc.token, opts.App, opts.Scene)
+		if err != nil {
+			errLogger.Error("read control table failed", "err", err)
+			return nil, 2
+		}
+		if paused {
+			errLogger.Warn("scene is paused; not claiming", "app", opts.App, "scene", opts.Scene, "reason", p.Reason, "paused_by", p.PausedBy)
+			return nil, 0
+		}
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 1
+	}
+	viewID := strings.TrimSpace(opts.ViewID)
+	if viewID == "" {
+		viewID = tc.ref.ViewID
+	}
+	var conds []filterCond
+	if !opts.IncludeDeleted {
+		conds = notDeletedFilter(ctx, tc.baseURL, tc.token, tc.ref, tc.fields)
+	}
+	filterObj := buildFilter(tc.fields, opts.App, opts.Scene, string(taskmodel.StatusPending), opts.Date, conds...)
+	if opts.MaxAge > 0 {
+		window, err := resolveAgeWindow(ctx, tc.baseURL, tc.token, tc.ref, tc.fields, opts.MaxAge, time.Now())
+		if err == nil {
+			filterObj, err = window.filter(filterObj)
+		}
+		if err != nil {
+			errLogger.Error("invalid --max-age", "err", err)
+			return nil, 2
+		}
+		if opts.Expire {
+			if _, err := expireStaleTasks(ctx, tc.baseURL, tc.token, tc.ref, tc.fields, window, opts.App, opts.Scene, opts.Date); err != nil {
+				errLogger.Error("expire stale tasks failed", "err", err)
+				return nil, 2
+			}
+		}
+	}
+	now := time.Now()
+	candidates := []string{}
+	pinned := map[string]string{}
+	// searched is each candidate's fields as the search returned them.
+	searched := map[string]map[string]any{}
+	accept := func(it map[string]any) {
+		recordID, _ := it["record_id"].(string)
+		fieldsRaw, _ := it["fields"].(map[string]any)
+		if !opts.IncludeDeleted && isSoftDeleted(fieldsRaw, tc.fields) {
+			return
+		}
+		if _, ok := decodeTask(fieldsRaw, tc.fields); !ok || strings.TrimSpace(recordID) == "" {
+			return
+		}
+		if l, ok := activeEditLock(fieldsRaw, tc.fields, now); ok {
+			logSkippedLocked(recordID, l, "claim")
+			return
+		}
+		rid := strings.TrimSpace(recordID)
+		candidates = append(candidates, rid)
+		searched[rid] = fieldsRaw
+		if col := tc.fields["DeviceSerial"]; col != "" {
+			pinned[rid] = strings.TrimSpace(common.BitableValueToString(fieldsRaw[col]))
+		}
+	}
+
+	// Locked rows can only be dropped after the search (a lock's TTL is
+	// text), so keep paging until limit candidates survive; a locked task at
+	// the head of the queue must not starve every poll.
+	body := map[string]any{}
+	if filterObj != nil {
+		body["filter"] = filterObj
+	}
+	if !opts.IgnoreView && viewID != "" {
+		body["view_id"] = viewID
+	}
+	pages, stop := pageStream{
+		BaseURL:  tc.baseURL,
+		Token:    tc.token,
+		Ref:      tc.ref,
+		Body:     body,
+		PageSize: common.ClampPageSize(max(limit, claimScanPageSize)),
+	}.stream(ctx)
+	for page := range pages {
+		if page.Err != nil {
+			stop()
+			errLogger.Error("search pending tasks failed", "err", page.Err)
+			return nil, 2
+		}
+		for _, it := range page.Items {
+			if len(candidates) >= limit {
+				break
+			}
+			accept(it)
+		}
+		if len(candidates) >= limit {
+			break
+		}
+	}
+	stop()
+	if opts.LeaseTimeout > 0 && len(candidates) < limit {
+		stale, err := staleLeaseItems(ctx, tc.baseURL, tc.token, tc.ref, tc.fields, opts.App, opts.Scene, opts.Date, opts.LeaseTimeout, limit, opts.IgnoreView, viewID)
+		if err != nil {
+			errLogger.Error("search expired leases failed", "err", err)
+			return nil, 2
+		}
+		for _, it := range stale {
+			if len(candidates) >= limit {
+				break
+			}
+			accept(it)
+		}
+	}
+	candidates, assigned := assignClaims(candidates, pinned, opts)
+	if len(candidates) == 0 {
+		return nil, 0
+	}
+
+	tokenCol := tc.attemptTokenColumn(ctx)
+	candidates, err := unchangedClaimCandidates(ctx, tc, candidates, searched, tokenCol)
+	if err != nil {
+		errLogger.Error("re-read claim candidates failed", "err", err)
+		return nil, 1
+	}
+	if len(candidates) == 0 {
+		return nil, 0
+	}
+	attemptToken := ""
+	if tokenCol != "" {
+		attemptToken, err = newAttemptToken()
+		if err != nil {
+			errLogger.Error("generate attempt token failed", "err", err)
+			return nil, 2
+		}
+	}
+	dispatchedAt := time.Now().UnixMilli()
+	byDevice := map[string]map[string]any{}
+	records := make([]map[string]any, 0, len(candidates))
+	for _, rid := range candidates {
+		device := assigned[rid]
+		fields := byDevice[device]
+		if fields == nil {
+			fields = buildUpdateFields(tc.fields, map[string]any{
+				"status":        claimedStatus,
+				"device_serial": device,
+				"dispatched_at": dispatchedAt,
+			}, dateWriter{})
+			if tokenCol != "" {
+				fields[tokenCol] = attemptToken
+			}
+			byDevice[device] = fields
+		}
+		records = append(records, map[string]any{"record_id": rid, "fields": fields})
+	}
+	if err := batchUpdateRecords(ctx, tc.baseURL, tc.token, tc.ref, records); err != nil {
+		errLogger.Error("mark tasks dispatched failed", "err", err)
+		return nil, 1
+	}
+
+	current, err := batchGetRecordFields(ctx, tc.baseURL, tc.token, tc.ref, candidates)
+	if err != nil {
+		errLogger.Error("re-read claimed tasks failed", "err", err)
+		return nil, 1
+	}
+	claimed := []Task{}
+	extras := newExtraCodec(tc.baseURL, tc.token, tc.ref, tc.fields)
+	for _, rid := range candidates {
+		deviceSerial := assigned[rid]
+		fieldsRaw := current[rid]
+		status := strings.TrimSpace(common.BitableValueToString(fieldsRaw[statusCol]))
+		device := strings.TrimSpace(common.BitableValueToString(fieldsRaw[deviceCol]))
+		lost := status != claimedStatus || device != deviceSerial
+		if tokenCol != "" && strings.TrimSpace(common.BitableValueToString(fieldsRaw[tokenCol])) != attemptToken {
+			lost = true
+		}
+		if lost {
+			errLogger.Warn("claim lost to another worker", "record_id", rid, "status", status, "device", device)
+			common.EmitClaimConflict(ctx, tc.ref.TableID, rid, deviceSerial, status, device)
+			continue
+		}
+		t, ok := decodeTask(fieldsRaw, tc.fields)
+		if !ok {
+			continue
+		}
+		t.RecordID = rid
+		extras.decodeTask(ctx, &t, fieldsRaw)
+		claimed = append(claimed, t)
+	}
+	return claimed, 0
+}
+
+// unchangedClaimCandidates re-reads the candidates just before they are
+// written and keeps those whose status, device and attempt token still
+// match the search and that nobody has locked since, so a task another
+// worker claimed in the meantime is not taken over.
+func unchangedClaimCandidates(ctx context.Context, tc *tableClient, candidates []string, searched map[string]map[string]any, tokenCol string) ([]string, error) {
+	current, err := batchGetRecordFields(ctx, tc.baseURL, tc.token, tc.ref, candidates)
+	if err != nil {
+		return nil, err
+	}
+	cols := []string{tc.fields["Status"], tc.fields["DispatchedDevice"], tokenCol}
+	now := time.Now()
+	kept := make([]string, 0, len(candidates))
+	for _, rid := range candidates {
+		fieldsRaw, ok := current[rid]
+		if !ok {
+			errLogger.Warn("claim candidate is gone; skipping", "record_id", rid)
+			continue
+		}
+		if l, ok := activeEditLock(fieldsRaw, tc.fields, now); ok {
+			logSkippedLocked(rid, l, "claim")
+			continue
+		}
+		changed := ""
+		for _, col := range cols {
+			col = strings.TrimSpace(col)
+			if col == "" {
+				continue
+			}
+			if common.BitableValueToString(fieldsRaw[col]) != common.BitableValueToString(searched[rid][col]) {
+				changed = col
+				break
+			}
+		}
+		if changed != "" {
+			errLogger.Warn("claim candidate changed since the search; skipping", "record_id", rid, "column", changed,
+				"status", strings.TrimSpace(common.BitableValueToString(fieldsRaw[tc.fields["Status"]])))
+			continue
+		}
+		kept = append(kept, rid)
+	}
+	return kept, nil
+}
+
+// assignClaims picks the device each candidate is dispatched to. A single
+// --device-serial takes them all. With --devices, device_serial partitioning
+// sends a task whose DeviceSerial names a listed device to it and drops
+// tasks pinned to other devices; every remaining task goes to the device
+// with the fewest assignments so far, ties in list order.
+func assignClaims(candidates []string, pinned map[string]string, opts ClaimOptions) ([]string, map[string]string) {
+	assigned := make(map[string]string, len(candidates))
+	if len(opts.Devices) == 0 {
+		device := strings.TrimSpace(opts.DeviceSerial)
+		for _, rid := range candidates {
+			assigned[rid] = device
+		}
+		return candidates, assigned
+	}
+
+	load := make(map[string]int, len(opts.Devices))
+	listed := make(map[string]bool, len(opts.Devices))
+	for _, d := range opts.Devices {
+		listed[d] = true
+	}
+	free := []string{}
+	for _, rid := range candidates {
+		pin := pinned[rid]
+		if opts.PartitionBy != partitionDeviceSerial || pin == "" {
+			free = append(free, rid)
+		} else if listed[pin] {
+			assigned[rid] = pin
+			load[pin]++
+		}
+	}
+	for _, rid := range free {
+		best := opts.Devices[0]
+		for _, d := range opts.Devices[1:] {
+			if load[d] < load[best] {
+				best = d
+			}
+		}
+		assigned[rid] = best
+		load[best]++
+	}
+	kept := make([]string, 0, len(assigned))
+	for _, rid := range candidates {
+		if _, ok := assigned[rid]; ok {
+			kept = append(kept, rid)
+		}
+	}
+	return kept, assigned
+}
+
+func newAttemptToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// attemptTokenColumn returns the AttemptToken column name when the table has
+// one; tables without it fall back to device-serial verification.
+func (t *tableClient) attemptTokenColumn(ctx context.Context) string {
+	col := strings.TrimSpace(t.fields["AttemptToken"])
+	if col == "" {
+		return ""
+	}
+	fields, err := common.ListFields(ctx, t.baseURL, t.token, t.ref.AppToken, t.ref.TableID)
+	if err != nil {
+		errLogger.Warn("list fields failed; claiming without attempt tokens", "err", err)
+		return ""
+	}
+	if _, ok := common.FieldsByName(fields)[col]; !ok {
+		return ""
+	}
+	return col
+}
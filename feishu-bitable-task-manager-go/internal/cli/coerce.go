@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// tableSchema reads a table's columns on first use and keeps them for the
+// rest of the run, so every write path shares one field list request.
+type tableSchema struct {
+	baseURL string
+	token   string
+	ref     common.BitableRef
+	// byName is nil until the schema is read.
+	byName map[string]*common.FieldInfo
+}
+
+func newTableSchema(baseURL, token string, ref common.BitableRef) *tableSchema {
+	return &tableSchema{baseURL: baseURL, token: token, ref: ref}
+}
+
+// columns returns the table's columns by name.
+func (s *tableSchema) columns(ctx context.Context) (map[string]*common.FieldInfo, error) {
+	if s.byName != nil {
+		return s.byName, nil
+	}
+	schema, err := common.ListFields(ctx, s.baseURL, s.token, s.ref.AppToken, s.ref.TableID)
+	if err != nil {
+		return nil, fmt.Errorf("list fields: %w", err)
+	}
+	s.byName = make(map[string]*common.FieldInfo, len(schema))
+	for i := range schema {
+		s.byName[schema[i].FieldName] = &schema[i]
+	}
+	return s.byName, nil
+}
+
+// has reports whether the table has a column named col. A failed schema
+// lookup counts as absent, like tableHasColumn.
+func (s *tableSchema) has(ctx context.Context, col string) bool {
+	cols, err := s.columns(ctx)
+	if err != nil {
+		errLogger.Warn("list fields failed", "err", err)
+		return false
+	}
+	return cols[col] != nil
+}
+
+// dateWriter returns the writer for the Date column col. If the schema
+// cannot be read it falls back to the legacy epoch-millis coercion.
+func (s *tableSchema) dateWriter(ctx context.Context, col string) dateWriter {
+	w := dateWriter{kind: common.DateKindUnknown, loc: common.TaskTimezone()}
+	if strings.TrimSpace(col) == "" {
+		return w
+	}
+	cols, err := s.columns(ctx)
+	if err != nil {
+		errLogger.Warn("list fields failed; writing Date without schema awareness", "err", err)
+		return w
+	}
+	if f := cols[col]; f != nil {
+		w.kind = common.DateKindOf(*f)
+	}
+	return w
+}
+
+// fieldEncoder turns the fields of a create or update into the payload
+// shapes their columns take. After Extra is split, each value is coerced by
+// its column's type: numbers and booleans become text for text columns,
+// numeric text becomes a number, dates and timestamps become what the
+// DateTime column expects, flags become checkbox booleans, and select,
+// hyperlink, person and attachment columns get their object payloads.
+// Columns the table does not have, and values already shaped as objects or
+// lists, are sent unchanged; so is everything when the schema cannot be
+// read.
+type fieldEncoder struct {
+	schema      *tableSchema
+	loc         *time.Location
+	extras      *extraCodec
+	attachments *attachmentUploader
+	people      *personCodec
+	selects     *selectCodec
+	// noSchema is set once the schema could not be read; later fields
+	// are sent unchanged without asking again.
+	noSchema bool
+}
+
+func newFieldEncoder(baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, createSelectOptions bool) *fieldEncoder {
+	schema := newTableSchema(baseURL, token, ref)
+	return &fieldEncoder{
+		schema:      schema,
+		loc:         common.TaskTimezone(),
+		extras:      newExtraCodec(baseURL, token, ref, fieldsMap),
+		attachments: newAttachmentUploader(baseURL, token, ref, fieldsMap),
+		people:      newPersonCodec(baseURL, token),
+		selects:     newSelectCodec(schema, createSelectOptions),
+	}
+}
+
+func (e *fieldEncoder) encode(ctx context.Context, fields map[string]any) error {
+	if err := e.extras.encode(ctx, fields); err != nil {
+		return err
+	}
+	if e.noSchema {
+		return nil
+	}
+	cols, err := e.schema.columns(ctx)
+	if err != nil {
+		// Like dateWriter: the values still go out, as the caller built
+		// them, and Feishu rejects any that do not fit their column.
+		errLogger.Warn("list fields failed; writing values without schema awareness", "err", err)
+		e.noSchema = true
+		return nil
+	}
+	for col, v := range fields {
+		f := cols[col]
+		if f == nil || v == nil {
+			continue
+		}
+		out, err := e.value(ctx, f, v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", col, err)
+		}
+		fields[col] = out
+	}
+	return nil
+}
+
+// value coerces one scalar v for column f.
+func (e *fieldEncoder) value(ctx context.Context, f *common.FieldInfo, v any) (any, error) {
+	s, isText := encoderText(v)
+	if !isText {
+		return v, nil
+	}
+	switch f.Type {
+	case common.FieldTypeText, common.FieldTypePhone:
+		return s, nil
+	case common.FieldTypeNumber:
+		if _, ok := v.(string); !ok {
+			return v, nil
+		}
+		if strings.TrimSpace(s) == "" {
+			return nil, nil
+		}
+		if n, ok := common.ParseInteger(s); ok {
+			return n, nil
+		}
+		if n, ok := common.ParseNumber(s); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("%q is not a number", s)
+	case common.FieldTypeDateTime:
+		if strings.TrimSpace(s) == "" {
+			return nil, nil
+		}
+		if out, ok := common.CoerceDatePayloadFor(v, common.DateKindOf(*f), e.loc); ok {
+			return out, nil
+		}
+		return nil, fmt.Errorf("%q is not a date or time", s)
+	case common.FieldTypeCheckbox:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true", "1", "yes", "y", "on", "checked":
+			return true, nil
+		case "false", "0", "no", "n", "off", "":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", s)
+	case common.FieldTypeSingleSelect, common.FieldTypeMultiSelect:
+		if strings.TrimSpace(s) == "" {
+			return v, nil
+		}
+		return e.selects.value(ctx, f, s)
+	case common.FieldTypeURL:
+		if !looksLikeURL(s) {
+			return v, nil
+		}
+		link := strings.TrimSpace(s)
+		return map[string]any{"link": link, "text": link}, nil
+	case common.FieldTypeUser:
+		if strings.TrimSpace(s) == "" {
+			return v, nil
+		}
+		return e.people.resolve(ctx, s)
+	case common.FieldTypeAttachment:
+		return e.attachments.value(ctx, f, s)
+	}
+	return v, nil
+}
+
+// encoderText renders strings, numbers and booleans as text; ok is false for
+// objects, lists and anything else that already is a payload.
+func encoderText(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case bool:
+		return strconv.FormatBool(x), true
+	case int, int64, float64, json.Number:
+		return common.NormalizeBitableValue(x), true
+	}
+	return "", false
+}
+
+func looksLikeURL(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
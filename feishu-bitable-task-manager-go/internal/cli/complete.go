@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+type CompleteOptions struct {
+	TaskURL   string
+	RecordID  string
+	TaskID    int
+	BizTaskID string
+
+	Status         string
+	ItemsCollected int
+	Logs           string
+	LogsFile       string
+	Screenshot     string
+	AttemptToken   string
+	RunsURL        string
+}
+
+type completeReport struct {
+	RecordID       string         `json:"record_id"`
+	TraceID        string         `json:"trace_id,omitempty"`
+	Fields         map[string]any `json:"fields"`
+	RunsCreated    int            `json:"runs_created,omitempty"`
+	CommandSeconds float64        `json:"command_seconds"`
+}
+
+// CompleteTask finishes one task with a single record write: terminal
+// status, EndAt=now, ElapsedSeconds derived from the stored StartAt, and
+// optionally ItemsCollected, Logs (or an uploaded logs file) and a
+// LastScreenShot attachment.
+func CompleteTask(ctx context.Context, opts CompleteOptions) int {
+	status := strings.TrimSpace(opts.Status)
+	if !terminalStatuses[strings.ToLower(status)] {
+		errLogger.Error("--status must be a terminal status", "status", status)
+		return 2
+	}
+	// A --logs value naming a local file is uploaded like --logs-file, so
+	// the record does not keep a path only this host can open.
+	if logs := strings.TrimSpace(opts.Logs); logs != "" && strings.TrimSpace(opts.LogsFile) == "" {
+		if fi, err := os.Stat(logs); err == nil && fi.Mode().IsRegular() {
+			opts.LogsFile, opts.Logs = logs, ""
+		}
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	recordID, err := tc.resolveRecordID(ctx, opts.RecordID, opts.TaskID, opts.BizTaskID)
+	if err != nil {
+		errLogger.Error("resolve record failed", "err", err)
+		return 2
+	}
+	current, err := tc.getRecordFields(ctx, recordID)
+	if err != nil {
+		errLogger.Error("get record failed", "record_id", recordID, "err", err)
+		return 2
+	}
+
+	traceID := strings.TrimSpace(common.BitableValueToString(current[tc.fields["TraceID"]]))
+
+	start := time.Now()
+	var runs *runsTable
+	if runsURL := strings.TrimSpace(opts.RunsURL); runsURL != "" {
+		runs, err = openRunsTable(ctx, tc.baseURL, tc.token, runsURL)
+		if err != nil {
+			errLogger.Error("open runs table failed", "err", err)
+			return 2
+		}
+	}
+
+	fields, code, err := completeFields(ctx, tc, recordID, current, opts, start.UnixMilli())
+	if err != nil {
+		errLogger.Error("complete task failed", taskAttrs(recordID, traceID, "err", err)...)
+		return code
+	}
+
+	if err := tc.updateRecord(ctx, recordID, fields); err != nil {
+		errLogger.Error("complete task failed", taskAttrs(recordID, traceID, "err", err)...)
+		return 1
+	}
+	report := completeReport{RecordID: recordID, TraceID: traceID, Fields: fields}
+	exit := 0
+	if runs != nil {
+		n, err := runs.writeRuns(ctx, tc.baseURL, tc.token, tc.ref, tc.fields, []recordUpdate{{RecordID: recordID, Fields: fields}})
+		report.RunsCreated = n
+		if err != nil {
+			errLogger.Error("write runs failed", taskAttrs(recordID, traceID, "err", err)...)
+			exit = 1
+		}
+	}
+	report.CommandSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	return exit
+}
+
+// completeFields builds the completion write for a record whose current
+// fields are given: status, EndAt=now, ElapsedSeconds from the stored
+// StartAt, metrics, the attempt-token and status checks and the uploads.
+// On error the returned code is the exit status to report.
+func completeFields(ctx context.Context, tc *tableClient, recordID string, current map[string]any, opts CompleteOptions, now int64) (map[string]any, int, error) {
+	upd := map[string]any{
+		"status":        strings.TrimSpace(opts.Status),
+		"completed_at":  now,
+		"attempt_token": opts.AttemptToken,
+	}
+	if startMS, ok := common.CoerceMillis(current[tc.fields["StartAt"]]); ok {
+		upd["elapsed_seconds"] = int(maxInt64(now-startMS, 0) / 1000)
+	}
+	if opts.ItemsCollected >= 0 {
+		upd["items_collected"] = opts.ItemsCollected
+	}
+	if logs := strings.TrimSpace(opts.Logs); logs != "" {
+		upd["logs"] = logs
+	}
+	fields := buildUpdateFields(tc.fields, upd, dateWriter{})
+	if err := checkCompletion(tc, current, upd, fields, opts, now); err != nil {
+		return nil, 1, err
+	}
+
+	uploaded := false
+	if path := strings.TrimSpace(opts.Screenshot); path != "" {
+		col := strings.TrimSpace(tc.fields["LastScreenShot"])
+		if col == "" {
+			return nil, 2, errors.New("LastScreenShot field is not mapped")
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, 2, fmt.Errorf("read screenshot %s: %w", path, err)
+		}
+		fileToken, err := uploadScreenshot(ctx, tc.baseURL, tc.token, tc.ref, path)
+		if err != nil {
+			return nil, 1, err
+		}
+		fields[col] = []map[string]any{{"file_token": fileToken}}
+		uploaded = true
+	}
+	if path := strings.TrimSpace(opts.LogsFile); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return nil, 2, fmt.Errorf("read logs file %s: %w", path, err)
+		}
+		if err := newLogsFileUploader(ctx, tc.baseURL, tc.token, tc.ref, tc.fields).apply(ctx, fields, path); err != nil {
+			return nil, 1, err
+		}
+		uploaded = true
+	}
+	if uploaded {
+		// Uploads can take minutes: check again against the record as it
+		// is now, so a requeue or another completion made meanwhile is not
+		// overwritten.
+		fresh, err := tc.getRecordFields(ctx, recordID)
+		if err != nil {
+			return nil, 1, err
+		}
+		if err := checkCompletion(tc, fresh, upd, fields, opts, now); err != nil {
+			return nil, 1, err
+		}
+	}
+	return fields, 0, nil
+}
+
+// checkCompletion refuses to complete a record that is locked, not held by
+// a worker or owned by another attempt, and clears the attempt token in
+// fields when one is stored.
+func checkCompletion(tc *tableClient, current, upd, fields map[string]any, opts CompleteOptions, now int64) error {
+	if l, ok := activeEditLock(current, tc.fields, time.UnixMilli(now)); ok {
+		return fmt.Errorf("record is locked for manual edits by %s", l)
+	}
+	// Only a task still held by a worker can be finished, so a second
+	// completion (or one racing a requeue) fails instead of overwriting the
+	// outcome. Statuses the CLI does not know are left to the caller.
+	stored := common.BitableValueToString(current[tc.fields["Status"]])
+	if from, ok := taskmodel.ParseStatus(stored); ok {
+		to, _ := taskmodel.ParseStatus(opts.Status)
+		if !taskmodel.CanTransition(from, to) {
+			return fmt.Errorf("task is %s; only dispatched or running tasks can be completed", from)
+		}
+	}
+	tokenCol := strings.TrimSpace(tc.fields["AttemptToken"])
+	if tokenCol == "" {
+		return nil
+	}
+	token := strings.TrimSpace(common.BitableValueToString(current[tokenCol]))
+	if err := checkAttemptToken(upd, fields, tc.fields, token); err != nil {
+		return err
+	}
+	if token != "" {
+		fields[tokenCol] = ""
+	}
+	return nil
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// allScenes in a control row's Scene pauses every scene of its app.
+const allScenes = "*"
+
+// controlTable holds one row per app/scene with a Paused checkbox that
+// claim and work consult before dispatching.
+type controlTable struct {
+	ref    common.BitableRef
+	fields map[string]string
+}
+
+// scenePause is the pause recorded for an app/scene.
+type scenePause struct {
+	RecordID string `json:"record_id,omitempty"`
+	App      string `json:"app"`
+	Scene    string `json:"scene"`
+	Paused   bool   `json:"paused"`
+	Reason   string `json:"reason,omitempty"`
+	PausedBy string `json:"paused_by,omitempty"`
+	PausedAt string `json:"paused_at,omitempty"`
+}
+
+func openControlTable(ctx context.Context, baseURL, token, controlURL string) (*controlTable, error) {
+	ref, err := common.ParseBitableURL(controlURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse control table URL: %w", err)
+	}
+	if ref.AppToken == "" {
+		if ref.WikiToken == "" {
+			return nil, fmt.Errorf("control table URL missing app_token and wiki_token")
+		}
+		appTok, err := common.ResolveWikiAppToken(ctx, baseURL, token, ref.WikiToken)
+		if err != nil {
+			return nil, err
+		}
+		ref.AppToken = appTok
+	}
+	return &controlTable{ref: ref, fields: common.LoadControlFieldsFromEnv()}, nil
+}
+
+// appRows returns the control rows of app, the whole-app row (Scene "*")
+// included.
+func (c *controlTable) appRows(ctx context.Context, baseURL, token, app string) ([]scenePause, error) {
+	filterObj := map[string]any{"conjunction": "and", "conditions": []map[string]any{
+		{"field_name": c.fields["App"], "operator": "is", "value": []string{app}},
+	}}
+	items, err := searchItems(ctx, baseURL, token, c.ref, filterObj, common.MaxPageSize, true, "")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]scenePause, 0, len(items))
+	loc := common.TaskTimezone()
+	for _, it := range items {
+		fieldsRaw, _ := it["fields"].(map[string]any)
+		p := scenePause{
+			App:      common.BitableValueToString(fieldsRaw[c.fields["App"]]),
+			Scene:    strings.TrimSpace(common.BitableValueToString(fieldsRaw[c.fields["Scene"]])),
+			Reason:   common.BitableValueToString(fieldsRaw[c.fields["Reason"]]),
+			PausedBy: common.BitableValueToString(fieldsRaw[c.fields["PausedBy"]]),
+		}
+		p.RecordID, _ = it["record_id"].(string)
+		switch strings.ToLower(common.BitableValueToString(fieldsRaw[c.fields["Paused"]])) {
+		case "true", "1":
+			p.Paused = true
+		}
+		if ms, ok := common.CoerceMillis(fieldsRaw[c.fields["PausedAt"]]); ok {
+			p.PausedAt = time.UnixMilli(ms).In(loc).Format(time.RFC3339)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// pausedScene returns the pause holding app/scene, if any: its own row or
+// the app's "*" row.
+func (c *controlTable) pausedScene(ctx context.Context, baseURL, token, app, scene string) (scenePause, bool, error) {
+	rows, err := c.appRows(ctx, baseURL, token, app)
+	if err != nil {
+		return scenePause{}, false, err
+	}
+	for _, p := range rows {
+		if p.Paused && (p.Scene == scene || p.Scene == allScenes) {
+			return p, true, nil
+		}
+	}
+	return scenePause{}, false, nil
+}
+
+type PauseOptions struct {
+	ControlURL string
+	App        string
+	Scene      string
+	Reason     string
+	By         string
+	// Resume clears the pause instead of setting it.
+	Resume bool
+}
+
+// PauseScene sets or clears the pause of one app/scene (Scene "*" for the
+// whole app) in the control table, creating its row on first use. Workers
+// see it on their next claim; tasks already running are not interrupted.
+func PauseScene(ctx context.Context, opts PauseOptions) int {
+	app := strings.TrimSpace(opts.App)
+	scene := strings.TrimSpace(opts.Scene)
+	if app == "" || scene == "" {
+		errLogger.Error("--app and --scene are required (--scene '*' for every scene of the app)")
+		return 2
+	}
+	controlURL := strings.TrimSpace(opts.ControlURL)
+	if controlURL == "" {
+		errLogger.Error("TASK_CONTROL_BITABLE_URL or --control-url is required")
+		return 2
+	}
+	tc, code := openBitable(ctx, controlURL, common.ParseBitableURL)
+	if tc == nil {
+		return code
+	}
+	ctl := &controlTable{ref: tc.ref, fields: common.LoadControlFieldsFromEnv()}
+	rows, err := ctl.appRows(ctx, tc.baseURL, tc.token, app)
+	if err != nil {
+		errLogger.Error("read control table failed", "err", err)
+		return 2
+	}
+	var row *scenePause
+	for i := range rows {
+		if rows[i].Scene == scene {
+			row = &rows[i]
+			break
+		}
+	}
+	if row == nil && opts.Resume {
+		errLogger.Warn("scene was not paused", "app", app, "scene", scene)
+		printJSON(scenePause{App: app, Scene: scene})
+		return 0
+	}
+
+	now := time.Now()
+	fields := map[string]any{ctl.fields["Paused"]: !opts.Resume}
+	if !opts.Resume {
+		fields[ctl.fields["Reason"]] = strings.TrimSpace(opts.Reason)
+		fields[ctl.fields["PausedBy"]] = strings.TrimSpace(opts.By)
+		fields[ctl.fields["PausedAt"]] = now.UnixMilli()
+	}
+	out := scenePause{App: app, Scene: scene, Paused: !opts.Resume}
+	if !opts.Resume {
+		out.Reason = strings.TrimSpace(opts.Reason)
+		out.PausedBy = strings.TrimSpace(opts.By)
+		out.PausedAt = now.In(common.TaskTimezone()).Format(time.RFC3339)
+	}
+	if row != nil {
+		if err := updateRecord(ctx, tc.baseURL, tc.token, ctl.ref, row.RecordID, fields); err != nil {
+			errLogger.Error("update control row failed", "record_id", row.RecordID, "err", err)
+			return 1
+		}
+		out.RecordID = row.RecordID
+	} else {
+		fields[ctl.fields["App"]] = app
+		fields[ctl.fields["Scene"]] = scene
+		rid, err := createRecord(ctx, tc.baseURL, tc.token, ctl.ref, fields)
+		if err != nil {
+			errLogger.Error("create control row failed", "err", err)
+			return 1
+		}
+		out.RecordID = rid
+	}
+	printJSON(out)
+	return 0
+}
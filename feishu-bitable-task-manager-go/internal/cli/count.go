@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// Count methods reported by fetch --count.
+const (
+	countByTotal = "total"
+	countByScan  = "scan"
+)
+
+type countOutput struct {
+	Count int `json:"count"`
+	// Method is "total" when every search reported the API's total, "scan"
+	// when records were paged and counted.
+	Method         string  `json:"method"`
+	Pages          int     `json:"pages"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// countRecords counts the records matched by each search body. A one-row
+// page is enough when the API reports its total; otherwise the search is
+// paged with only the TaskID column requested. Soft-deleted rows are left
+// out by the bodies' filter (see notDeletedFilter).
+func countRecords(ctx context.Context, baseURL, token string, ref common.BitableRef, bodies []map[string]any, fields map[string]string, prefetch int) (countOutput, error) {
+	out := countOutput{Method: countByTotal}
+	for _, body := range bodies {
+		p := pageStream{BaseURL: baseURL, Token: token, Ref: ref, Body: body, PageSize: 1, MaxPages: 1}
+		page := firstPage(ctx, p)
+		if page.Err != nil {
+			return out, page.Err
+		}
+		out.Pages++
+		if page.Total != nil {
+			out.Count += *page.Total
+			continue
+		}
+
+		out.Method = countByScan
+		scanBody := map[string]any{}
+		for k, v := range body {
+			scanBody[k] = v
+		}
+		scanBody["field_names"] = []string{strings.TrimSpace(fields["TaskID"])}
+		stream, stop := pageStream{
+			BaseURL:  baseURL,
+			Token:    token,
+			Ref:      ref,
+			Body:     scanBody,
+			PageSize: common.MaxPageSize,
+			Prefetch: prefetch,
+		}.stream(ctx)
+		for page := range stream {
+			if page.Err != nil {
+				stop()
+				return out, page.Err
+			}
+			out.Pages++
+			out.Count += len(page.Items)
+		}
+		stop()
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+func firstPage(ctx context.Context, p pageStream) searchPage {
+	stream, stop := p.stream(ctx)
+	defer stop()
+	page, ok := <-stream
+	if !ok {
+		return searchPage{Err: ctx.Err()}
+	}
+	return page
+}
+
+// countTasks is fetch --count: it counts what fetch would return, plus the
+// expired leases --lease-timeout adds, and prints the count in format.
+func countTasks(ctx context.Context, opts FetchOptions, baseURL, token string, ref common.BitableRef, fields map[string]string, bodies []map[string]any, pageSize int, viewID, format string, rowTemplate *template.Template) int {
+	start := time.Now()
+	out, err := countRecords(ctx, baseURL, token, ref, bodies, fields, opts.Prefetch)
+	if err != nil {
+		errLogger.Error("count records failed", "err", err)
+		return 2
+	}
+	if opts.LeaseTimeout > 0 && strings.EqualFold(strings.TrimSpace(opts.Status), "pending") {
+		stale, err := staleLeaseItems(ctx, baseURL, token, ref, fields, opts.App, opts.Scene, opts.Date, opts.LeaseTimeout, pageSize, opts.IgnoreView, viewID)
+		if err != nil {
+			errLogger.Error("search expired leases failed", "err", err)
+			return 2
+		}
+		for _, it := range stale {
+			fieldsRaw, _ := it["fields"].(map[string]any)
+			if opts.IncludeDeleted || !isSoftDeleted(fieldsRaw, fields) {
+				out.Count++
+			}
+		}
+	}
+	out.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	switch format {
+	case outputJSON:
+		logger.Info("count", "data", out)
+		return 0
+	case outputTemplate:
+		if err := rowTemplate.Execute(os.Stdout, out); err != nil {
+			errLogger.Error("render go-template failed", "err", err)
+			return 1
+		}
+		return 0
+	}
+	return printFormatted(format, out, []any{out})
+}
@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,6 +15,10 @@ import (
 const (
 	createMaxBatchSize    = 500
 	createMaxFilterValues = 50
+	// createAttempts is how often one create call is sent when the network
+	// or Feishu fails before answering; the client_token makes the resends
+	// idempotent.
+	createAttempts = 3
 )
 
 var appGroupLabels = map[string]string{
@@ -21,6 +28,9 @@ var appGroupLabels = map[string]string{
 type CreateOptions struct {
 	TaskURL   string
 	InputPath string
+	// Source reads the items from elsewhere instead: sheet:<url> for a
+	// Feishu spreadsheet, see readSourceItems.
+	Source string
 
 	BizTaskID    string
 	ParentTaskID string
@@ -49,19 +59,48 @@ type CreateOptions struct {
 	GroupID          string
 	Extra            string
 
-	SkipExisting string
+	SkipExisting    string
+	DedupeNormalize string
+	DedupeHash      string
+	CanonicalizeURL bool
+	// CreateSelectOptions adds unknown select values as new options instead
+	// of failing the row, see selectCodec.
+	CreateSelectOptions bool
+	// JSONResult prints the per-row outcome (rowResults) on stdout instead
+	// of the report.
+	JSONResult bool
+
+	// UpsertOn names the key field (e.g. BizTaskID): inputs whose key
+	// matches an existing record update it instead of creating a new one.
+	UpsertOn string
+	// Transform pipes the creates through an external command first.
+	Transform string
+	// PlanPath writes the creates and updates to this plan file instead of
+	// the table, see writePlan.
+	PlanPath string
 }
 
 type createReport struct {
-	Created        int      `json:"created"`
-	Requested      int      `json:"requested"`
-	Skipped        int      `json:"skipped"`
-	Failed         int      `json:"failed"`
-	Errors         []string `json:"errors"`
-	ElapsedSeconds float64  `json:"elapsed_seconds"`
+	Created        int             `json:"created"`
+	Updated        int             `json:"updated"`
+	Requested      int             `json:"requested"`
+	Skipped        int             `json:"skipped"`
+	Failed         int             `json:"failed"`
+	Errors         []string        `json:"errors"`
+	Records        []createdRecord `json:"records"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+}
+
+// createdRecord ties a 1-based input row to the record it created or
+// updated.
+type createdRecord struct {
+	Row       int    `json:"row"`
+	RecordID  string `json:"record_id"`
+	BizTaskID string `json:"biz_task_id,omitempty"`
+	Action    string `json:"action"`
 }
 
-func CreateTasks(opts CreateOptions) int {
+func CreateTasks(ctx context.Context, opts CreateOptions) int {
 	taskURL := strings.TrimSpace(opts.TaskURL)
 	if taskURL == "" {
 		errLogger.Error("TASK_BITABLE_URL is required")
@@ -77,40 +116,69 @@ func CreateTasks(opts CreateOptions) int {
 	baseURL := common.Env("FEISHU_BASE_URL", common.DefaultBaseURL)
 	fieldsMap := common.LoadTaskFieldsFromEnv()
 
-	creates, err := loadCreates(opts, fieldsMap)
+	creates, err := loadCreates(ctx, opts, fieldsMap)
 	if err != nil {
 		errLogger.Error("load creates failed", "err", err)
 		return 2
 	}
+	if creates, err = transformItems(ctx, opts.Transform, "create", creates); err != nil {
+		errLogger.Error("transform creates failed", "err", err)
+		return 2
+	}
 	if len(creates) == 0 {
 		errLogger.Error("no tasks provided")
 		return 2
 	}
 
+	if opts.CanonicalizeURL {
+		for _, w := range canonicalizeItemURLs(ctx, creates) {
+			errLogger.Warn("canonicalize URL failed; keeping unresolved URL", "err", w)
+		}
+	}
+
 	ref, err := common.ParseBitableURL(taskURL)
 	if err != nil {
 		errLogger.Error("parse bitable URL failed", "err", err)
 		return 2
 	}
-	token, err := common.GetTenantAccessToken(baseURL, appID, appSecret)
+	token, err := common.GetTenantAccessToken(ctx, baseURL, appID, appSecret)
 	if err != nil {
 		errLogger.Error("get tenant access token failed", "err", err)
-		return 2
+		return exitCodeFor(err, exitUsage)
 	}
 	if ref.AppToken == "" {
 		if ref.WikiToken == "" {
 			errLogger.Error("bitable URL missing app_token and wiki_token")
 			return 2
 		}
-		appTok, err := common.ResolveWikiAppToken(baseURL, token, ref.WikiToken)
+		appTok, err := common.ResolveWikiAppToken(ctx, baseURL, token, ref.WikiToken)
 		if err != nil {
 			errLogger.Error("resolve wiki app token failed", "err", err)
-			return 2
+			return exitCodeFor(err, exitUsage)
 		}
 		ref.AppToken = appTok
 	}
 
 	skipFields := normalizeSkipFields(opts.SkipExisting)
+	if len(skipFields) > 0 && strings.TrimSpace(opts.UpsertOn) != "" {
+		errLogger.Error("--skip-existing and --upsert-on are mutually exclusive")
+		return 2
+	}
+	spec, err := common.ParseFingerprintSpec(skipFields, opts.DedupeNormalize, opts.DedupeHash)
+	if err != nil {
+		errLogger.Error("invalid dedupe settings", "err", err)
+		return 2
+	}
+	var deduper *fingerprintDeduper
+	if len(skipFields) > 0 && !spec.IsDefault() {
+		deduper, err = newFingerprintDeduper(ctx, baseURL, token, ref, fieldsMap, skipFields, spec, creates)
+		if err != nil {
+			errLogger.Error("load dedupe fingerprints failed", "err", err)
+			return exitCodeFor(err, exitUsage)
+		}
+		// Plain field equality below is replaced by fingerprints.
+		skipFields = nil
+	}
 	existingByField := map[string]map[string]string{}
 	existingRecordIDs := map[string]bool{}
 
@@ -133,7 +201,7 @@ func CreateTasks(opts CreateOptions) int {
 				if f == "RecordID" {
 					rid := strings.TrimSpace(common.BitableValueToString(item["record_id"]))
 					if rid != "" && !existingRecordIDs[rid] {
-						if recordExists(baseURL, token, ref, rid) {
+						if recordExists(ctx, baseURL, token, ref, rid) {
 							existingRecordIDs[rid] = true
 						}
 					}
@@ -156,24 +224,58 @@ func CreateTasks(opts CreateOptions) int {
 				values = append(values, v)
 			}
 			mappedField := fieldMap[f]
-			resolved, err := resolveExistingByField(baseURL, token, ref, mappedField, values)
+			resolved, err := resolveExistingByField(ctx, baseURL, token, ref, mappedField, values)
 			if err != nil {
 				errLogger.Error("resolve existing records failed", "err", err)
-				return 2
+				return exitCodeFor(err, exitUsage)
 			}
 			existingByField[f] = resolved
 		}
 	}
 
-	type createRec struct {
-		Fields map[string]any
+	enc := newFieldEncoder(baseURL, token, ref, fieldsMap, opts.CreateSelectOptions)
+	dates := dateWriter{kind: common.DateKindUnknown, loc: common.TaskTimezone()}
+	if itemsHaveValue(creates, "date") {
+		dates = enc.schema.dateWriter(ctx, fieldsMap["Date"])
 	}
 
-	records := []createRec{}
+	// TraceID is optional: stamp it only when the table has the column.
+	traceCol := ""
+	if col := strings.TrimSpace(fieldsMap["TraceID"]); col != "" && enc.schema.has(ctx, col) {
+		traceCol = col
+	}
+
+	var upserts *upsertIndex
+	if strings.TrimSpace(opts.UpsertOn) != "" {
+		upserts, err = newUpsertIndex(ctx, baseURL, token, ref, fieldsMap, opts.UpsertOn, creates)
+		if err != nil {
+			errLogger.Error("resolve upsert keys failed", "err", err)
+			return exitCodeFor(err, exitUsage)
+		}
+	}
+
+	// pending holds the writes in input order, still unencoded: a row whose
+	// upsert key repeats an earlier one merges into that write, and each
+	// write is encoded once its fields are final.
+	pending := []*pendingWrite{}
+	planned := map[string]*pendingWrite{}
 	errorsList := []string{}
+	var outcome writeOutcome
+	results := newRowResults(opts.JSONResult)
 	skipped := 0
+	planning := strings.TrimSpace(opts.PlanPath) != ""
+	var plan taskPlan
+	noop := func(row int, recordID, reason string) {
+		skipped++
+		results.skip(creates[row-1], recordID, reason)
+		if planning {
+			plan.Noops = append(plan.Noops, planNoop{Row: row, RecordID: recordID, Reason: reason})
+		}
+	}
 
-	for _, item := range creates {
+	for i, item := range creates {
+		row := i + 1
+		bizTaskID := extractItemValue(item, "BizTaskID")
 		if len(skipFields) > 0 {
 			allMatch := true
 			for _, f := range skipFields {
@@ -196,76 +298,322 @@ func CreateTasks(opts CreateOptions) int {
 				}
 			}
 			if allMatch {
-				skipped++
+				noop(row, "", "exists")
 				continue
 			}
 		}
 
-		fields := buildCreateFields(fieldsMap, item)
+		fields := buildCreateFields(fieldsMap, item, dates)
 		if len(fields) == 0 {
+			outcome.invalid++
 			errorsList = append(errorsList, "task: no fields to create")
+			results.fail(item, "no fields to create")
+			continue
+		}
+		if deduper != nil && !deduper.claim(item, fields) {
+			noop(row, "", "duplicate")
 			continue
 		}
-		records = append(records, createRec{Fields: fields})
+		if upserts != nil {
+			key := upserts.key(item)
+			if prev, ok := planned[key]; ok && key != "" {
+				for k, v := range fields {
+					prev.Fields[k] = v
+				}
+				prev.merged = append(prev.merged, row)
+				continue
+			}
+			if target, ok := upserts.match(item); ok {
+				if fieldsUnchanged(fields, target.Fields) {
+					noop(row, target.RecordID, "unchanged")
+					continue
+				}
+				w := &pendingWrite{createRec: createRec{Row: row, BizTaskID: bizTaskID, Fields: fields}, recordID: target.RecordID, current: target.Fields}
+				planned[key] = w
+				pending = append(pending, w)
+				continue
+			}
+		}
+		if _, ok := fields[traceCol]; traceCol != "" && !ok {
+			fields[traceCol] = common.NewUUID()
+		}
+		w := &pendingWrite{createRec: createRec{Row: row, BizTaskID: bizTaskID, Fields: fields}}
+		if upserts != nil {
+			if key := upserts.key(item); key != "" {
+				planned[key] = w
+			}
+		}
+		pending = append(pending, w)
 	}
 
-	start := time.Now()
-	created := 0
-	if len(records) > 0 {
-		if len(records) == 1 {
-			if err := createRecord(baseURL, token, ref, records[0].Fields); err != nil {
-				errorsList = append(errorsList, err.Error())
+	if planning {
+		for _, w := range pending {
+			if w.recordID != "" {
+				plan.Updates = append(plan.Updates, planUpdate{Row: w.Row, RecordID: w.recordID, Fields: w.Fields, MergedRows: w.merged, current: w.current})
 			} else {
-				created = 1
+				plan.Creates = append(plan.Creates, planCreate{Row: w.Row, BizTaskID: w.BizTaskID, Fields: w.Fields, MergedRows: w.merged})
 			}
+		}
+		plan.Errors = errorsList
+		return writePlan(opts, plan)
+	}
+
+	records := []createRec{}
+	updates := []map[string]any{}
+	updateRows := []createdRecord{}
+	// mergedRows maps the first row of each write to the later rows merged
+	// into it, which share its outcome.
+	mergedRows := map[int][]int{}
+	for _, w := range pending {
+		if len(w.merged) > 0 {
+			mergedRows[w.Row] = w.merged
+		}
+		if err := enc.encode(ctx, w.Fields); err != nil {
+			for _, row := range w.rows() {
+				outcome.invalid++
+				errorsList = append(errorsList, fmt.Sprintf("row %d: %v", row, err))
+				results.fail(creates[row-1], err.Error())
+			}
+			continue
+		}
+		if w.recordID != "" {
+			updates = append(updates, map[string]any{"record_id": w.recordID, "fields": w.Fields})
+			updateRows = append(updateRows, createdRecord{Row: w.Row, RecordID: w.recordID, BizTaskID: w.BizTaskID, Action: "updated"})
+			continue
+		}
+		records = append(records, w.createRec)
+	}
+
+	start := time.Now()
+	written, errs, failedCreates := writeCreates(ctx, baseURL, token, ref, records)
+	errorsList = append(errorsList, errs...)
+	updatedRows, lockedRows, updateErrs, failedUpdates := writeUpdates(ctx, enc.schema, fieldsMap, updates, updateRows)
+	errorsList = append(errorsList, updateErrs...)
+	for _, l := range lockedRows {
+		for _, row := range append([]int{l.Row}, mergedRows[l.Row]...) {
+			skipped++
+			results.skip(creates[row-1], l.RecordID, fmt.Sprintf("locked for manual edits by %s", l.lock))
+		}
+	}
+	for _, e := range append(errs, updateErrs...) {
+		outcome.failed = append(outcome.failed, errors.New(e))
+	}
+	written = append(written, updatedRows...)
+	for _, w := range written {
+		for _, row := range mergedRows[w.Row] {
+			written = append(written, createdRecord{Row: row, RecordID: w.RecordID, BizTaskID: extractItemValue(creates[row-1], "BizTaskID"), Action: w.Action})
+		}
+	}
+	sort.Slice(written, func(a, b int) bool { return written[a].Row < written[b].Row })
+	created, updated := 0, 0
+	for _, w := range written {
+		if w.Action == "updated" {
+			updated++
+			results.updated(w.RecordID)
 		} else {
-			for i := 0; i < len(records); i += createMaxBatchSize {
-				j := i + createMaxBatchSize
-				if j > len(records) {
-					j = len(records)
-				}
-				batch := make([]map[string]any, 0, j-i)
-				for _, r := range records[i:j] {
-					batch = append(batch, map[string]any{"fields": r.Fields})
-				}
-				if err := batchCreateRecords(baseURL, token, ref, batch); err != nil {
-					errorsList = append(errorsList, err.Error())
-					break
-				}
-				created += (j - i)
+			created++
+			results.created(w.RecordID)
+		}
+	}
+	failedRows := 0
+	for _, failed := range []map[int]string{failedCreates, failedUpdates} {
+		msgs := map[int]string{}
+		for row, msg := range failed {
+			msgs[row] = msg
+			for _, m := range mergedRows[row] {
+				msgs[m] = msg
 			}
 		}
+		rows := make([]int, 0, len(msgs))
+		for row := range msgs {
+			rows = append(rows, row)
+		}
+		sort.Ints(rows)
+		for _, row := range rows {
+			results.fail(creates[row-1], msgs[row])
+		}
+		failedRows += len(rows)
 	}
 
 	elapsed := time.Since(start).Seconds()
 	report := createReport{
 		Created:        created,
-		Requested:      len(records),
+		Updated:        updated,
+		Requested:      len(creates),
 		Skipped:        skipped,
-		Failed:         len(errorsList),
+		Failed:         outcome.invalid + failedRows,
 		Errors:         errorsList,
+		Records:        written,
 		ElapsedSeconds: float64(int(elapsed*1000)) / 1000,
 	}
-	printJSON(report)
-	if len(errorsList) > 0 {
-		return 1
+	if results != nil {
+		results.print(report)
+	} else {
+		printJSON(report)
 	}
-	return 0
+	outcome.written = created + updated
+	return outcome.exitCode()
+}
+
+// createRec is a pending create tied to its 1-based input row.
+type createRec struct {
+	Row       int
+	BizTaskID string
+	Fields    map[string]any
+}
+
+// pendingWrite is the create, or the update of recordID, planned for one
+// input row, with the later rows merged into it by a repeated upsert key.
+type pendingWrite struct {
+	createRec
+	recordID string
+	merged   []int
+	// current is the record's stored fields, for the plan diff.
+	current map[string]any
+}
+
+// rows lists every input row the write carries.
+func (w *pendingWrite) rows() []int {
+	return append([]int{w.Row}, w.merged...)
 }
 
-func loadCreates(opts CreateOptions, fieldsMap map[string]string) ([]map[string]any, error) {
+// writeCreates creates records, one batch_create call per
+// createMaxBatchSize records; the returned record ids come back in request
+// order. A failed chunk is reported and the remaining chunks are still sent.
+func writeCreates(ctx context.Context, baseURL, token string, ref common.BitableRef, records []createRec) ([]createdRecord, []string, map[int]string) {
+	written := []createdRecord{}
+	errorsList := []string{}
+	failed := map[int]string{}
+	if len(records) == 1 {
+		tctx, endTrace := traceBatch(ctx, "create", [][]any{createSpanAttrs(records[0])})
+		rid, err := createRecord(tctx, baseURL, token, ref, records[0].Fields)
+		endTrace(err)
+		if err != nil {
+			errorsList = append(errorsList, err.Error())
+			failed[records[0].Row] = err.Error()
+		} else {
+			written = append(written, createdRecord{Row: records[0].Row, RecordID: rid, BizTaskID: records[0].BizTaskID, Action: "created"})
+		}
+	} else {
+		for i := 0; i < len(records); i += createMaxBatchSize {
+			j := minInt(i+createMaxBatchSize, len(records))
+			batch := make([]map[string]any, 0, j-i)
+			traced := make([][]any, 0, j-i)
+			for _, r := range records[i:j] {
+				batch = append(batch, map[string]any{"fields": r.Fields})
+				traced = append(traced, createSpanAttrs(r))
+			}
+			tctx, endTrace := traceBatch(ctx, "create", traced)
+			ids, err := batchCreateRecords(tctx, baseURL, token, ref, batch)
+			endTrace(err)
+			if err != nil {
+				errorsList = append(errorsList, fmt.Sprintf("rows %d-%d: %v", records[i].Row, records[j-1].Row, err))
+				for _, r := range records[i:j] {
+					failed[r.Row] = err.Error()
+				}
+				continue
+			}
+			for k, r := range records[i:j] {
+				rid := ""
+				if k < len(ids) {
+					rid = ids[k]
+				}
+				written = append(written, createdRecord{Row: r.Row, RecordID: rid, BizTaskID: r.BizTaskID, Action: "created"})
+			}
+		}
+	}
+	return written, errorsList, failed
+}
+
+// createSpanAttrs tags the span of a record to create; it has no record id
+// yet.
+func createSpanAttrs(r createRec) []any {
+	return []any{"bitable.row", r.Row, "bitable.biz_task_id", r.BizTaskID}
+}
+
+// lockedWrite is an update left out because its record is locked for
+// manual edits.
+type lockedWrite struct {
+	createdRecord
+	lock editLock
+}
+
+// writeUpdates sends updates (record_id + fields) in batch_update chunks;
+// rows[i] describes updates[i]. Records an operator has locked are re-read
+// just before writing and left out. It returns the rows of the chunks
+// written, the locked rows, and the error of each row that was not
+// written.
+func writeUpdates(ctx context.Context, schema *tableSchema, mapping map[string]string, updates []map[string]any, rows []createdRecord) ([]createdRecord, []lockedWrite, []string, map[int]string) {
+	written := []createdRecord{}
+	locked := []lockedWrite{}
+	errorsList := []string{}
+	failed := map[int]string{}
+	if len(updates) == 0 {
+		return written, locked, errorsList, failed
+	}
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.RecordID)
+	}
+	locks, err := editLocks(ctx, schema, mapping, ids)
+	if err != nil {
+		errorsList = append(errorsList, fmt.Sprintf("rows %d-%d: read edit locks: %v", rows[0].Row, rows[len(rows)-1].Row, err))
+		for _, r := range rows {
+			failed[r.Row] = err.Error()
+		}
+		return written, locked, errorsList, failed
+	}
+	if len(locks) > 0 {
+		keptUpdates := make([]map[string]any, 0, len(updates))
+		keptRows := make([]createdRecord, 0, len(rows))
+		for i, r := range rows {
+			if l, ok := locks[r.RecordID]; ok {
+				logSkippedLocked(r.RecordID, l, "update")
+				locked = append(locked, lockedWrite{createdRecord: r, lock: l})
+				continue
+			}
+			keptUpdates = append(keptUpdates, updates[i])
+			keptRows = append(keptRows, r)
+		}
+		updates, rows = keptUpdates, keptRows
+	}
+	for i := 0; i < len(updates); i += updateMaxBatchSize {
+		j := minInt(i+updateMaxBatchSize, len(updates))
+		traced := make([][]any, 0, j-i)
+		for _, r := range rows[i:j] {
+			traced = append(traced, []any{"bitable.row", r.Row, "bitable.record_id", r.RecordID, "bitable.biz_task_id", r.BizTaskID})
+		}
+		tctx, endTrace := traceBatch(ctx, "update", traced)
+		err := batchUpdateRecords(tctx, schema.baseURL, schema.token, schema.ref, updates[i:j])
+		endTrace(err)
+		if err != nil {
+			errorsList = append(errorsList, fmt.Sprintf("rows %d-%d: %v", rows[i].Row, rows[j-1].Row, err))
+			for _, r := range rows[i:j] {
+				failed[r.Row] = err.Error()
+			}
+			continue
+		}
+		written = append(written, rows[i:j]...)
+	}
+	return written, locked, errorsList, failed
+}
+
+func loadCreates(ctx context.Context, opts CreateOptions, fieldsMap map[string]string) ([]map[string]any, error) {
 	var items []map[string]any
-	if strings.TrimSpace(opts.InputPath) != "" {
+	if strings.TrimSpace(opts.Source) != "" {
+		if strings.TrimSpace(opts.InputPath) != "" {
+			return nil, fmt.Errorf("--input and --source are mutually exclusive")
+		}
+		var err error
+		if items, err = readSourceItems(ctx, opts.Source); err != nil {
+			return nil, fmt.Errorf("read --source: %w", err)
+		}
+	} else if strings.TrimSpace(opts.InputPath) != "" {
 		raw, err := readAllInput(opts.InputPath)
 		if err != nil {
 			return nil, err
 		}
-		mode := detectInputFormat(opts.InputPath, raw)
-		if mode == "jsonl" {
-			items, err = parseJSONLItems(raw)
-		} else {
-			items, err = parseJSONItems(raw)
-		}
+		items, err = parseInputItems(opts.InputPath, raw)
 		if err != nil {
 			return nil, err
 		}
@@ -315,6 +663,9 @@ func loadCreates(opts CreateOptions, fieldsMap map[string]string) ([]map[string]
 		"parent_task_id":    true,
 		"parentTaskId":      true,
 		"ParentTaskID":      true,
+		"trace_id":          true,
+		"traceId":           true,
+		"TraceID":           true,
 		"app":               true,
 		"App":               true,
 		"scene":             true,
@@ -428,6 +779,7 @@ func loadCreates(opts CreateOptions, fieldsMap map[string]string) ([]map[string]
 			"biz_task_id":       firstNonNil(item["biz_task_id"], item["bizTaskId"], item["BizTaskID"]),
 			"record_id":         firstNonNil(item["record_id"], item["recordId"], item["RecordID"]),
 			"parent_task_id":    firstNonNil(item["parent_task_id"], item["parentTaskId"], item["ParentTaskID"]),
+			"trace_id":          firstNonNil(item["trace_id"], item["traceId"], item["TraceID"]),
 			"app":               firstNonNil(pick(item, "app", opts.App), item["App"]),
 			"scene":             firstNonNil(pick(item, "scene", opts.Scene), item["Scene"]),
 			"params":            firstNonNil(pick(item, "params", opts.Params), item["Params"]),
@@ -459,7 +811,7 @@ func loadCreates(opts CreateOptions, fieldsMap map[string]string) ([]map[string]
 	return out, nil
 }
 
-func buildCreateFields(fieldsMap map[string]string, item map[string]any) map[string]any {
+func buildCreateFields(fieldsMap map[string]string, item map[string]any, dates dateWriter) map[string]any {
 	out := map[string]any{}
 
 	setStr := func(jsonKey, colKey string) {
@@ -476,6 +828,7 @@ func buildCreateFields(fieldsMap map[string]string, item map[string]any) map[str
 
 	setStr("biz_task_id", "BizTaskID")
 	setStr("parent_task_id", "ParentTaskID")
+	setStr("trace_id", "TraceID")
 
 	appValue := strings.TrimSpace(common.BitableValueToString(item["app"]))
 	sceneValue := strings.TrimSpace(common.BitableValueToString(item["scene"]))
@@ -525,7 +878,7 @@ func buildCreateFields(fieldsMap map[string]string, item map[string]any) map[str
 
 	if fieldsMap["Date"] != "" {
 		if v, ok := item["date"]; ok && v != nil {
-			if payload, ok := common.CoerceDatePayload(v); ok {
+			if payload, ok := dates.payload(v); ok {
 				out[fieldsMap["Date"]] = payload
 			}
 		}
@@ -622,37 +975,57 @@ func buildCreateFields(fieldsMap map[string]string, item map[string]any) map[str
 	return out
 }
 
-func batchCreateRecords(baseURL, token string, ref common.BitableRef, records []map[string]any) error {
+type createRecordsResp struct {
+	common.FeishuResp
+	Data struct {
+		Record struct {
+			RecordID string `json:"record_id"`
+		} `json:"record"`
+		Records []struct {
+			RecordID string `json:"record_id"`
+		} `json:"records"`
+	} `json:"data"`
+}
+
+// batchCreateRecords creates records in one call and returns their record
+// ids in request order.
+func batchCreateRecords(ctx context.Context, baseURL, token string, ref common.BitableRef, records []map[string]any) ([]string, error) {
 	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/batch_create",
 		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID,
 	)
 	payload := map[string]any{"records": records}
-	var resp common.FeishuResp
-	if err := common.RequestJSON("POST", urlStr, token, payload, &resp); err != nil {
-		return err
+	var resp createRecordsResp
+	if err := requestCreate(ctx, urlStr, token, payload, &resp); err != nil {
+		return nil, err
 	}
 	if resp.Code != 0 {
-		return fmt.Errorf("batch create failed: code=%d msg=%s", resp.Code, resp.Msg)
+		return nil, fmt.Errorf("batch create failed: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	ids := make([]string, 0, len(resp.Data.Records))
+	for _, r := range resp.Data.Records {
+		ids = append(ids, r.RecordID)
 	}
-	return nil
+	common.CountTasks(common.TasksCreated, len(ids))
+	return ids, nil
 }
 
-func createRecord(baseURL, token string, ref common.BitableRef, fields map[string]any) error {
+func createRecord(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]any) (string, error) {
 	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records",
 		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID,
 	)
 	payload := map[string]any{"fields": fields}
-	var resp common.FeishuResp
-	if err := common.RequestJSON("POST", urlStr, token, payload, &resp); err != nil {
-		return err
+	var resp createRecordsResp
+	if err := requestCreate(ctx, urlStr, token, payload, &resp); err != nil {
+		return "", err
 	}
 	if resp.Code != 0 {
-		return fmt.Errorf("create record failed: code=%d msg=%s", resp.Code, resp.Msg)
+		return "", fmt.Errorf("create record failed: code=%d msg=%s", resp.Code, resp.Msg)
 	}
-	return nil
+	common.CountTasks(common.TasksCreated, 1)
+	return resp.Data.Record.RecordID, nil
 }
 
-func resolveExistingByField(baseURL, token string, ref common.BitableRef, fieldName string, values []string) (map[string]string, error) {
+func resolveExistingByField(ctx context.Context, baseURL, token string, ref common.BitableRef, fieldName string, values []string) (map[string]string, error) {
 	out := map[string]string{}
 	if len(values) == 0 {
 		return out, nil
@@ -662,7 +1035,7 @@ func resolveExistingByField(baseURL, token string, ref common.BitableRef, fieldN
 		if filterObj == nil {
 			continue
 		}
-		items, err := fetchRecordsForCreate(baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)))
+		items, err := fetchRecordsForCreate(ctx, baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)))
 		if err != nil {
 			return nil, err
 		}
@@ -680,7 +1053,7 @@ func resolveExistingByField(baseURL, token string, ref common.BitableRef, fieldN
 	return out, nil
 }
 
-func fetchRecordsForCreate(baseURL, token string, ref common.BitableRef, filterObj map[string]any, pageSize int) ([]map[string]any, error) {
+func fetchRecordsForCreate(ctx context.Context, baseURL, token string, ref common.BitableRef, filterObj map[string]any, pageSize int) ([]map[string]any, error) {
 	pageSize = common.ClampPageSize(pageSize)
 	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/search?page_size=%d",
 		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID, pageSize,
@@ -690,16 +1063,17 @@ func fetchRecordsForCreate(baseURL, token string, ref common.BitableRef, filterO
 		body = map[string]any{"filter": filterObj}
 	}
 	var resp searchItemsResp
-	if err := common.RequestJSON("POST", urlStr, token, body, &resp); err != nil {
+	if err := common.RequestJSON(ctx, "POST", urlStr, token, body, &resp); err != nil {
 		return nil, err
 	}
 	if resp.Code != 0 {
 		return nil, fmt.Errorf("search records failed: code=%d msg=%s", resp.Code, resp.Msg)
 	}
+	common.CountTasks(common.TasksFetched, len(resp.Data.Items))
 	return resp.Data.Items, nil
 }
 
-func recordExists(baseURL, token string, ref common.BitableRef, recordID string) bool {
+func recordExists(ctx context.Context, baseURL, token string, ref common.BitableRef, recordID string) bool {
 	recordID = strings.TrimSpace(recordID)
 	if recordID == "" {
 		return false
@@ -708,7 +1082,7 @@ func recordExists(baseURL, token string, ref common.BitableRef, recordID string)
 		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID, url.PathEscape(recordID),
 	)
 	var resp common.FeishuResp
-	if err := common.RequestJSON("GET", urlStr, token, nil, &resp); err != nil {
+	if err := common.RequestJSON(ctx, "GET", urlStr, token, nil, &resp); err != nil {
 		return false
 	}
 	return resp.Code == 0
@@ -739,6 +1113,10 @@ func normalizeSkipFields(raw string) []string {
 		"userid":      "UserID",
 		"app":         "App",
 		"scene":       "Scene",
+		"url":         "URL",
+		"item_id":     "ItemID",
+		"itemid":      "ItemID",
+		"params":      "Params",
 	}
 	seen := map[string]bool{}
 	out := []string{}
@@ -775,7 +1153,44 @@ func extractItemValue(item map[string]any, fieldName string) string {
 		return strings.TrimSpace(common.BitableValueToString(item["app"]))
 	case "Scene":
 		return strings.TrimSpace(common.BitableValueToString(item["scene"]))
+	case "URL":
+		return strings.TrimSpace(common.BitableValueToString(item["url"]))
+	case "ItemID":
+		return strings.TrimSpace(common.BitableValueToString(item["item_id"]))
+	case "Params":
+		return strings.TrimSpace(common.BitableValueToString(item["params"]))
 	default:
 		return strings.TrimSpace(common.BitableValueToString(item[fieldName]))
 	}
 }
+
+// requestCreate sends a create call with a fresh client_token and resends
+// it, with the same token, when no answer came back (transport error or
+// HTTP 5xx). Feishu returns the records of the first request for a token it
+// has seen, so a create that landed before the connection dropped is not
+// made twice.
+func requestCreate(ctx context.Context, urlStr, token string, payload any, out *createRecordsResp) error {
+	q := url.Values{}
+	q.Set("client_token", common.NewUUID())
+	urlStr += "?" + q.Encode()
+	var err error
+	for attempt := 1; attempt <= createAttempts; attempt++ {
+		*out = createRecordsResp{}
+		if err = common.RequestJSON(ctx, "POST", urlStr, token, payload, out); err == nil {
+			return nil
+		}
+		var httpErr *common.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Status < 500 {
+			return err
+		}
+		if attempt < createAttempts {
+			wait := time.Duration(attempt) * time.Second
+			errLogger.Warn("create call failed; resending with the same client_token", "attempt", attempt, "wait", wait.String(), "err", err)
+			common.EmitRetry(ctx, "create", attempt, wait, err)
+			if !sleepContext(ctx, wait) {
+				return err
+			}
+		}
+	}
+	return err
+}
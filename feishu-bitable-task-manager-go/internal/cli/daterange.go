@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// maxTextDateRangeDays caps the days enumerated for a text Date column,
+// which has no range operators and is matched day by day.
+const maxTextDateRangeDays = 366
+
+var relativeDateRe = regexp.MustCompile(`^([+-]?)(\d+)([dwh])$`)
+
+// parseDateBound reads a --date-from / --date-to value in loc: a bare date
+// (YYYY-MM-DD), today / yesterday, now, an ISO timestamp, epoch seconds or
+// milliseconds, or an offset from now such as -7d, -2w or -36h. Bare dates
+// (and today / yesterday) cover the whole day, so as an upper bound they
+// return the following midnight. The result is the exclusive end for upper
+// bounds and the inclusive start otherwise.
+func parseDateBound(s string, loc *time.Location, now time.Time, upper bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	day := func(t time.Time) time.Time {
+		y, m, d := t.In(loc).Date()
+		start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		if upper {
+			return start.AddDate(0, 0, 1)
+		}
+		return start
+	}
+	switch strings.ToLower(s) {
+	case "":
+		return time.Time{}, nil
+	case "now":
+		return now, nil
+	case "today":
+		return day(now), nil
+	case "yesterday":
+		return day(now.AddDate(0, 0, -1)), nil
+	}
+	if m := relativeDateRe.FindStringSubmatch(strings.ToLower(s)); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		if m[1] != "+" {
+			n = -n
+		}
+		switch m[3] {
+		case "d":
+			return now.AddDate(0, 0, n), nil
+		case "w":
+			return now.AddDate(0, 0, 7*n), nil
+		default:
+			return now.Add(time.Duration(n) * time.Hour), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return day(t), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	if ms, ok := common.CoerceMillis(s); ok {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q (want YYYY-MM-DD, today, -7d, an ISO timestamp or epoch)", s)
+}
+
+// dateRangeFilter returns the search conditions selecting Date in
+// [from, to): isGreater/isLess on exact dates for DateTime columns, or, for
+// text columns, an OR group of the covered days (returned as a filter child).
+// Zero bounds are open.
+func dateRangeFilter(kind, col string, from, to time.Time, loc *time.Location) ([]filterCond, map[string]any, error) {
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, nil, fmt.Errorf("--date-from must be before --date-to")
+	}
+	if kind != common.DateKindText {
+		conds := []filterCond{}
+		if !from.IsZero() {
+			conds = append(conds, filterCond{Column: col, Operator: "isGreater", Value: []string{"ExactDate", strconv.FormatInt(from.UnixMilli()-1, 10)}})
+		}
+		if !to.IsZero() {
+			conds = append(conds, filterCond{Column: col, Operator: "isLess", Value: []string{"ExactDate", strconv.FormatInt(to.UnixMilli(), 10)}})
+		}
+		return conds, nil, nil
+	}
+
+	if from.IsZero() || to.IsZero() {
+		return nil, nil, fmt.Errorf("the Date column is text, so --date-from and --date-to are both required")
+	}
+	days := []map[string]any{}
+	y, m, d := from.In(loc).Date()
+	for t := time.Date(y, m, d, 0, 0, 0, 0, loc); t.Before(to); t = t.AddDate(0, 0, 1) {
+		if len(days) == maxTextDateRangeDays {
+			return nil, nil, fmt.Errorf("the Date column is text and is matched day by day; ranges are limited to %d days", maxTextDateRangeDays)
+		}
+		days = append(days, map[string]any{"field_name": col, "operator": "is", "value": []string{t.Format("2006-01-02")}})
+	}
+	if len(days) == 1 {
+		return []filterCond{{Column: col, Operator: "is", Value: days[0]["value"].([]string)}}, nil, nil
+	}
+	return nil, map[string]any{"conjunction": "or", "conditions": days}, nil
+}
+
+// dateRangePreset clears the --date preset when a range is given, unless
+// the preset was set explicitly, which is a usage error.
+func dateRangePreset(fs *flag.FlagSet, preset *string, from, to string) int {
+	if strings.TrimSpace(from) == "" && strings.TrimSpace(to) == "" {
+		return 0
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "date" })
+	if explicit && !strings.EqualFold(strings.TrimSpace(*preset), "Any") {
+		errLogger.Error("--date cannot be combined with --date-from/--date-to")
+		return 2
+	}
+	*preset = "Any"
+	return 0
+}
+
+// applyDateRange adds the --date-from / --date-to conditions to filterObj
+// (as built by buildFilter), reading the Date column type from the schema.
+func applyDateRange(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]string, from, to string, filterObj map[string]any) (map[string]any, error) {
+	if strings.TrimSpace(from) == "" && strings.TrimSpace(to) == "" {
+		return filterObj, nil
+	}
+	col := strings.TrimSpace(fields["Date"])
+	if col == "" {
+		return nil, fmt.Errorf("the Date field is not mapped")
+	}
+	loc := common.TaskTimezone()
+	now := time.Now()
+	start, err := parseDateBound(from, loc, now, false)
+	if err != nil {
+		return nil, fmt.Errorf("--date-from: %w", err)
+	}
+	end, err := parseDateBound(to, loc, now, true)
+	if err != nil {
+		return nil, fmt.Errorf("--date-to: %w", err)
+	}
+	kind := loadDateWriter(ctx, baseURL, token, ref, col).kind
+	conds, child, err := dateRangeFilter(kind, col, start, end, loc)
+	if err != nil {
+		return nil, err
+	}
+	if filterObj == nil {
+		filterObj = map[string]any{"conjunction": "and", "conditions": []map[string]any{}}
+	}
+	list, _ := filterObj["conditions"].([]map[string]any)
+	for _, c := range conds {
+		list = append(list, whereCondition(c))
+	}
+	filterObj["conditions"] = list
+	if child != nil {
+		children, _ := filterObj["children"].([]map[string]any)
+		filterObj["children"] = append(children, child)
+	}
+	return filterObj, nil
+}
@@ -0,0 +1,315 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// fingerprintDeduper implements --skip-existing when values are normalized
+// or hashed before comparison, which Bitable's `is` filter cannot do. If the
+// table has a Fingerprint column, keys are written there on create and
+// looked up with an exact filter; otherwise existing rows are scanned and
+// fingerprinted locally.
+type fingerprintDeduper struct {
+	fp       common.Fingerprinter
+	fields   []string
+	fpCol    string
+	existing map[string]bool
+}
+
+func newFingerprintDeduper(ctx context.Context, baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, skipFields []string, spec common.FingerprintSpec, creates []map[string]any) (*fingerprintDeduper, error) {
+	for _, f := range skipFields {
+		if f == "RecordID" {
+			return nil, errors.New("RecordID cannot be combined with dedupe normalization or hashing")
+		}
+	}
+	fp, err := common.NewFingerprinter(spec)
+	if err != nil {
+		return nil, err
+	}
+	d := &fingerprintDeduper{fp: fp, fields: skipFields, existing: map[string]bool{}}
+
+	if col := strings.TrimSpace(fieldsMap["Fingerprint"]); col != "" {
+		schema, err := common.ListFields(ctx, baseURL, token, ref.AppToken, ref.TableID)
+		if err != nil {
+			return nil, fmt.Errorf("list fields: %w", err)
+		}
+		if _, ok := common.FieldsByName(schema)[col]; ok {
+			d.fpCol = col
+		}
+	}
+
+	if d.fpCol != "" {
+		keys := []string{}
+		for _, item := range creates {
+			if k := d.itemKey(item); k != "" {
+				keys = append(keys, k)
+			}
+		}
+		found, err := resolveExistingByField(ctx, baseURL, token, ref, d.fpCol, keys)
+		if err != nil {
+			return nil, err
+		}
+		for k := range found {
+			d.existing[k] = true
+		}
+		return d, nil
+	}
+
+	columns := make([]string, 0, len(skipFields))
+	for _, f := range skipFields {
+		columns = append(columns, columnFor(fieldsMap, f))
+	}
+	err = scanRecords(ctx, baseURL, token, ref, map[string]any{"field_names": columns}, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		values := map[string]string{}
+		for i, f := range skipFields {
+			values[f] = common.BitableValueToString(fieldsRaw[columns[i]])
+		}
+		if k := d.fp.Fingerprint(values); k != "" {
+			d.existing[k] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *fingerprintDeduper) itemKey(item map[string]any) string {
+	values := map[string]string{}
+	for _, f := range d.fields {
+		values[f] = extractItemValue(item, f)
+	}
+	return d.fp.Fingerprint(values)
+}
+
+// claim reports whether item is new and records its key, so duplicates
+// later in the same input are skipped as well. New items get their key
+// written to the Fingerprint column when the table has one.
+func (d *fingerprintDeduper) claim(item map[string]any, fields map[string]any) bool {
+	key := d.itemKey(item)
+	if key == "" {
+		return true
+	}
+	if d.existing[key] {
+		return false
+	}
+	d.existing[key] = true
+	if d.fpCol != "" {
+		fields[d.fpCol] = key
+	}
+	return true
+}
+
+func columnFor(fieldsMap map[string]string, logical string) string {
+	if mapped := strings.TrimSpace(fieldsMap[logical]); mapped != "" {
+		return mapped
+	}
+	return logical
+}
+
+// scanRecords pages through every record matching body and calls fn for
+// each item, fetching the next page while fn runs.
+func scanRecords(ctx context.Context, baseURL, token string, ref common.BitableRef, body map[string]any, fn func(item map[string]any)) error {
+	pages, stop := pageStream{BaseURL: baseURL, Token: token, Ref: ref, Body: body, Prefetch: defaultPrefetchPages}.stream(ctx)
+	defer stop()
+	for page := range pages {
+		if page.Err != nil {
+			return page.Err
+		}
+		for _, it := range page.Items {
+			fn(it)
+		}
+	}
+	return ctx.Err()
+}
+
+// Which record of a duplicate group dedupe keeps.
+const (
+	dedupeKeepOldest = "oldest"
+	dedupeKeepNewest = "newest"
+)
+
+type DedupeOptions struct {
+	TaskURL string
+	// Key is one or more fields (comma-separated) whose values together
+	// identify a task, e.g. BizTaskID or App,URL.
+	Key       string
+	Normalize string
+	App       string
+	Scene     string
+	Keep      string
+	// Soft marks the extra records Deleted instead of removing them.
+	Soft bool
+	Yes  bool
+}
+
+type dedupeGroup struct {
+	Key    string   `json:"key"`
+	Keep   string   `json:"keep"`
+	Remove []string `json:"remove"`
+}
+
+type dedupeReport struct {
+	Key            string        `json:"key"`
+	Keep           string        `json:"keep"`
+	Soft           bool          `json:"soft,omitempty"`
+	DryRun         bool          `json:"dry_run"`
+	Scanned        int           `json:"scanned"`
+	Duplicates     int           `json:"duplicates"`
+	Removed        int           `json:"removed"`
+	Groups         []dedupeGroup `json:"groups"`
+	Failed         int           `json:"failed"`
+	Errors         []string      `json:"errors"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+}
+
+// DedupeTasks finds records sharing a key and, with Yes, deletes (or soft
+// deletes) all but the oldest or newest of each group by creation time.
+// Without Yes it only reports the groups, so the plan can be reviewed first.
+// Keys are compared after the --skip-existing normalizers; records with an
+// empty key and soft-deleted records are left out.
+func DedupeTasks(ctx context.Context, opts DedupeOptions) int {
+	keys := normalizeSkipFields(opts.Key)
+	if len(keys) == 0 {
+		errLogger.Error("--key is required")
+		return 2
+	}
+	for _, k := range keys {
+		if k == "RecordID" {
+			errLogger.Error("RecordID cannot be a dedupe key; record ids are unique")
+			return 2
+		}
+	}
+	keep := strings.ToLower(strings.TrimSpace(opts.Keep))
+	if keep != dedupeKeepOldest && keep != dedupeKeepNewest {
+		errLogger.Error("--keep must be oldest or newest", "keep", opts.Keep)
+		return 2
+	}
+	spec, err := common.ParseFingerprintSpec(keys, opts.Normalize, "")
+	if err != nil {
+		errLogger.Error("invalid --normalize", "err", err)
+		return 2
+	}
+	fp, err := common.NewFingerprinter(spec)
+	if err != nil {
+		errLogger.Error("invalid dedupe key", "err", err)
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	deletedCol := ""
+	if col := strings.TrimSpace(tc.fields["Deleted"]); col != "" && tableHasColumn(ctx, tc.baseURL, tc.token, tc.ref, col) {
+		deletedCol = col
+	}
+	if opts.Soft && deletedCol == "" {
+		errLogger.Error("--soft needs a Deleted checkbox column (run init-table or set TASK_FIELD_DELETED)")
+		return 2
+	}
+
+	start := time.Now()
+	columns := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		columns = append(columns, columnFor(tc.fields, k))
+	}
+	if deletedCol != "" {
+		columns = append(columns, deletedCol)
+	}
+	body := map[string]any{"field_names": columns, "automatic_fields": true}
+	if filterObj := buildFilter(tc.fields, opts.App, opts.Scene, "", ""); filterObj != nil {
+		body["filter"] = filterObj
+	}
+	type member struct {
+		recordID string
+		created  int64
+	}
+	groups := map[string][]member{}
+	order := []string{}
+	report := dedupeReport{Key: strings.Join(keys, ","), Keep: keep, Soft: opts.Soft, DryRun: !opts.Yes, Groups: []dedupeGroup{}, Errors: []string{}}
+	err = scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if isSoftDeleted(fieldsRaw, tc.fields) {
+			return
+		}
+		report.Scanned++
+		values := map[string]string{}
+		for i, k := range keys {
+			values[k] = common.BitableValueToString(fieldsRaw[columns[i]])
+		}
+		key := fp.Fingerprint(values)
+		if key == "" {
+			return
+		}
+		rid, _ := item["record_id"].(string)
+		created, _ := common.CoerceMillis(item["created_time"])
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], member{recordID: rid, created: created})
+	})
+	if err != nil {
+		errLogger.Error("scan tasks failed", "err", err)
+		return 2
+	}
+
+	remove := []string{}
+	for _, key := range order {
+		members := groups[key]
+		if len(members) < 2 {
+			continue
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			if members[i].created != members[j].created {
+				return members[i].created < members[j].created
+			}
+			return members[i].recordID < members[j].recordID
+		})
+		kept := members[0]
+		rest := members[1:]
+		if keep == dedupeKeepNewest {
+			kept = members[len(members)-1]
+			rest = members[:len(members)-1]
+		}
+		g := dedupeGroup{Key: strings.ReplaceAll(key, "\x1f", ","), Keep: kept.recordID, Remove: make([]string, 0, len(rest))}
+		for _, m := range rest {
+			g.Remove = append(g.Remove, m.recordID)
+		}
+		remove = append(remove, g.Remove...)
+		report.Groups = append(report.Groups, g)
+	}
+	report.Duplicates = len(remove)
+
+	if opts.Yes {
+		for i, batch := range chunkStrings(remove, deleteMaxBatchSize) {
+			if len(batch) == 0 {
+				continue
+			}
+			if opts.Soft {
+				err = softDeleteRecords(ctx, tc, deletedCol, batch, true)
+			} else {
+				err = batchDeleteRecords(ctx, tc.baseURL, tc.token, tc.ref, batch)
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("chunk %d (%s..%s): %v", i+1, batch[0], batch[len(batch)-1], err))
+				continue
+			}
+			report.Removed += len(batch)
+		}
+	}
+	report.Failed = len(report.Errors)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
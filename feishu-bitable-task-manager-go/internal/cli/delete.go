@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+const deleteMaxBatchSize = 500
+
+type DeleteOptions struct {
+	TaskURL   string
+	InputPath string
+	RecordID  string
+	TaskID    int
+	BizTaskID string
+	Yes       bool
+	Soft      bool
+	Restore   bool
+}
+
+type deleteReport struct {
+	Deleted   int      `json:"deleted"`
+	Requested int      `json:"requested"`
+	Soft      bool     `json:"soft,omitempty"`
+	Restored  bool     `json:"restored,omitempty"`
+	DryRun    bool     `json:"dry_run"`
+	RecordIDs []string `json:"record_ids"`
+	Failed    int      `json:"failed"`
+	Errors    []string `json:"errors"`
+	// LockedRecordIDs are records locked for manual edits; they are left
+	// alone.
+	LockedRecordIDs []string `json:"locked_record_ids,omitempty"`
+	// Chunks has one entry per batch call; FailedRecordIDs lists the
+	// records of failed chunks so they can be retried.
+	Chunks          []deleteChunk `json:"chunks,omitempty"`
+	FailedRecordIDs []string      `json:"failed_record_ids,omitempty"`
+	ElapsedSeconds  float64       `json:"elapsed_seconds"`
+}
+
+// deleteChunk reports one batch_delete (or soft-delete batch_update) call.
+type deleteChunk struct {
+	Chunk   int    `json:"chunk"`
+	Records int    `json:"records"`
+	First   string `json:"first_record_id"`
+	Last    string `json:"last_record_id"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DeleteTasks removes the target records, or with Soft sets their Deleted
+// checkbox so fetch/claim/watch skip them while the row stays recoverable
+// (Restore clears it again). Records locked for manual edits are left
+// alone and listed in the report.
+func DeleteTasks(ctx context.Context, opts DeleteOptions) int {
+	if opts.Restore && !opts.Soft {
+		errLogger.Error("--restore requires --soft")
+		return 2
+	}
+	targets, err := loadDeleteTargets(opts)
+	if err != nil {
+		errLogger.Error("load delete targets failed", "err", err)
+		return 2
+	}
+	if len(targets) == 0 {
+		errLogger.Error("no records to delete: use --record-id, --task-id, --biz-task-id or --input")
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+
+	deletedCol := ""
+	if opts.Soft {
+		deletedCol = strings.TrimSpace(tc.fields["Deleted"])
+		if deletedCol == "" || !tableHasColumn(ctx, tc.baseURL, tc.token, tc.ref, deletedCol) {
+			errLogger.Error("--soft needs a Deleted checkbox column (run init-table or set TASK_FIELD_DELETED)", "column", deletedCol)
+			return 2
+		}
+	}
+
+	recordIDs, errorsList, err := tc.resolveTargets(ctx, targets)
+	if err != nil {
+		errLogger.Error("resolve record IDs failed", "err", err)
+		return 2
+	}
+
+	locks, err := editLocks(ctx, newTableSchema(tc.baseURL, tc.token, tc.ref), tc.fields, recordIDs)
+	if err != nil {
+		errLogger.Error("read edit locks failed", "err", err)
+		return exitCodeFor(err, 2)
+	}
+	requested := len(recordIDs)
+	lockedIDs := []string{}
+	if len(locks) > 0 {
+		kept := make([]string, 0, len(recordIDs))
+		for _, rid := range recordIDs {
+			if l, ok := locks[rid]; ok {
+				logSkippedLocked(rid, l, "delete")
+				lockedIDs = append(lockedIDs, rid)
+				continue
+			}
+			kept = append(kept, rid)
+		}
+		recordIDs = kept
+	}
+
+	start := time.Now()
+	report := deleteReport{Requested: requested, RecordIDs: recordIDs, LockedRecordIDs: lockedIDs, Soft: opts.Soft, Restored: opts.Restore, DryRun: !opts.Yes}
+	if !opts.Yes {
+		report.Errors = errorsList
+		report.Failed = len(errorsList)
+		printJSON(report)
+		errLogger.Error("refusing to delete without --yes", "records", len(recordIDs))
+		return 2
+	}
+	// One call per deleteMaxBatchSize records. A failed chunk is reported
+	// and the remaining chunks are still sent.
+	for i, batch := range chunkStrings(recordIDs, deleteMaxBatchSize) {
+		if len(batch) == 0 {
+			continue
+		}
+		if deletedCol != "" {
+			err = softDeleteRecords(ctx, tc, deletedCol, batch, !opts.Restore)
+		} else {
+			err = batchDeleteRecords(ctx, tc.baseURL, tc.token, tc.ref, batch)
+		}
+		chunk := deleteChunk{Chunk: i + 1, Records: len(batch), First: batch[0], Last: batch[len(batch)-1]}
+		if err != nil {
+			chunk.Error = err.Error()
+			errorsList = append(errorsList, fmt.Sprintf("chunk %d (%s..%s): %v", chunk.Chunk, chunk.First, chunk.Last, err))
+			report.FailedRecordIDs = append(report.FailedRecordIDs, batch...)
+		} else {
+			report.Deleted += len(batch)
+		}
+		report.Chunks = append(report.Chunks, chunk)
+	}
+	report.Errors = errorsList
+	report.Failed = len(errorsList)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if len(errorsList) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// recordTarget identifies a record by record_id, TaskID or BizTaskID.
+type recordTarget struct {
+	RecordID  string
+	TaskID    int
+	BizTaskID string
+}
+
+func loadDeleteTargets(opts DeleteOptions) ([]recordTarget, error) {
+	if strings.TrimSpace(opts.InputPath) == "" {
+		t := recordTarget{RecordID: strings.TrimSpace(opts.RecordID), TaskID: opts.TaskID, BizTaskID: strings.TrimSpace(opts.BizTaskID)}
+		if t.RecordID == "" && t.TaskID <= 0 && t.BizTaskID == "" {
+			return nil, nil
+		}
+		return []recordTarget{t}, nil
+	}
+	items, err := readInputItems(opts.InputPath)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]recordTarget, 0, len(items))
+	for _, item := range items {
+		taskID, _ := common.CoerceInt(firstNonNil(item["task_id"], item["taskID"], item["TaskID"]))
+		out = append(out, recordTarget{
+			RecordID:  strings.TrimSpace(common.BitableValueToString(firstNonNil(item["record_id"], item["recordId"], item["RecordID"]))),
+			TaskID:    taskID,
+			BizTaskID: strings.TrimSpace(common.BitableValueToString(firstNonNil(item["biz_task_id"], item["bizTaskId"], item["BizTaskID"]))),
+		})
+	}
+	return out, nil
+}
+
+// readInputItems reads a JSON, JSONL or CSV input file (or stdin for "-").
+func readInputItems(path string) ([]map[string]any, error) {
+	raw, err := readAllInput(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseInputItems(path, raw)
+}
+
+// resolveTargets maps targets to unique record IDs, resolving TaskID and
+// BizTaskID in batches. Targets that cannot be resolved are reported as
+// errors rather than aborting the whole run.
+func (t *tableClient) resolveTargets(ctx context.Context, targets []recordTarget) ([]string, []string, error) {
+	taskIDs := []int{}
+	bizIDs := []string{}
+	for _, tg := range targets {
+		if tg.RecordID != "" {
+			continue
+		}
+		if tg.TaskID > 0 {
+			taskIDs = append(taskIDs, tg.TaskID)
+		} else if tg.BizTaskID != "" {
+			bizIDs = append(bizIDs, tg.BizTaskID)
+		}
+	}
+	byTask := map[int]string{}
+	byBiz := map[string]string{}
+	if len(taskIDs) > 0 {
+		m, _, err := resolveRecordIDsByTaskID(ctx, t.baseURL, t.token, t.ref, t.fields, taskIDs, true, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		byTask = m
+	}
+	if len(bizIDs) > 0 {
+		m, _, err := resolveRecordIDsByBizTaskID(ctx, t.baseURL, t.token, t.ref, t.fields, bizIDs, true, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		byBiz = m
+	}
+
+	seen := map[string]bool{}
+	recordIDs := []string{}
+	errorsList := []string{}
+	for _, tg := range targets {
+		rid := tg.RecordID
+		switch {
+		case rid != "":
+		case tg.TaskID > 0:
+			rid = byTask[tg.TaskID]
+			if rid == "" {
+				errorsList = append(errorsList, fmt.Sprintf("task %d: record not found", tg.TaskID))
+				continue
+			}
+		case tg.BizTaskID != "":
+			rid = byBiz[tg.BizTaskID]
+			if rid == "" {
+				errorsList = append(errorsList, fmt.Sprintf("biz task %s: record not found", tg.BizTaskID))
+				continue
+			}
+		default:
+			errorsList = append(errorsList, "missing record_id/task_id/biz_task_id")
+			continue
+		}
+		if !seen[rid] {
+			seen[rid] = true
+			recordIDs = append(recordIDs, rid)
+		}
+	}
+	return recordIDs, errorsList, nil
+}
+
+// softDeleteRecords sets (or with deleted=false clears) the Deleted
+// checkbox of the given records in one batch update.
+func softDeleteRecords(ctx context.Context, tc *tableClient, deletedCol string, recordIDs []string, deleted bool) error {
+	records := make([]map[string]any, 0, len(recordIDs))
+	for _, rid := range recordIDs {
+		records = append(records, map[string]any{"record_id": rid, "fields": map[string]any{deletedCol: deleted}})
+	}
+	return batchUpdateRecords(ctx, tc.baseURL, tc.token, tc.ref, records)
+}
+
+func batchDeleteRecords(ctx context.Context, baseURL, token string, ref common.BitableRef, recordIDs []string) error {
+	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/batch_delete",
+		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID,
+	)
+	payload := map[string]any{"records": recordIDs}
+	var resp common.FeishuResp
+	if err := common.RequestJSON(ctx, "POST", urlStr, token, payload, &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("batch delete failed: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// Sources of device history rows.
+const (
+	historySourceTasks = "tasks"
+	historySourceRuns  = "runs"
+	historySourceAll   = "all"
+)
+
+type DeviceHistoryOptions struct {
+	TaskURL string
+	// RunsURL is the runs table (TASK_RUNS_BITABLE_URL); "" reads tasks only.
+	RunsURL string
+	Serial  string
+	// Since and Until bound the attempt start time, see parseDateBound.
+	Since string
+	Until string
+	// Source is tasks, runs or all (default: runs too when RunsURL is set).
+	Source string
+	Format string
+}
+
+// deviceAttempt is one task execution on the device.
+type deviceAttempt struct {
+	// Source is "run" (runs table) or "task" (the task record).
+	Source         string  `json:"source"`
+	TaskID         int     `json:"task_id"`
+	RecordID       string  `json:"record_id,omitempty"`
+	App            string  `json:"app,omitempty"`
+	Scene          string  `json:"scene,omitempty"`
+	Attempt        int     `json:"attempt,omitempty"`
+	Status         string  `json:"status"`
+	StartAt        string  `json:"start_at"`
+	EndAt          string  `json:"end_at,omitempty"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	ItemsCollected string  `json:"items_collected,omitempty"`
+
+	startMs int64
+}
+
+type deviceHistoryReport struct {
+	Serial         string          `json:"serial"`
+	Since          string          `json:"since"`
+	Until          string          `json:"until"`
+	Attempts       []deviceAttempt `json:"attempts"`
+	Count          int             `json:"count"`
+	ByStatus       map[string]int  `json:"by_status"`
+	BusySeconds    float64         `json:"busy_seconds"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+}
+
+// DeviceHistory reconstructs what one device executed in a time window.
+// Task records hold each task's latest attempt (DispatchedDevice, else
+// DeviceSerial); the runs table, when configured, holds every finished
+// attempt. An attempt found in both is reported once, from the runs table.
+func DeviceHistory(ctx context.Context, opts DeviceHistoryOptions) int {
+	serial := strings.TrimSpace(opts.Serial)
+	if serial == "" {
+		errLogger.Error("--serial is required")
+		return 2
+	}
+	source := strings.ToLower(strings.TrimSpace(opts.Source))
+	if source == "" {
+		source = historySourceTasks
+		if strings.TrimSpace(opts.RunsURL) != "" {
+			source = historySourceAll
+		}
+	}
+	if source != historySourceTasks && source != historySourceRuns && source != historySourceAll {
+		errLogger.Error("--source must be tasks, runs or all", "source", opts.Source)
+		return 2
+	}
+	if source != historySourceTasks && strings.TrimSpace(opts.RunsURL) == "" {
+		errLogger.Error("--source " + source + " needs TASK_RUNS_BITABLE_URL or --runs-url")
+		return 2
+	}
+	format, err := parseOutputFormat(opts.Format, outputJSON, outputJSONL, outputTable, outputCSV, outputYAML)
+	if err != nil {
+		errLogger.Error("invalid output format", "err", err)
+		return 2
+	}
+	loc := common.TaskTimezone()
+	now := time.Now()
+	since, err := parseDateBound(opts.Since, loc, now, false)
+	if err != nil {
+		errLogger.Error("invalid --since", "err", err)
+		return 2
+	}
+	until := now
+	if strings.TrimSpace(opts.Until) != "" {
+		if until, err = parseDateBound(opts.Until, loc, now, true); err != nil {
+			errLogger.Error("invalid --until", "err", err)
+			return 2
+		}
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+
+	start := time.Now()
+	inWindow := func(ms int64) bool {
+		return ms >= since.UnixMilli() && ms < until.UnixMilli()
+	}
+	attempts := []deviceAttempt{}
+	seen := map[string]bool{}
+	attemptKey := func(taskID int, startMs int64) string {
+		return strconv.Itoa(taskID) + "@" + strconv.FormatInt(startMs, 10)
+	}
+
+	if source != historySourceTasks {
+		runs, err := openRunsTable(ctx, tc.baseURL, tc.token, opts.RunsURL)
+		if err != nil {
+			errLogger.Error("open runs table failed", "err", err)
+			return 2
+		}
+		col := strings.TrimSpace(runs.fields["DeviceSerial"])
+		body := map[string]any{"filter": map[string]any{"conjunction": "and", "conditions": []map[string]any{
+			{"field_name": col, "operator": "is", "value": []string{serial}},
+		}}}
+		err = scanRecords(ctx, tc.baseURL, tc.token, runs.ref, body, func(item map[string]any) {
+			fieldsRaw, _ := item["fields"].(map[string]any)
+			a, ok := attemptFromFields(fieldsRaw, runs.fields, loc)
+			if !ok || !inWindow(a.startMs) {
+				return
+			}
+			a.Source = "run"
+			a.Attempt = common.FieldInt(fieldsRaw, runs.fields["Attempt"])
+			seen[attemptKey(a.TaskID, a.startMs)] = true
+			attempts = append(attempts, a)
+		})
+		if err != nil {
+			errLogger.Error("scan runs table failed", "err", err)
+			return 1
+		}
+	}
+
+	if source != historySourceRuns {
+		conds := []map[string]any{}
+		for _, key := range []string{"DispatchedDevice", "DeviceSerial"} {
+			if col := strings.TrimSpace(tc.fields[key]); col != "" {
+				conds = append(conds, map[string]any{"field_name": col, "operator": "is", "value": []string{serial}})
+			}
+		}
+		if len(conds) == 0 {
+			errLogger.Error("device history needs the DispatchedDevice or DeviceSerial field mapped")
+			return 2
+		}
+		body := map[string]any{"filter": map[string]any{"conjunction": "or", "conditions": conds}}
+		err = scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+			fieldsRaw, _ := item["fields"].(map[string]any)
+			if isSoftDeleted(fieldsRaw, tc.fields) {
+				return
+			}
+			// DeviceSerial is only the requested device once another one
+			// picked the task up.
+			device := common.BitableValueToString(fieldsRaw[tc.fields["DispatchedDevice"]])
+			if device != "" && device != serial {
+				return
+			}
+			a, ok := attemptFromFields(fieldsRaw, tc.fields, loc)
+			if !ok || !inWindow(a.startMs) || seen[attemptKey(a.TaskID, a.startMs)] {
+				return
+			}
+			a.Source = "task"
+			a.RecordID, _ = item["record_id"].(string)
+			a.App = common.BitableValueToString(fieldsRaw[tc.fields["App"]])
+			a.Scene = common.BitableValueToString(fieldsRaw[tc.fields["Scene"]])
+			attempts = append(attempts, a)
+		})
+		if err != nil {
+			errLogger.Error("scan tasks failed", "err", err)
+			return 1
+		}
+	}
+
+	sort.SliceStable(attempts, func(i, j int) bool { return attempts[i].startMs < attempts[j].startMs })
+	report := deviceHistoryReport{
+		Serial:   serial,
+		Since:    since.In(loc).Format(time.RFC3339),
+		Until:    until.In(loc).Format(time.RFC3339),
+		Attempts: attempts,
+		Count:    len(attempts),
+		ByStatus: map[string]int{},
+	}
+	busy := 0.0
+	for _, a := range attempts {
+		report.ByStatus[a.Status]++
+		busy += a.ElapsedSeconds
+	}
+	report.BusySeconds = float64(int(busy*1000)) / 1000
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	rows := make([]any, 0, len(attempts))
+	for _, a := range attempts {
+		rows = append(rows, a)
+	}
+	switch format {
+	case outputJSON:
+		printJSON(report)
+		return 0
+	case outputJSONL:
+		for _, a := range attempts {
+			logger.Info("attempt", "attempt", a)
+		}
+		return 0
+	}
+	return printFormatted(format, report, rows)
+}
+
+// attemptFromFields reads the attempt timing of a task or runs-table row:
+// StartAt (else DispatchedAt), EndAt, and ElapsedSeconds or, when it is
+// empty, EndAt - StartAt. Rows without a start time are skipped.
+func attemptFromFields(fieldsRaw map[string]any, fields map[string]string, loc *time.Location) (deviceAttempt, bool) {
+	startMs, ok := common.CoerceMillis(fieldsRaw[fields["StartAt"]])
+	if !ok {
+		if startMs, ok = common.CoerceMillis(fieldsRaw[fields["DispatchedAt"]]); !ok {
+			return deviceAttempt{}, false
+		}
+	}
+	a := deviceAttempt{
+		TaskID:         common.FieldInt(fieldsRaw, fields["TaskID"]),
+		Status:         strings.ToLower(common.BitableValueToString(fieldsRaw[fields["Status"]])),
+		StartAt:        time.UnixMilli(startMs).In(loc).Format(time.RFC3339),
+		ItemsCollected: common.BitableValueToString(fieldsRaw[fields["ItemsCollected"]]),
+		startMs:        startMs,
+	}
+	endMs, hasEnd := common.CoerceMillis(fieldsRaw[fields["EndAt"]])
+	if hasEnd {
+		a.EndAt = time.UnixMilli(endMs).In(loc).Format(time.RFC3339)
+	}
+	if v, ok := statsNumber(fieldsRaw[fields["ElapsedSeconds"]]); ok {
+		a.ElapsedSeconds = v
+	} else if hasEnd && endMs >= startMs {
+		a.ElapsedSeconds = float64(endMs-startMs) / 1000
+	}
+	return a, true
+}
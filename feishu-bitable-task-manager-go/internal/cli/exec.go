@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+type ExecOptions struct {
+	TaskURL   string
+	RecordID  string
+	TaskID    int
+	BizTaskID string
+	EnvPrefix string
+	PrintEnv  bool
+	Shell     string
+	Command   []string
+}
+
+type execReport struct {
+	RecordID       string  `json:"record_id"`
+	TraceID        string  `json:"trace_id,omitempty"`
+	ExitCode       int     `json:"exit_code"`
+	Result         any     `json:"result,omitempty"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// ExecTask runs a command with one task injected through the taskEnv
+// contract. The command may write a JSON result to $TASK_RESULT_FILE; it is
+// echoed in the report.
+func ExecTask(ctx context.Context, opts ExecOptions) int {
+	if !opts.PrintEnv && len(opts.Command) == 0 {
+		errLogger.Error("a command is required: bitable-task exec [flags] -- <command> [args...]")
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	task, err := tc.loadTask(ctx, opts.RecordID, opts.TaskID, opts.BizTaskID)
+	if err != nil {
+		errLogger.Error("load task failed", "err", err)
+		return 2
+	}
+
+	if opts.PrintEnv {
+		exit := 0
+		for _, kv := range taskEnv(task, opts.EnvPrefix, "") {
+			k, v, _ := strings.Cut(kv, "=")
+			line, err := envAssignment(opts.Shell, k, v)
+			if err != nil {
+				errLogger.Warn("skip env var", "err", err)
+				exit = 1
+				continue
+			}
+			fmt.Println(line)
+		}
+		return exit
+	}
+	argv, err := shellCommand(opts.Shell, opts.Command)
+	if err != nil {
+		errLogger.Error("invalid --shell", "err", err)
+		return 2
+	}
+
+	dir, err := os.MkdirTemp("", "bitable-task-exec-")
+	if err != nil {
+		errLogger.Error("create result dir failed", "err", err)
+		return 2
+	}
+	defer os.RemoveAll(dir)
+	resultFile := filepath.Join(dir, "result.json")
+
+	start := time.Now()
+	exitCode, err := runTaskCommand(ctx, argv, taskEnv(task, opts.EnvPrefix, resultFile), nil, os.Stdout, os.Stderr)
+	if err != nil {
+		errLogger.Error("run command failed", taskAttrs(task.RecordID, task.TraceID, "err", err)...)
+		return 2
+	}
+	report := execReport{RecordID: task.RecordID, TraceID: task.TraceID, ExitCode: exitCode}
+	report.Result = readResultFile(resultFile)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if exitCode != 0 {
+		return 1
+	}
+	return 0
+}
+
+// runTaskCommand runs argv with extra env appended to the current
+// environment. A non-zero exit is reported through the exit code; err is
+// only set when the command could not be run at all.
+func runTaskCommand(ctx context.Context, argv []string, env []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	configureCommand(cmd)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children of a killed command may keep its output pipes open; stop
+	// waiting for them shortly after the kill.
+	cmd.WaitDelay = 2 * time.Second
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// readResultFile returns the decoded JSON result, the raw text when it is
+// not JSON, or nil when the file was not written.
+func readResultFile(path string) any {
+	raw, err := os.ReadFile(path)
+	if err != nil || len(strings.TrimSpace(string(raw))) == 0 {
+		return nil
+	}
+	var v any
+	if err := common.DecodeJSON(raw, &v); err != nil {
+		return strings.TrimSpace(string(raw))
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// Exit codes of the commands that read or write tasks. 1 is the generic
+// failure other commands use too; probe keeps its Nagios codes.
+const (
+	exitOK = 0
+	// exitUsage: bad flags, input or configuration; nothing was sent.
+	exitUsage = 2
+	// exitAuth: Feishu refused the credentials or the app lacks access.
+	exitAuth = 3
+	// exitNotFound: the table, view, wiki node or a named record does not
+	// exist.
+	exitNotFound = 4
+	// exitRateLimited: Feishu's frequency limit outlasted the retries.
+	exitRateLimited = 5
+	// exitValidation: rows were rejected for values the table cannot take.
+	exitValidation = 6
+	// exitConflict: another writer got there first (attempt token, expected
+	// status, lock or status transition).
+	exitConflict = 7
+	// exitPartial: some records were written and some were not. It has a
+	// code of its own so a retry loop can tell it from a run where
+	// nothing was written.
+	exitPartial = 8
+)
+
+var (
+	authCodes = map[int]bool{
+		10003: true, 10014: true, // invalid app_id / app_secret
+		99991661: true, 99991663: true, 99991664: true, 99991665: true, 99991668: true, // missing or invalid token
+		99991672: true, 99991679: true, // scope not granted
+		1254302: true, 91403: true, // no permission on the base
+	}
+	notFoundCodes = map[int]bool{
+		1254040: true, 1254041: true, 1254042: true, 1254043: true, 1254044: true, // base, table, view, record, field
+		131005: true, // wiki node
+	}
+	rateLimitCodes = map[int]bool{99991400: true, 1254290: true}
+	conflictCodes  = map[int]bool{1254291: true} // concurrent write
+)
+
+// feishuCodePattern finds the Feishu code in errors built as "... code=N
+// msg=..." and in the JSON body an HTTPError carries.
+var feishuCodePattern = regexp.MustCompile(`code(?:=|"\s*:\s*)(\d+)`)
+
+var httpStatusPattern = regexp.MustCompile(`\bhttp (\d{3}):`)
+
+// exitCodeFor maps a Feishu failure to its exit code, or returns fallback
+// for errors that carry no recognizable code (network errors, bad input).
+func exitCodeFor(err error, fallback int) int {
+	if err == nil {
+		return fallback
+	}
+	status := 0
+	var httpErr *common.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Status
+	} else if m := httpStatusPattern.FindStringSubmatch(err.Error()); m != nil {
+		status, _ = strconv.Atoi(m[1])
+	}
+	if m := feishuCodePattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		switch {
+		case authCodes[code]:
+			return exitAuth
+		case notFoundCodes[code]:
+			return exitNotFound
+		case rateLimitCodes[code]:
+			return exitRateLimited
+		case conflictCodes[code]:
+			return exitConflict
+		case code >= 1254060 && code <= 1254069: // field value conversion
+			return exitValidation
+		}
+	}
+	switch status {
+	case 401, 403:
+		return exitAuth
+	case 404:
+		return exitNotFound
+	case 429:
+		return exitRateLimited
+	}
+	return fallback
+}
+
+// writeOutcome tallies what went wrong in a batch so the exit code names
+// the worst of it: failed writes first, then rejected rows, unknown
+// records and conflicts.
+type writeOutcome struct {
+	written   int
+	failed    []error
+	invalid   int
+	notFound  int
+	conflicts int
+}
+
+func (o writeOutcome) exitCode() int {
+	switch {
+	case len(o.failed) > 0 && o.written == 0:
+		// Nothing got through: the cause is usually the same for every
+		// batch, so report it. A network error carries no code and counts
+		// like one before the first write.
+		return exitCodeFor(o.failed[0], exitUsage)
+	case len(o.failed) > 0:
+		return exitPartial
+	case o.invalid > 0:
+		return exitValidation
+	case o.notFound > 0:
+		return exitNotFound
+	case o.conflicts > 0:
+		return exitConflict
+	}
+	return exitOK
+}
@@ -0,0 +1,438 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+type ExportOptions struct {
+	TaskURL    string
+	App        string
+	Scene      string
+	Status     string
+	Date       string
+	IgnoreView bool
+	ViewID     string
+	Format     string
+	Output     string
+	// Where is a --where expression, see compileWhere.
+	Where string
+	// DateFrom and DateTo bound the Date column, see parseDateBound.
+	DateFrom string
+	DateTo   string
+
+	// TaskURLs and Dir export several tables at once: one file per table
+	// in Dir plus manifest.json, Parallel tables at a time.
+	TaskURLs []string
+	Dir      string
+	Parallel int
+}
+
+type exportReport struct {
+	Output         string   `json:"output"`
+	Format         string   `json:"format"`
+	Rows           int      `json:"rows"`
+	Columns        []string `json:"columns"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+}
+
+// exportColumn is one output column: a mapped task column that exists in
+// the table, with its Bitable type.
+type exportColumn struct {
+	Logical string
+	Name    string
+	Type    int
+}
+
+// ExportTasks dumps matching task records to CSV or .xlsx, one row per
+// record and one column per mapped task field present in the table, in
+// canonical schema order after a leading record_id. Timestamps are written
+// as local times in TASK_TIMEZONE so the file reads without Feishu.
+func ExportTasks(ctx context.Context, opts ExportOptions) int {
+	output := strings.TrimSpace(opts.Output)
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	if format == "" {
+		format = "csv"
+		if strings.EqualFold(filepath.Ext(output), ".xlsx") {
+			format = "xlsx"
+		}
+	}
+	if format != "csv" && format != "xlsx" {
+		errLogger.Error("--format must be csv or xlsx", "format", opts.Format)
+		return 2
+	}
+	if _, err := compileWhere(opts.Where, nil, nil); err != nil {
+		errLogger.Error("invalid --where", "err", err)
+		return 2
+	}
+	if strings.TrimSpace(opts.Dir) != "" || len(opts.TaskURLs) > 1 {
+		if output != "" {
+			errLogger.Error("--output cannot be combined with --dir; each table gets its own file")
+			return 2
+		}
+		return exportToDir(ctx, opts, format)
+	}
+	if format == "xlsx" && (output == "" || output == "-") {
+		errLogger.Error("--output <file>.xlsx is required for xlsx")
+		return 2
+	}
+	taskURL := opts.TaskURL
+	if len(opts.TaskURLs) == 1 {
+		taskURL = opts.TaskURLs[0]
+	}
+	tc, code := openTable(ctx, taskURL)
+	if tc == nil {
+		return code
+	}
+	start := time.Now()
+	rows, err := exportRows(ctx, tc, opts)
+	if err != nil {
+		errLogger.Error("export tasks failed", "err", err)
+		return 1
+	}
+
+	if output == "" || output == "-" {
+		if err := writeExport(os.Stdout, format, rows); err != nil {
+			errLogger.Error("write export failed", "err", err)
+			return 1
+		}
+		return 0
+	}
+	if err := writeExportFile(output, format, rows); err != nil {
+		errLogger.Error("write export failed", "path", output, "err", err)
+		return 1
+	}
+	report := exportReport{Output: output, Format: format, Rows: len(rows) - 1, Columns: exportHeader(rows)}
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	return 0
+}
+
+// exportRows reads the matching records of one table into rows, header
+// first.
+func exportRows(ctx context.Context, tc *tableClient, opts ExportOptions) ([][]any, error) {
+	schema, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		return nil, fmt.Errorf("list fields: %w", err)
+	}
+	columns := exportColumns(tc.fields, common.FieldsByName(schema))
+
+	body := map[string]any{}
+	filterObj, err := applyDateRange(ctx, tc.baseURL, tc.token, tc.ref, tc.fields, opts.DateFrom, opts.DateTo,
+		buildFilter(tc.fields, opts.App, opts.Scene, opts.Status, opts.Date))
+	if err != nil {
+		return nil, err
+	}
+	if filterObj, err = compileWhere(opts.Where, tc.fields, filterObj); err != nil {
+		return nil, err
+	}
+	if filterObj != nil {
+		body["filter"] = filterObj
+	}
+	viewID := strings.TrimSpace(opts.ViewID)
+	if viewID == "" {
+		viewID = tc.ref.ViewID
+	}
+	if !opts.IgnoreView && viewID != "" {
+		body["view_id"] = viewID
+	}
+
+	header := make([]any, 0, len(columns)+1)
+	header = append(header, "record_id")
+	for _, c := range columns {
+		header = append(header, c.Name)
+	}
+	rows := [][]any{header}
+	loc := common.TaskTimezone()
+	err = scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		row := make([]any, 0, len(columns)+1)
+		row = append(row, common.BitableValueToString(item["record_id"]))
+		for _, c := range columns {
+			row = append(row, exportValue(c, fieldsRaw[c.Name], loc))
+		}
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan tasks: %w", err)
+	}
+	return rows, nil
+}
+
+func exportHeader(rows [][]any) []string {
+	out := []string{}
+	if len(rows) == 0 {
+		return out
+	}
+	for _, h := range rows[0] {
+		out = append(out, fmt.Sprint(h))
+	}
+	return out
+}
+
+func writeExport(w io.Writer, format string, rows [][]any) error {
+	if format == "xlsx" {
+		return common.WriteXLSX(w, "Tasks", rows)
+	}
+	return writeCSV(w, rows)
+}
+
+func writeExportFile(path, format string, rows [][]any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeExport(f, format, rows); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportManifest describes a multi-table export written by --dir.
+type exportManifest struct {
+	StartedAt      string         `json:"started_at"`
+	Format         string         `json:"format"`
+	Filters        exportFilters  `json:"filters"`
+	Sources        []exportSource `json:"sources"`
+	Failed         int            `json:"failed"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+}
+
+type exportFilters struct {
+	App    string `json:"app,omitempty"`
+	Scene  string `json:"scene,omitempty"`
+	Status string `json:"status,omitempty"`
+	Date   string `json:"date,omitempty"`
+}
+
+// exportSource is one table of a multi-table export.
+type exportSource struct {
+	TaskURL    string   `json:"task_url"`
+	AppToken   string   `json:"app_token,omitempty"`
+	TableID    string   `json:"table_id,omitempty"`
+	File       string   `json:"file,omitempty"`
+	Rows       int      `json:"rows"`
+	Columns    []string `json:"columns,omitempty"`
+	SHA256     string   `json:"sha256,omitempty"`
+	ExportedAt string   `json:"exported_at,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// exportToDir exports every table URL into opts.Dir, Parallel tables at a
+// time, one <app_token>_<table_id> file each, and writes manifest.json
+// describing each source. The manifest is printed as the report.
+func exportToDir(ctx context.Context, opts ExportOptions, format string) int {
+	dir := strings.TrimSpace(opts.Dir)
+	if dir == "" {
+		errLogger.Error("--dir is required when exporting several tables")
+		return 2
+	}
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, u := range opts.TaskURLs {
+		if u = strings.TrimSpace(u); u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 && strings.TrimSpace(opts.TaskURL) != "" {
+		urls = append(urls, strings.TrimSpace(opts.TaskURL))
+	}
+	if len(urls) == 0 {
+		errLogger.Error("at least one --task-url is required")
+		return 2
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		errLogger.Error("create export dir failed", "dir", dir, "err", err)
+		return 2
+	}
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = 4
+	}
+
+	start := time.Now()
+	manifest := exportManifest{
+		StartedAt: start.UTC().Format(time.RFC3339),
+		Format:    format,
+		Filters:   exportFilters{App: opts.App, Scene: opts.Scene, Status: opts.Status, Date: opts.Date},
+		Sources:   make([]exportSource, len(urls)),
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			manifest.Sources[i] = exportSourceToDir(ctx, opts, format, dir, u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	files := map[string]bool{}
+	for i, src := range manifest.Sources {
+		if src.Error == "" && files[src.File] {
+			// Two URLs naming the same table (e.g. different views) would
+			// overwrite one file; keep the first and flag the rest.
+			manifest.Sources[i].Error = "duplicate table; file already written by an earlier source"
+		}
+		files[src.File] = true
+		if manifest.Sources[i].Error != "" {
+			manifest.Failed++
+			errLogger.Error("export table failed", "task_url", src.TaskURL, "err", manifest.Sources[i].Error)
+		}
+	}
+	manifest.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		errLogger.Error("encode manifest failed", "err", err)
+		return 1
+	}
+	manifestPath := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0o644); err != nil {
+		errLogger.Error("write manifest failed", "path", manifestPath, "err", err)
+		return 1
+	}
+	printJSON(manifest)
+	if manifest.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// exportSourceToDir exports one table into dir and describes the result.
+func exportSourceToDir(ctx context.Context, opts ExportOptions, format, dir, taskURL string) exportSource {
+	src := exportSource{TaskURL: taskURL}
+	tc, code := openTable(ctx, taskURL)
+	if tc == nil {
+		src.Error = fmt.Sprintf("open table failed (exit %d); see the error log", code)
+		return src
+	}
+	src.AppToken = tc.ref.AppToken
+	src.TableID = tc.ref.TableID
+	src.File = fmt.Sprintf("%s_%s.%s", tc.ref.AppToken, tc.ref.TableID, format)
+
+	rows, err := exportRows(ctx, tc, opts)
+	if err != nil {
+		src.Error = err.Error()
+		return src
+	}
+	src.ExportedAt = time.Now().UTC().Format(time.RFC3339)
+	var buf bytes.Buffer
+	if err := writeExport(&buf, format, rows); err != nil {
+		src.Error = err.Error()
+		return src
+	}
+	if err := os.WriteFile(filepath.Join(dir, src.File), buf.Bytes(), 0o644); err != nil {
+		src.Error = err.Error()
+		return src
+	}
+	src.Rows = len(rows) - 1
+	src.Columns = exportHeader(rows)
+	src.SHA256 = sha256Hex(buf.Bytes())
+	return src
+}
+
+// exportColumns lists the mapped task columns that exist in the table, in
+// taskSchema order, each column once.
+func exportColumns(fields map[string]string, byName map[string]common.FieldInfo) []exportColumn {
+	out := []exportColumn{}
+	seen := map[string]bool{}
+	for _, sf := range taskSchema {
+		col := strings.TrimSpace(fields[sf.Logical])
+		if col == "" || seen[col] {
+			continue
+		}
+		f, ok := byName[col]
+		if !ok {
+			continue
+		}
+		seen[col] = true
+		out = append(out, exportColumn{Logical: sf.Logical, Name: col, Type: f.Type})
+	}
+	return out
+}
+
+// exportTimestamps are the logical fields holding epoch milliseconds.
+var exportTimestamps = map[string]bool{"DispatchedAt": true, "HeartbeatAt": true, "StartAt": true, "EndAt": true, "TouchedAt": true}
+
+// exportValue renders one cell: numbers stay numeric, Date becomes
+// "YYYY-MM-DD" and timestamps "YYYY-MM-DD HH:MM:SS" in loc, attachments list
+// their file names, and everything else is text.
+func exportValue(c exportColumn, v any, loc *time.Location) any {
+	if v == nil {
+		return ""
+	}
+	if c.Type != common.FieldTypeText && (c.Logical == "Date" || exportTimestamps[c.Logical]) {
+		if ms, ok := common.CoerceMillis(v); ok && ms > 0 {
+			t := time.UnixMilli(ms).In(loc)
+			if c.Logical == "Date" {
+				return t.Format("2006-01-02")
+			}
+			return t.Format("2006-01-02 15:04:05")
+		}
+	}
+	switch c.Type {
+	case common.FieldTypeNumber, common.FieldTypeAutoNumber:
+		if n, ok := common.ParseInteger(common.BitableValueToString(v)); ok {
+			return n
+		}
+		if f, ok := statsNumber(v); ok {
+			return f
+		}
+	case common.FieldTypeAttachment:
+		list, _ := v.([]any)
+		names := make([]string, 0, len(list))
+		for _, it := range list {
+			if m, ok := it.(map[string]any); ok {
+				if name := common.BitableValueToString(m["name"]); name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+		return strings.Join(names, ",")
+	}
+	return common.BitableValueToString(v)
+}
+
+// writeCSV writes rows as UTF-8 CSV with a BOM so Excel detects the
+// encoding of Chinese scene names.
+func writeCSV(w io.Writer, rows [][]any) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	record := []string{}
+	for _, row := range rows {
+		record = record[:0]
+		for _, v := range row {
+			switch x := v.(type) {
+			case float64:
+				record = append(record, strconv.FormatFloat(x, 'f', -1, 64))
+			default:
+				record = append(record, fmt.Sprint(x))
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// defaultExtraMaxChars is the longest Extra value written to one text
+// cell; Bitable rejects longer cell text.
+const defaultExtraMaxChars = 100000
+
+// extraRefName is the file name of Extra payloads uploaded to Drive.
+const extraRefName = "extra.json"
+
+// extraRef is the pointer stored in Extra when the payload was uploaded.
+type extraRef struct {
+	FileToken string `json:"file_token"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+// extraCodec splits oversized Extra values on write and reassembles them on
+// read. A value longer than max characters is split across the overflow
+// columns (BITABLE_EXTRA_OVERFLOW, comma-separated, in order) when they
+// can hold it; otherwise it is uploaded as a Drive JSON file and Extra holds
+// {"extra_ref": {...}}.
+type extraCodec struct {
+	baseURL  string
+	token    string
+	ref      common.BitableRef
+	col      string
+	overflow []string
+	max      int
+}
+
+func newExtraCodec(baseURL, token string, ref common.BitableRef, fieldsMap map[string]string) *extraCodec {
+	x := &extraCodec{
+		baseURL: baseURL,
+		token:   token,
+		ref:     ref,
+		col:     strings.TrimSpace(fieldsMap["Extra"]),
+		max:     defaultExtraMaxChars,
+	}
+	for _, c := range strings.Split(common.Env("BITABLE_EXTRA_OVERFLOW", ""), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			x.overflow = append(x.overflow, c)
+		}
+	}
+	if n, ok := common.CoerceInt(common.Env("BITABLE_EXTRA_MAX_CHARS", "")); ok && n > 0 {
+		x.max = n
+	}
+	return x
+}
+
+// encode rewrites the Extra value in fields so every cell fits. Overflow
+// columns are always rewritten alongside Extra so a shorter value does not
+// pick up stale chunks.
+func (x *extraCodec) encode(ctx context.Context, fields map[string]any) error {
+	if x == nil || x.col == "" {
+		return nil
+	}
+	v, ok := fields[x.col]
+	if !ok {
+		return nil
+	}
+	s, isText := v.(string)
+	if !isText {
+		return nil
+	}
+	for _, c := range x.overflow {
+		fields[c] = ""
+	}
+	if utf8.RuneCountInString(s) <= x.max {
+		return nil
+	}
+
+	chunks := splitRunes(s, x.max)
+	if len(chunks)-1 <= len(x.overflow) {
+		fields[x.col] = chunks[0]
+		for i, chunk := range chunks[1:] {
+			fields[x.overflow[i]] = chunk
+		}
+		return nil
+	}
+
+	data := []byte(s)
+	fileToken, err := common.UploadMedia(ctx, x.baseURL, x.token, common.MediaParentBitableFile, x.ref.AppToken, extraRefName, data)
+	if err != nil {
+		return fmt.Errorf("upload oversized Extra (%d bytes): %w", len(data), err)
+	}
+	ptr, err := json.Marshal(map[string]extraRef{"extra_ref": {FileToken: fileToken, Size: int64(len(data)), SHA256: sha256Hex(data)}})
+	if err != nil {
+		return err
+	}
+	fields[x.col] = string(ptr)
+	return nil
+}
+
+// decode returns the full Extra value of a record: Extra followed by its
+// overflow columns, with an uploaded payload downloaded and verified.
+func (x *extraCodec) decode(ctx context.Context, fieldsRaw map[string]any) (string, error) {
+	if x == nil || x.col == "" {
+		return "", nil
+	}
+	var b strings.Builder
+	b.WriteString(common.NormalizeBitableValue(fieldsRaw[x.col]))
+	for _, c := range x.overflow {
+		b.WriteString(common.NormalizeBitableValue(fieldsRaw[c]))
+	}
+	s := strings.TrimSpace(b.String())
+
+	ref, ok := parseExtraRef(s)
+	if !ok {
+		return s, nil
+	}
+	data, err := common.DownloadMedia(ctx, x.baseURL, x.token, ref.FileToken, x.ref.TableID)
+	if err != nil {
+		return s, fmt.Errorf("download Extra %s: %w", ref.FileToken, err)
+	}
+	if err := verifyArtifact(artifact{Size: ref.Size, SHA256: ref.SHA256}, data); err != nil {
+		return s, fmt.Errorf("Extra %s: %w", ref.FileToken, err)
+	}
+	return string(data), nil
+}
+
+// decodeTask replaces the task's Extra with its reassembled value. A
+// payload that cannot be restored keeps the pointer and is logged.
+func (x *extraCodec) decodeTask(ctx context.Context, t *Task, fieldsRaw map[string]any) {
+	if x == nil || x.col == "" || (len(x.overflow) == 0 && !strings.Contains(t.Extra, `"extra_ref"`)) {
+		return
+	}
+	extra, err := x.decode(ctx, fieldsRaw)
+	if err != nil {
+		errLogger.Warn("restore Extra failed", taskAttrs(t.RecordID, t.TraceID, "err", err)...)
+	}
+	t.Extra = extra
+}
+
+func parseExtraRef(s string) (extraRef, bool) {
+	if !strings.HasPrefix(s, "{") || !strings.Contains(s, `"extra_ref"`) {
+		return extraRef{}, false
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &m); err != nil || len(m) != 1 {
+		return extraRef{}, false
+	}
+	var ref extraRef
+	if err := json.Unmarshal(m["extra_ref"], &ref); err != nil || ref.FileToken == "" {
+		return extraRef{}, false
+	}
+	return ref, true
+}
+
+// splitRunes cuts s into pieces of at most n characters without splitting
+// a UTF-8 sequence.
+func splitRunes(s string, n int) []string {
+	out := []string{}
+	for s != "" {
+		i, count := 0, 0
+		for i < len(s) && count < n {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+			count++
+		}
+		out = append(out, s[:i])
+		s = s[i:]
+	}
+	return out
+}
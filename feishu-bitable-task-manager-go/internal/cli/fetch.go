@@ -1,8 +1,8 @@
 package cli
 
 import (
-	"fmt"
-	"net/url"
+	"context"
+	"os"
 	"strings"
 	"time"
 
@@ -15,6 +15,8 @@ type searchResp struct {
 		Items     []map[string]any `json:"items"`
 		HasMore   bool             `json:"has_more"`
 		PageToken string           `json:"page_token"`
+		// Total is the number of records matching the search, when reported.
+		Total *int `json:"total"`
 	} `json:"data"`
 }
 
@@ -22,10 +24,13 @@ type pageInfo struct {
 	HasMore       bool   `json:"has_more"`
 	NextPageToken string `json:"next_page_token"`
 	Pages         int    `json:"pages"`
+	// Slices is the number of windows of a sliced fetch (--slice-by).
+	Slices int `json:"slices,omitempty"`
 }
 
 type fetchOutput struct {
-	Tasks          []Task   `json:"tasks"`
+	// Tasks is []Task, or []projectedTask with --fields.
+	Tasks          any      `json:"tasks"`
 	Count          int      `json:"count"`
 	ElapsedSeconds float64  `json:"elapsed_seconds"`
 	PageInfo       pageInfo `json:"page_info"`
@@ -43,10 +48,56 @@ type FetchOptions struct {
 	IgnoreView bool
 	ViewID     string
 	JSONL      bool
-	Raw        bool
+	// OutputFormat is json, jsonl, table, csv, yaml or go-template=...
+	// (see parseRowTemplate); JSONL implies jsonl.
+	OutputFormat string
+	Raw          bool
+	// IncludeDeleted also returns soft-deleted tasks.
+	IncludeDeleted bool
+
+	LeaseTimeout time.Duration
+	// Prefetch is how many pages are fetched ahead of decoding.
+	Prefetch int
+	// Transform pipes the fetched tasks through an external command.
+	Transform string
+	// Filters are --filter specs on any column, see parseFieldFilter.
+	Filters []string
+	// Where is a --where expression, see compileWhere.
+	Where string
+	// DateFrom and DateTo bound the Date column, see parseDateBound.
+	DateFrom string
+	DateTo   string
+	// Sort is a --sort spec, see parseSortSpec.
+	Sort string
+	// Fields limits the requested columns and the output to these logical
+	// fields, see parseTaskProjection.
+	Fields string
+	// SliceBy (date or created) fetches the DateFrom..DateTo range as
+	// consecutive SliceSize windows, see fetchSlices.
+	SliceBy   string
+	SliceSize time.Duration
+	// Count prints only the number of matching records, see countRecords.
+	Count bool
+	// MaxAge leaves out tasks older than this, see ageWindow; Expire also
+	// marks the pending ones among them expired.
+	MaxAge time.Duration
+	Expire bool
+
+	Preset     string
+	StuckAfter time.Duration
+	conds      []filterCond
+}
+
+// filterCond is an extra search condition on a logical task field, or on
+// Column verbatim when Field is empty.
+type filterCond struct {
+	Field    string
+	Column   string
+	Operator string
+	Value    []string
 }
 
-func buildFilter(fields map[string]string, app, scene, status, datePreset string) map[string]any {
+func buildFilter(fields map[string]string, app, scene, status, datePreset string, extra ...filterCond) map[string]any {
 	conds := []map[string]any{}
 	add := func(fieldKey, value string) {
 		name := strings.TrimSpace(fields[fieldKey])
@@ -61,12 +112,51 @@ func buildFilter(fields map[string]string, app, scene, status, datePreset string
 	if datePreset != "" && datePreset != "Any" {
 		add("Date", datePreset)
 	}
+	for _, c := range extra {
+		name := strings.TrimSpace(fields[c.Field])
+		if c.Field == "" {
+			name = strings.TrimSpace(c.Column)
+		}
+		if name == "" {
+			continue
+		}
+		value := c.Value
+		if value == nil {
+			value = []string{}
+		}
+		conds = append(conds, map[string]any{"field_name": name, "operator": c.Operator, "value": value})
+	}
 	if len(conds) == 0 {
 		return nil
 	}
 	return map[string]any{"conjunction": "and", "conditions": conds}
 }
 
+// isSoftDeleted reports whether the record's Deleted checkbox is set (see
+// delete --soft). Tables without the column have no soft-deleted rows.
+func isSoftDeleted(fieldsRaw map[string]any, mapping map[string]string) bool {
+	col := strings.TrimSpace(mapping["Deleted"])
+	if col == "" {
+		return false
+	}
+	switch strings.ToLower(common.BitableValueToString(fieldsRaw[col])) {
+	case "true", "1":
+		return true
+	}
+	return false
+}
+
+// notDeletedFilter returns the search condition that leaves soft-deleted
+// records out on the server, so they never count toward --limit. Tables
+// without the Deleted column need none.
+func notDeletedFilter(ctx context.Context, baseURL, token string, ref common.BitableRef, mapping map[string]string) []filterCond {
+	col := strings.TrimSpace(mapping["Deleted"])
+	if col == "" || !tableHasColumn(ctx, baseURL, token, ref, col) {
+		return nil
+	}
+	return []filterCond{{Column: col, Operator: "isNot", Value: []string{"true"}}}
+}
+
 func decodeTask(fieldsRaw map[string]any, mapping map[string]string) (Task, bool) {
 	if len(fieldsRaw) == 0 {
 		return Task{}, false
@@ -99,19 +189,25 @@ func decodeTask(fieldsRaw map[string]any, mapping map[string]string) (Task, bool
 		DeviceSerial:     get("DeviceSerial"),
 		DispatchedDevice: get("DispatchedDevice"),
 		DispatchedAt:     get("DispatchedAt"),
+		HeartbeatAt:      get("HeartbeatAt"),
 		StartAt:          get("StartAt"),
 		EndAt:            get("EndAt"),
 		ElapsedSeconds:   get("ElapsedSeconds"),
 		ItemsCollected:   get("ItemsCollected"),
 		RetryCount:       get("RetryCount"),
+		Artifacts:        get("Artifacts"),
+		AttemptToken:     get("AttemptToken"),
+		TraceID:          get("TraceID"),
 	}
+	decodePersonFields(&t, fieldsRaw, mapping)
 	if t.Params == "" && t.ItemID == "" && t.BookID == "" && t.URL == "" && t.UserID == "" && t.UserName == "" {
 		return Task{}, false
 	}
+	applyLegacyRead(&t)
 	return t, true
 }
 
-func FetchTasks(opts FetchOptions) int {
+func FetchTasks(ctx context.Context, opts FetchOptions) int {
 	taskURL := strings.TrimSpace(opts.TaskURL)
 	if taskURL == "" {
 		errLogger.Error("TASK_BITABLE_URL is required")
@@ -131,25 +227,128 @@ func FetchTasks(opts FetchOptions) int {
 		return 2
 	}
 	fields := common.LoadTaskFieldsFromEnv()
-	filterObj := buildFilter(fields, opts.App, opts.Scene, opts.Status, opts.Date)
+	conds := append([]filterCond{}, opts.conds...)
+	for _, spec := range opts.Filters {
+		cond, err := parseFieldFilter(spec, fields)
+		if err != nil {
+			errLogger.Error("invalid --filter", "err", err)
+			return 2
+		}
+		conds = append(conds, cond)
+	}
+	if _, err := compileWhere(opts.Where, fields, nil); err != nil {
+		errLogger.Error("invalid --where", "err", err)
+		return 2
+	}
+	sortSpec, err := parseSortSpec(opts.Sort, fields)
+	if err != nil {
+		errLogger.Error("invalid --sort", "err", err)
+		return 2
+	}
+	projection, err := parseTaskProjection(opts.Fields)
+	if err != nil {
+		errLogger.Error("invalid --fields", "err", err)
+		return 2
+	}
+	sliceBy, err := parseSliceBy(opts.SliceBy)
+	if err != nil {
+		errLogger.Error("invalid --slice-by", "err", err)
+		return 2
+	}
+	sliceSize := opts.SliceSize
+	if sliceSize == 0 {
+		sliceSize = defaultSliceSize
+	}
+	if sliceBy != "" && (strings.TrimSpace(opts.DateFrom) == "" || sliceSize < 0) {
+		errLogger.Error("--slice-by needs --date-from and a positive --slice-size", "slice_size", sliceSize)
+		return 2
+	}
+	format := opts.OutputFormat
+	if strings.TrimSpace(format) == "" {
+		format = outputJSON
+		if opts.JSONL {
+			format = outputJSONL
+		}
+	}
+	rowTemplate, err := parseRowTemplate(strings.TrimSpace(format))
+	if err != nil {
+		errLogger.Error("invalid go-template", "err", err)
+		return 2
+	}
+	if rowTemplate != nil {
+		format = outputTemplate
+	} else if format, err = parseOutputFormat(format, outputJSON, outputJSONL, outputTable, outputCSV, outputYAML); err != nil {
+		errLogger.Error("invalid output format", "err", err)
+		return 2
+	}
+	if opts.JSONL && format != outputJSONL {
+		errLogger.Error("--jsonl conflicts with --output-format", "output_format", format)
+		return 2
+	}
+	if opts.Count && (format == outputJSONL || opts.Raw || projection != nil || strings.TrimSpace(opts.Transform) != "") {
+		errLogger.Error("--count cannot be combined with --jsonl, --raw, --fields or --transform")
+		return 2
+	}
 
-	token, err := common.GetTenantAccessToken(baseURL, appID, appSecret)
+	token, err := common.GetTenantAccessToken(ctx, baseURL, appID, appSecret)
 	if err != nil {
 		errLogger.Error("get tenant access token failed", "err", err)
-		return 2
+		return exitCodeFor(err, exitUsage)
 	}
 	if ref.AppToken == "" {
 		if ref.WikiToken == "" {
 			errLogger.Error("bitable URL missing app_token and wiki_token")
 			return 2
 		}
-		appToken, err := common.ResolveWikiAppToken(baseURL, token, ref.WikiToken)
+		appToken, err := common.ResolveWikiAppToken(ctx, baseURL, token, ref.WikiToken)
 		if err != nil {
 			errLogger.Error("resolve wiki app token failed", "err", err)
-			return 2
+			return exitCodeFor(err, exitUsage)
 		}
 		ref.AppToken = appToken
 	}
+	if !opts.IncludeDeleted {
+		conds = append(conds, notDeletedFilter(ctx, baseURL, token, ref, fields)...)
+	}
+	baseFilter := buildFilter(fields, opts.App, opts.Scene, opts.Status, opts.Date, conds...)
+	var slices []timeSlice
+	var filters []map[string]any
+	if sliceBy == "" {
+		filterObj, err := applyDateRange(ctx, baseURL, token, ref, fields, opts.DateFrom, opts.DateTo, baseFilter)
+		if err != nil {
+			errLogger.Error("invalid date range", "err", err)
+			return exitCodeFor(err, exitUsage)
+		}
+		filters = []map[string]any{filterObj}
+	} else if slices, filters, err = fetchSlices(ctx, baseURL, token, ref, fields, sliceBy, opts.DateFrom, opts.DateTo, sliceSize, baseFilter); err != nil {
+		errLogger.Error("invalid date range", "err", err)
+		return exitCodeFor(err, exitUsage)
+	}
+	for i := range filters {
+		if filters[i], err = compileWhere(opts.Where, fields, filters[i]); err != nil {
+			errLogger.Error("invalid --where", "err", err)
+			return 2
+		}
+	}
+	if opts.MaxAge > 0 {
+		window, err := resolveAgeWindow(ctx, baseURL, token, ref, fields, opts.MaxAge, time.Now())
+		if err != nil {
+			errLogger.Error("invalid --max-age", "err", err)
+			return exitCodeFor(err, exitUsage)
+		}
+		for i := range filters {
+			if filters[i], err = window.filter(filters[i]); err != nil {
+				errLogger.Error("invalid --max-age", "err", err)
+				return exitCodeFor(err, exitUsage)
+			}
+		}
+		if opts.Expire {
+			if _, err := expireStaleTasks(ctx, baseURL, token, ref, fields, window, opts.App, opts.Scene, opts.Date); err != nil {
+				errLogger.Error("expire stale tasks failed", "err", err)
+				return exitCodeFor(err, exitUsage)
+			}
+		}
+	}
 
 	viewID := strings.TrimSpace(opts.ViewID)
 	if viewID == "" {
@@ -161,82 +360,186 @@ func FetchTasks(opts FetchOptions) int {
 		pageSize = opts.Limit
 	}
 
-	items := []map[string]any{}
-	pageToken := ""
-	pages := 0
-
-	start := time.Now()
-	for {
-		q := url.Values{}
-		q.Set("page_size", fmt.Sprintf("%d", pageSize))
-		if pageToken != "" {
-			q.Set("page_token", pageToken)
-		}
-		urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/search?%s",
-			strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID, q.Encode(),
-		)
-		var body map[string]any
-		if (!opts.IgnoreView && viewID != "") || filterObj != nil {
-			body = map[string]any{}
-			if !opts.IgnoreView && viewID != "" {
-				body["view_id"] = viewID
-			}
-			if filterObj != nil {
-				body["filter"] = filterObj
-			}
+	extras := newExtraCodec(baseURL, token, ref, fields)
+	var fieldNames []string
+	if projection != nil {
+		schema, err := common.ListFields(ctx, baseURL, token, ref.AppToken, ref.TableID)
+		if err != nil {
+			errLogger.Error("list fields failed", "err", err)
+			return exitCodeFor(err, exitUsage)
 		}
-		var resp searchResp
-		if err := common.RequestJSON("POST", urlStr, token, body, &resp); err != nil {
-			errLogger.Error("search records request failed", "err", err)
-			return 2
+		fieldNames = projection.columns(fields, common.FieldsByName(schema), opts.IncludeDeleted, extras)
+	}
+	bodies := make([]map[string]any, len(filters))
+	for i, filterObj := range filters {
+		if (opts.IgnoreView || viewID == "") && filterObj == nil && len(sortSpec) == 0 && projection == nil {
+			continue
 		}
-		if resp.Code != 0 {
-			errLogger.Error("search records failed", "code", resp.Code, "msg", resp.Msg)
-			return 2
+		body := map[string]any{}
+		if projection != nil {
+			body["field_names"] = fieldNames
 		}
-		items = append(items, resp.Data.Items...)
-		pages++
-		pageToken = strings.TrimSpace(resp.Data.PageToken)
-
-		if opts.Limit > 0 && len(items) >= opts.Limit {
-			items = items[:opts.Limit]
-			break
+		if !opts.IgnoreView && viewID != "" {
+			body["view_id"] = viewID
 		}
-		if opts.MaxPages > 0 && pages >= opts.MaxPages {
-			break
+		if filterObj != nil {
+			body["filter"] = filterObj
 		}
-		if !resp.Data.HasMore || pageToken == "" {
-			break
+		if len(sortSpec) > 0 {
+			body["sort"] = sortSpec
 		}
+		bodies[i] = body
+	}
+	if opts.Count {
+		return countTasks(ctx, opts, baseURL, token, ref, fields, bodies, pageSize, viewID, format, rowTemplate)
 	}
-	elapsed := time.Since(start).Seconds()
 
+	// With --jsonl each task is printed as soon as its page is decoded, so
+	// pipes see data early and memory stays flat. A transformer sees the whole
+	// result, so --transform keeps buffering.
+	streamJSONL := (format == outputJSONL || format == outputTemplate) && strings.TrimSpace(opts.Transform) == ""
 	tasks := []Task{}
-	for _, it := range items {
-		recordID, _ := it["record_id"].(string)
-		fieldsRaw, _ := it["fields"].(map[string]any)
-		t, ok := decodeTask(fieldsRaw, fields)
-		if !ok {
-			continue
+	var templateErr error
+	printTask := func(t Task) {
+		if format == outputJSONL {
+			logger.Info("task", "task", projection.output(t))
+		} else if err := rowTemplate.Execute(os.Stdout, t); err != nil && templateErr == nil {
+			templateErr = err
+			errLogger.Error("render go-template failed", taskAttrs(t.RecordID, t.TraceID, "err", err)...)
 		}
-		t.RecordID = strings.TrimSpace(recordID)
-		if opts.Raw {
-			t.RawFields = fieldsRaw
+	}
+	emit := func(t Task) {
+		if streamJSONL {
+			printTask(t)
+			return
 		}
 		tasks = append(tasks, t)
 	}
+	decodeItems := func(items []map[string]any) {
+		for _, it := range items {
+			recordID, _ := it["record_id"].(string)
+			fieldsRaw, _ := it["fields"].(map[string]any)
+			if !opts.IncludeDeleted && isSoftDeleted(fieldsRaw, fields) {
+				continue
+			}
+			t, ok := decodeTask(fieldsRaw, fields)
+			if !ok {
+				continue
+			}
+			t.RecordID = strings.TrimSpace(recordID)
+			extras.decodeTask(ctx, &t, fieldsRaw)
+			if opts.Raw {
+				t.RawFields = fieldsRaw
+			}
+			emit(t)
+		}
+	}
 
-	if opts.JSONL {
+	// Records are decoded page by page while the next pages download. A
+	// sliced fetch searches each window in turn; --limit and --max-pages
+	// cap the whole fetch.
+	matched := 0
+	pageToken := ""
+	pages := 0
+	slicesLeft := false
+	start := time.Now()
+	for i, body := range bodies {
+		if (opts.Limit > 0 && matched >= opts.Limit) || (opts.MaxPages > 0 && pages >= opts.MaxPages) {
+			slicesLeft = true
+			break
+		}
+		stream, stop := pageStream{
+			BaseURL:  baseURL,
+			Token:    token,
+			Ref:      ref,
+			Body:     body,
+			PageSize: pageSize,
+			MaxPages: remaining(opts.MaxPages, pages),
+			MaxItems: remaining(opts.Limit, matched),
+			Prefetch: opts.Prefetch,
+		}.stream(ctx)
+		for page := range stream {
+			if page.Err != nil {
+				stop()
+				if slices != nil {
+					errLogger.Error("search records failed", "slice_from", slices[i].From, "slice_to", slices[i].To, "err", page.Err)
+				} else {
+					errLogger.Error("search records failed", "err", page.Err)
+				}
+				return exitCodeFor(page.Err, exitUsage)
+			}
+			pages++
+			pageToken = page.PageToken
+			batch := page.Items
+			if opts.Limit > 0 && matched+len(batch) > opts.Limit {
+				batch = batch[:opts.Limit-matched]
+			}
+			matched += len(batch)
+			decodeItems(batch)
+		}
+		stop()
+		if err := ctx.Err(); err != nil {
+			errLogger.Error("search records failed", "err", err)
+			return exitCodeFor(err, exitUsage)
+		}
+	}
+	if opts.LeaseTimeout > 0 && strings.EqualFold(strings.TrimSpace(opts.Status), "pending") &&
+		(opts.Limit <= 0 || matched < opts.Limit) {
+		stale, err := staleLeaseItems(ctx, baseURL, token, ref, fields, opts.App, opts.Scene, opts.Date, opts.LeaseTimeout, pageSize, opts.IgnoreView, viewID)
+		if err != nil {
+			errLogger.Error("search expired leases failed", "err", err)
+			return exitCodeFor(err, exitUsage)
+		}
+		if opts.Limit > 0 && matched+len(stale) > opts.Limit {
+			stale = stale[:opts.Limit-matched]
+		}
+		decodeItems(stale)
+	}
+	elapsed := time.Since(start).Seconds()
+	if streamJSONL {
+		if templateErr != nil {
+			return 1
+		}
+		return 0
+	}
+
+	tasks, err = transformTasks(ctx, opts.Transform, tasks)
+	if err != nil {
+		errLogger.Error("transform tasks failed", "err", err)
+		return 2
+	}
+
+	if format == outputJSONL || format == outputTemplate {
 		for _, t := range tasks {
-			logger.Info("task", "task", t)
+			printTask(t)
+		}
+		if templateErr != nil {
+			return 1
 		}
 		return 0
 	}
+	// A page token only resumes the window it came from, so a sliced fetch
+	// reports has_more without one.
+	info := pageInfo{HasMore: pageToken != "" || slicesLeft, NextPageToken: pageToken, Pages: pages, Slices: len(slices)}
+	if slices != nil {
+		info.NextPageToken = ""
+	}
+	rows := make([]any, 0, len(tasks))
+	for _, t := range tasks {
+		rows = append(rows, projection.output(t))
+	}
+	var outTasks any = tasks
+	if projection != nil {
+		outTasks = rows
+	}
 	out := fetchOutput{
-		Tasks:          tasks,
+		Tasks:          outTasks,
 		Count:          len(tasks),
 		ElapsedSeconds: float64(int(elapsed*1000)) / 1000,
-		PageInfo:       pageInfo{HasMore: pageToken != "", NextPageToken: pageToken, Pages: pages},
+		PageInfo:       info,
+	}
+	if format != outputJSON {
+		return printFormatted(format, out, rows)
 	}
 	logger.Info("tasks", "data", out)
 	return 0
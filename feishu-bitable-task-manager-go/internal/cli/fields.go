@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+type FieldsOptions struct {
+	TaskURL string
+	Format  string
+}
+
+type fieldRow struct {
+	Name     string   `json:"name"`
+	FieldID  string   `json:"field_id"`
+	Type     int      `json:"type"`
+	TypeName string   `json:"type_name"`
+	Primary  bool     `json:"primary,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Logical  []string `json:"logical,omitempty"`
+}
+
+// missingField is a logical Task field whose mapped column does not exist in
+// the table.
+type missingField struct {
+	Logical string `json:"logical"`
+	Column  string `json:"column"`
+	Env     string `json:"env"`
+}
+
+type fieldsReport struct {
+	Fields         []fieldRow     `json:"fields"`
+	Missing        []missingField `json:"missing"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+}
+
+// ListTableFields prints the table schema next to the TASK_FIELD_* mapping:
+// every column with its type, select options and the logical Task fields
+// mapped to it, followed by logical fields whose column is missing.
+func ListTableFields(ctx context.Context, opts FieldsOptions) int {
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	if format != "json" && format != "table" {
+		errLogger.Error("--format must be json or table", "format", opts.Format)
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	start := time.Now()
+	schema, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		errLogger.Error("list fields failed", "err", err)
+		return 1
+	}
+
+	envByLogical := map[string]string{}
+	for env, logical := range common.TaskFieldEnvMap {
+		envByLogical[logical] = env
+	}
+	logicalByColumn := map[string][]string{}
+	for logical, col := range tc.fields {
+		if col = strings.TrimSpace(col); col != "" {
+			logicalByColumn[col] = append(logicalByColumn[col], logical)
+		}
+	}
+
+	report := fieldsReport{Fields: make([]fieldRow, 0, len(schema)), Missing: []missingField{}}
+	for _, f := range schema {
+		logical := logicalByColumn[f.FieldName]
+		sort.Strings(logical)
+		report.Fields = append(report.Fields, fieldRow{
+			Name:     f.FieldName,
+			FieldID:  f.FieldID,
+			Type:     f.Type,
+			TypeName: f.TypeName(),
+			Primary:  f.IsPrimary,
+			Options:  f.SelectOptions(),
+			Logical:  logical,
+		})
+	}
+	byName := common.FieldsByName(schema)
+	for logical, col := range tc.fields {
+		col = strings.TrimSpace(col)
+		if col == "" {
+			continue
+		}
+		if _, ok := byName[col]; !ok {
+			report.Missing = append(report.Missing, missingField{Logical: logical, Column: col, Env: envByLogical[logical]})
+		}
+	}
+	sort.Slice(report.Missing, func(i, j int) bool { return report.Missing[i].Logical < report.Missing[j].Logical })
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	if format == "table" {
+		printFieldsTable(report)
+		return 0
+	}
+	printJSON(report)
+	return 0
+}
+
+func printFieldsTable(report fieldsReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tLOGICAL\tOPTIONS")
+	for _, f := range report.Fields {
+		name := f.Name
+		if f.Primary {
+			name += " *"
+		}
+		logical := strings.Join(f.Logical, ",")
+		if logical == "" {
+			logical = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, f.TypeName, logical, strings.Join(f.Options, ","))
+	}
+	w.Flush()
+	if len(report.Missing) == 0 {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MISSING\tCOLUMN\tOVERRIDE")
+	for _, m := range report.Missing {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Logical, m.Column, m.Env)
+	}
+	w.Flush()
+}
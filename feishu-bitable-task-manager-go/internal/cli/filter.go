@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// filterOperators maps --filter operator names to Bitable search operators.
+// Unary operators take no value.
+var filterOperators = map[string]string{
+	"is":           "is",
+	"is_not":       "isNot",
+	"contains":     "contains",
+	"not_contains": "doesNotContain",
+	"is_empty":     "isEmpty",
+	"is_not_empty": "isNotEmpty",
+	"gt":           "isGreater",
+	"gte":          "isGreaterEqual",
+	"lt":           "isLess",
+	"lte":          "isLessEqual",
+}
+
+var unaryFilterOperators = map[string]bool{"is_empty": true, "is_not_empty": true}
+
+func filterOperatorNames() []string {
+	names := make([]string, 0, len(filterOperators))
+	for name := range filterOperators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFieldFilter parses one --filter spec into a search condition:
+//
+//	Name=value          is
+//	Name!=value         is_not
+//	Name:op=value       any operator, e.g. Logs:contains=timeout
+//	Name:op             is_empty / is_not_empty
+//
+// Name is a logical task field (resolved through TASK_FIELD_*) or, failing
+// that, a column name taken verbatim.
+func parseFieldFilter(spec string, fields map[string]string) (filterCond, error) {
+	spec = strings.TrimSpace(spec)
+	left, value, hasValue := strings.Cut(spec, "=")
+	op := "is"
+	if hasValue && strings.HasSuffix(left, "!") {
+		left, op = strings.TrimSuffix(left, "!"), "is_not"
+	} else if i := strings.LastIndex(left, ":"); i >= 0 {
+		left, op = left[:i], strings.ToLower(strings.TrimSpace(left[i+1:]))
+	}
+	name := strings.TrimSpace(left)
+	if name == "" {
+		return filterCond{}, fmt.Errorf("filter %q: missing field name", spec)
+	}
+	operator, ok := filterOperators[op]
+	if !ok {
+		return filterCond{}, fmt.Errorf("filter %q: unknown operator %q (valid: %s)", spec, op, strings.Join(filterOperatorNames(), ", "))
+	}
+	if unaryFilterOperators[op] == hasValue {
+		if hasValue {
+			return filterCond{}, fmt.Errorf("filter %q: %s takes no value", spec, op)
+		}
+		return filterCond{}, fmt.Errorf("filter %q: %s needs a value (Name:%s=value)", spec, op, op)
+	}
+
+	cond := filterCond{Operator: operator}
+	if strings.TrimSpace(fields[name]) != "" {
+		cond.Field = name
+	} else {
+		cond.Column = name
+	}
+	if hasValue {
+		cond.Value = []string{strings.TrimSpace(value)}
+	}
+	return cond, nil
+}
+
+// parseSortSpec parses --sort "Field:asc,Other:desc" into the sort list of
+// a records search; the direction defaults to asc. Names resolve like
+// --filter.
+func parseSortSpec(spec string, fields map[string]string) ([]map[string]any, error) {
+	out := []map[string]any{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, dir := part, "asc"
+		if i := strings.LastIndex(part, ":"); i >= 0 {
+			name, dir = strings.TrimSpace(part[:i]), strings.ToLower(strings.TrimSpace(part[i+1:]))
+		}
+		if name == "" {
+			return nil, fmt.Errorf("sort %q: missing field name", part)
+		}
+		if dir != "asc" && dir != "desc" {
+			return nil, fmt.Errorf("sort %q: direction must be asc or desc", part)
+		}
+		if col := strings.TrimSpace(fields[name]); col != "" {
+			name = col
+		}
+		out = append(out, map[string]any{"field_name": name, "desc": dir == "desc"})
+	}
+	return out, nil
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+// leaseStatuses are the states in which a task is held by a worker and its
+// lease can expire.
+var leaseStatuses = []string{string(taskmodel.StatusDispatched), string(taskmodel.StatusRunning)}
+
+var errLeaseLost = errors.New("lease lost: attempt token no longer matches")
+
+type HeartbeatOptions struct {
+	TaskURL      string
+	RecordID     string
+	TaskID       int
+	BizTaskID    string
+	AttemptToken string
+	Interval     time.Duration
+	Jitter       jitter
+}
+
+// heartbeater refreshes the HeartbeatAt field of one claimed task.
+type heartbeater struct {
+	tc           *tableClient
+	recordID     string
+	attemptToken string
+}
+
+// beat writes HeartbeatAt=now. When an attempt token is set and the record
+// no longer carries it, beat returns errLeaseLost and writes nothing.
+func (h *heartbeater) beat(ctx context.Context) (int64, error) {
+	if h.attemptToken != "" {
+		current, err := h.tc.getRecordFields(ctx, h.recordID)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(common.BitableValueToString(current[h.tc.fields["AttemptToken"]])) != h.attemptToken {
+			return 0, errLeaseLost
+		}
+	}
+	now := time.Now().UnixMilli()
+	if err := h.tc.updateRecord(ctx, h.recordID, map[string]any{h.tc.fields["HeartbeatAt"]: now}); err != nil {
+		return 0, err
+	}
+	return now, nil
+}
+
+func HeartbeatTask(ctx context.Context, opts HeartbeatOptions) int {
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	if strings.TrimSpace(tc.fields["HeartbeatAt"]) == "" {
+		errLogger.Error("HeartbeatAt field is not mapped")
+		return 2
+	}
+	recordID, err := tc.resolveRecordID(ctx, opts.RecordID, opts.TaskID, opts.BizTaskID)
+	if err != nil {
+		errLogger.Error("resolve record failed", "err", err)
+		return 2
+	}
+	h := &heartbeater{tc: tc, recordID: recordID, attemptToken: strings.TrimSpace(opts.AttemptToken)}
+	traceID := ""
+	if col := strings.TrimSpace(tc.fields["TraceID"]); col != "" {
+		if current, err := tc.getRecordFields(ctx, recordID); err == nil {
+			traceID = strings.TrimSpace(common.BitableValueToString(current[col]))
+		}
+	}
+
+	if opts.Interval <= 0 {
+		at, err := h.beat(ctx)
+		if err != nil {
+			errLogger.Error("heartbeat failed", taskAttrs(recordID, traceID, "err", err)...)
+			return 1
+		}
+		logger.Info("heartbeat", taskAttrs(recordID, traceID, "heartbeat_at", at)...)
+		return 0
+	}
+
+	failures := 0
+	for {
+		beatStart := time.Now()
+		at, err := h.beat(ctx)
+		switch {
+		case errors.Is(err, errLeaseLost):
+			errLogger.Error("heartbeat stopped", taskAttrs(recordID, traceID, "err", err)...)
+			return 1
+		case err != nil && ctx.Err() != nil:
+			return 0
+		case err != nil:
+			// A missed beat is not fatal; the lease only expires after
+			// several intervals.
+			failures++
+			errLogger.Warn("heartbeat failed", taskAttrs(recordID, traceID, "err", err)...)
+			common.EmitRetry(ctx, "heartbeat", failures, opts.Interval, err)
+		default:
+			failures = 0
+			logger.Info("heartbeat", taskAttrs(recordID, traceID, "heartbeat_at", at)...)
+		}
+		if !sleepContext(ctx, opts.Jitter.apply(opts.Interval)-time.Since(beatStart)) {
+			return 0
+		}
+	}
+}
+
+// staleLeaseItems returns tasks held by a worker whose last sign of life
+// (HeartbeatAt, else DispatchedAt/StartAt) is older than leaseTimeout. Tasks
+// without any timestamp are left alone. Each lease status is searched with a
+// single page of up to pageSize rows.
+func staleLeaseItems(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]string, app, scene, datePreset string, leaseTimeout time.Duration, pageSize int, ignoreView bool, viewID string) ([]map[string]any, error) {
+	cutoff := time.Now().Add(-leaseTimeout).UnixMilli()
+	out := []map[string]any{}
+	for _, status := range leaseStatuses {
+		filterObj := buildFilter(fields, app, scene, status, datePreset)
+		items, err := searchItems(ctx, baseURL, token, ref, filterObj, pageSize, ignoreView, viewID)
+		if err != nil {
+			return nil, fmt.Errorf("search %s tasks: %w", status, err)
+		}
+		for _, it := range items {
+			fieldsRaw, _ := it["fields"].(map[string]any)
+			if last, ok := lastLeaseActivity(fieldsRaw, fields); ok && last < cutoff {
+				out = append(out, it)
+			}
+		}
+	}
+	return out, nil
+}
+
+func lastLeaseActivity(fieldsRaw map[string]any, fields map[string]string) (int64, bool) {
+	var last int64
+	found := false
+	for _, key := range []string{"HeartbeatAt", "DispatchedAt", "StartAt"} {
+		col := strings.TrimSpace(fields[key])
+		if col == "" {
+			continue
+		}
+		if ms, ok := common.CoerceMillis(fieldsRaw[col]); ok && ms > last {
+			last, found = ms, true
+		}
+	}
+	return last, found
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"strings"
+)
+
+const defaultImportKey = "BizTaskID"
+
+type ImportOptions struct {
+	TaskURL   string
+	InputPath string
+	// Source is a --source such as sheet:<url>, see readSourceItems.
+	Source    string
+	Key       string
+	Transform string
+	// PlanPath writes a plan instead of the table, see writePlan.
+	PlanPath string
+}
+
+// ImportTasks upserts JSON, JSONL, CSV or spreadsheet (--source) records
+// keyed by opts.Key: items whose key matches an existing record update it,
+// the rest are created, and items that would not change their record are
+// skipped. Input keys follow create (logical names, snake_case aliases or
+// column names), so an export CSV can be imported back.
+func ImportTasks(ctx context.Context, opts ImportOptions) int {
+	if strings.TrimSpace(opts.InputPath) == "" && strings.TrimSpace(opts.Source) == "" {
+		errLogger.Error("--input or --source is required")
+		return 2
+	}
+	key := strings.TrimSpace(opts.Key)
+	if key == "" {
+		key = defaultImportKey
+	}
+	return CreateTasks(ctx, CreateOptions{
+		TaskURL:   opts.TaskURL,
+		InputPath: opts.InputPath,
+		Source:    opts.Source,
+		UpsertOn:  key,
+		Transform: opts.Transform,
+		PlanPath:  opts.PlanPath,
+	})
+}
@@ -0,0 +1,425 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// outputEnv prints the proposed mapping as TASK_FIELD_* lines for --env-file.
+const outputEnv = "env"
+
+const (
+	defaultInferSample = 200
+	// inferMinScore is the lowest score proposed: an alias name match, or
+	// a value shape only one field has (status labels, package names, URLs).
+	inferMinScore = 2
+	// inferShapeRatio is the share of non-empty sampled values that must
+	// have a shape for it to count.
+	inferShapeRatio = 0.8
+)
+
+// inferAliases are other column names seen for a logical field, compared
+// after inferName. The logical name itself always matches.
+var inferAliases = map[string][]string{
+	"TaskID":           {"id", "taskno", "任务id", "任务编号", "编号"},
+	"BizTaskID":        {"bizid", "externalid", "extid", "业务id", "业务任务id", "外部id"},
+	"ParentTaskID":     {"parentid", "parent", "父任务", "父任务id"},
+	"App":              {"package", "packagename", "pkg", "appname", "应用", "app名称", "包名"},
+	"Scene":            {"scenario", "type", "tasktype", "场景", "任务类型"},
+	"Params":           {"param", "args", "arguments", "query", "keyword", "参数", "关键词"},
+	"ItemID":           {"item", "itemno", "作品id", "视频id"},
+	"BookID":           {"book", "剧id", "书id", "短剧id"},
+	"URL":              {"link", "href", "address", "链接", "网址", "地址"},
+	"UserID":           {"uid", "user", "authorid", "用户id", "作者id"},
+	"UserName":         {"nickname", "author", "username", "用户名", "昵称", "作者"},
+	"Date":             {"day", "taskdate", "bizdate", "日期", "任务日期"},
+	"Status":           {"state", "taskstatus", "状态", "任务状态"},
+	"RetryCount":       {"retries", "retry", "attempts", "重试次数", "重试"},
+	"GroupID":          {"group", "分组", "分组id"},
+	"DeviceSerial":     {"device", "serial", "deviceid", "udid", "设备", "设备号", "设备序列号"},
+	"DispatchedDevice": {"assigneddevice", "dispatchdevice", "派发设备", "执行设备"},
+	"DispatchedAt":     {"dispatchtime", "dispatched", "assignedat", "派发时间", "分配时间"},
+	"HeartbeatAt":      {"heartbeat", "lastheartbeat", "心跳", "心跳时间"},
+	"StartAt":          {"start", "starttime", "startedat", "begin", "开始时间", "开始"},
+	"EndAt":            {"end", "endtime", "finishedat", "completedat", "结束时间", "完成时间", "结束"},
+	"ElapsedSeconds":   {"elapsed", "duration", "cost", "seconds", "耗时", "用时", "时长"},
+	"ItemsCollected":   {"items", "count", "collected", "采集数", "采集数量", "数量"},
+	"Logs":             {"log", "logurl", "logpath", "日志"},
+	"LogsFile":         {"logfile", "logsfile", "logattachment", "日志文件", "日志附件"},
+	"LastScreenShot":   {"screenshot", "lastscreenshot", "snapshot", "截图", "最后截图"},
+	"Extra":            {"ext", "meta", "metadata", "result", "扩展", "附加信息", "结果"},
+	"Artifacts":        {"artifact", "files", "outputs", "产物", "附件"},
+	"AttemptToken":     {"token", "attempt"},
+	"Fingerprint":      {"hash", "dedupkey", "dedupekey", "指纹"},
+	"TraceID":          {"trace", "traceid", "requestid", "链路id"},
+	"Deleted":          {"isdeleted", "removed", "archived", "已删除", "删除"},
+	"EditLock":         {"lock", "lockedby", "锁", "编辑锁"},
+	"TouchedAt":        {"touched", "touch", "touchtime", "触碰时间"},
+	"Priority":         {"prio", "pri", "level", "优先级"},
+}
+
+var (
+	inferPackageRe = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z0-9_]+)+$`)
+	// inferSerialRe matches adb serials: hex-ish ids and host:port pairs.
+	inferSerialRe = regexp.MustCompile(`^([0-9A-Za-z]{6,24}|[0-9a-z.-]+:\d{2,5}|emulator-\d+)$`)
+)
+
+type InferOptions struct {
+	TaskURL string
+	// Sample is how many records are read (one search page, at most 500).
+	Sample int
+	Format string
+}
+
+// inferProposal maps one logical field to the column that fits it best.
+type inferProposal struct {
+	Logical    string   `json:"logical"`
+	Env        string   `json:"env"`
+	Column     string   `json:"column"`
+	Type       string   `json:"type"`
+	Score      int      `json:"score"`
+	Confidence string   `json:"confidence"`
+	Reasons    []string `json:"reasons"`
+}
+
+type inferReport struct {
+	Sampled         int             `json:"sampled"`
+	Columns         int             `json:"columns"`
+	Proposals       []inferProposal `json:"proposals"`
+	Unmatched       []string        `json:"unmatched_columns"`
+	MissingRequired []string        `json:"missing_required"`
+	ElapsedSeconds  float64         `json:"elapsed_seconds"`
+}
+
+// columnProfile is what infer learned about one column from the sample:
+// how many values were set and the share of them with each shape.
+type columnProfile struct {
+	field  common.FieldInfo
+	values int
+	shapes map[string]float64
+}
+
+// InferFields proposes a TASK_FIELD_* mapping for a table the tool did not
+// create. Every column is scored against every logical field by its name
+// (the logical name or a known alias) and by the shape of sampled values
+// (status labels, timestamps, device serials, URLs, package names), and
+// each field gets the best remaining column. Nothing is written; exit 1
+// when a required field found no column.
+func InferFields(ctx context.Context, opts InferOptions) int {
+	format, err := parseOutputFormat(opts.Format, outputJSON, outputEnv, outputTable, outputYAML)
+	if err != nil {
+		errLogger.Error("invalid output format", "err", err)
+		return 2
+	}
+	sample := opts.Sample
+	if sample <= 0 {
+		sample = defaultInferSample
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	start := time.Now()
+	fields, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		errLogger.Error("list fields failed", "err", err)
+		return 2
+	}
+	items, err := searchItems(ctx, tc.baseURL, tc.token, tc.ref, nil, sample, true, "")
+	if err != nil {
+		errLogger.Error("sample records failed", "err", err)
+		return 2
+	}
+
+	profiles := make([]columnProfile, 0, len(fields))
+	for _, f := range fields {
+		profiles = append(profiles, profileColumn(f, items))
+	}
+	type candidate struct {
+		schema  int
+		column  int
+		score   int
+		reasons []string
+	}
+	candidates := []candidate{}
+	for si, sf := range taskSchema {
+		for ci, p := range profiles {
+			if !sf.accepts(p.field.Type) {
+				continue
+			}
+			score, reasons := scoreColumn(sf, p)
+			if score >= inferMinScore {
+				candidates = append(candidates, candidate{schema: si, column: ci, score: score, reasons: reasons})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if candidates[i].schema != candidates[j].schema {
+			return candidates[i].schema < candidates[j].schema
+		}
+		// Between equally good columns, prefer the one that is filled in.
+		return profiles[candidates[i].column].values > profiles[candidates[j].column].values
+	})
+
+	envByLogical := map[string]string{}
+	for env, logical := range common.TaskFieldEnvMap {
+		envByLogical[logical] = env
+	}
+	takenField := map[int]bool{}
+	takenColumn := map[int]bool{}
+	proposals := []inferProposal{}
+	for _, c := range candidates {
+		if takenField[c.schema] || takenColumn[c.column] {
+			continue
+		}
+		takenField[c.schema] = true
+		takenColumn[c.column] = true
+		sf := taskSchema[c.schema]
+		f := profiles[c.column].field
+		confidence := "low"
+		switch {
+		case c.score >= 4:
+			confidence = "high"
+		case c.score == 3:
+			confidence = "medium"
+		}
+		proposals = append(proposals, inferProposal{
+			Logical:    sf.Logical,
+			Env:        envByLogical[sf.Logical],
+			Column:     f.FieldName,
+			Type:       f.TypeName(),
+			Score:      c.score,
+			Confidence: confidence,
+			Reasons:    c.reasons,
+		})
+	}
+	order := map[string]int{}
+	for i, sf := range taskSchema {
+		order[sf.Logical] = i
+	}
+	sort.Slice(proposals, func(i, j int) bool { return order[proposals[i].Logical] < order[proposals[j].Logical] })
+
+	report := inferReport{
+		Sampled:         len(items),
+		Columns:         len(fields),
+		Proposals:       proposals,
+		Unmatched:       []string{},
+		MissingRequired: []string{},
+	}
+	for ci, p := range profiles {
+		if !takenColumn[ci] {
+			report.Unmatched = append(report.Unmatched, p.field.FieldName)
+		}
+	}
+	for si, sf := range taskSchema {
+		if sf.Required && !takenField[si] {
+			report.MissingRequired = append(report.MissingRequired, sf.Logical)
+		}
+	}
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	if format == outputEnv {
+		printInferEnv(report)
+	} else {
+		rows := make([]any, 0, len(proposals))
+		for _, p := range proposals {
+			rows = append(rows, p)
+		}
+		if out := printFormatted(format, report, rows); out != 0 {
+			return out
+		}
+	}
+	if len(report.MissingRequired) > 0 {
+		errLogger.Warn("no column found for required fields", "fields", strings.Join(report.MissingRequired, ","))
+		return 1
+	}
+	return 0
+}
+
+// inferName folds a column name for alias comparison: lower case, without
+// spaces, underscores, dashes or dots.
+func inferName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '_' || r == '-' || r == '.' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// profileColumn measures the value shapes of one column over the sample.
+func profileColumn(f common.FieldInfo, items []map[string]any) columnProfile {
+	p := columnProfile{field: f, shapes: map[string]float64{}}
+	counts := map[string]int{}
+	for _, it := range items {
+		fieldsRaw, _ := it["fields"].(map[string]any)
+		raw, ok := fieldsRaw[f.FieldName]
+		if !ok || raw == nil {
+			continue
+		}
+		s := strings.TrimSpace(common.BitableValueToString(raw))
+		if s == "" {
+			continue
+		}
+		p.values++
+		if _, ok := canonicalStatus(s); ok {
+			counts["status"]++
+		}
+		if ms, ok := common.CoerceMillis(raw); ok && plausibleMillis(ms) {
+			counts["time"]++
+			if t := time.UnixMilli(ms).In(common.TaskTimezone()); t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+				counts["day"]++
+			}
+		}
+		lower := strings.ToLower(s)
+		if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+			counts["url"]++
+		}
+		if inferPackageRe.MatchString(s) {
+			counts["package"]++
+		}
+		if inferSerialRe.MatchString(s) && strings.ContainsAny(s, "0123456789") && !onlyDigitString(s) {
+			counts["serial"]++
+		}
+		if strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[") {
+			counts["json"]++
+		}
+	}
+	for shape, n := range counts {
+		p.shapes[shape] = float64(n) / float64(p.values)
+	}
+	return p
+}
+
+// plausibleMillis rejects numbers that decode as timestamps but are ids or
+// counters: only 2000-01-01 through 2100-01-01 count.
+func plausibleMillis(ms int64) bool {
+	return ms >= 946684800000 && ms < 4102444800000
+}
+
+func onlyDigitString(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+// scoreColumn rates how well column p fits logical field sf: 4 for the
+// logical name, 2 for an alias, 1 for a name containing an alias, plus up
+// to 2 for column type and value shapes.
+func scoreColumn(sf schemaField, p columnProfile) (int, []string) {
+	score := 0
+	reasons := []string{}
+	name := inferName(p.field.FieldName)
+	switch {
+	case name == inferName(sf.Logical):
+		score += 4
+		reasons = append(reasons, "name")
+	default:
+		for _, a := range inferAliases[sf.Logical] {
+			if name == a {
+				score += 2
+				reasons = append(reasons, "alias "+a)
+				break
+			}
+		}
+		if score == 0 {
+			for _, a := range inferAliases[sf.Logical] {
+				if len([]rune(a)) >= 3 && strings.Contains(name, a) {
+					score++
+					reasons = append(reasons, "name contains "+a)
+					break
+				}
+			}
+		}
+	}
+
+	has := func(shape string) bool { return p.values > 0 && p.shapes[shape] >= inferShapeRatio }
+	shape := 0
+	switch sf.Logical {
+	case "Status":
+		if has("status") {
+			shape, reasons = 2, append(reasons, "status values")
+		} else if known := knownStatusOptions(p.field); known >= 2 {
+			shape, reasons = 2, append(reasons, fmt.Sprintf("%d status options", known))
+		}
+	case "Date":
+		if has("day") || p.field.Type == common.FieldTypeDateTime && p.field.Property["date_formatter"] == "yyyy/MM/dd" {
+			shape, reasons = 1, append(reasons, "date values")
+		}
+	case "DispatchedAt", "StartAt", "EndAt", "HeartbeatAt", "TouchedAt":
+		if p.field.Type == common.FieldTypeDateTime || has("time") {
+			shape, reasons = 1, append(reasons, "timestamp values")
+		}
+	case "DeviceSerial", "DispatchedDevice":
+		if has("serial") {
+			shape, reasons = 1, append(reasons, "device serial values")
+		}
+	case "URL":
+		if p.field.Type == common.FieldTypeURL || has("url") {
+			shape, reasons = 2, append(reasons, "url values")
+		}
+	case "App":
+		if has("package") {
+			shape, reasons = 2, append(reasons, "package name values")
+		}
+	case "Params", "Extra", "Artifacts":
+		if has("json") {
+			shape, reasons = 1, append(reasons, "json values")
+		}
+	case "TaskID":
+		if p.field.Type == common.FieldTypeAutoNumber {
+			shape, reasons = 2, append(reasons, "auto number")
+		}
+	case "LastScreenShot", "LogsFile":
+		shape, reasons = 1, append(reasons, "attachment")
+	case "Deleted":
+		shape, reasons = 1, append(reasons, "checkbox")
+	}
+	// A shape alone proposes a column only when it is distinctive; generic
+	// ones (any timestamp, any checkbox) need a name hint too.
+	if score == 0 && shape < 2 {
+		return 0, nil
+	}
+	return score + shape, reasons
+}
+
+// knownStatusOptions counts the select options of f that are known statuses.
+func knownStatusOptions(f common.FieldInfo) int {
+	n := 0
+	for _, o := range f.SelectOptions() {
+		if _, ok := canonicalStatus(o); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// printInferEnv prints proposals as TASK_FIELD_* lines loadable with
+// --env-file. Fields whose column already has the default name need no
+// line; they are listed as comments.
+func printInferEnv(report inferReport) {
+	fmt.Fprintf(os.Stdout, "# bitable-task infer: %d columns, %d records sampled\n", report.Columns, report.Sampled)
+	for _, p := range report.Proposals {
+		comment := fmt.Sprintf("# %s: %s (%s)", p.Logical, p.Confidence, strings.Join(p.Reasons, ", "))
+		if p.Column == p.Logical || p.Env == "" {
+			fmt.Fprintf(os.Stdout, "%s, default name\n", comment)
+			continue
+		}
+		fmt.Fprintln(os.Stdout, comment)
+		fmt.Fprintf(os.Stdout, "%s=%s\n", p.Env, strconv.Quote(p.Column))
+	}
+	for _, logical := range report.MissingRequired {
+		fmt.Fprintf(os.Stdout, "# %s: no column found (required)\n", logical)
+	}
+}
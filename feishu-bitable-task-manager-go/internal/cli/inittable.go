@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+type InitTableOptions struct {
+	TaskURL string
+	Name    string
+	DryRun  bool
+}
+
+type typeMismatch struct {
+	Logical string `json:"logical"`
+	Column  string `json:"column"`
+	Want    string `json:"want"`
+	Got     string `json:"got"`
+}
+
+type initTableReport struct {
+	TableID        string         `json:"table_id"`
+	CreatedTable   bool           `json:"created_table"`
+	Created        []string       `json:"created"`
+	Existing       []string       `json:"existing"`
+	Mismatched     []typeMismatch `json:"mismatched"`
+	DryRun         bool           `json:"dry_run"`
+	Failed         int            `json:"failed"`
+	Errors         []string       `json:"errors"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+}
+
+// InitTable provisions the canonical task schema (taskSchema) using the
+// column names of the TASK_FIELD_* mapping. With a table in the URL it adds
+// the missing columns to that table; with a base-only URL it creates a new
+// table named opts.Name. Existing columns are never changed: a column with
+// the wrong type is reported in mismatched and the command exits 1.
+func InitTable(ctx context.Context, opts InitTableOptions) int {
+	taskURL := strings.TrimSpace(opts.TaskURL)
+	if taskURL == "" {
+		errLogger.Error("--task-url is required")
+		return 2
+	}
+	tc, code := openBitable(ctx, taskURL, common.ParseBitableBaseURL)
+	if tc == nil {
+		return code
+	}
+	start := time.Now()
+	report := initTableReport{
+		TableID:    tc.ref.TableID,
+		Created:    []string{},
+		Existing:   []string{},
+		Mismatched: []typeMismatch{},
+		DryRun:     opts.DryRun,
+		Errors:     []string{},
+	}
+
+	wanted := []common.FieldInfo{}
+	specOf := map[string]schemaField{}
+	for _, sf := range taskSchema {
+		col := strings.TrimSpace(tc.fields[sf.Logical])
+		if col == "" {
+			continue
+		}
+		if _, dup := specOf[col]; dup {
+			continue
+		}
+		specOf[col] = sf
+		wanted = append(wanted, common.FieldInfo{FieldName: col, Type: sf.Type, Property: sf.Property})
+	}
+
+	if tc.ref.TableID == "" {
+		name := strings.TrimSpace(opts.Name)
+		if name == "" {
+			errLogger.Error("--name is required when the URL has no table")
+			return 2
+		}
+		for _, f := range wanted {
+			report.Created = append(report.Created, f.FieldName)
+		}
+		if !opts.DryRun {
+			tableID, err := common.CreateTable(ctx, tc.baseURL, tc.token, tc.ref.AppToken, name, wanted)
+			if err != nil {
+				errLogger.Error("create table failed", "name", name, "err", err)
+				return 1
+			}
+			report.TableID = tableID
+			report.CreatedTable = true
+		}
+		report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+		printJSON(report)
+		return 0
+	}
+
+	schema, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		errLogger.Error("list fields failed", "err", err)
+		return 2
+	}
+	existing := common.FieldsByName(schema)
+	for _, f := range wanted {
+		if cur, ok := existing[f.FieldName]; ok {
+			report.Existing = append(report.Existing, f.FieldName)
+			if !specOf[f.FieldName].accepts(cur.Type) {
+				report.Mismatched = append(report.Mismatched, typeMismatch{
+					Logical: specOf[f.FieldName].Logical,
+					Column:  f.FieldName,
+					Want:    f.TypeName(),
+					Got:     cur.TypeName(),
+				})
+			}
+			continue
+		}
+		if opts.DryRun {
+			report.Created = append(report.Created, f.FieldName)
+			continue
+		}
+		if _, err := common.CreateField(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID, f); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Created = append(report.Created, f.FieldName)
+	}
+	report.Failed = len(report.Errors)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if report.Failed > 0 || len(report.Mismatched) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -2,11 +2,16 @@ package cli
 
 import (
 	"bufio"
-	"encoding/json"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
 )
 
 func readAllInput(path string) ([]byte, error) {
@@ -25,6 +30,9 @@ func detectInputFormat(path string, raw []byte) string {
 		if s == ".jsonl" {
 			return "jsonl"
 		}
+		if s == ".csv" {
+			return "csv"
+		}
 	}
 	stripped := strings.TrimSpace(string(raw))
 	if strings.HasPrefix(stripped, "[") || strings.HasPrefix(stripped, "{") {
@@ -33,9 +41,101 @@ func detectInputFormat(path string, raw []byte) string {
 	return "jsonl"
 }
 
+// parseInputItems parses JSON, JSONL or CSV input as detected by
+// detectInputFormat.
+func parseInputItems(path string, raw []byte) ([]map[string]any, error) {
+	switch detectInputFormat(path, raw) {
+	case "csv":
+		return parseCSVItems(raw)
+	case "jsonl":
+		return parseJSONLItems(raw)
+	default:
+		return parseJSONItems(raw)
+	}
+}
+
+// parseCSVItems reads a CSV with a header row into one item per row, keyed
+// by header. Empty cells are left out so CLI defaults still apply, which
+// also makes the output of export readable as input.
+func parseCSVItems(raw []byte) ([]map[string]any, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return headerRowItems(rows), nil
+}
+
+// headerRowItems keys each row after the first by the first row's cells.
+func headerRowItems(rows [][]string) []map[string]any {
+	if len(rows) == 0 {
+		return nil
+	}
+	header := rows[0]
+	out := make([]map[string]any, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		item := map[string]any{}
+		for i, cell := range row {
+			if i >= len(header) || strings.TrimSpace(header[i]) == "" || strings.TrimSpace(cell) == "" {
+				continue
+			}
+			item[strings.TrimSpace(header[i])] = cell
+		}
+		if len(item) > 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// sheetSourcePrefix marks a --source read from a Feishu spreadsheet.
+const sheetSourcePrefix = "sheet:"
+
+// readSourceItems reads a create/import --source. sheet:<url> reads a
+// spreadsheet through the Sheets API: the first row is the header and every
+// other row an item keyed by it, exactly like a CSV input.
+func readSourceItems(ctx context.Context, source string) ([]map[string]any, error) {
+	rawURL, ok := strings.CutPrefix(strings.TrimSpace(source), sheetSourcePrefix)
+	if !ok {
+		return nil, fmt.Errorf("unsupported --source %q (want %s<url>)", source, sheetSourcePrefix)
+	}
+	ref, err := common.ParseSheetURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	appID := common.Env("FEISHU_APP_ID", "")
+	appSecret := common.Env("FEISHU_APP_SECRET", "")
+	if appID == "" || appSecret == "" {
+		return nil, fmt.Errorf("FEISHU_APP_ID/FEISHU_APP_SECRET are required")
+	}
+	baseURL := common.Env("FEISHU_BASE_URL", common.DefaultBaseURL)
+	token, err := common.GetTenantAccessToken(ctx, baseURL, appID, appSecret)
+	if err != nil {
+		return nil, fmt.Errorf("get tenant access token: %w", err)
+	}
+	if ref.SpreadsheetToken == "" {
+		if ref.SpreadsheetToken, err = common.ResolveWikiObjToken(ctx, baseURL, token, ref.WikiToken, "sheet"); err != nil {
+			return nil, fmt.Errorf("resolve wiki sheet token: %w", err)
+		}
+	}
+	values, err := common.ReadSheetRows(ctx, baseURL, token, ref)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, len(values))
+	for i, row := range values {
+		rows[i] = make([]string, len(row))
+		for j, v := range row {
+			rows[i][j] = common.BitableValueToString(v)
+		}
+	}
+	return headerRowItems(rows), nil
+}
+
 func parseJSONItems(raw []byte) ([]map[string]any, error) {
 	var v any
-	if err := json.Unmarshal(raw, &v); err != nil {
+	if err := common.DecodeJSON(raw, &v); err != nil {
 		return nil, err
 	}
 	switch t := v.(type) {
@@ -76,7 +176,7 @@ func parseJSONLItems(raw []byte) ([]map[string]any, error) {
 			continue
 		}
 		var m map[string]any
-		if err := json.Unmarshal([]byte(line), &m); err != nil {
+		if err := common.DecodeJSON([]byte(line), &m); err != nil {
 			return nil, err
 		}
 		out = append(out, m)
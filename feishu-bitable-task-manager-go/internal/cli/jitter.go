@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jitter randomizes periodic waits so identically configured hosts drift
+// apart instead of hitting the Feishu API in the same second. It is either
+// a fraction of the interval ("20%") or a fixed bound ("5s"); each wait is
+// the interval plus or minus a uniform random amount up to that bound.
+type jitter struct {
+	frac float64
+	abs  time.Duration
+}
+
+func parseJitter(s string) (jitter, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return jitter{}, nil
+	}
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || f < 0 || f > 100 {
+			return jitter{}, fmt.Errorf("jitter %q: percentage must be between 0%% and 100%%", s)
+		}
+		return jitter{frac: f / 100}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return jitter{}, fmt.Errorf("jitter %q: want a percentage (20%%) or a duration (5s)", s)
+	}
+	return jitter{abs: d}, nil
+}
+
+func (j *jitter) String() string {
+	switch {
+	case j == nil:
+		return ""
+	case j.abs > 0:
+		return j.abs.String()
+	case j.frac > 0:
+		return strconv.FormatFloat(j.frac*100, 'f', -1, 64) + "%"
+	}
+	return "0"
+}
+
+func (j *jitter) Set(s string) error {
+	v, err := parseJitter(s)
+	if err != nil {
+		return err
+	}
+	*j = v
+	return nil
+}
+
+// addJitterFlag registers --jitter on fs, defaulting to BITABLE_JITTER so a
+// fleet can set it once in its environment.
+func addJitterFlag(fs *flag.FlagSet, j *jitter) error {
+	if err := j.Set(os.Getenv("BITABLE_JITTER")); err != nil {
+		return fmt.Errorf("BITABLE_JITTER: %w", err)
+	}
+	fs.Var(j, "jitter", "Randomize each wait by up to this share of the interval (20%) or duration (5s) (default: BITABLE_JITTER)")
+	return nil
+}
+
+// apply returns d moved by a random amount within the jitter bound. The
+// bound never exceeds d, so the result is between 0 and 2d.
+func (j jitter) apply(d time.Duration) time.Duration {
+	spread := j.abs
+	if j.frac > 0 {
+		spread = time.Duration(float64(d) * j.frac)
+	}
+	if spread > d {
+		spread = d
+	}
+	if spread <= 0 {
+		return d
+	}
+	return d - spread + time.Duration(rand.Int63n(int64(2*spread)+1))
+}
+
+// sleepContext waits for d and reports false when ctx ended first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+// knownStatuses are the status labels this tool writes. The legacy Python
+// tool wrote some of them capitalized ("Success", "FAILED").
+var knownStatuses = func() []string {
+	out := make([]string, 0, len(taskmodel.Statuses))
+	for _, s := range taskmodel.Statuses {
+		out = append(out, string(s))
+	}
+	return out
+}()
+
+// legacyTimestampFields are datetime columns the legacy tool sometimes
+// wrote as epoch seconds instead of milliseconds.
+var legacyTimestampFields = []string{"DispatchedAt", "HeartbeatAt", "StartAt", "EndAt"}
+
+// secondsEpochLimit matches normalizeEpochMillis: smaller epoch values are
+// seconds.
+const secondsEpochLimit = 100000000000
+
+type MigrateLegacyOptions struct {
+	TaskURL string
+	App     string
+	Scene   string
+	Status  string
+	Date    string
+	Limit   int
+	DryRun  bool
+}
+
+type migrateLegacyReport struct {
+	Scanned        int            `json:"scanned"`
+	Matched        int            `json:"matched"`
+	Migrated       int            `json:"migrated"`
+	Fixes          map[string]int `json:"fixes"`
+	DryRun         bool           `json:"dry_run"`
+	RecordIDs      []string       `json:"record_ids"`
+	Failed         int            `json:"failed"`
+	Errors         []string       `json:"errors"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+}
+
+// MigrateLegacy rewrites records written by the legacy Python tool into the
+// current conventions, in place:
+//
+//   - status labels are lower-cased to the known statuses,
+//   - epoch-second timestamps become milliseconds,
+//   - ElapsedSeconds stored in milliseconds (about 1000x EndAt-StartAt) is
+//     converted to seconds.
+//
+// Records that already follow the conventions are left untouched, so the
+// command is safe to re-run.
+func MigrateLegacy(ctx context.Context, opts MigrateLegacyOptions) int {
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	start := time.Now()
+	filterObj := buildFilter(tc.fields, opts.App, opts.Scene, opts.Status, opts.Date)
+	var body map[string]any
+	if filterObj != nil {
+		body = map[string]any{"filter": filterObj}
+	}
+
+	report := migrateLegacyReport{Fixes: map[string]int{}, DryRun: opts.DryRun, RecordIDs: []string{}, Errors: []string{}}
+	records := []map[string]any{}
+	err := scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		report.Scanned++
+		recordID := strings.TrimSpace(common.BitableValueToString(item["record_id"]))
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if recordID == "" || (opts.Limit > 0 && len(records) >= opts.Limit) {
+			return
+		}
+		fixes, kinds := legacyFixes(fieldsRaw, tc.fields)
+		if len(fixes) == 0 {
+			return
+		}
+		for _, k := range kinds {
+			report.Fixes[k]++
+		}
+		report.RecordIDs = append(report.RecordIDs, recordID)
+		records = append(records, map[string]any{"record_id": recordID, "fields": fixes})
+	})
+	if err != nil {
+		errLogger.Error("scan tasks failed", "err", err)
+		return 2
+	}
+	report.Matched = len(records)
+
+	if !opts.DryRun {
+		for i := 0; i < len(records); i += updateMaxBatchSize {
+			j := minInt(i+updateMaxBatchSize, len(records))
+			if err := batchUpdateRecords(ctx, tc.baseURL, tc.token, tc.ref, records[i:j]); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+				break
+			}
+			report.Migrated += j - i
+		}
+	}
+	report.Failed = len(report.Errors)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// legacyFixes returns the column writes that bring one record to the current
+// conventions, and the kinds of fixes applied (status, timestamp, elapsed).
+func legacyFixes(fieldsRaw map[string]any, fields map[string]string) (map[string]any, []string) {
+	out := map[string]any{}
+	kinds := []string{}
+
+	if col := strings.TrimSpace(fields["Status"]); col != "" {
+		raw := common.BitableValueToString(fieldsRaw[col])
+		if canonical, ok := canonicalStatus(raw); ok && canonical != raw {
+			out[col] = canonical
+			kinds = append(kinds, "status")
+		}
+	}
+
+	millis := map[string]int64{}
+	for _, key := range legacyTimestampFields {
+		col := strings.TrimSpace(fields[key])
+		if col == "" {
+			continue
+		}
+		v := fieldsRaw[col]
+		n, ok := epochValue(v)
+		if !ok {
+			continue
+		}
+		if n > 0 && n < secondsEpochLimit {
+			n *= 1000
+			if _, isText := v.(string); isText {
+				out[col] = strconv.FormatInt(n, 10)
+			} else {
+				out[col] = n
+			}
+			kinds = append(kinds, "timestamp")
+		}
+		millis[key] = n
+	}
+
+	if col := strings.TrimSpace(fields["ElapsedSeconds"]); col != "" {
+		startMS, okStart := millis["StartAt"]
+		endMS, okEnd := millis["EndAt"]
+		stored, okStored := epochValue(fieldsRaw[col])
+		if okStart && okEnd && okStored && endMS > startMS {
+			expected := float64(endMS-startMS) / 1000
+			// Stored milliseconds are ~1000x the span; allow a couple of
+			// seconds of rounding either way.
+			if float64(stored) > 10*math.Max(expected, 1) && math.Abs(float64(stored)/1000-expected) <= 2 {
+				out[col] = int(math.Round(float64(stored) / 1000))
+				kinds = append(kinds, "elapsed")
+			}
+		}
+	}
+	return out, kinds
+}
+
+// canonicalStatus maps a status label to its canonical lower-case form when
+// it matches a known status case-insensitively.
+func canonicalStatus(raw string) (string, bool) {
+	s := strings.TrimSpace(raw)
+	for _, known := range knownStatuses {
+		if strings.EqualFold(s, known) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// epochValue reads a numeric cell (number or digit string) without the
+// seconds-to-milliseconds normalization CoerceMillis applies.
+func epochValue(v any) (int64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return int64(x), true
+	case json.Number:
+		n, err := x.Int64()
+		return n, err == nil
+	case int64:
+		return x, true
+	case int:
+		return int64(x), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// legacyRead reports whether the TASK_LEGACY_READ switch enables name
+// ("status" or "seconds"); "1" or "all" enables every switch.
+func legacyRead(name string) bool {
+	for _, part := range strings.Split(common.Env("TASK_LEGACY_READ", ""), ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == name || part == "1" || part == "all" || part == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyLegacyRead normalizes a decoded task according to TASK_LEGACY_READ,
+// without touching the table.
+func applyLegacyRead(t *Task) {
+	if legacyRead("status") {
+		if canonical, ok := canonicalStatus(t.Status); ok {
+			t.Status = canonical
+		}
+	}
+	if legacyRead("seconds") {
+		for _, p := range []*string{&t.DispatchedAt, &t.HeartbeatAt, &t.StartAt, &t.EndAt} {
+			if n, ok := epochValue(*p); ok && n > 0 && n < secondsEpochLimit {
+				*p = strconv.FormatInt(n*1000, 10)
+			}
+		}
+	}
+}
@@ -3,6 +3,8 @@ package cli
 import (
 	"log/slog"
 	"os"
+
+	"feishu-bitable-task-manager-go/internal/common"
 )
 
 var (
@@ -10,12 +12,43 @@ var (
 	errLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 )
 
+// taskAttrs prefixes log attributes with the task's record id and, when the
+// task has one, its TraceID, so every line about a task can be correlated
+// across the CLI, devices and downstream systems.
+func taskAttrs(recordID, traceID string, kv ...any) []any {
+	attrs := []any{"record_id", recordID}
+	if traceID != "" {
+		attrs = append(attrs, "trace_id", traceID)
+	}
+	return append(attrs, kv...)
+}
+
+// setCorrelationID tags every log line, result envelope and Feishu request
+// of this command with id. It is exported as BITABLE_CORRELATION_ID so
+// handlers that call bitable-task again (exec, work) share the ID.
+func setCorrelationID(id string) {
+	common.SetCorrelationID(id)
+	os.Setenv("BITABLE_CORRELATION_ID", id)
+	logger = logger.With("correlation_id", id)
+	errLogger = errLogger.With("correlation_id", id)
+	common.SetWarnLogger(errLogger)
+	if common.EventsEnabled() {
+		common.SetEventLogger(errLogger)
+	}
+}
+
 func setLoggerJSON(enabled bool) {
 	if enabled {
 		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 		errLogger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		// Events (api_call, page_fetched, ...) go to stderr with the other
+		// diagnostics so stdout stays the command's result.
+		common.SetEventLogger(errLogger)
+		common.SetWarnLogger(errLogger)
 		return
 	}
 	logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
 	errLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	common.SetEventLogger(nil)
+	common.SetWarnLogger(errLogger)
 }
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+const expiredStatus = string(taskmodel.StatusExpired)
+
+// ageWindow is a --max-age window. Age is read from the table's CreatedTime
+// column, or, in a table without one, from Date, which only knows the day:
+// a task then counts as fresh while its Date is on or after the day of the
+// cutoff.
+type ageWindow struct {
+	col    string
+	kind   string
+	byDay  bool
+	cutoff time.Time
+	loc    *time.Location
+}
+
+// resolveAgeWindow picks the column --max-age compares against and the
+// cutoff, now minus maxAge.
+func resolveAgeWindow(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]string, maxAge time.Duration, now time.Time) (*ageWindow, error) {
+	w := &ageWindow{cutoff: now.Add(-maxAge), loc: common.TaskTimezone()}
+	schema, err := common.ListFields(ctx, baseURL, token, ref.AppToken, ref.TableID)
+	if err != nil {
+		return nil, fmt.Errorf("list fields: %w", err)
+	}
+	for _, f := range schema {
+		if f.Type == common.FieldTypeCreatedTime {
+			w.col, w.kind = f.FieldName, common.DateKindDateTime
+			return w, nil
+		}
+	}
+	col := strings.TrimSpace(fields["Date"])
+	f, ok := common.FieldsByName(schema)[col]
+	if col == "" || !ok {
+		return nil, fmt.Errorf("--max-age needs a CreatedTime column or a Date column")
+	}
+	y, m, d := w.cutoff.In(w.loc).Date()
+	w.col, w.kind, w.byDay = col, common.DateKindOf(f), true
+	w.cutoff = time.Date(y, m, d, 0, 0, 0, 0, w.loc)
+	return w, nil
+}
+
+// filter returns a copy of filterObj that also requires the age column to
+// be at or after the cutoff. A text Date column has no range operators, so
+// it matches the days from the cutoff through today.
+func (w *ageWindow) filter(filterObj map[string]any) (map[string]any, error) {
+	var to time.Time
+	if w.kind == common.DateKindText {
+		y, m, d := time.Now().In(w.loc).Date()
+		to = time.Date(y, m, d+1, 0, 0, 0, 0, w.loc)
+	}
+	conds, child, err := dateRangeFilter(w.kind, w.col, w.cutoff, to, w.loc)
+	if err != nil {
+		return nil, fmt.Errorf("--max-age: %w", err)
+	}
+	return withDateConds(filterObj, conds, child), nil
+}
+
+// stale reports whether a record is older than the window. Records without
+// a readable age are kept.
+func (w *ageWindow) stale(fieldsRaw map[string]any) bool {
+	v := fieldsRaw[w.col]
+	if w.byDay {
+		day, ok := taskDay(v, w.loc)
+		return ok && day.Before(w.cutoff)
+	}
+	ms, ok := common.CoerceMillis(v)
+	return ok && ms < w.cutoff.UnixMilli()
+}
+
+// expireStaleTasks marks the pending tasks of app/scene that fall outside
+// the window as expired, so they stop showing up as work. Tasks that left
+// pending since the scan, or that carry an active edit lock, are left
+// alone. It returns how many were expired.
+func expireStaleTasks(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]string, w *ageWindow, app, scene, datePreset string) (int, error) {
+	statusCol := strings.TrimSpace(fields["Status"])
+	if statusCol == "" {
+		return 0, fmt.Errorf("the Status field is not mapped")
+	}
+	body := map[string]any{"field_names": []string{w.col}}
+	if filterObj := buildFilter(fields, app, scene, string(taskmodel.StatusPending), datePreset); filterObj != nil {
+		body["filter"] = filterObj
+	}
+	ids := []string{}
+	err := scanRecords(ctx, baseURL, token, ref, body, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if rid, _ := item["record_id"].(string); rid != "" && w.stale(fieldsRaw) {
+			ids = append(ids, rid)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan pending tasks: %w", err)
+	}
+	expired := 0
+	for _, batch := range chunkStrings(ids, updateMaxBatchSize) {
+		// Re-read right before the write, as update --expect-status pending
+		// would: a worker may have claimed the task since the scan, and an
+		// operator may have locked it.
+		current, err := batchGetRecordFields(ctx, baseURL, token, ref, batch)
+		if err != nil {
+			return expired, fmt.Errorf("re-read stale tasks: %w", err)
+		}
+		now := time.Now()
+		records := make([]map[string]any, 0, len(batch))
+		for _, rid := range batch {
+			fieldsRaw, ok := current[rid]
+			if !ok {
+				continue
+			}
+			if l, ok := activeEditLock(fieldsRaw, fields, now); ok {
+				logSkippedLocked(rid, l, "expiry")
+				continue
+			}
+			status := strings.ToLower(strings.TrimSpace(common.BitableValueToString(fieldsRaw[statusCol])))
+			if status != string(taskmodel.StatusPending) {
+				continue
+			}
+			records = append(records, map[string]any{"record_id": rid, "fields": map[string]any{statusCol: expiredStatus}})
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if err := batchUpdateRecords(ctx, baseURL, token, ref, records); err != nil {
+			return expired, fmt.Errorf("mark tasks expired: %w", err)
+		}
+		expired += len(records)
+	}
+	if expired > 0 {
+		errLogger.Warn("expired stale pending tasks", "count", expired, "app", app, "scene", scene, "older_than", w.cutoff.Format(time.RFC3339))
+	}
+	return expired, nil
+}
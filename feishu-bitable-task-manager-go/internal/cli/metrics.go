@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// apiLatencyBuckets are the upper bounds, in seconds, of the per-endpoint
+// latency histogram.
+var apiLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type latencyHistogram struct {
+	counts []uint64 // per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	total  uint64
+}
+
+// prometheusMetrics is the common.Metrics behind --metrics-addr: it keeps
+// the process's counters and writes them in the Prometheus text format.
+type prometheusMetrics struct {
+	mu          sync.Mutex
+	apiCalls    map[[3]string]uint64 // method, endpoint, status
+	apiLatency  map[[2]string]*latencyHistogram
+	retries     map[string]uint64
+	rateLimited map[string]uint64
+	tasks       map[string]uint64
+}
+
+func newPrometheusMetrics() *prometheusMetrics {
+	return &prometheusMetrics{
+		apiCalls:    map[[3]string]uint64{},
+		apiLatency:  map[[2]string]*latencyHistogram{},
+		retries:     map[string]uint64{},
+		rateLimited: map[string]uint64{},
+		tasks:       map[string]uint64{},
+	}
+}
+
+func (p *prometheusMetrics) APICall(method, endpoint string, status int, elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.apiCalls[[3]string{method, endpoint, strconv.Itoa(status)}]++
+	key := [2]string{method, endpoint}
+	h := p.apiLatency[key]
+	if h == nil {
+		h = &latencyHistogram{counts: make([]uint64, len(apiLatencyBuckets)+1)}
+		p.apiLatency[key] = h
+	}
+	s := elapsed.Seconds()
+	i := sort.SearchFloat64s(apiLatencyBuckets, s)
+	h.counts[i]++
+	h.sum += s
+	h.total++
+}
+
+func (p *prometheusMetrics) Retry(op string) {
+	p.mu.Lock()
+	p.retries[op]++
+	p.mu.Unlock()
+}
+
+func (p *prometheusMetrics) RateLimited(endpoint string) {
+	p.mu.Lock()
+	p.rateLimited[endpoint]++
+	p.mu.Unlock()
+}
+
+func (p *prometheusMetrics) Tasks(kind string, n int) {
+	p.mu.Lock()
+	p.tasks[kind] += uint64(n)
+	p.mu.Unlock()
+}
+
+// write writes the counters in the Prometheus text format.
+func (p *prometheusMetrics) write(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var b strings.Builder
+
+	b.WriteString("# HELP bitable_api_requests_total Feishu API requests by method, endpoint and HTTP status (0 = transport error).\n")
+	b.WriteString("# TYPE bitable_api_requests_total counter\n")
+	for _, k := range sortedLabels(p.apiCalls, func(k [3]string) string { return strings.Join(k[:], "\x00") }) {
+		fmt.Fprintf(&b, "bitable_api_requests_total{method=%q,endpoint=%q,status=%q} %d\n", k[0], k[1], k[2], p.apiCalls[k])
+	}
+
+	b.WriteString("# HELP bitable_api_request_duration_seconds Feishu API request latency by method and endpoint.\n")
+	b.WriteString("# TYPE bitable_api_request_duration_seconds histogram\n")
+	for _, k := range sortedLabels(p.apiLatency, func(k [2]string) string { return strings.Join(k[:], "\x00") }) {
+		h := p.apiLatency[k]
+		var cum uint64
+		for i, le := range apiLatencyBuckets {
+			cum += h.counts[i]
+			fmt.Fprintf(&b, "bitable_api_request_duration_seconds_bucket{method=%q,endpoint=%q,le=%q} %d\n", k[0], k[1], strconv.FormatFloat(le, 'g', -1, 64), cum)
+		}
+		fmt.Fprintf(&b, "bitable_api_request_duration_seconds_bucket{method=%q,endpoint=%q,le=\"+Inf\"} %d\n", k[0], k[1], h.total)
+		fmt.Fprintf(&b, "bitable_api_request_duration_seconds_sum{method=%q,endpoint=%q} %s\n", k[0], k[1], strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "bitable_api_request_duration_seconds_count{method=%q,endpoint=%q} %d\n", k[0], k[1], h.total)
+	}
+
+	b.WriteString("# HELP bitable_retries_total Operations retried after a failure, by operation.\n")
+	b.WriteString("# TYPE bitable_retries_total counter\n")
+	for _, op := range sortedLabels(p.retries, func(k string) string { return k }) {
+		fmt.Fprintf(&b, "bitable_retries_total{op=%q} %d\n", op, p.retries[op])
+	}
+
+	b.WriteString("# HELP bitable_rate_limited_total Requests refused by Feishu's frequency limit, by endpoint.\n")
+	b.WriteString("# TYPE bitable_rate_limited_total counter\n")
+	for _, ep := range sortedLabels(p.rateLimited, func(k string) string { return k }) {
+		fmt.Fprintf(&b, "bitable_rate_limited_total{endpoint=%q} %d\n", ep, p.rateLimited[ep])
+	}
+
+	b.WriteString("# HELP bitable_tasks_total Task records read by searches, created and updated.\n")
+	b.WriteString("# TYPE bitable_tasks_total counter\n")
+	for _, kind := range []string{common.TasksFetched, common.TasksCreated, common.TasksUpdated} {
+		fmt.Fprintf(&b, "bitable_tasks_total{op=%q} %d\n", kind, p.tasks[kind])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedLabels returns m's keys ordered by name(key), so the output is
+// stable between scrapes.
+func sortedLabels[K comparable, V any](m map[K]V, name func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return name(keys[i]) < name(keys[j]) })
+	return keys
+}
+
+// serveMetrics records the client's measurements and serves them in the
+// Prometheus text format on addr's /metrics until the returned stop is
+// called. The listener is opened before it returns, so a busy port fails
+// the command at startup.
+func serveMetrics(addr string) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	metrics := newPrometheusMetrics()
+	common.SetMetrics(metrics)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := metrics.write(w); err != nil {
+			errLogger.Warn("write metrics failed", "err", err)
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errLogger.Warn("metrics server stopped", "err", err)
+		}
+	}()
+	errLogger.Info("serving metrics", "addr", ln.Addr().String(), "path", "/metrics")
+	return func() {
+		common.SetMetrics(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,555 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// mirrorVersion is written into the mirror's header line; files with
+// another version are refused rather than misread.
+const mirrorVersion = 1
+
+// mirrorWatermarkSlack widens the incremental search: Bitable compares
+// ExactDate values by day, so records modified on the watermark's day are
+// fetched again and sorted out by their last-modified time.
+const mirrorWatermarkSlack = 24 * time.Hour
+
+// Change kinds reported by mirror sync --diff.
+const (
+	mirrorAdded   = "added"
+	mirrorChanged = "changed"
+	mirrorRemoved = "removed"
+)
+
+// mirrorHeader is the first line of a mirror file.
+type mirrorHeader struct {
+	Mirror  int    `json:"mirror"`
+	TaskURL string `json:"task_url"`
+	// Watermark is the newest last_modified_time in the mirror, in ms.
+	Watermark int64  `json:"watermark"`
+	SyncedAt  string `json:"synced_at"`
+}
+
+// mirrorRecord is one record line: the raw fields as the search API
+// returned them, so mapping changes apply on the next query.
+type mirrorRecord struct {
+	RecordID     string         `json:"record_id"`
+	LastModified int64          `json:"last_modified_time"`
+	Fields       map[string]any `json:"fields"`
+}
+
+// sealedMirror is the single line of a mirror file encrypted with
+// BITABLE_STORE_KEY; the sealed data is the plain JSONL mirror.
+type sealedMirror struct {
+	Mirror int            `json:"mirror"`
+	Sealed *common.Sealed `json:"sealed"`
+}
+
+// taskMirror is a local copy of the task table: a JSONL file holding a
+// header and one line per record, sorted by record_id.
+type taskMirror struct {
+	path    string
+	header  mirrorHeader
+	records map[string]mirrorRecord
+}
+
+// loadMirror reads the mirror at path, opening it first if it is sealed.
+// A missing file is an empty mirror.
+func loadMirror(path string) (*taskMirror, error) {
+	m := &taskMirror{path: path, header: mirrorHeader{Mirror: mirrorVersion}, records: map[string]mirrorRecord{}}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	first, _, _ := bytes.Cut(raw, []byte("\n"))
+	var sealed sealedMirror
+	if common.DecodeJSON(bytes.TrimSpace(first), &sealed) == nil && sealed.Sealed != nil {
+		if raw, err = common.OpenSealed(common.StoreKey(), sealed.Sealed); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if line == 1 {
+			if err := common.DecodeJSON(text, &m.header); err != nil {
+				return nil, fmt.Errorf("%s: header: %w", path, err)
+			}
+			if m.header.Mirror != mirrorVersion {
+				return nil, fmt.Errorf("%s: not a task mirror (version %d)", path, m.header.Mirror)
+			}
+			continue
+		}
+		var rec mirrorRecord
+		if err := common.DecodeJSON(text, &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if rec.RecordID != "" {
+			m.records[rec.RecordID] = rec
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// save writes the mirror to a temporary file and renames it into place, so
+// readers never see a half-written mirror. With BITABLE_STORE_KEY set the
+// whole file is sealed into one line.
+func (m *taskMirror) save() error {
+	dir := filepath.Dir(m.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(m.records))
+	for id := range m.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m.header); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := enc.Encode(m.records[id]); err != nil {
+			return err
+		}
+	}
+	if key := common.StoreKey(); key != nil {
+		env := sealedMirror{Mirror: mirrorVersion}
+		var err error
+		if env.Sealed, err = key.Seal(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+		if err := enc.Encode(env); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(dir, ".mirror-*")
+	if err != nil {
+		return err
+	}
+	if err = tmp.Chmod(0o600); err == nil {
+		if _, err = tmp.Write(buf.Bytes()); err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+type MirrorSyncOptions struct {
+	TaskURL string
+	File    string
+	// Full rescans the whole table and drops records no longer in it;
+	// otherwise only records modified since the watermark are fetched.
+	Full bool
+	// Diff emits one JSONL line per added, changed or removed record.
+	Diff bool
+}
+
+type mirrorChange struct {
+	Op       string `json:"op"`
+	RecordID string `json:"record_id"`
+	// Task is the decoded Task, or nil for records that are not tasks.
+	Task any `json:"task,omitempty"`
+}
+
+type mirrorSyncReport struct {
+	File        string `json:"file"`
+	Incremental bool   `json:"incremental"`
+	// ModifiedColumn is the ModifiedTime column incremental syncs search on.
+	ModifiedColumn string  `json:"modified_column,omitempty"`
+	Scanned        int     `json:"scanned"`
+	Added          int     `json:"added"`
+	Changed        int     `json:"changed"`
+	Removed        int     `json:"removed"`
+	Unchanged      int     `json:"unchanged"`
+	Records        int     `json:"records"`
+	Watermark      int64   `json:"watermark"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// SyncMirror brings the mirror file up to date with the table. With a
+// watermark and a ModifiedTime column only records modified since then are
+// searched; records are compared by last_modified_time and only newer ones
+// replace the local copy. Hard deletes are invisible to an incremental
+// search, so only a full sync (Full, the first sync, or a table without a
+// ModifiedTime column) drops records that are gone from the table.
+func SyncMirror(ctx context.Context, opts MirrorSyncOptions) int {
+	start := time.Now()
+	m, code := openMirror(opts.File, opts.TaskURL)
+	if m == nil {
+		return code
+	}
+	report, changes, code := m.sync(ctx, opts)
+	if code != 0 {
+		return code
+	}
+	if opts.Diff {
+		for _, c := range changes {
+			logger.Info("change", "change", c)
+		}
+	}
+	report.ElapsedSeconds = time.Since(start).Seconds()
+	printJSON(report)
+	return 0
+}
+
+// openMirror loads the mirror at file and checks it belongs to taskURL.
+func openMirror(file, taskURL string) (*taskMirror, int) {
+	file = strings.TrimSpace(file)
+	if file == "" {
+		errLogger.Error("--file is required (or set BITABLE_MIRROR_FILE)")
+		return nil, 2
+	}
+	m, err := loadMirror(file)
+	if err != nil {
+		errLogger.Error("read mirror failed", "file", file, "err", err)
+		return nil, 2
+	}
+	taskURL = strings.TrimSpace(taskURL)
+	if m.header.TaskURL != "" && taskURL != "" && m.header.TaskURL != taskURL {
+		errLogger.Error("mirror belongs to another table", "file", file, "mirror_url", m.header.TaskURL, "task_url", taskURL)
+		return nil, 2
+	}
+	return m, 0
+}
+
+// sync fetches changed records into m and saves it. On failure it logs the
+// reason and returns the exit code.
+func (m *taskMirror) sync(ctx context.Context, opts MirrorSyncOptions) (mirrorSyncReport, []mirrorChange, int) {
+	report := mirrorSyncReport{File: m.path}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return report, nil, code
+	}
+	body := map[string]any{"automatic_fields": true}
+	if !opts.Full && m.header.Watermark > 0 {
+		col, err := modifiedTimeColumn(ctx, tc)
+		if err != nil {
+			errLogger.Error("list fields failed", "err", err)
+			return report, nil, exitCodeFor(err, 1)
+		}
+		if col != "" {
+			since := m.header.Watermark - mirrorWatermarkSlack.Milliseconds()
+			body["filter"] = buildFilter(tc.fields, "", "", "", "", filterCond{Column: col, Operator: "isGreater", Value: []string{"ExactDate", strconv.FormatInt(since, 10)}})
+			report.Incremental, report.ModifiedColumn = true, col
+		}
+	}
+
+	changes := []mirrorChange{}
+	seen := map[string]bool{}
+	watermark := m.header.Watermark
+	err := scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(it map[string]any) {
+		recordID := strings.TrimSpace(common.BitableValueToString(it["record_id"]))
+		if recordID == "" {
+			return
+		}
+		report.Scanned++
+		seen[recordID] = true
+		modified, _ := common.CoerceMillis(it["last_modified_time"])
+		if modified > watermark {
+			watermark = modified
+		}
+		fieldsRaw, _ := it["fields"].(map[string]any)
+		prev, ok := m.records[recordID]
+		switch {
+		case !ok:
+			report.Added++
+			changes = append(changes, mirrorRecordChange(mirrorAdded, recordID, fieldsRaw, tc.fields))
+		case modified > prev.LastModified:
+			report.Changed++
+			changes = append(changes, mirrorRecordChange(mirrorChanged, recordID, fieldsRaw, tc.fields))
+		default:
+			report.Unchanged++
+			return
+		}
+		m.records[recordID] = mirrorRecord{RecordID: recordID, LastModified: modified, Fields: fieldsRaw}
+	})
+	if err != nil {
+		errLogger.Error("search records failed", "err", err)
+		return report, nil, exitCodeFor(err, 1)
+	}
+	if !report.Incremental {
+		for id, rec := range m.records {
+			if !seen[id] {
+				report.Removed++
+				changes = append(changes, mirrorRecordChange(mirrorRemoved, id, rec.Fields, tc.fields))
+				delete(m.records, id)
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].RecordID < changes[j].RecordID })
+
+	m.header = mirrorHeader{
+		Mirror:    mirrorVersion,
+		TaskURL:   strings.TrimSpace(opts.TaskURL),
+		Watermark: watermark,
+		SyncedAt:  time.Now().Format(time.RFC3339),
+	}
+	if err := m.save(); err != nil {
+		errLogger.Error("write mirror failed", "file", m.path, "err", err)
+		return report, nil, 1
+	}
+	report.Records = len(m.records)
+	report.Watermark = watermark
+	return report, changes, 0
+}
+
+func mirrorRecordChange(op, recordID string, fieldsRaw map[string]any, mapping map[string]string) mirrorChange {
+	c := mirrorChange{Op: op, RecordID: recordID}
+	if t, ok := decodeTask(fieldsRaw, mapping); ok {
+		t.RecordID = recordID
+		c.Task = t
+	}
+	return c
+}
+
+// modifiedTimeColumn returns the name of the table's first ModifiedTime
+// column, or "" when it has none.
+func modifiedTimeColumn(ctx context.Context, tc *tableClient) (string, error) {
+	fields, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range fields {
+		if f.Type == common.FieldTypeModifiedTime {
+			return f.FieldName, nil
+		}
+	}
+	return "", nil
+}
+
+type MirrorQueryOptions struct {
+	TaskURL string
+	File    string
+	// Sync brings the mirror up to date (incrementally) before querying.
+	Sync   bool
+	App    string
+	Scene  string
+	Status string
+	Date   string
+	// Filters and Where are matched locally, see matchFilter.
+	Filters        []string
+	Where          string
+	Limit          int
+	IncludeDeleted bool
+}
+
+// QueryMirror prints the mirrored tasks matching the filters as JSONL, the
+// same lines as fetch --jsonl, without calling the API unless Sync is set.
+func QueryMirror(ctx context.Context, opts MirrorQueryOptions) int {
+	m, code := openMirror(opts.File, opts.TaskURL)
+	if m == nil {
+		return code
+	}
+	if opts.Sync {
+		report, _, code := m.sync(ctx, MirrorSyncOptions{TaskURL: opts.TaskURL, File: opts.File})
+		if code != 0 {
+			return code
+		}
+		errLogger.Info("mirror synced", "added", report.Added, "changed", report.Changed, "removed", report.Removed, "incremental", report.Incremental)
+	} else if m.header.SyncedAt == "" {
+		errLogger.Error("mirror is empty; run mirror sync first", "file", m.path)
+		return 2
+	}
+
+	mapping := common.LoadTaskFieldsFromEnv()
+	conds := []filterCond{}
+	for _, spec := range opts.Filters {
+		cond, err := parseFieldFilter(spec, mapping)
+		if err != nil {
+			errLogger.Error("invalid --filter", "err", err)
+			return 2
+		}
+		conds = append(conds, cond)
+	}
+	filterObj := buildFilter(mapping, opts.App, opts.Scene, opts.Status, strings.TrimSpace(opts.Date), conds...)
+	filterObj, err := compileWhere(opts.Where, mapping, filterObj)
+	if err != nil {
+		errLogger.Error("invalid --where", "err", err)
+		return 2
+	}
+
+	ids := make([]string, 0, len(m.records))
+	for id := range m.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	now := time.Now().In(common.TaskTimezone())
+	count := 0
+	for _, id := range ids {
+		rec := m.records[id]
+		if !opts.IncludeDeleted && isSoftDeleted(rec.Fields, mapping) {
+			continue
+		}
+		if !matchFilter(filterObj, rec.Fields, now) {
+			continue
+		}
+		t, ok := decodeTask(rec.Fields, mapping)
+		if !ok {
+			continue
+		}
+		t.RecordID = id
+		logger.Info("task", "task", t)
+		count++
+		if opts.Limit > 0 && count >= opts.Limit {
+			break
+		}
+	}
+	errLogger.Info("mirror query", "count", count, "synced_at", m.header.SyncedAt)
+	return 0
+}
+
+// matchFilter evaluates a search filter, as built by buildFilter and
+// compileWhere, against a record's raw fields the way the search API
+// would. A nil filter matches everything.
+func matchFilter(filterObj map[string]any, fieldsRaw map[string]any, now time.Time) bool {
+	if filterObj == nil {
+		return true
+	}
+	results := []bool{}
+	conds, _ := filterObj["conditions"].([]map[string]any)
+	for _, c := range conds {
+		results = append(results, matchCondition(fieldsRaw[fmt.Sprint(c["field_name"])], fmt.Sprint(c["operator"]), toStrings(c["value"]), now))
+	}
+	children, _ := filterObj["children"].([]map[string]any)
+	for _, ch := range children {
+		results = append(results, matchFilter(ch, fieldsRaw, now))
+	}
+	or := fmt.Sprint(filterObj["conjunction"]) == "or"
+	for _, r := range results {
+		if r == or {
+			return or
+		}
+	}
+	return !or || len(results) == 0
+}
+
+// matchCondition applies one search operator to a field value. Dates given
+// as ExactDate or Today/Yesterday/Tomorrow compare by day for is/isNot and
+// by time otherwise; other values compare as numbers when both sides parse
+// and as text when not.
+func matchCondition(v any, op string, want []string, now time.Time) bool {
+	got := strings.TrimSpace(common.BitableValueToString(v))
+	switch op {
+	case "isEmpty":
+		return got == ""
+	case "isNotEmpty":
+		return got != ""
+	}
+	if len(want) == 0 {
+		return true
+	}
+	if bound, byDay, ok := filterDate(want, now); ok {
+		day, ok := taskDay(v, now.Location())
+		if !ok {
+			return op == "isNot"
+		}
+		if byDay || op == "is" || op == "isNot" {
+			y, mo, d := bound.Date()
+			bound = time.Date(y, mo, d, 0, 0, 0, 0, now.Location())
+			y, mo, d = day.Date()
+			day = time.Date(y, mo, d, 0, 0, 0, 0, now.Location())
+		}
+		return compareMatches(op, day.Compare(bound))
+	}
+	switch op {
+	case "is":
+		return got == want[0]
+	case "isNot":
+		return got != want[0]
+	case "contains":
+		return strings.Contains(got, want[0])
+	case "doesNotContain":
+		return !strings.Contains(got, want[0])
+	}
+	a, aerr := strconv.ParseFloat(got, 64)
+	b, berr := strconv.ParseFloat(strings.TrimSpace(want[0]), 64)
+	if aerr == nil && berr == nil {
+		switch {
+		case a < b:
+			return compareMatches(op, -1)
+		case a > b:
+			return compareMatches(op, 1)
+		}
+		return compareMatches(op, 0)
+	}
+	return got != "" && compareMatches(op, strings.Compare(got, want[0]))
+}
+
+// filterDate reads a date filter value. byDay is set for the relative
+// presets, which name a whole day.
+func filterDate(want []string, now time.Time) (t time.Time, byDay, ok bool) {
+	switch want[0] {
+	case "Today":
+		return now, true, true
+	case "Yesterday":
+		return now.AddDate(0, 0, -1), true, true
+	case "Tomorrow":
+		return now.AddDate(0, 0, 1), true, true
+	case "ExactDate":
+		if len(want) < 2 {
+			return time.Time{}, false, false
+		}
+		ms, ok := common.CoerceMillis(want[1])
+		if !ok {
+			return time.Time{}, false, false
+		}
+		return time.UnixMilli(ms).In(now.Location()), false, true
+	}
+	return time.Time{}, false, false
+}
+
+func compareMatches(op string, cmp int) bool {
+	switch op {
+	case "is":
+		return cmp == 0
+	case "isNot":
+		return cmp != 0
+	case "isGreater":
+		return cmp > 0
+	case "isGreaterEqual":
+		return cmp >= 0
+	case "isLess":
+		return cmp < 0
+	case "isLessEqual":
+		return cmp <= 0
+	}
+	return false
+}
@@ -0,0 +1,398 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Output formats shared by list-style commands (--output-format). json and
+// jsonl go through the result logger like every other report; table, csv
+// and yaml are written to stdout as-is.
+const (
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+	outputTable = "table"
+	outputCSV   = "csv"
+	outputYAML  = "yaml"
+	// outputTemplate renders each row with a Go template (go-template=...).
+	outputTemplate = "go-template"
+)
+
+// parseRowTemplate reads "go-template=<text>" or "go-template-file=<path>"
+// and returns nil for any other format value. Rows are rendered one per
+// line; a trailing newline is added when the template has none. Besides the
+// text/template builtins, {{json .}} marshals a value.
+func parseRowTemplate(spec string) (*template.Template, error) {
+	var text string
+	if t, ok := strings.CutPrefix(spec, "go-template="); ok {
+		text = t
+	} else if path, ok := strings.CutPrefix(spec, "go-template-file="); ok {
+		raw, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		text = string(raw)
+	} else {
+		return nil, nil
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return template.New("row").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			raw, err := json.Marshal(v)
+			return string(raw), err
+		},
+	}).Parse(text)
+}
+
+// parseOutputFormat validates an --output-format value against the formats
+// a command supports.
+func parseOutputFormat(s string, allowed ...string) (string, error) {
+	f := strings.ToLower(strings.TrimSpace(s))
+	for _, a := range allowed {
+		if f == a {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("--output-format must be one of %s", strings.Join(allowed, ", "))
+}
+
+// writeRows prints rows (structs or maps, anything that marshals to a JSON
+// object) as an aligned table or CSV. Nested objects become dotted columns
+// (elapsed_seconds.p50); columns appear in first-seen order. The table
+// leaves out columns that are empty in every row.
+func writeRows(w io.Writer, format string, rows []any) error {
+	cols := []string{}
+	seen := map[string]bool{}
+	flat := make([]map[string]string, 0, len(rows))
+	for _, r := range rows {
+		v, err := orderedJSON(r)
+		if err != nil {
+			return err
+		}
+		m := map[string]string{}
+		for _, kv := range flattenOrdered("", v) {
+			if !seen[kv.key] {
+				seen[kv.key] = true
+				cols = append(cols, kv.key)
+			}
+			m[kv.key] = kv.value
+		}
+		flat = append(flat, m)
+	}
+
+	if format == outputCSV {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(cols); err != nil {
+			return err
+		}
+		for _, m := range flat {
+			rec := make([]string, len(cols))
+			for i, c := range cols {
+				rec[i] = m[c]
+			}
+			if err := cw.Write(rec); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	kept := cols[:0:0]
+	for _, c := range cols {
+		for _, m := range flat {
+			if m[c] != "" {
+				kept = append(kept, c)
+				break
+			}
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(kept))
+	for i, c := range kept {
+		header[i] = strings.ToUpper(c)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, m := range flat {
+		cells := make([]string, len(kept))
+		for i, c := range kept {
+			cells[i] = tableCell(m[c])
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableCell keeps a value on one line and marks empty cells.
+func tableCell(s string) string {
+	if s == "" {
+		return "-"
+	}
+	s = strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(s)
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	return s
+}
+
+// writeYAML prints v (anything JSON-marshalable) as a YAML document.
+func writeYAML(w io.Writer, v any) error {
+	doc, err := orderedJSON(v)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	writeYAMLValue(&b, doc, 0, false)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// printFormatted prints report in a text format; rows are what table and
+// csv print, one line each.
+func printFormatted(format string, report any, rows []any) int {
+	var err error
+	switch format {
+	case outputYAML:
+		err = writeYAML(os.Stdout, report)
+	case outputTable, outputCSV:
+		err = writeRows(os.Stdout, format, rows)
+	default:
+		printJSON(report)
+	}
+	if err != nil {
+		errLogger.Error("write output failed", "format", format, "err", err)
+		return 1
+	}
+	return 0
+}
+
+// orderedField is one member of a decoded JSON object; orderedJSON keeps
+// members in their marshaled order, so struct field order is preserved.
+type orderedField struct {
+	key   string
+	value any
+}
+
+func orderedJSON(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeOrdered(dec)
+}
+
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			out := []any{}
+			for dec.More() {
+				v, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+			_, err := dec.Token()
+			return out, err
+		}
+		out := []orderedField{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, orderedField{key: fmt.Sprint(k), value: v})
+		}
+		_, err := dec.Token()
+		return out, err
+	}
+	return tok, nil
+}
+
+type flatField struct {
+	key   string
+	value string
+}
+
+// flattenOrdered turns nested objects into dotted keys; arrays are kept as
+// one JSON cell.
+func flattenOrdered(prefix string, v any) []flatField {
+	obj, ok := v.([]orderedField)
+	if !ok {
+		return []flatField{{key: prefix, value: scalarText(v)}}
+	}
+	out := []flatField{}
+	for _, f := range obj {
+		key := f.key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		out = append(out, flattenOrdered(key, f.value)...)
+	}
+	return out
+}
+
+func scalarText(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	case []any:
+		raw, _ := json.Marshal(plainJSON(x))
+		return string(raw)
+	}
+	return fmt.Sprint(v)
+}
+
+// plainJSON converts ordered values back into marshalable ones.
+func plainJSON(v any) any {
+	switch x := v.(type) {
+	case []orderedField:
+		m := make(map[string]any, len(x))
+		for _, f := range x {
+			m[f.key] = plainJSON(f.value)
+		}
+		return m
+	case []any:
+		out := make([]any, len(x))
+		for i, it := range x {
+			out[i] = plainJSON(it)
+		}
+		return out
+	}
+	return v
+}
+
+func writeYAMLValue(b *strings.Builder, v any, indent int, inList bool) {
+	pad := strings.Repeat("  ", indent)
+	switch x := v.(type) {
+	case []orderedField:
+		if len(x) == 0 {
+			b.WriteString("{}\n")
+			return
+		}
+		for i, f := range x {
+			if !(inList && i == 0) {
+				b.WriteString(pad)
+			}
+			b.WriteString(yamlScalar(f.key) + ":")
+			writeYAMLChild(b, f.value, indent)
+		}
+	case []any:
+		if len(x) == 0 {
+			b.WriteString("[]\n")
+			return
+		}
+		for i, it := range x {
+			if !(inList && i == 0) {
+				b.WriteString(pad)
+			}
+			b.WriteString("- ")
+			if isYAMLCollection(it) {
+				writeYAMLValue(b, it, indent+1, true)
+			} else {
+				b.WriteString(yamlScalar(it) + "\n")
+			}
+		}
+	default:
+		b.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLChild writes the value of a mapping key: scalars and empty
+// collections inline, others on the following lines.
+func writeYAMLChild(b *strings.Builder, v any, indent int) {
+	switch x := v.(type) {
+	case []orderedField:
+		if len(x) > 0 {
+			b.WriteString("\n")
+			writeYAMLValue(b, x, indent+1, false)
+			return
+		}
+	case []any:
+		if len(x) > 0 {
+			b.WriteString("\n")
+			writeYAMLValue(b, x, indent+1, false)
+			return
+		}
+	}
+	b.WriteString(" ")
+	writeYAMLValue(b, v, indent+1, false)
+}
+
+func isYAMLCollection(v any) bool {
+	switch x := v.(type) {
+	case []orderedField:
+		return len(x) > 0
+	case []any:
+		return len(x) > 0
+	}
+	return false
+}
+
+// yamlScalar renders a scalar, double-quoting strings a YAML reader would
+// otherwise take for another type or mis-parse.
+func yamlScalar(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(x)
+	case json.Number:
+		return x.String()
+	case string:
+		if yamlNeedsQuotes(x) {
+			return strconv.Quote(x)
+		}
+		return x
+	}
+	return strconv.Quote(fmt.Sprint(v))
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t") || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") {
+		return true
+	}
+	for _, r := range s {
+		if r < 0x20 {
+			return true
+		}
+	}
+	return false
+}
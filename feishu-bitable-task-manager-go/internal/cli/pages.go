@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// defaultPrefetchPages is how many search pages are fetched ahead of the
+// page being processed.
+const defaultPrefetchPages = 2
+
+// searchPage is one page of a records search, or the error that ended it.
+type searchPage struct {
+	Items     []map[string]any
+	HasMore   bool
+	PageToken string
+	// Total is the server's count of matching records, nil when absent.
+	Total *int
+	Err   error
+}
+
+// pageStream describes a paged records search.
+type pageStream struct {
+	BaseURL  string
+	Token    string
+	Ref      common.BitableRef
+	Body     map[string]any
+	PageSize int
+	// MaxPages and MaxItems stop the search early (0 = no cap).
+	MaxPages int
+	MaxItems int
+	// Prefetch is how many pages may wait, fetched, for the consumer.
+	Prefetch int
+}
+
+// stream pulls pages in a goroutine so the next request is in flight while
+// the caller processes the current page; at most Prefetch pages are
+// buffered ahead. Page tokens are sequential, so requests themselves stay
+// one at a time. The channel is closed after the last page or the first
+// error (sent as a page with Err); call stop when abandoning it early.
+func (p pageStream) stream(ctx context.Context) (pages <-chan searchPage, stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	prefetch := p.Prefetch
+	if prefetch < 0 {
+		prefetch = 0
+	}
+	pageSize := p.PageSize
+	if pageSize <= 0 {
+		pageSize = common.MaxPageSize
+	}
+	ch := make(chan searchPage, prefetch)
+	go func() {
+		defer close(ch)
+		pageToken := ""
+		items := 0
+		for page := 1; ; page++ {
+			q := url.Values{}
+			q.Set("page_size", fmt.Sprintf("%d", pageSize))
+			if pageToken != "" {
+				q.Set("page_token", pageToken)
+			}
+			urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/search?%s",
+				strings.TrimRight(p.BaseURL, "/"), p.Ref.AppToken, p.Ref.TableID, q.Encode(),
+			)
+			var resp searchResp
+			pageStart := time.Now()
+			out := searchPage{}
+			if err := common.RequestJSON(ctx, "POST", urlStr, p.Token, p.Body, &resp); err != nil {
+				out.Err = err
+			} else if resp.Code != 0 {
+				out.Err = fmt.Errorf("search records failed: code=%d msg=%s", resp.Code, resp.Msg)
+			} else {
+				out.Items = resp.Data.Items
+				out.HasMore = resp.Data.HasMore
+				out.PageToken = strings.TrimSpace(resp.Data.PageToken)
+				out.Total = resp.Data.Total
+				common.EmitPageFetched(ctx, p.Ref.TableID, page, len(out.Items), out.HasMore, time.Since(pageStart))
+			}
+			select {
+			case ch <- out:
+			case <-ctx.Done():
+				return
+			}
+			items += len(out.Items)
+			pageToken = out.PageToken
+			if out.Err != nil || !out.HasMore || pageToken == "" ||
+				(p.MaxPages > 0 && page >= p.MaxPages) || (p.MaxItems > 0 && items >= p.MaxItems) {
+				return
+			}
+		}
+	}()
+	stop = func() {
+		cancel()
+		for range ch {
+		}
+	}
+	return ch, stop
+}
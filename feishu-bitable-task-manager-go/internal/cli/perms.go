@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// Capability states in the perms matrix.
+const (
+	permAllowed = "allowed"
+	permDenied  = "denied"
+	permUnknown = "unknown"
+)
+
+// permDeniedCodes are Feishu error codes meaning the app lacks a scope or
+// a role on the document, as opposed to a rejected request.
+var permDeniedCodes = map[int]bool{
+	91403:    true, // forbidden on the document
+	1061004:  true, // drive: forbidden
+	1254302:  true, // bitable: role has no permission
+	99991672: true, // app scope missing
+	99991679: true, // user scope missing
+}
+
+// Placeholders addressed by write probes; no record or field has these ids,
+// so a permitted request fails with "not found" and changes nothing.
+const (
+	permProbeRecordID = "recPermsProbe"
+	permProbeFieldID  = "fldPermsProbe"
+)
+
+type PermsOptions struct {
+	TaskURL string
+	Format  string
+}
+
+type permCheck struct {
+	Capability string `json:"capability"`
+	State      string `json:"state"`
+	Endpoint   string `json:"endpoint"`
+	Code       int    `json:"code"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+type permsReport struct {
+	AppID          string      `json:"app_id"`
+	AppToken       string      `json:"app_token"`
+	TableID        string      `json:"table_id"`
+	Checks         []permCheck `json:"checks"`
+	ElapsedSeconds float64     `json:"elapsed_seconds"`
+}
+
+// CheckPerms reports what the configured app can do on the task table.
+// Reads are real; writes are probed with requests that cannot change
+// anything (an unknown record or field id, an empty upload): a permission
+// error means denied, any other outcome means the request got past the
+// permission check. Exit 1 when a capability is denied or unknown.
+func CheckPerms(ctx context.Context, opts PermsOptions) int {
+	format, err := parseOutputFormat(opts.Format, outputJSON, outputTable, outputCSV, outputYAML)
+	if err != nil {
+		errLogger.Error("invalid output format", "err", err)
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+
+	start := time.Now()
+	base := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s",
+		strings.TrimRight(tc.baseURL, "/"), tc.ref.AppToken, tc.ref.TableID,
+	)
+	probes := []struct {
+		capability, method, path string
+		body                     any
+	}{
+		{"read_records", http.MethodPost, "/records/search?page_size=1", map[string]any{}},
+		{"read_fields", http.MethodGet, "/fields?page_size=1", nil},
+		{"write_records", http.MethodPost, "/records/batch_update", map[string]any{
+			"records": []map[string]any{{"record_id": permProbeRecordID, "fields": map[string]any{}}},
+		}},
+		{"delete_records", http.MethodPost, "/records/batch_delete", map[string]any{
+			"records": []string{permProbeRecordID},
+		}},
+		{"manage_fields", http.MethodPut, "/fields/" + permProbeFieldID, map[string]any{
+			"field_name": "perms probe", "type": 1,
+		}},
+	}
+	report := permsReport{
+		AppID:    common.Env("FEISHU_APP_ID", ""),
+		AppToken: tc.ref.AppToken,
+		TableID:  tc.ref.TableID,
+		Checks:   []permCheck{},
+	}
+	for _, p := range probes {
+		var resp common.FeishuResp
+		err := common.RequestJSON(ctx, p.method, base+p.path, tc.token, p.body, &resp)
+		c := classifyPerm(err, resp)
+		c.Capability = p.capability
+		c.Endpoint = p.method + " " + strings.SplitN(p.path, "?", 2)[0]
+		report.Checks = append(report.Checks, c)
+	}
+	// An empty upload is rejected for its size after the scope check.
+	_, err = common.UploadMedia(ctx, tc.baseURL, tc.token, common.MediaParentBitableFile, tc.ref.AppToken, "perms-probe.txt", nil)
+	upload := classifyPerm(err, common.FeishuResp{})
+	upload.Capability = "upload_media"
+	upload.Endpoint = "POST /drive/v1/medias/upload_all"
+	report.Checks = append(report.Checks, upload)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	code = 0
+	rows := make([]any, 0, len(report.Checks))
+	for _, c := range report.Checks {
+		if c.State != permAllowed {
+			code = 1
+		}
+		rows = append(rows, c)
+	}
+	if out := printFormatted(format, report, rows); out != 0 {
+		return out
+	}
+	return code
+}
+
+// classifyPerm maps a probe outcome to a capability state.
+func classifyPerm(err error, resp common.FeishuResp) permCheck {
+	var httpErr *common.HTTPError
+	switch {
+	case err == nil:
+	case errors.As(err, &httpErr):
+		if r, ok := httpErr.FeishuCode(); ok {
+			resp = r
+		} else if httpErr.Status == http.StatusForbidden {
+			return permCheck{State: permDenied, Code: -1, Detail: err.Error()}
+		} else {
+			return permCheck{State: permUnknown, Code: -1, Detail: err.Error()}
+		}
+	default:
+		var code int
+		if _, scanErr := fmt.Sscanf(err.Error(), "upload media failed: code=%d", &code); scanErr == nil {
+			resp = common.FeishuResp{Code: code, Msg: err.Error()}
+			break
+		}
+		return permCheck{State: permUnknown, Code: -1, Detail: err.Error()}
+	}
+	c := permCheck{State: permAllowed, Code: resp.Code}
+	if resp.Code != 0 {
+		c.Detail = resp.Msg
+	}
+	if permDeniedCodes[resp.Code] {
+		c.State = permDenied
+	}
+	return c
+}
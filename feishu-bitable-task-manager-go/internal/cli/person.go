@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// personCodec resolves text values bound for Person columns into person
+// payloads. A value is an open_id (ou_...), an email or a mobile number;
+// emails and mobiles are resolved through the contact API. Several people
+// are separated by commas.
+type personCodec struct {
+	baseURL string
+	token   string
+	// openIDs caches resolved emails and mobiles by their normalized form
+	// (common.NormalizeEmail, common.NormalizeMobile).
+	openIDs map[string]string
+}
+
+func newPersonCodec(baseURL, token string) *personCodec {
+	return &personCodec{baseURL: baseURL, token: token, openIDs: map[string]string{}}
+}
+
+// resolve turns "ou_x, someone@example.com" into [{"id": "ou_x"}, ...].
+func (c *personCodec) resolve(ctx context.Context, raw string) ([]map[string]any, error) {
+	// values pairs each input with its lookup key: the open_id itself,
+	// or the normalized email or mobile.
+	type person struct{ raw, key string }
+	values := []person{}
+	emails, mobiles := []string{}, []string{}
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		p := person{raw: v, key: v}
+		switch {
+		case strings.HasPrefix(v, "ou_"):
+		case strings.Contains(v, "@"):
+			p.key = common.NormalizeEmail(v)
+			if c.openIDs[p.key] == "" {
+				emails = append(emails, p.key)
+			}
+		case isMobile(v):
+			p.key = common.NormalizeMobile(v)
+			if c.openIDs[p.key] == "" {
+				mobiles = append(mobiles, p.key)
+			}
+		default:
+			return nil, fmt.Errorf("%q is not an open_id (ou_...), email or mobile number", v)
+		}
+		values = append(values, p)
+	}
+	if len(emails) > 0 || len(mobiles) > 0 {
+		found, err := common.LookupOpenIDs(ctx, c.baseURL, c.token, emails, mobiles)
+		if err != nil {
+			return nil, err
+		}
+		for k, id := range found {
+			c.openIDs[k] = id
+		}
+	}
+	out := make([]map[string]any, 0, len(values))
+	for _, p := range values {
+		id := p.key
+		if !strings.HasPrefix(p.raw, "ou_") {
+			if id = c.openIDs[p.key]; id == "" {
+				return nil, fmt.Errorf("no Feishu user found for %q", p.raw)
+			}
+		}
+		out = append(out, map[string]any{"id": id})
+	}
+	return out, nil
+}
+
+// isMobile accepts phone numbers written with an optional leading + and
+// digits, spaces, dashes or parentheses.
+func isMobile(s string) bool {
+	digits := 0
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0, r == ' ', r == '-', r == '(', r == ')':
+		default:
+			return false
+		}
+	}
+	return digits >= 7
+}
+
+// decodePersonFields fills UserID and UserName from Person cells: ids for
+// UserID, display names for UserName. A UserName column that is empty or
+// absent takes the names of a Person UserID.
+func decodePersonFields(t *Task, fieldsRaw map[string]any, mapping map[string]string) {
+	ids, names, isPerson := common.PersonValue(fieldsRaw[mapping["UserID"]])
+	if isPerson {
+		t.UserID = strings.Join(ids, ",")
+		if strings.TrimSpace(t.UserName) == "" || mapping["UserName"] == mapping["UserID"] {
+			t.UserName = strings.Join(names, ",")
+		}
+	}
+	if _, names, ok := common.PersonValue(fieldsRaw[mapping["UserName"]]); ok && mapping["UserName"] != mapping["UserID"] {
+		t.UserName = strings.Join(names, ",")
+	}
+}
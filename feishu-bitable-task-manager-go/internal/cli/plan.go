@@ -0,0 +1,380 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// planVersion is bumped when the plan file layout changes.
+const planVersion = 1
+
+// taskPlan is what plan writes and apply executes: the creates and updates
+// an import would make, plus the rows it would leave alone.
+type taskPlan struct {
+	Version   int          `json:"version"`
+	TaskURL   string       `json:"task_url"`
+	Key       string       `json:"key,omitempty"`
+	CreatedAt string       `json:"created_at"`
+	Creates   []planCreate `json:"creates"`
+	Updates   []planUpdate `json:"updates"`
+	Noops     []planNoop   `json:"noops"`
+	Errors    []string     `json:"errors,omitempty"`
+}
+
+type planCreate struct {
+	Row       int            `json:"row"`
+	BizTaskID string         `json:"biz_task_id,omitempty"`
+	Fields    map[string]any `json:"fields"`
+	// MergedRows are later rows with the same key, already folded into
+	// Fields; they share this row's outcome.
+	MergedRows []int `json:"merged_rows,omitempty"`
+}
+
+type planUpdate struct {
+	Row      int            `json:"row"`
+	RecordID string         `json:"record_id"`
+	Fields   map[string]any `json:"fields"`
+	// MergedRows is as for planCreate.
+	MergedRows []int `json:"merged_rows,omitempty"`
+	// Changes lists the columns whose value differs; apply refuses the
+	// update when a From no longer matches the record.
+	Changes []planChange `json:"changes"`
+
+	current map[string]any
+}
+
+type planChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type planNoop struct {
+	Row      int    `json:"row"`
+	RecordID string `json:"record_id,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// writePlan saves plan to opts.PlanPath and prints it as a diff.
+func writePlan(opts CreateOptions, plan taskPlan) int {
+	plan.Version = planVersion
+	plan.TaskURL = strings.TrimSpace(opts.TaskURL)
+	plan.Key = strings.TrimSpace(opts.UpsertOn)
+	plan.CreatedAt = time.Now().In(common.TaskTimezone()).Format(time.RFC3339)
+	if plan.Creates == nil {
+		plan.Creates = []planCreate{}
+	}
+	if plan.Updates == nil {
+		plan.Updates = []planUpdate{}
+	}
+	if plan.Noops == nil {
+		plan.Noops = []planNoop{}
+	}
+	for i := range plan.Updates {
+		u := &plan.Updates[i]
+		for _, k := range sortedKeys(u.Fields) {
+			from := common.BitableValueToString(u.current[k])
+			to := common.BitableValueToString(u.Fields[k])
+			if from != to {
+				u.Changes = append(u.Changes, planChange{Field: k, From: from, To: to})
+			}
+		}
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		errLogger.Error("encode plan failed", "err", err)
+		return 2
+	}
+	if err := os.WriteFile(opts.PlanPath, append(data, '\n'), 0o644); err != nil {
+		errLogger.Error("write plan failed", "path", opts.PlanPath, "err", err)
+		return 2
+	}
+	printPlanDiff(plan)
+	if len(plan.Errors) > 0 {
+		for _, e := range plan.Errors {
+			errLogger.Error("plan row failed", "err", e)
+		}
+		return 1
+	}
+	return 0
+}
+
+// printPlanDiff prints one block per input row: + create, ~ update (with
+// the changed columns) and = no-op.
+func printPlanDiff(plan taskPlan) {
+	type entry struct {
+		row   int
+		lines []string
+	}
+	entries := []entry{}
+	for _, c := range plan.Creates {
+		lines := []string{fmt.Sprintf("+ row %d: create%s", c.Row, mergedRowsNote(c.MergedRows))}
+		for _, k := range sortedKeys(c.Fields) {
+			lines = append(lines, fmt.Sprintf("    %s: %q", k, common.BitableValueToString(c.Fields[k])))
+		}
+		entries = append(entries, entry{c.Row, lines})
+	}
+	for _, u := range plan.Updates {
+		lines := []string{fmt.Sprintf("~ row %d: update %s%s", u.Row, u.RecordID, mergedRowsNote(u.MergedRows))}
+		for _, ch := range u.Changes {
+			lines = append(lines, fmt.Sprintf("    %s: %q -> %q", ch.Field, ch.From, ch.To))
+		}
+		entries = append(entries, entry{u.Row, lines})
+	}
+	for _, n := range plan.Noops {
+		line := fmt.Sprintf("= row %d: no-op (%s)", n.Row, n.Reason)
+		if n.RecordID != "" {
+			line = fmt.Sprintf("= row %d: no-op %s (%s)", n.Row, n.RecordID, n.Reason)
+		}
+		entries = append(entries, entry{n.Row, []string{line}})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].row < entries[j].row })
+	for _, e := range entries {
+		fmt.Fprintln(os.Stdout, strings.Join(e.lines, "\n"))
+	}
+	fmt.Fprintf(os.Stdout, "\nPlan: %d to create, %d to update, %d unchanged.\n", len(plan.Creates), len(plan.Updates), len(plan.Noops))
+}
+
+// mergedRowsNote names the rows folded into a planned write, if any.
+func mergedRowsNote(rows []int) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(rows))
+	for _, r := range rows {
+		names = append(names, strconv.Itoa(r))
+	}
+	return " (with rows " + strings.Join(names, ", ") + ")"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type ApplyOptions struct {
+	PlanPath string
+	// TaskURL overrides the table recorded in the plan.
+	TaskURL string
+	// Force applies updates whose record changed since the plan was made.
+	Force bool
+	// CreateSelectOptions adds unknown select values as new options, see
+	// selectCodec.
+	CreateSelectOptions bool
+}
+
+// ApplyPlan executes a plan written by plan. Each update's record is read
+// again first: when a planned column no longer holds the value the plan saw,
+// the update is refused as a conflict (exit 1) unless Force is set. Each
+// create's upsert key is searched again too, and a create whose key a
+// record now carries is refused as a conflict, Force or not.
+func ApplyPlan(ctx context.Context, opts ApplyOptions) int {
+	path := strings.TrimSpace(opts.PlanPath)
+	if path == "" {
+		errLogger.Error("--plan is required")
+		return 2
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		errLogger.Error("read plan failed", "path", path, "err", err)
+		return 2
+	}
+	var plan taskPlan
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		errLogger.Error("parse plan failed", "path", path, "err", err)
+		return 2
+	}
+	if plan.Version != planVersion {
+		errLogger.Error("unsupported plan version", "version", plan.Version, "want", planVersion)
+		return 2
+	}
+	taskURL := strings.TrimSpace(opts.TaskURL)
+	if taskURL == "" {
+		taskURL = plan.TaskURL
+	}
+	tc, code := openTable(ctx, taskURL)
+	if tc == nil {
+		return code
+	}
+
+	start := time.Now()
+	errorsList := []string{}
+	// invalid counts the rows refused before writing.
+	invalid := 0
+	current := map[string]map[string]any{}
+	if len(plan.Updates) > 0 && !opts.Force {
+		ids := make([]string, 0, len(plan.Updates))
+		for _, u := range plan.Updates {
+			ids = append(ids, u.RecordID)
+		}
+		if current, err = batchGetRecordFields(ctx, tc.baseURL, tc.token, tc.ref, ids); err != nil {
+			errLogger.Error("read planned records failed", "err", err)
+			return 2
+		}
+	}
+
+	// taken is checked even with Force: creating a record whose key is
+	// already in the table makes a duplicate, not an overwrite.
+	taken, err := plannedKeysTaken(ctx, tc, plan.Key, plan.Creates)
+	if err != nil {
+		errLogger.Error("re-resolve planned creates failed", "err", err)
+		return exitCodeFor(err, exitUsage)
+	}
+
+	enc := newFieldEncoder(tc.baseURL, tc.token, tc.ref, tc.fields, opts.CreateSelectOptions)
+	// mergedRows maps a planned row to the rows merged into it, which
+	// share its outcome.
+	mergedRows := map[int][]int{}
+	requested := len(plan.Noops)
+	for _, c := range plan.Creates {
+		mergedRows[c.Row] = c.MergedRows
+		requested += 1 + len(c.MergedRows)
+	}
+	for _, u := range plan.Updates {
+		mergedRows[u.Row] = u.MergedRows
+		requested += 1 + len(u.MergedRows)
+	}
+	records := make([]createRec, 0, len(plan.Creates))
+	for _, c := range plan.Creates {
+		if rid, ok := taken[c.Row]; ok {
+			invalid += 1 + len(c.MergedRows)
+			errorsList = append(errorsList, fmt.Sprintf("row %d: conflict: record %s now has this %s; plan again to update it", c.Row, rid, plan.Key))
+			continue
+		}
+		if err := enc.encode(ctx, c.Fields); err != nil {
+			invalid += 1 + len(c.MergedRows)
+			errorsList = append(errorsList, fmt.Sprintf("row %d: %v", c.Row, err))
+			continue
+		}
+		records = append(records, createRec{Row: c.Row, BizTaskID: c.BizTaskID, Fields: c.Fields})
+	}
+	updates := []map[string]any{}
+	updateRows := []createdRecord{}
+	for _, u := range plan.Updates {
+		if !opts.Force {
+			fields, ok := current[u.RecordID]
+			if !ok {
+				invalid += 1 + len(u.MergedRows)
+				errorsList = append(errorsList, fmt.Sprintf("row %d: record %s no longer exists", u.Row, u.RecordID))
+				continue
+			}
+			if ch, drifted := planDrift(u, fields); drifted {
+				invalid += 1 + len(u.MergedRows)
+				errorsList = append(errorsList, fmt.Sprintf("row %d: record %s changed since plan: %s is %q, plan saw %q",
+					u.Row, u.RecordID, ch.Field, common.BitableValueToString(fields[ch.Field]), ch.From))
+				continue
+			}
+		}
+		if err := enc.encode(ctx, u.Fields); err != nil {
+			invalid += 1 + len(u.MergedRows)
+			errorsList = append(errorsList, fmt.Sprintf("row %d: %v", u.Row, err))
+			continue
+		}
+		updates = append(updates, map[string]any{"record_id": u.RecordID, "fields": u.Fields})
+		updateRows = append(updateRows, createdRecord{Row: u.Row, RecordID: u.RecordID, Action: "updated"})
+	}
+
+	written, errs, failedCreates := writeCreates(ctx, tc.baseURL, tc.token, tc.ref, records)
+	errorsList = append(errorsList, errs...)
+	updatedRows, lockedRows, errs, failedUpdates := writeUpdates(ctx, enc.schema, tc.fields, updates, updateRows)
+	errorsList = append(errorsList, errs...)
+	skipped := len(plan.Noops)
+	for _, l := range lockedRows {
+		skipped += 1 + len(mergedRows[l.Row])
+	}
+	written = append(written, updatedRows...)
+	for _, w := range written {
+		for _, row := range mergedRows[w.Row] {
+			written = append(written, createdRecord{Row: row, RecordID: w.RecordID, BizTaskID: w.BizTaskID, Action: w.Action})
+		}
+	}
+	sort.Slice(written, func(a, b int) bool { return written[a].Row < written[b].Row })
+	created, updated := 0, 0
+	for _, w := range written {
+		if w.Action == "updated" {
+			updated++
+		} else {
+			created++
+		}
+	}
+	failed := invalid
+	for _, m := range []map[int]string{failedCreates, failedUpdates} {
+		for row := range m {
+			failed += 1 + len(mergedRows[row])
+		}
+	}
+
+	report := createReport{
+		Created:        created,
+		Updated:        updated,
+		Requested:      requested,
+		Skipped:        skipped,
+		Failed:         failed,
+		Errors:         errorsList,
+		Records:        written,
+		ElapsedSeconds: float64(int(time.Since(start).Seconds()*1000)) / 1000,
+	}
+	printJSON(report)
+	if len(errorsList) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// plannedKeysTaken re-resolves the upsert key of each planned create and
+// returns the rows whose key value a record has taken since the plan was
+// made, with that record's ID. Plans keyed by RecordID never create a
+// record that could collide.
+func plannedKeysTaken(ctx context.Context, tc *tableClient, key string, creates []planCreate) (map[int]string, error) {
+	taken := map[int]string{}
+	key = strings.TrimSpace(key)
+	if key == "" || key == "RecordID" || len(creates) == 0 {
+		return taken, nil
+	}
+	col := strings.TrimSpace(tc.fields[key])
+	if col == "" {
+		col = key
+	}
+	values := []string{}
+	for _, c := range creates {
+		if v := strings.TrimSpace(common.BitableValueToString(c.Fields[col])); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return taken, nil
+	}
+	existing, err := findByColumn(ctx, tc.baseURL, tc.token, tc.ref, col, values)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range creates {
+		if t, ok := existing[strings.TrimSpace(common.BitableValueToString(c.Fields[col]))]; ok {
+			taken[c.Row] = t.RecordID
+		}
+	}
+	return taken, nil
+}
+
+// planDrift returns the first planned change whose From no longer matches
+// the record's current value.
+func planDrift(u planUpdate, fields map[string]any) (planChange, bool) {
+	for _, ch := range u.Changes {
+		if common.BitableValueToString(fields[ch.Field]) != ch.From {
+			return ch, true
+		}
+	}
+	return planChange{}, false
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultStuckAfter = time.Hour
+
+// fetchPreset is a named operational query. Status and Date replace the
+// fetch defaults unless set explicitly on the command line.
+type fetchPreset struct {
+	Description string
+	Status      string
+	Date        string
+	Conds       func(opts FetchOptions, now time.Time) []filterCond
+}
+
+var fetchPresets = map[string]fetchPreset{
+	"stuck-running": {
+		Description: "running tasks started more than --stuck-after ago (default 1h)",
+		Status:      "running",
+		Date:        "Any",
+		Conds: func(opts FetchOptions, now time.Time) []filterCond {
+			after := opts.StuckAfter
+			if after <= 0 {
+				after = defaultStuckAfter
+			}
+			cutoff := now.Add(-after).UnixMilli()
+			return []filterCond{{Field: "StartAt", Operator: "isLess", Value: []string{"ExactDate", strconv.FormatInt(cutoff, 10)}}}
+		},
+	},
+	"todays-failures": {
+		Description: "failed tasks that ended today",
+		Status:      "failed",
+		Date:        "Any",
+		Conds: func(FetchOptions, time.Time) []filterCond {
+			return []filterCond{{Field: "EndAt", Operator: "is", Value: []string{"Today"}}}
+		},
+	},
+	"unassigned": {
+		Description: "pending tasks not dispatched to any device",
+		Status:      "pending",
+		Date:        "Any",
+		Conds: func(FetchOptions, time.Time) []filterCond {
+			return []filterCond{{Field: "DispatchedDevice", Operator: "isEmpty"}}
+		},
+	},
+}
+
+func fetchPresetNames() []string {
+	names := make([]string, 0, len(fetchPresets))
+	for name := range fetchPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyFetchPreset fills opts from the named preset. explicit holds the flag
+// names set on the command line, which the preset does not override.
+func applyFetchPreset(opts *FetchOptions, explicit map[string]bool, now time.Time) error {
+	name := strings.TrimSpace(opts.Preset)
+	if name == "" {
+		return nil
+	}
+	p, ok := fetchPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(fetchPresetNames(), ", "))
+	}
+	if !explicit["status"] {
+		opts.Status = p.Status
+	}
+	if !explicit["date"] {
+		opts.Date = p.Date
+	}
+	if p.Conds != nil {
+		opts.conds = append(opts.conds, p.Conds(*opts, now)...)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// Probe states double as exit codes, following the Nagios plugin convention.
+const (
+	probeOK       = 0
+	probeWarning  = 1
+	probeCritical = 2
+	probeUnknown  = 3
+)
+
+var probeStateNames = map[int]string{
+	probeOK:       "OK",
+	probeWarning:  "WARNING",
+	probeCritical: "CRITICAL",
+	probeUnknown:  "UNKNOWN",
+}
+
+type ProbeOptions struct {
+	TaskURL    string
+	App        string
+	Scene      string
+	MaxAge     time.Duration
+	MinPending int
+	MaxPending int
+	Format     string
+	IgnoreView bool
+	ViewID     string
+}
+
+type probeReport struct {
+	State   string `json:"state"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Total   int    `json:"total"`
+	Pending int    `json:"pending"`
+	// NewestAt is the creation time of the most recent task; AgeSeconds is
+	// how long ago that was (-1 when the table has no tasks).
+	NewestAt       string   `json:"newest_at,omitempty"`
+	AgeSeconds     float64  `json:"age_seconds"`
+	MaxAgeSeconds  float64  `json:"max_age_seconds,omitempty"`
+	MinPending     int      `json:"min_pending,omitempty"`
+	MaxPending     int      `json:"max_pending,omitempty"`
+	Problems       []string `json:"problems,omitempty"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+}
+
+// ProbeTasks checks queue health for monitoring systems: the newest task
+// must be younger than MaxAge (else CRITICAL) and the pending backlog must
+// lie within [MinPending, MaxPending] (else WARNING). It returns the state
+// as the exit code; setup and API failures are UNKNOWN.
+func ProbeTasks(ctx context.Context, opts ProbeOptions) int {
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	if format != "nagios" && format != "json" {
+		errLogger.Error("--format must be nagios or json", "format", opts.Format)
+		return probeUnknown
+	}
+	if opts.MaxAge < 0 || opts.MinPending < 0 || opts.MaxPending < 0 {
+		errLogger.Error("--max-age, --min-pending and --max-pending must not be negative")
+		return probeUnknown
+	}
+	if opts.MaxPending > 0 && opts.MaxPending < opts.MinPending {
+		errLogger.Error("--max-pending must not be below --min-pending", "min_pending", opts.MinPending, "max_pending", opts.MaxPending)
+		return probeUnknown
+	}
+
+	start := time.Now()
+	report := probeReport{
+		AgeSeconds:    -1,
+		MaxAgeSeconds: opts.MaxAge.Seconds(),
+		MinPending:    opts.MinPending,
+		MaxPending:    opts.MaxPending,
+	}
+	finish := func(code int, message string) int {
+		report.Code = code
+		report.State = probeStateNames[code]
+		report.Message = message
+		report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+		if format == "json" {
+			printJSON(report)
+		} else {
+			printProbeNagios(report)
+		}
+		return code
+	}
+
+	tc, _ := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return finish(probeUnknown, "cannot open task table")
+	}
+	statusCol := strings.TrimSpace(tc.fields["Status"])
+	body := map[string]any{"automatic_fields": true}
+	if statusCol != "" {
+		body["field_names"] = []string{statusCol}
+	}
+	if filterObj := buildFilter(tc.fields, opts.App, opts.Scene, "", ""); filterObj != nil {
+		body["filter"] = filterObj
+	}
+	viewID := strings.TrimSpace(opts.ViewID)
+	if viewID == "" {
+		viewID = tc.ref.ViewID
+	}
+	if !opts.IgnoreView && viewID != "" {
+		body["view_id"] = viewID
+	}
+
+	var newest int64
+	err := scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		report.Total++
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if strings.EqualFold(strings.TrimSpace(common.NormalizeBitableValue(fieldsRaw[statusCol])), "pending") {
+			report.Pending++
+		}
+		if created, ok := common.CoerceMillis(item["created_time"]); ok && created > newest {
+			newest = created
+		}
+	})
+	if err != nil {
+		errLogger.Error("scan tasks failed", "err", err)
+		return finish(probeUnknown, "scan tasks failed: "+err.Error())
+	}
+
+	code := probeOK
+	if newest > 0 {
+		at := time.UnixMilli(newest)
+		report.NewestAt = at.In(common.TaskTimezone()).Format(time.RFC3339)
+		report.AgeSeconds = float64(int(time.Since(at).Seconds()))
+	}
+	if opts.MaxAge > 0 {
+		if newest == 0 {
+			report.Problems = append(report.Problems, "no tasks found")
+			code = probeCritical
+		} else if age := time.Since(time.UnixMilli(newest)); age > opts.MaxAge {
+			report.Problems = append(report.Problems, fmt.Sprintf("newest task is %s old (max %s)", age.Truncate(time.Second), opts.MaxAge))
+			code = probeCritical
+		}
+	}
+	if report.Pending < opts.MinPending {
+		report.Problems = append(report.Problems, fmt.Sprintf("%d pending (min %d)", report.Pending, opts.MinPending))
+		code = maxInt(code, probeWarning)
+	}
+	if opts.MaxPending > 0 && report.Pending > opts.MaxPending {
+		report.Problems = append(report.Problems, fmt.Sprintf("%d pending (max %d)", report.Pending, opts.MaxPending))
+		code = maxInt(code, probeWarning)
+	}
+
+	message := fmt.Sprintf("%d pending of %d tasks", report.Pending, report.Total)
+	if report.AgeSeconds >= 0 {
+		message += fmt.Sprintf(", newest %s ago", (time.Duration(report.AgeSeconds) * time.Second).String())
+	}
+	if len(report.Problems) > 0 {
+		message = strings.Join(report.Problems, "; ") + " - " + message
+	}
+	return finish(code, message)
+}
+
+// printProbeNagios prints one plugin status line with performance data, e.g.
+// "BITABLE_QUEUE OK - 3 pending of 40 tasks, newest 2m0s ago | pending=3;1:;;0 ...".
+func printProbeNagios(r probeReport) {
+	pendingWarn := ""
+	if r.MinPending > 0 || r.MaxPending > 0 {
+		pendingWarn = fmt.Sprintf("%d:", r.MinPending)
+		if r.MaxPending > 0 {
+			pendingWarn += fmt.Sprintf("%d", r.MaxPending)
+		}
+	}
+	ageCrit := ""
+	if r.MaxAgeSeconds > 0 {
+		ageCrit = fmt.Sprintf("%d", int(r.MaxAgeSeconds))
+	}
+	perf := fmt.Sprintf("pending=%d;%s;;0 total=%d;;;0", r.Pending, pendingWarn, r.Total)
+	if r.AgeSeconds >= 0 {
+		perf += fmt.Sprintf(" age=%ds;;%s;0", int(r.AgeSeconds), ageCrit)
+	}
+	fmt.Printf("BITABLE_QUEUE %s - %s | %s\n", r.State, r.Message, perf)
+}
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package cli
+
+import "os/exec"
+
+func configureCommand(cmd *exec.Cmd) {}
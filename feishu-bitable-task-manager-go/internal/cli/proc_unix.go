@@ -0,0 +1,18 @@
+//go:build unix
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureCommand runs cmd in its own process group, so a terminal Ctrl+C
+// aimed at the worker does not reach the handler, and cancellation kills
+// the handler together with any children it spawned.
+func configureCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package cli
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// configureCommand starts cmd in a new process group, so Ctrl+C / Ctrl+Break
+// in the worker's console is not delivered to the handler, and cancellation
+// terminates the whole process tree (TerminateProcess alone would leave
+// adb or shell children behind).
+func configureCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// taskFieldKeys maps logical task fields to their key in the Task JSON.
+var taskFieldKeys = map[string]string{
+	"TaskID":           "task_id",
+	"BizTaskID":        "biz_task_id",
+	"ParentTaskID":     "parent_task_id",
+	"App":              "app",
+	"Scene":            "scene",
+	"Params":           "params",
+	"ItemID":           "item_id",
+	"BookID":           "book_id",
+	"URL":              "url",
+	"UserID":           "user_id",
+	"UserName":         "user_name",
+	"Date":             "date",
+	"Status":           "status",
+	"Extra":            "extra",
+	"Logs":             "logs",
+	"LastScreenShot":   "last_screenshot",
+	"GroupID":          "group_id",
+	"DeviceSerial":     "device_serial",
+	"DispatchedDevice": "dispatched_device",
+	"DispatchedAt":     "dispatched_at",
+	"HeartbeatAt":      "heartbeat_at",
+	"StartAt":          "start_at",
+	"EndAt":            "end_at",
+	"ElapsedSeconds":   "elapsed_seconds",
+	"ItemsCollected":   "items_collected",
+	"RetryCount":       "retry_count",
+	"Artifacts":        "artifacts",
+	"AttemptToken":     "attempt_token",
+	"TraceID":          "trace_id",
+}
+
+// taskIdentityFields are read even when not projected: decodeTask needs a
+// TaskID and one of the target fields to accept a record.
+var taskIdentityFields = []string{"TaskID", "Params", "ItemID", "BookID", "URL", "UserID", "UserName"}
+
+// taskProjection is a parsed --fields list: the logical fields to output,
+// in the order given.
+type taskProjection struct {
+	fields []string
+}
+
+// parseTaskProjection reads --fields "TaskID,URL,Status". Names are logical
+// fields or their JSON keys (task_id), case-insensitive. record_id is always
+// output so results can be written back.
+func parseTaskProjection(spec string) (*taskProjection, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	byName := map[string]string{}
+	for logical, key := range taskFieldKeys {
+		byName[strings.ToLower(logical)] = logical
+		byName[key] = logical
+	}
+	p := &taskProjection{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		name := strings.TrimSpace(part)
+		if name == "" || strings.EqualFold(name, "record_id") || strings.EqualFold(name, "RecordID") {
+			continue
+		}
+		logical, ok := byName[strings.ToLower(name)]
+		if !ok {
+			known := make([]string, 0, len(taskFieldKeys))
+			for l := range taskFieldKeys {
+				known = append(known, l)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown field %q (valid: %s)", name, strings.Join(known, ", "))
+		}
+		if !seen[logical] {
+			seen[logical] = true
+			p.fields = append(p.fields, logical)
+		}
+	}
+	return p, nil
+}
+
+// columns returns the columns to request: the projected fields plus what
+// decoding needs (identity fields, Deleted unless includeDeleted, Extra
+// overflow columns). The search API rejects unknown field_names, so columns
+// missing from the table (optional fields left at their default) are
+// dropped.
+func (p *taskProjection) columns(mapping map[string]string, existing map[string]common.FieldInfo, includeDeleted bool, extras *extraCodec) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	add := func(col string) {
+		if _, ok := existing[strings.TrimSpace(col)]; !ok {
+			return
+		}
+		if col = strings.TrimSpace(col); col != "" && !seen[col] {
+			seen[col] = true
+			out = append(out, col)
+		}
+	}
+	for _, f := range p.fields {
+		add(mapping[f])
+		if f == "Extra" && extras != nil {
+			for _, c := range extras.overflow {
+				add(c)
+			}
+		}
+	}
+	for _, f := range taskIdentityFields {
+		add(mapping[f])
+	}
+	if !includeDeleted {
+		add(mapping["Deleted"])
+	}
+	return out
+}
+
+// apply returns t with only the projected fields, as an ordered JSON object.
+func (p *taskProjection) apply(t Task) projectedTask {
+	raw, _ := json.Marshal(t)
+	var all map[string]json.RawMessage
+	_ = json.Unmarshal(raw, &all)
+	out := projectedTask{}
+	for _, f := range p.fields {
+		key := taskFieldKeys[f]
+		v, ok := all[key]
+		if !ok {
+			v = json.RawMessage(`""`)
+		}
+		out = append(out, projectedField{key, v})
+	}
+	out = append(out, projectedField{"record_id", all["record_id"]})
+	if v, ok := all["raw_fields"]; ok {
+		out = append(out, projectedField{"raw_fields", v})
+	}
+	return out
+}
+
+// output is apply, or t itself when no projection was requested.
+func (p *taskProjection) output(t Task) any {
+	if p == nil {
+		return t
+	}
+	return p.apply(t)
+}
+
+type projectedField struct {
+	key   string
+	value json.RawMessage
+}
+
+// projectedTask marshals as a JSON object keeping the --fields order, so
+// JSONL lines have a stable shape.
+type projectedTask []projectedField
+
+func (t projectedTask) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, f := range t {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(f.value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+func (t projectedTask) String() string {
+	raw, _ := t.MarshalJSON()
+	return string(raw)
+}
@@ -0,0 +1,450 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// queueFailedDir holds entries whose replay failed for a reason waiting
+// will not fix (validation, conflicts, missing records).
+const queueFailedDir = "failed"
+
+// queueLockName is the file a replaying process creates in the queue
+// directory, so processes sharing it replay one at a time and in order.
+const queueLockName = ".lock"
+
+// queueLockStale is how long a lock may go untouched before it is taken
+// over from a process that died mid-replay. The holder touches it before
+// each entry.
+const queueLockStale = 10 * time.Minute
+
+var errQueueBusy = errors.New("queue is being replayed by another process")
+
+// queueEntry is one queued mutation: update rows, already loaded and
+// transformed, with the options needed to send them again. Each entry is
+// a file in the queue directory; names sort in queueing order.
+type queueEntry struct {
+	QueuedAt            string           `json:"queued_at"`
+	Error               string           `json:"error"`
+	TaskURL             string           `json:"task_url"`
+	IgnoreView          bool             `json:"ignore_view,omitempty"`
+	ViewID              string           `json:"view_id,omitempty"`
+	SkipStatus          string           `json:"skip_status,omitempty"`
+	ExpectStatus        string           `json:"expect_status,omitempty"`
+	RunsURL             string           `json:"runs_url,omitempty"`
+	CreateSelectOptions bool             `json:"create_select_options,omitempty"`
+	Updates             []map[string]any `json:"updates"`
+
+	path     string
+	requeued bool
+}
+
+func (e *queueEntry) updateOptions() UpdateOptions {
+	return UpdateOptions{
+		TaskURL:             e.TaskURL,
+		IgnoreView:          e.IgnoreView,
+		ViewID:              e.ViewID,
+		SkipStatus:          e.SkipStatus,
+		ExpectStatus:        e.ExpectStatus,
+		RunsURL:             e.RunsURL,
+		CreateSelectOptions: e.CreateSelectOptions,
+	}
+}
+
+// mutationQueue keeps updates that could not reach Feishu. A nil
+// *mutationQueue queues nothing.
+type mutationQueue struct {
+	dir  string
+	base queueEntry
+	// replay is the entry being replayed: rows that fail again are written
+	// back to it instead of to a new entry, so they keep their place.
+	replay *queueEntry
+}
+
+// newMutationQueue returns the queue of opts (QueueDir, or the entry being
+// replayed), or nil when it has none.
+func newMutationQueue(opts UpdateOptions) *mutationQueue {
+	if opts.replay != nil {
+		return &mutationQueue{dir: filepath.Dir(opts.replay.path), base: *opts.replay, replay: opts.replay}
+	}
+	dir := strings.TrimSpace(opts.QueueDir)
+	if dir == "" {
+		return nil
+	}
+	return &mutationQueue{dir: dir, base: queueEntry{
+		TaskURL:             opts.TaskURL,
+		IgnoreView:          opts.IgnoreView,
+		ViewID:              opts.ViewID,
+		SkipStatus:          opts.SkipStatus,
+		ExpectStatus:        opts.ExpectStatus,
+		RunsURL:             opts.RunsURL,
+		CreateSelectOptions: opts.CreateSelectOptions,
+	}}
+}
+
+// offer queues rows when err means Feishu could not be reached (network
+// errors, timeouts, 5xx, rate limits) and reports whether it did. Any other
+// error, or a queue that cannot be written, leaves the failure to the
+// caller.
+func (q *mutationQueue) offer(err error, rows []map[string]any) bool {
+	if q == nil || len(rows) == 0 || !queueable(err) {
+		return false
+	}
+	e := q.base
+	e.QueuedAt = time.Now().Format(time.RFC3339)
+	e.Error = err.Error()
+	// Rows hold every update key; the empty ones read back the same.
+	e.Updates = make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		e.Updates = append(e.Updates, compactInput(row))
+	}
+	path := ""
+	if q.replay != nil {
+		// Several batches of one replay can fail; keep them all.
+		if q.replay.requeued {
+			e.Updates = append(q.replay.Updates, e.Updates...)
+		}
+		path = q.replay.path
+	}
+	path, werr := writeQueueEntry(q.dir, path, e)
+	if werr != nil {
+		errLogger.Error("queue updates failed", "dir", q.dir, "err", werr)
+		return false
+	}
+	if q.replay != nil {
+		q.replay.Updates, q.replay.requeued = e.Updates, true
+	}
+	errLogger.Warn("feishu unreachable, updates queued for replay", "rows", len(rows), "entry", filepath.Base(path), "err", err)
+	return true
+}
+
+// queueable reports whether a later replay may get past err.
+func queueable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var httpErr *common.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Status >= 500 {
+		return true
+	}
+	if exitCodeFor(err, 0) == exitRateLimited {
+		return true
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// queueFile is the on-disk form of a queue entry. Checksum is the SHA-256
+// of the compact entry JSON, so a damaged file is recognised instead of
+// replayed. With BITABLE_STORE_KEY set the entry is Sealed instead of
+// stored in Entry. Files written before checksums existed hold a bare
+// queueEntry and are read as is.
+type queueFile struct {
+	Checksum string          `json:"checksum"`
+	Entry    json.RawMessage `json:"entry,omitempty"`
+	Sealed   *common.Sealed  `json:"sealed,omitempty"`
+}
+
+func queueChecksum(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeQueueEntry writes e to path, or to a new file in dir when path is
+// empty, through a temporary file so a crash never leaves half an entry.
+func writeQueueEntry(dir, path string, e queueEntry) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = filepath.Join(dir, fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), common.NewUUID()[:8]))
+	}
+	entry, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	file := queueFile{Checksum: queueChecksum(entry), Entry: entry}
+	if key := common.StoreKey(); key != nil {
+		if file.Sealed, err = key.Seal(entry); err != nil {
+			return "", err
+		}
+		file.Entry = nil
+	}
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(raw); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// decodeQueueEntry parses one queue file. Errors wrapping
+// common.ErrStoreKey mean the key is wrong, not that the file is damaged.
+func decodeQueueEntry(raw []byte, key *common.SealKey) (*queueEntry, error) {
+	var file queueFile
+	if err := common.DecodeJSON(raw, &file); err != nil {
+		return nil, err
+	}
+	e := &queueEntry{}
+	if file.Checksum == "" && file.Entry == nil && file.Sealed == nil {
+		// Written before checksums; nothing to verify.
+		if err := common.DecodeJSON(raw, e); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	entry := []byte(file.Entry)
+	if file.Sealed != nil {
+		var err error
+		if entry, err = common.OpenSealed(key, file.Sealed); err != nil {
+			return nil, err
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, entry); err != nil {
+			return nil, err
+		}
+		entry = buf.Bytes()
+	}
+	if sum := queueChecksum(entry); sum != file.Checksum {
+		return nil, fmt.Errorf("checksum mismatch: file says %s, entry is %s", file.Checksum, sum)
+	}
+	if err := common.DecodeJSON(entry, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// corruptEntry is a queue file that cannot be replayed as written.
+type corruptEntry struct {
+	path string
+	err  error
+}
+
+// readQueue lists the entries of dir in queueing order. Files that do not
+// parse or fail their checksum come back in corrupt rather than failing
+// the whole read; a missing or wrong BITABLE_STORE_KEY is an error, since
+// every sealed entry would otherwise look corrupt.
+func readQueue(dir string) ([]*queueEntry, []corruptEntry, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(names)
+	key := common.StoreKey()
+	entries := make([]*queueEntry, 0, len(names))
+	var corrupt []corruptEntry
+	for _, name := range names {
+		raw, err := os.ReadFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := decodeQueueEntry(raw, key)
+		if errors.Is(err, common.ErrStoreKey) {
+			return nil, nil, fmt.Errorf("%s: %w", filepath.Base(name), err)
+		}
+		if err != nil {
+			corrupt = append(corrupt, corruptEntry{path: name, err: err})
+			continue
+		}
+		e.path = name
+		entries = append(entries, e)
+	}
+	return entries, corrupt, nil
+}
+
+type queueLock struct {
+	path string
+}
+
+// lockQueue takes the replay lock of dir, or returns errQueueBusy while
+// another process holds it.
+func lockQueue(dir string) (*queueLock, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, queueLockName)
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			host, _ := os.Hostname()
+			_, err = fmt.Fprintf(f, "%s %d %s\n", host, os.Getpid(), time.Now().Format(time.RFC3339))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &queueLock{path: path}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		fi, err := os.Stat(path)
+		if err == nil && time.Since(fi.ModTime()) < queueLockStale {
+			return nil, errQueueBusy
+		}
+		if err == nil {
+			owner, _ := os.ReadFile(path)
+			errLogger.Warn("taking over stale queue lock", "dir", dir, "held_by", strings.TrimSpace(string(owner)), "since", fi.ModTime().Format(time.RFC3339))
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	return nil, errQueueBusy
+}
+
+// touch keeps the lock from going stale during a long replay.
+func (l *queueLock) touch() {
+	now := time.Now()
+	_ = os.Chtimes(l.path, now, now)
+}
+
+func (l *queueLock) unlock() {
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errLogger.Warn("remove queue lock failed", "path", l.path, "err", err)
+	}
+}
+
+type FlushOptions struct {
+	QueueDir string
+}
+
+type flushReport struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+	// Corrupt counts entries that did not parse or failed their checksum;
+	// they are moved to failed/ without being replayed.
+	Corrupt   int `json:"corrupt"`
+	Remaining int `json:"remaining"`
+}
+
+// FlushQueue replays the queued updates of opts.QueueDir, oldest first.
+func FlushQueue(ctx context.Context, opts FlushOptions) int {
+	dir := strings.TrimSpace(opts.QueueDir)
+	if dir == "" {
+		errLogger.Error("--queue-dir (or BITABLE_QUEUE_DIR) is required")
+		return 2
+	}
+	report, err := flushQueue(ctx, dir)
+	if errors.Is(err, errQueueBusy) {
+		errLogger.Error("queue is being replayed by another process; try again later", "dir", dir, "lock", filepath.Join(dir, queueLockName))
+		return 1
+	}
+	if err != nil {
+		errLogger.Error("read queue failed", "dir", dir, "err", err)
+		return 2
+	}
+	printJSON(report)
+	if report.Failed > 0 || report.Corrupt > 0 || report.Remaining > 0 {
+		return 1
+	}
+	return 0
+}
+
+// flushQueue replays entries in order and stops at the first one that is
+// queued again, since Feishu is still out of reach and later updates to the
+// same records must not overtake it. An entry that fails for another reason
+// is moved to the failed/ subdirectory; so is a corrupt entry, before the
+// replay starts. It returns errQueueBusy while another process is
+// replaying dir.
+func flushQueue(ctx context.Context, dir string) (flushReport, error) {
+	var report flushReport
+	lock, err := lockQueue(dir)
+	if err != nil {
+		return report, err
+	}
+	defer lock.unlock()
+	entries, corrupt, err := readQueue(dir)
+	if err != nil {
+		return report, err
+	}
+	for _, c := range corrupt {
+		report.Corrupt++
+		if err := moveToFailed(dir, c.path); err != nil {
+			return report, err
+		}
+		errLogger.Error("queue entry is corrupt; not replaying it", "entry", filepath.Base(c.path), "moved_to", filepath.Join(dir, queueFailedDir), "err", c.err)
+	}
+	for i, e := range entries {
+		if ctx.Err() != nil {
+			report.Remaining = len(entries) - i
+			break
+		}
+		lock.touch()
+		opts := e.updateOptions()
+		opts.replay = e
+		code := UpdateTasks(ctx, opts)
+		switch {
+		case e.requeued, ctx.Err() != nil:
+			// An interrupted replay is sent again next time; the writes
+			// it already made are simply repeated.
+			report.Remaining = len(entries) - i
+			return report, nil
+		case code != exitOK:
+			report.Failed++
+			if err := moveToFailed(dir, e.path); err != nil {
+				return report, err
+			}
+			errLogger.Error("queued updates could not be replayed", "entry", filepath.Base(e.path), "moved_to", filepath.Join(dir, queueFailedDir), "exit_code", code)
+		default:
+			report.Replayed++
+			// Already gone means it was removed by hand; either way it is
+			// not replayed again.
+			if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// moveToFailed moves the queue file at path into dir's failed/
+// subdirectory. A file already gone was removed by hand.
+func moveToFailed(dir, path string) error {
+	failed := filepath.Join(dir, queueFailedDir)
+	if err := os.MkdirAll(failed, 0o700); err != nil {
+		return err
+	}
+	if err := os.Rename(path, filepath.Join(failed, filepath.Base(path))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
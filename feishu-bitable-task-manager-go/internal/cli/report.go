@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+// failureStatuses are counted as failures in the report's top failing
+// scenes.
+var failureStatuses = map[taskmodel.Status]bool{
+	taskmodel.StatusFailed:    true,
+	taskmodel.StatusError:     true,
+	taskmodel.StatusTimeout:   true,
+	taskmodel.StatusExhausted: true,
+}
+
+type ReportOptions struct {
+	TaskURL    string
+	App        string
+	Scene      string
+	Date       string
+	IgnoreView bool
+	ViewID     string
+	// Top is how many failing scenes to list.
+	Top int
+	// PublishURL is a docx or wiki link whose block (BlockID, else the URL
+	// fragment) is rewritten with the snapshot.
+	PublishURL string
+	BlockID    string
+}
+
+type statusCount struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+type sceneFailures struct {
+	App         string  `json:"app"`
+	Scene       string  `json:"scene"`
+	Failed      int     `json:"failed"`
+	Total       int     `json:"total"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+type queueReport struct {
+	GeneratedAt    string          `json:"generated_at"`
+	Total          int             `json:"total"`
+	ByStatus       []statusCount   `json:"by_status"`
+	TopFailing     []sceneFailures `json:"top_failing_scenes"`
+	Published      string          `json:"published,omitempty"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+}
+
+// ReportTasks prints a queue snapshot: counts by status and the scenes with
+// the most failures. With PublishURL it also rewrites a document block with
+// the snapshot, so a landing page shows current health; run it from cron.
+func ReportTasks(ctx context.Context, opts ReportOptions) int {
+	if opts.Top < 0 {
+		errLogger.Error("--top must not be negative", "top", opts.Top)
+		return 2
+	}
+	var doc common.DocRef
+	if strings.TrimSpace(opts.PublishURL) != "" {
+		var err error
+		if doc, err = common.ParseDocURL(opts.PublishURL); err != nil {
+			errLogger.Error("parse --publish URL failed", "err", err)
+			return 2
+		}
+		if id := strings.TrimSpace(opts.BlockID); id != "" {
+			doc.BlockID = id
+		}
+		if doc.BlockID == "" {
+			errLogger.Error("--publish needs a block: pass --block-id or a URL with #<block_id>")
+			return 2
+		}
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+
+	start := time.Now()
+	body := map[string]any{}
+	if filterObj := buildFilter(tc.fields, opts.App, opts.Scene, "", opts.Date); filterObj != nil {
+		body["filter"] = filterObj
+	}
+	viewID := strings.TrimSpace(opts.ViewID)
+	if viewID == "" {
+		viewID = tc.ref.ViewID
+	}
+	if !opts.IgnoreView && viewID != "" {
+		body["view_id"] = viewID
+	}
+
+	byStatus := map[string]int{}
+	scenes := map[string]*sceneFailures{}
+	total := 0
+	err := scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if isSoftDeleted(fieldsRaw, tc.fields) {
+			return
+		}
+		total++
+		status := strings.ToLower(common.BitableValueToString(fieldsRaw[tc.fields["Status"]]))
+		byStatus[status]++
+		app := common.BitableValueToString(fieldsRaw[tc.fields["App"]])
+		scene := common.BitableValueToString(fieldsRaw[tc.fields["Scene"]])
+		key := app + "\x1f" + scene
+		sf := scenes[key]
+		if sf == nil {
+			sf = &sceneFailures{App: app, Scene: scene}
+			scenes[key] = sf
+		}
+		sf.Total++
+		if failureStatuses[taskmodel.Status(status)] {
+			sf.Failed++
+		}
+	})
+	if err != nil {
+		errLogger.Error("scan tasks failed", "err", err)
+		return 1
+	}
+
+	report := queueReport{
+		GeneratedAt: time.Now().In(common.TaskTimezone()).Format(time.RFC3339),
+		Total:       total,
+		ByStatus:    []statusCount{},
+		TopFailing:  []sceneFailures{},
+	}
+	// Known statuses in lifecycle order, then any others by name.
+	for _, st := range taskmodel.Statuses {
+		if n := byStatus[string(st)]; n > 0 {
+			report.ByStatus = append(report.ByStatus, statusCount{Status: string(st), Count: n})
+			delete(byStatus, string(st))
+		}
+	}
+	others := make([]string, 0, len(byStatus))
+	for st := range byStatus {
+		others = append(others, st)
+	}
+	sort.Strings(others)
+	for _, st := range others {
+		report.ByStatus = append(report.ByStatus, statusCount{Status: st, Count: byStatus[st]})
+	}
+	for _, sf := range scenes {
+		if sf.Failed > 0 {
+			sf.FailureRate = float64(int(float64(sf.Failed)/float64(sf.Total)*1000)) / 1000
+			report.TopFailing = append(report.TopFailing, *sf)
+		}
+	}
+	sort.Slice(report.TopFailing, func(i, j int) bool {
+		a, b := report.TopFailing[i], report.TopFailing[j]
+		if a.Failed != b.Failed {
+			return a.Failed > b.Failed
+		}
+		if a.App != b.App {
+			return a.App < b.App
+		}
+		return a.Scene < b.Scene
+	})
+	if len(report.TopFailing) > opts.Top {
+		report.TopFailing = report.TopFailing[:opts.Top]
+	}
+
+	code = 0
+	if doc.BlockID != "" {
+		if err := publishReport(ctx, tc, doc, report); err != nil {
+			errLogger.Error("publish report failed", "url", doc.RawURL, "block_id", doc.BlockID, "err", err)
+			code = 1
+		} else {
+			report.Published = doc.BlockID
+		}
+	}
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	return code
+}
+
+func publishReport(ctx context.Context, tc *tableClient, doc common.DocRef, report queueReport) error {
+	if doc.DocumentID == "" {
+		id, err := common.ResolveWikiObjToken(ctx, tc.baseURL, tc.token, doc.WikiToken, "docx")
+		if err != nil {
+			return fmt.Errorf("resolve wiki document: %w", err)
+		}
+		doc.DocumentID = id
+	}
+	return common.ReplaceBlockText(ctx, tc.baseURL, tc.token, doc.DocumentID, doc.BlockID, reportLines(report))
+}
+
+// reportLines renders the snapshot as the paragraphs written to the
+// document.
+func reportLines(r queueReport) []string {
+	lines := []string{fmt.Sprintf("Task queue snapshot at %s: %d tasks", r.GeneratedAt, r.Total)}
+	parts := make([]string, 0, len(r.ByStatus))
+	for _, sc := range r.ByStatus {
+		name := sc.Status
+		if name == "" {
+			name = "(no status)"
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", name, sc.Count))
+	}
+	if len(parts) > 0 {
+		lines = append(lines, "By status: "+strings.Join(parts, " · "))
+	}
+	if len(r.TopFailing) == 0 {
+		return append(lines, "No failing scenes.")
+	}
+	lines = append(lines, "Top failing scenes:")
+	for i, sf := range r.TopFailing {
+		lines = append(lines, fmt.Sprintf("%d. %s / %s: %d failed of %d (%.1f%%)", i+1, sf.App, sf.Scene, sf.Failed, sf.Total, sf.FailureRate*100))
+	}
+	return lines
+}
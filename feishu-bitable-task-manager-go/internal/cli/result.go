@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// rowResults collects what happened to each input row of create or update
+// for --json-result. A nil *rowResults ignores every call, so the write
+// paths record rows unconditionally.
+type rowResults struct {
+	Created []string     `json:"created"`
+	Updated []string     `json:"updated"`
+	Skipped []skippedRow `json:"skipped"`
+	Failed  []failedRow  `json:"failed"`
+	// Queued rows could not reach Feishu and wait in --queue-dir.
+	Queued []failedRow `json:"queued,omitempty"`
+}
+
+type skippedRow struct {
+	Input    any    `json:"input"`
+	RecordID string `json:"record_id,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+type failedRow struct {
+	Input any    `json:"input"`
+	Error string `json:"error"`
+}
+
+func newRowResults(enabled bool) *rowResults {
+	if !enabled {
+		return nil
+	}
+	return &rowResults{Created: []string{}, Updated: []string{}, Skipped: []skippedRow{}, Failed: []failedRow{}}
+}
+
+func (r *rowResults) created(recordID string) {
+	if r != nil {
+		r.Created = append(r.Created, recordID)
+	}
+}
+
+func (r *rowResults) updated(recordID string) {
+	if r != nil {
+		r.Updated = append(r.Updated, recordID)
+	}
+}
+
+func (r *rowResults) skip(input map[string]any, recordID, reason string) {
+	if r != nil {
+		r.Skipped = append(r.Skipped, skippedRow{Input: compactInput(input), RecordID: recordID, Reason: reason})
+	}
+}
+
+func (r *rowResults) fail(input map[string]any, err string) {
+	if r != nil {
+		r.Failed = append(r.Failed, failedRow{Input: compactInput(input), Error: err})
+	}
+}
+
+func (r *rowResults) queue(input map[string]any, err string) {
+	if r != nil {
+		r.Queued = append(r.Queued, failedRow{Input: compactInput(input), Error: err})
+	}
+}
+
+// compactInput drops the keys input loading fills with empty defaults, so a
+// row is echoed roughly as it was given.
+func compactInput(input map[string]any) map[string]any {
+	out := make(map[string]any, len(input))
+	for k, v := range input {
+		switch x := v.(type) {
+		case nil:
+			continue
+		case string:
+			if x == "" {
+				continue
+			}
+		case bool:
+			if !x {
+				continue
+			}
+		case map[string]any:
+			if len(x) == 0 {
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// print writes the result as one JSON object on stdout. The command's usual
+// report is logged to stderr instead, so stdout holds nothing else.
+func (r *rowResults) print(report any) {
+	errLogger.Info("result", "data", report)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		errLogger.Error("write result failed", "err", err)
+	}
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+const exhaustedStatus = string(taskmodel.StatusExhausted)
+
+type RetryOptions struct {
+	TaskURL    string
+	App        string
+	Scene      string
+	Status     string
+	Date       string
+	Limit      int
+	MaxRetries int
+	DryRun     bool
+}
+
+type retryReport struct {
+	Matched        int      `json:"matched"`
+	Requeued       int      `json:"requeued"`
+	Exhausted      int      `json:"exhausted"`
+	Locked         int      `json:"locked,omitempty"`
+	DryRun         bool     `json:"dry_run"`
+	RecordIDs      []string `json:"record_ids"`
+	Failed         int      `json:"failed"`
+	Errors         []string `json:"errors"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+}
+
+// RetryTasks requeues failed tasks: Status=pending, dispatch fields cleared
+// and RetryCount incremented. Tasks that already used --max-retries are
+// marked exhausted instead.
+func RetryTasks(ctx context.Context, opts RetryOptions) int {
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	statusCol := strings.TrimSpace(tc.fields["Status"])
+	if statusCol == "" {
+		errLogger.Error("Status field is not mapped")
+		return 2
+	}
+
+	// Optional columns (HeartbeatAt, AttemptToken) are mapped by default but
+	// may not exist; clearing a missing column fails the whole batch.
+	schema, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		errLogger.Error("list fields failed", "err", err)
+		return 2
+	}
+	columns := common.FieldsByName(schema)
+	clearCols := []string{}
+	for _, key := range []string{"DispatchedDevice", "DispatchedAt", "StartAt", "EndAt", "HeartbeatAt", "AttemptToken"} {
+		if col := strings.TrimSpace(tc.fields[key]); col != "" {
+			if _, ok := columns[col]; ok {
+				clearCols = append(clearCols, col)
+			}
+		}
+	}
+
+	start := time.Now()
+	filterObj := buildFilter(tc.fields, opts.App, opts.Scene, opts.Status, opts.Date)
+	var body map[string]any
+	if filterObj != nil {
+		body = map[string]any{"filter": filterObj}
+	}
+	type match struct {
+		recordID string
+		retries  int
+	}
+	matches := []match{}
+	locked := 0
+	now := time.Now()
+	err = scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		recordID := strings.TrimSpace(common.BitableValueToString(item["record_id"]))
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if recordID == "" {
+			return
+		}
+		if l, ok := activeEditLock(fieldsRaw, tc.fields, now); ok {
+			logSkippedLocked(recordID, l, "retry")
+			locked++
+			return
+		}
+		retries, _ := common.CoerceInt(common.BitableValueToString(fieldsRaw[tc.fields["RetryCount"]]))
+		matches = append(matches, match{recordID: recordID, retries: retries})
+	})
+	if err != nil {
+		errLogger.Error("search failed tasks failed", "err", err)
+		return 2
+	}
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+
+	report := retryReport{Matched: len(matches), Locked: locked, DryRun: opts.DryRun, RecordIDs: []string{}, Errors: []string{}}
+	records := make([]map[string]any, 0, len(matches))
+	exhausted := map[string]bool{}
+	for _, m := range matches {
+		report.RecordIDs = append(report.RecordIDs, m.recordID)
+		fields := map[string]any{}
+		if opts.MaxRetries > 0 && m.retries >= opts.MaxRetries {
+			fields[statusCol] = exhaustedStatus
+			exhausted[m.recordID] = true
+		} else {
+			fields[statusCol] = string(taskmodel.StatusPending)
+			if col := tc.fields["RetryCount"]; col != "" {
+				fields[col] = m.retries + 1
+			}
+			// null clears a Bitable cell.
+			for _, col := range clearCols {
+				fields[col] = nil
+			}
+		}
+		records = append(records, map[string]any{"record_id": m.recordID, "fields": fields})
+	}
+
+	if !opts.DryRun {
+		for i := 0; i < len(records); i += updateMaxBatchSize {
+			j := minInt(i+updateMaxBatchSize, len(records))
+			if err := batchUpdateRecords(ctx, tc.baseURL, tc.token, tc.ref, records[i:j]); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+				break
+			}
+			for _, r := range records[i:j] {
+				if exhausted[r["record_id"].(string)] {
+					report.Exhausted++
+				} else {
+					report.Requeued++
+				}
+			}
+		}
+	}
+	report.Failed = len(report.Errors)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
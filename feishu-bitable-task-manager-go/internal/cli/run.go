@@ -1,14 +1,26 @@
 package cli
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
 )
 
 func Run(args []string) int {
-	fs, logJSON := rootFlagSet(os.Stderr)
+	return RunContext(context.Background(), args)
+}
+
+// RunContext is Run with a caller-supplied context. SIGINT/SIGTERM and the
+// --timeout flag cancel ctx, which aborts in-flight Feishu requests.
+func RunContext(ctx context.Context, args []string) int {
+	fs, root := rootFlagSet(os.Stderr)
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
 			fs.SetOutput(os.Stdout)
@@ -17,21 +29,175 @@ func Run(args []string) int {
 		}
 		return 2
 	}
-	setLoggerJSON(*logJSON)
+	setLoggerJSON(root.LogJSON)
+	correlationID := strings.TrimSpace(root.CorrelationID)
+	if correlationID == "" {
+		correlationID = strings.TrimSpace(os.Getenv("BITABLE_CORRELATION_ID"))
+	}
+	if correlationID == "" {
+		correlationID = common.NewUUID()
+	}
+	setCorrelationID(correlationID)
 	rest := fs.Args()
 	if len(rest) == 0 || rest[0] == "-h" || rest[0] == "--help" || rest[0] == "help" {
 		fs.SetOutput(os.Stdout)
 		fs.Usage()
 		return 0
 	}
+	envFile, required := root.EnvFile, true
+	if envFile == "" {
+		envFile, required = ".env", false
+	}
+	if _, err := common.LoadDotEnv(envFile, required); err != nil {
+		errLogger.Error("load env file failed", "err", err)
+		return 2
+	}
+	if _, err := common.ApplyProfile(root.ConfigPath, root.Profile); err != nil {
+		errLogger.Error("load config profile failed", "err", err)
+		return 2
+	}
+	qps := common.QPSFromEnv()
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "qps" {
+			qps = root.QPS
+		}
+	})
+	common.SetQPS(qps)
+	tuning := common.HTTPTuningFromEnv()
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "http-timeout":
+			tuning.RequestTimeout = root.HTTP.RequestTimeout
+		case "dial-timeout":
+			tuning.DialTimeout = root.HTTP.DialTimeout
+		case "tls-handshake-timeout":
+			tuning.TLSHandshakeTimeout = root.HTTP.TLSHandshakeTimeout
+		case "max-idle-conns":
+			tuning.MaxIdleConns = root.HTTP.MaxIdleConns
+		case "keep-alive":
+			tuning.KeepAlive = root.HTTP.KeepAlive
+		}
+	})
+	if err := common.SetHTTPTuning(tuning); err != nil {
+		errLogger.Error("configure HTTP client failed", "err", err)
+		return 2
+	}
+	if root.DebugHTTP || os.Getenv("BITABLE_DEBUG_HTTP") == "1" {
+		common.SetHTTPDebugLogger(errLogger)
+	}
+	if root.EmbeddedRoots || os.Getenv("BITABLE_USE_EMBEDDED_ROOTS") == "1" {
+		if err := common.UseEmbeddedRoots(); err != nil {
+			errLogger.Error("load embedded CA roots failed", "err", err)
+			return 2
+		}
+	} else if common.SystemRootsMissing() {
+		errLogger.Warn("no CA certificates found on this host, TLS verification will fail", "hint", "install ca-certificates or pass --use-embedded-roots")
+	}
 
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if root.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, root.Timeout)
+		defer cancel()
+	}
+
+	shutdownTracing, err := common.StartTracing(errLogger)
+	if err != nil {
+		errLogger.Warn("tracing disabled", "err", err)
+	}
+	ctx, span := common.StartSpan(ctx, "bitable-task "+rest[0], "bitable.command", rest[0], "bitable.correlation_id", correlationID)
+	code := runCommand(ctx, fs, rest)
+	span.SetAttributes("process.exit.code", code)
+	var spanErr error
+	if code != 0 {
+		spanErr = fmt.Errorf("exit code %d", code)
+	}
+	span.End(spanErr)
+	// ctx may be cancelled by now; give the last export its own deadline.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	shutdownTracing(flushCtx)
+	return code
+}
+
+// runCommand dispatches rest[0] to its subcommand.
+func runCommand(ctx context.Context, fs *flag.FlagSet, rest []string) int {
 	switch rest[0] {
 	case "fetch":
-		return runFetch(rest[1:])
+		return runFetch(ctx, rest[1:])
+	case "watch":
+		return runWatch(ctx, rest[1:])
+	case "stats":
+		return runStats(ctx, rest[1:])
+	case "probe":
+		return runProbe(ctx, rest[1:])
+	case "perms":
+		return runPerms(ctx, rest[1:])
+	case "infer":
+		return runInfer(ctx, rest[1:])
+	case "report":
+		return runReport(ctx, rest[1:])
+	case "device":
+		return runDevice(ctx, rest[1:])
+	case "export":
+		return runExport(ctx, rest[1:])
+	case "mirror":
+		return runMirror(ctx, rest[1:])
+	case "init-table":
+		return runInitTable(ctx, rest[1:])
+	case "migrate-legacy":
+		return runMigrateLegacy(ctx, rest[1:])
+	case "fields":
+		return runFields(ctx, rest[1:])
+	case "validate":
+		return runValidate(ctx, rest[1:])
+	case "self-update":
+		return runSelfUpdate(ctx, rest[1:])
 	case "update":
-		return runUpdate(rest[1:])
+		return runUpdate(ctx, rest[1:])
 	case "create":
-		return runCreate(rest[1:])
+		return runCreate(ctx, rest[1:])
+	case "import":
+		return runImport(ctx, rest[1:])
+	case "plan":
+		return runPlan(ctx, rest[1:])
+	case "apply":
+		return runApply(ctx, rest[1:])
+	case "delete":
+		return runDelete(ctx, rest[1:])
+	case "dedupe":
+		return runDedupe(ctx, rest[1:])
+	case "touch":
+		return runTouch(ctx, rest[1:])
+	case "claim":
+		return runClaim(ctx, rest[1:])
+	case "heartbeat":
+		return runHeartbeat(ctx, rest[1:])
+	case "lock":
+		return runLock(ctx, rest[1:], false)
+	case "unlock":
+		return runLock(ctx, rest[1:], true)
+	case "pause-scene":
+		return runPauseScene(ctx, rest[1:], false)
+	case "resume-scene":
+		return runPauseScene(ctx, rest[1:], true)
+	case "retry":
+		return runRetry(ctx, rest[1:])
+	case "flush":
+		return runFlush(ctx, rest[1:])
+	case "complete", "finish":
+		return runComplete(ctx, rest[1:])
+	case "exec":
+		return runExec(ctx, rest[1:])
+	case "work":
+		return runWork(ctx, rest[1:])
+	case "canonicalize-url":
+		return runCanonicalizeURL(ctx, rest[1:])
+	case "attach":
+		return runAttach(ctx, rest[1:])
+	case "download":
+		return runDownload(ctx, rest[1:])
 	default:
 		errLogger.Error("unknown command", "command", rest[0])
 		fs.SetOutput(os.Stdout)
@@ -40,6 +206,16 @@ func Run(args []string) int {
 	}
 }
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func setFlagUsage(fs *flag.FlagSet, usageLine string) {
 	fs.Usage = func() {
 		fmt.Fprintln(fs.Output(), "Usage:")
@@ -49,18 +225,81 @@ func setFlagUsage(fs *flag.FlagSet, usageLine string) {
 	}
 }
 
-func rootFlagSet(out *os.File) (*flag.FlagSet, *bool) {
+// rootOptions holds the global flags parsed before the subcommand.
+type rootOptions struct {
+	LogJSON       bool
+	Timeout       time.Duration
+	QPS           float64
+	Profile       string
+	ConfigPath    string
+	EnvFile       string
+	EmbeddedRoots bool
+	CorrelationID string
+	HTTP          common.HTTPTuning
+	DebugHTTP     bool
+}
+
+func rootFlagSet(out *os.File) (*flag.FlagSet, *rootOptions) {
+	root := &rootOptions{}
 	fs := flag.NewFlagSet("bitable-task", flag.ContinueOnError)
 	fs.SetOutput(out)
-	logJSON := fs.Bool("log-json", false, "Output logs in JSON")
+	fs.BoolVar(&root.LogJSON, "log-json", false, "Output logs in JSON")
+	fs.DurationVar(&root.Timeout, "timeout", 0, "Abort the command after this duration (0 = no deadline)")
+	fs.Float64Var(&root.QPS, "qps", 0, "Max Feishu API requests per second, overrides BITABLE_QPS (0 = unlimited)")
+	fs.StringVar(&root.Profile, "profile", "", "Config profile to load (default: BITABLE_PROFILE or default_profile)")
+	fs.StringVar(&root.ConfigPath, "config", "", "Config file path (default: ~/.config/bitable-task/config.yaml)")
+	fs.BoolVar(&root.EmbeddedRoots, "use-embedded-roots", false, "Verify TLS against the built-in Mozilla CA bundle (hosts without ca-certificates)")
+	fs.StringVar(&root.CorrelationID, "correlation-id", "", "ID sent as X-Request-Id and logged as correlation_id (default: BITABLE_CORRELATION_ID or a new UUID)")
+	fs.DurationVar(&root.HTTP.RequestTimeout, "http-timeout", 0, "Timeout of one Feishu API request, overrides BITABLE_HTTP_TIMEOUT (default 30s)")
+	fs.DurationVar(&root.HTTP.DialTimeout, "dial-timeout", 0, "TCP connect timeout, overrides BITABLE_DIAL_TIMEOUT (default 30s)")
+	fs.DurationVar(&root.HTTP.TLSHandshakeTimeout, "tls-handshake-timeout", 0, "TLS handshake timeout, overrides BITABLE_TLS_HANDSHAKE_TIMEOUT (default 10s)")
+	fs.IntVar(&root.HTTP.MaxIdleConns, "max-idle-conns", 0, "Idle connections kept for reuse, overrides BITABLE_MAX_IDLE_CONNS (default 2)")
+	fs.DurationVar(&root.HTTP.KeepAlive, "keep-alive", 0, "How long idle connections stay open, overrides BITABLE_KEEP_ALIVE (default 90s)")
+	fs.BoolVar(&root.DebugHTTP, "debug-http", false, "Log every Feishu API call in full (URL, masked headers, bodies, status, latency, log id) to stderr")
+	fs.StringVar(&root.EnvFile, "env-file", "", "Load FEISHU_*/TASK_*/BITABLE_* vars from this file (default: ./.env if present)")
 	fs.Usage = func() {
 		fmt.Fprintln(fs.Output(), "Usage:")
-		fmt.Fprintln(fs.Output(), "  bitable-task [--log-json] <command> [flags]")
+		fmt.Fprintln(fs.Output(), "  bitable-task [--profile name] [--log-json] [--timeout 5m] <command> [flags]")
 		fmt.Fprintln(fs.Output(), "")
 		fmt.Fprintln(fs.Output(), "Commands:")
-		fmt.Fprintln(fs.Output(), "  fetch   Fetch tasks from Bitable")
-		fmt.Fprintln(fs.Output(), "  update  Update tasks in Bitable")
-		fmt.Fprintln(fs.Output(), "  create  Create tasks in Bitable")
+		fmt.Fprintln(fs.Output(), "  fetch     Fetch tasks from Bitable")
+		fmt.Fprintln(fs.Output(), "  watch     Stream newly appearing tasks as JSONL")
+		fmt.Fprintln(fs.Output(), "  stats     Count tasks and summarize elapsed/items per group")
+		fmt.Fprintln(fs.Output(), "  probe     Check queue freshness and backlog; Nagios exit codes (0 OK, 1 WARN, 2 CRIT, 3 UNKNOWN)")
+		fmt.Fprintln(fs.Output(), "  perms     Report what the app credentials can do on the table (read, write, fields, media)")
+		fmt.Fprintln(fs.Output(), "  infer     Propose TASK_FIELD_* mappings for an existing table from column names and sampled values")
+		fmt.Fprintln(fs.Output(), "  report    Queue snapshot (counts by status, top failing scenes), optionally written to a wiki/docx block")
+		fmt.Fprintln(fs.Output(), "  device history  What one device executed in a time window, with durations and outcomes")
+		fmt.Fprintln(fs.Output(), "  export    Dump tasks to CSV or .xlsx")
+		fmt.Fprintln(fs.Output(), "  mirror sync   Copy the table into a local JSONL mirror, fetching only records modified since the last sync")
+		fmt.Fprintln(fs.Output(), "  mirror query  Filter the mirrored tasks offline and print them as JSONL")
+		fmt.Fprintln(fs.Output(), "  fields    Show the table schema and the TASK_FIELD_* mapping")
+		fmt.Fprintln(fs.Output(), "  validate  Check the TASK_FIELD_* mapping against the table; exit 1 on problems")
+		fmt.Fprintln(fs.Output(), "  init-table  Create a task table, or add missing task columns to one")
+		fmt.Fprintln(fs.Output(), "  migrate-legacy  Normalize records written by the legacy Python tool")
+		fmt.Fprintln(fs.Output(), "  update    Update tasks in Bitable")
+		fmt.Fprintln(fs.Output(), "  create    Create tasks in Bitable")
+		fmt.Fprintln(fs.Output(), "  import    Upsert tasks from JSON/JSONL/CSV keyed by a field")
+		fmt.Fprintln(fs.Output(), "  plan      Diff an import against the table and save it as a plan file")
+		fmt.Fprintln(fs.Output(), "  apply     Execute a plan file written by plan")
+		fmt.Fprintln(fs.Output(), "  complete  Finish a task: uploads, status, end time, elapsed, metrics in one write (alias: finish)")
+		fmt.Fprintln(fs.Output(), "  flush     Replay updates queued in --queue-dir while Feishu was unreachable")
+		fmt.Fprintln(fs.Output(), "  exec      Run a command with one task injected as TASK_* env vars")
+		fmt.Fprintln(fs.Output(), "  work      Claim tasks continuously and run a handler command for each")
+		fmt.Fprintln(fs.Output(), "  retry     Requeue failed tasks (or mark them exhausted)")
+		fmt.Fprintln(fs.Output(), "  lock      Lock a record for manual edits; automated updates skip it")
+		fmt.Fprintln(fs.Output(), "  unlock    Release a manual-edit lock")
+		fmt.Fprintln(fs.Output(), "  pause-scene   Stop claim/work from dispatching one scene (control table)")
+		fmt.Fprintln(fs.Output(), "  resume-scene  Clear a scene pause")
+		fmt.Fprintln(fs.Output(), "  delete    Delete task records from Bitable")
+		fmt.Fprintln(fs.Output(), "  dedupe    Report tasks sharing a key and delete all but one")
+		fmt.Fprintln(fs.Output(), "  touch     Stamp TouchedAt on matching tasks so modified-time syncs pick them up again")
+		fmt.Fprintln(fs.Output(), "  claim     Claim pending tasks for a device and emit them as JSONL")
+		fmt.Fprintln(fs.Output(), "  heartbeat Refresh the lease of a claimed task")
+		fmt.Fprintln(fs.Output(), "  canonicalize-url  Print canonical task URLs (resolves short links)")
+		fmt.Fprintln(fs.Output(), "  attach    Upload files into a task's Artifacts manifest")
+		fmt.Fprintln(fs.Output(), "  download  Download files from a task's Artifacts manifest")
+		fmt.Fprintln(fs.Output(), "  self-update  Replace this binary with a verified GitHub release build")
 		fmt.Fprintln(fs.Output(), "")
 		fmt.Fprintln(fs.Output(), "Global Flags:")
 		fs.PrintDefaults()
@@ -68,12 +307,32 @@ func rootFlagSet(out *os.File) (*flag.FlagSet, *bool) {
 		fmt.Fprintln(fs.Output(), "Environment:")
 		fmt.Fprintln(fs.Output(), "  FEISHU_APP_ID, FEISHU_APP_SECRET, TASK_BITABLE_URL (required)")
 		fmt.Fprintln(fs.Output(), "  FEISHU_BASE_URL (optional, default: https://open.feishu.cn)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_QPS (optional, max API requests per second)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_RATE_LIMIT_RETRIES, BITABLE_RATE_LIMIT_MAX_WAIT (optional, retries after a Feishu rate limit; default 5, 1m)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_JITTER (optional, default --jitter for watch/work/heartbeat, e.g. 20% or 5s)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_EXTRA_OVERFLOW, BITABLE_EXTRA_MAX_CHARS (optional, split oversized Extra across columns or upload it)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_CORRELATION_ID (optional, reuse one correlation ID across commands; set for exec/work handlers)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_USE_EMBEDDED_ROOTS=1 (optional, same as --use-embedded-roots)")
+		fmt.Fprintln(fs.Output(), "  FEISHU_TOKEN_CACHE_DIR (optional, persist tenant tokens across runs)")
 		fmt.Fprintln(fs.Output(), "  TASK_FIELD_* overrides (optional)")
+		fmt.Fprintln(fs.Output(), "  TASK_RUNS_BITABLE_URL, RUN_FIELD_* (optional, runs history table for update)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_QUEUE_DIR (optional, default --queue-dir for update/work/flush)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_MIRROR_FILE (optional, default --file for mirror sync/query)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_STORE_KEY (optional, encrypt queue entries and the mirror file)")
+		fmt.Fprintln(fs.Output(), "  TASK_CONTROL_BITABLE_URL, CONTROL_FIELD_* (optional, scene pause table for claim/work)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_OPERATOR (optional, default --owner for lock/unlock, falls back to USER)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_REPORT_DOC_URL (optional, default --publish for report)")
+		fmt.Fprintln(fs.Output(), "  TASK_EXEC_SHELL (optional, default --shell for exec and work)")
+		fmt.Fprintln(fs.Output(), "  TASK_FETCH_TRANSFORM, TASK_WRITE_TRANSFORM (optional, default --transform for fetch and create/update/import)")
+		fmt.Fprintln(fs.Output(), "  TASK_LEGACY_READ=status,seconds|all (optional, normalize legacy records when reading)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_TASK_RELEASE_REPO, BITABLE_TASK_UPDATE_PUBKEY, GITHUB_API_URL, GITHUB_TOKEN (optional, self-update)")
+		fmt.Fprintln(fs.Output(), "  BITABLE_PROFILE, BITABLE_TASK_CONFIG (optional, config profile selection)")
+		fmt.Fprintln(fs.Output(), "  Precedence: process env > --env-file/.env > config profile.")
 	}
-	return fs, logJSON
+	return fs, root
 }
 
-func runFetch(args []string) int {
+func runFetch(ctx context.Context, args []string) int {
 	opts := FetchOptions{
 		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
 		Status:     "pending",
@@ -82,38 +341,110 @@ func runFetch(args []string) int {
 		IgnoreView: true,
 	}
 	var useView bool
+	var filters stringList
 	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	setFlagUsage(fs, "bitable-task fetch [flags]")
 	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
-	fs.StringVar(&opts.App, "app", "", "App value for filter (required)")
-	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter (required)")
+	fs.StringVar(&opts.App, "app", "", "App value for filter (required unless --preset)")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter (required unless --preset)")
 	fs.StringVar(&opts.Status, "status", opts.Status, "Task status filter (default: pending)")
 	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
 	fs.IntVar(&opts.Limit, "limit", 0, "Max tasks to return (0 = no cap)")
 	fs.IntVar(&opts.PageSize, "page-size", opts.PageSize, "Page size (max 500)")
 	fs.IntVar(&opts.MaxPages, "max-pages", 0, "Max pages to fetch (0 = no cap)")
+	fs.IntVar(&opts.Prefetch, "prefetch", defaultPrefetchPages, "Pages fetched ahead while the current page is decoded (0 = one request in flight)")
 	fs.BoolVar(&opts.IgnoreView, "ignore-view", true, "Ignore view_id when searching (default: true)")
 	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
 	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
-	fs.BoolVar(&opts.JSONL, "jsonl", false, "Output JSONL (one task per line)")
+	fs.BoolVar(&opts.JSONL, "jsonl", false, "Output JSONL (one task per line); same as --output-format jsonl")
+	fs.StringVar(&opts.OutputFormat, "output-format", "", "Output format: json (default), jsonl, table, csv, yaml, go-template=TEMPLATE or go-template-file=PATH")
+	fs.StringVar(&opts.OutputFormat, "format", "", "Alias of --output-format, e.g. 'go-template={{.TaskID}} {{.Status}}'")
 	fs.BoolVar(&opts.Raw, "raw", false, "Include raw fields in output")
+	fs.BoolVar(&opts.IncludeDeleted, "include-deleted", false, "Also return soft-deleted tasks")
+	fs.DurationVar(&opts.LeaseTimeout, "lease-timeout", 0, "Also return dispatched/running tasks whose heartbeat is older than this (e.g. 10m)")
+	fs.StringVar(&opts.Preset, "preset", "", "Named query: "+strings.Join(fetchPresetNames(), ", "))
+	fs.DurationVar(&opts.StuckAfter, "stuck-after", defaultStuckAfter, "Age threshold for --preset stuck-running")
+	fs.StringVar(&opts.Transform, "transform", os.Getenv("TASK_FETCH_TRANSFORM"), "Command the fetched tasks are piped through as JSONL")
+	fs.Var(&filters, "filter", "Extra condition on any column: Name=value, Name!=value, Name:op=value or Name:is_empty (repeatable; ops: "+strings.Join(filterOperatorNames(), ", ")+")")
+	fs.StringVar(&opts.Where, "where", "", "Filter expression, e.g. \"Status in (pending,failed) and (App = xhs or App = dy)\"")
+	fs.StringVar(&opts.Fields, "fields", "", "Only request and output these logical fields, e.g. \"TaskID,URL,Status\" (record_id is always included)")
+	fs.StringVar(&opts.Sort, "sort", "", "Server-side order, e.g. \"DispatchedAt:asc,RetryCount:desc\" (direction defaults to asc)")
+	fs.StringVar(&opts.DateFrom, "date-from", "", "Only tasks whose Date is on/after this: YYYY-MM-DD, today, yesterday, -7d, ISO time or epoch")
+	fs.StringVar(&opts.DateTo, "date-to", "", "Only tasks whose Date is on/before this day (or before this instant); same forms as --date-from")
+	fs.BoolVar(&opts.Count, "count", false, "Only print the number of matching records (uses the API total when available)")
+	fs.StringVar(&opts.SliceBy, "slice-by", "", "Fetch --date-from..--date-to as consecutive windows of the date or created column (date|created)")
+	fs.DurationVar(&opts.SliceSize, "slice-size", defaultSliceSize, "Window length for --slice-by (whole days for a text Date column)")
+	fs.DurationVar(&opts.MaxAge, "max-age", 0, "Leave out tasks created longer ago than this, e.g. 2h (CreatedTime column, else Date by day)")
+	fs.BoolVar(&opts.Expire, "expire", false, "With --max-age: mark the pending tasks left out as expired")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
+	if opts.Expire && opts.MaxAge <= 0 {
+		errLogger.Error("--expire needs --max-age")
+		return 2
+	}
+	if code := dateRangePreset(fs, &opts.Date, opts.DateFrom, opts.DateTo); code != 0 {
+		return code
+	}
 	if useView {
 		opts.IgnoreView = false
 	}
+	opts.Filters = filters
 	opts.App = strings.TrimSpace(opts.App)
 	opts.Scene = strings.TrimSpace(opts.Scene)
-	if opts.App == "" || opts.Scene == "" {
+	if opts.Preset == "" && (opts.App == "" || opts.Scene == "") {
 		errLogger.Error("--app and --scene are required")
 		return 2
 	}
-	return FetchTasks(opts)
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if err := applyFetchPreset(&opts, explicit, time.Now()); err != nil {
+		errLogger.Error("invalid preset", "err", err)
+		return 2
+	}
+	return FetchTasks(ctx, opts)
+}
+
+func runWatch(ctx context.Context, args []string) int {
+	opts := WatchOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Status:     "pending",
+		Date:       "Any",
+		Interval:   10 * time.Second,
+		By:         watchByCreatedTime,
+		IgnoreView: true,
+	}
+	var useView bool
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task watch [--app <app>] [--scene <scene>] [--interval 10s] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.StringVar(&opts.Status, "status", opts.Status, "Task status filter")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.DurationVar(&opts.Interval, "interval", opts.Interval, "Poll interval")
+	fs.StringVar(&opts.By, "by", opts.By, "Detect new tasks by created-time watermark or record-id")
+	fs.BoolVar(&opts.FromStart, "from-start", false, "Also emit tasks that already match when the watch starts")
+	fs.BoolVar(&opts.IncludeDeleted, "include-deleted", false, "Also emit soft-deleted tasks")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	fs.StringVar(&opts.MetricsAddr, "metrics-addr", os.Getenv("BITABLE_METRICS_ADDR"), "Serve Prometheus metrics on this address, e.g. :9464")
+	if err := addJitterFlag(fs, &opts.Jitter); err != nil {
+		errLogger.Error("invalid jitter", "err", err)
+		return 2
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	return WatchTasks(ctx, opts)
 }
 
-func runUpdate(args []string) int {
+func runUpdate(ctx context.Context, args []string) int {
 	opts := UpdateOptions{
 		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
 		IgnoreView: true,
@@ -137,9 +468,18 @@ func runUpdate(args []string) int {
 	fs.StringVar(&opts.ElapsedSeconds, "elapsed-seconds", "", "Elapsed seconds (int)")
 	fs.StringVar(&opts.ItemsCollected, "items-collected", "", "Items collected (int)")
 	fs.StringVar(&opts.Logs, "logs", "", "Logs path or identifier")
+	fs.StringVar(&opts.LogsFile, "logs-file", "", "Log file uploaded to Feishu; its file_token is written to Logs (and attached in LogsFile when the column exists)")
 	fs.StringVar(&opts.RetryCount, "retry-count", "", "Retry count (int)")
+	fs.StringVar(&opts.LastScreenshot, "last-screenshot", "", "Screenshot image uploaded into LastScreenShot (a non-file value is written as is)")
 	fs.StringVar(&opts.Extra, "extra", "", "Extra JSON string")
+	fs.StringVar(&opts.AttemptToken, "attempt-token", "", "Attempt token issued by claim; reports with a stale token are rejected")
 	fs.StringVar(&opts.SkipStatus, "skip-status", "", "Skip updates when current status matches (comma-separated)")
+	fs.StringVar(&opts.ExpectStatus, "expect-status", "", "Update only if the status re-read just before writing is one of these (comma-separated); others are reported as conflicts")
+	fs.StringVar(&opts.RunsURL, "runs-url", os.Getenv("TASK_RUNS_BITABLE_URL"), "Runs history table URL; finished attempts are appended there")
+	fs.StringVar(&opts.Transform, "transform", os.Getenv("TASK_WRITE_TRANSFORM"), "Command the updates are piped through as JSONL before writing")
+	fs.BoolVar(&opts.CreateSelectOptions, "create-options", false, "Add unknown single/multi select values as new options instead of failing the row")
+	fs.BoolVar(&opts.JSONResult, "json-result", false, "Print {created, updated, skipped, failed} per input row as JSON on stdout; the report goes to stderr")
+	fs.StringVar(&opts.QueueDir, "queue-dir", os.Getenv("BITABLE_QUEUE_DIR"), "Queue updates that cannot reach Feishu (network errors, 5xx, rate limits) in this directory for flush")
 	fs.BoolVar(&opts.IgnoreView, "ignore-view", true, "Ignore view_id when searching (default: true)")
 	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
 	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
@@ -149,10 +489,10 @@ func runUpdate(args []string) int {
 	if useView {
 		opts.IgnoreView = false
 	}
-	return UpdateTasks(opts)
+	return UpdateTasks(ctx, opts)
 }
 
-func runCreate(args []string) int {
+func runCreate(ctx context.Context, args []string) int {
 	opts := CreateOptions{
 		TaskURL: os.Getenv("TASK_BITABLE_URL"),
 	}
@@ -161,6 +501,7 @@ func runCreate(args []string) int {
 	setFlagUsage(fs, "bitable-task create [flags]")
 	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
 	fs.StringVar(&opts.InputPath, "input", "", "Input JSON or JSONL file (use - for stdin)")
+	fs.StringVar(&opts.Source, "source", "", "Read rows from a Feishu spreadsheet instead: sheet:<url> (header row = field names)")
 	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id to create")
 	fs.StringVar(&opts.ParentTaskID, "parent-task-id", "", "Parent task id")
 	fs.StringVar(&opts.App, "app", "", "App value")
@@ -183,12 +524,834 @@ func runCreate(args []string) int {
 	fs.StringVar(&opts.ItemsCollected, "items-collected", "", "Items collected (int)")
 	fs.StringVar(&opts.Logs, "logs", "", "Logs path or identifier")
 	fs.StringVar(&opts.RetryCount, "retry-count", "", "Retry count (int)")
-	fs.StringVar(&opts.LastScreenshot, "last-screenshot", "", "Last screenshot reference")
+	fs.StringVar(&opts.LastScreenshot, "last-screenshot", "", "Screenshot image uploaded into LastScreenShot (a non-file value is written as is)")
 	fs.StringVar(&opts.GroupID, "group-id", "", "Group id")
 	fs.StringVar(&opts.Extra, "extra", "", "Extra JSON string")
-	fs.StringVar(&opts.SkipExisting, "skip-existing", "", "Skip create when existing records match these fields (comma-separated, all must match)")
+	fs.StringVar(&opts.SkipExisting, "skip-existing", os.Getenv("TASK_DEDUPE_FIELDS"), "Skip create when existing records match these fields (comma-separated, all must match)")
+	fs.StringVar(&opts.DedupeNormalize, "dedupe-normalize", os.Getenv("TASK_DEDUPE_NORMALIZE"), "Normalizers for --skip-existing values, e.g. trim,URL:url,UserID:lower")
+	fs.BoolVar(&opts.CanonicalizeURL, "canonicalize-url", os.Getenv("TASK_CANONICALIZE_URL") == "1", "Canonicalize URL before create (resolve short links, strip tracking params)")
+	fs.BoolVar(&opts.CreateSelectOptions, "create-options", false, "Add unknown single/multi select values as new options instead of failing the row")
+	fs.BoolVar(&opts.JSONResult, "json-result", false, "Print {created, updated, skipped, failed} per input row as JSON on stdout; the report goes to stderr")
+	fs.StringVar(&opts.DedupeHash, "dedupe-hash", os.Getenv("TASK_DEDUPE_HASH"), "Hash dedupe keys: none or sha256")
+	fs.StringVar(&opts.Transform, "transform", os.Getenv("TASK_WRITE_TRANSFORM"), "Command the creates are piped through as JSONL before writing")
+	fs.StringVar(&opts.UpsertOn, "upsert-on", "", "Update the existing record with the same key field (e.g. biz_task_id) instead of creating")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if opts.UpsertOn != "" && !explicit["skip-existing"] {
+		// TASK_DEDUPE_FIELDS is a default for plain creates; --upsert-on
+		// replaces dedupe rather than conflicting with it.
+		opts.SkipExisting = ""
+	}
+	return CreateTasks(ctx, opts)
+}
+
+func runImport(ctx context.Context, args []string) int {
+	opts := ImportOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Key:     defaultImportKey,
+	}
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task import --input tasks.csv|--source sheet:<url> [--key BizTaskID]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.InputPath, "input", "", "Input JSON, JSONL or CSV file (use - for stdin)")
+	fs.StringVar(&opts.Source, "source", "", "Read rows from a Feishu spreadsheet instead: sheet:<url> (header row = field names)")
+	fs.StringVar(&opts.Key, "key", opts.Key, "Field matching input rows to existing records (e.g. BizTaskID, URL, RecordID)")
+	fs.StringVar(&opts.Transform, "transform", os.Getenv("TASK_WRITE_TRANSFORM"), "Command the rows are piped through as JSONL before writing")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return ImportTasks(ctx, opts)
+}
+
+func runPlan(ctx context.Context, args []string) int {
+	opts := ImportOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Key:     defaultImportKey,
+	}
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task plan --input tasks.csv|--source sheet:<url> --out plan.json [--key BizTaskID]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.InputPath, "input", "", "Input JSON, JSONL or CSV file (use - for stdin)")
+	fs.StringVar(&opts.Source, "source", "", "Read rows from a Feishu spreadsheet instead: sheet:<url> (header row = field names)")
+	fs.StringVar(&opts.Key, "key", opts.Key, "Field matching input rows to existing records (e.g. BizTaskID, URL, RecordID)")
+	fs.StringVar(&opts.Transform, "transform", os.Getenv("TASK_WRITE_TRANSFORM"), "Command the rows are piped through as JSONL before writing")
+	fs.StringVar(&opts.PlanPath, "out", "", "Plan file to write (required)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if strings.TrimSpace(opts.PlanPath) == "" {
+		errLogger.Error("--out is required")
+		return 2
+	}
+	return ImportTasks(ctx, opts)
+}
+
+func runApply(ctx context.Context, args []string) int {
+	var opts ApplyOptions
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task apply --plan plan.json [--force]")
+	fs.StringVar(&opts.PlanPath, "plan", "", "Plan file written by plan (required)")
+	fs.StringVar(&opts.TaskURL, "task-url", "", "Bitable task table URL (default: the table the plan was made against)")
+	fs.BoolVar(&opts.Force, "force", false, "Apply updates even when their record changed since the plan was made")
+	fs.BoolVar(&opts.CreateSelectOptions, "create-options", false, "Add unknown single/multi select values as new options instead of failing the row")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return ApplyPlan(ctx, opts)
+}
+
+func runDelete(ctx context.Context, args []string) int {
+	opts := DeleteOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+	}
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task delete [flags] --yes")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.InputPath, "input", "", "Input JSON or JSONL file with record_id/task_id/biz_task_id (use - for stdin)")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Single record id to delete")
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Single task id to delete")
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Single biz task id to delete")
+	fs.BoolVar(&opts.Yes, "yes", false, "Confirm deletion (without it, only report what would be deleted)")
+	fs.BoolVar(&opts.Soft, "soft", false, "Set the Deleted checkbox instead of removing the records")
+	fs.BoolVar(&opts.Restore, "restore", false, "With --soft, clear the Deleted checkbox (undo a soft delete)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return DeleteTasks(ctx, opts)
+}
+
+func runDedupe(ctx context.Context, args []string) int {
+	opts := DedupeOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Key:     "BizTaskID",
+		Keep:    dedupeKeepOldest,
+	}
+	fs := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task dedupe [flags] [--yes]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.Key, "key", opts.Key, "Comma-separated fields that identify a task (e.g. BizTaskID or App,Params)")
+	fs.StringVar(&opts.Normalize, "normalize", "", "Normalizers for key values, e.g. trim,URL:url,UserID:lower")
+	fs.StringVar(&opts.App, "app", "", "Only consider tasks of this App")
+	fs.StringVar(&opts.Scene, "scene", "", "Only consider tasks of this Scene")
+	fs.StringVar(&opts.Keep, "keep", opts.Keep, "Record to keep in each group: oldest or newest (by creation time)")
+	fs.BoolVar(&opts.Soft, "soft", false, "Set the Deleted checkbox on the extra records instead of removing them")
+	fs.BoolVar(&opts.Yes, "yes", false, "Remove the duplicates (without it, only report the groups)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return DedupeTasks(ctx, opts)
+}
+
+func runTouch(ctx context.Context, args []string) int {
+	opts := TouchOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+	}
+	var filters stringList
+	fs := flag.NewFlagSet("touch", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task touch [--filter ...] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "Only tasks of this App")
+	fs.StringVar(&opts.Scene, "scene", "", "Only tasks of this Scene")
+	fs.StringVar(&opts.Status, "status", "", "Only tasks with this Status")
+	fs.StringVar(&opts.Date, "date", "", "Only tasks with this Date preset (Today/Yesterday or a date)")
+	fs.Var(&filters, "filter", "Condition on any column: Name=value, Name!=value, Name:op=value or Name:is_empty (repeatable; ops: "+strings.Join(filterOperatorNames(), ", ")+")")
+	fs.StringVar(&opts.Where, "where", "", "Filter expression, e.g. \"Status in (pending,failed) and (App = xhs or App = dy)\"")
+	fs.BoolVar(&opts.IncludeDeleted, "include-deleted", false, "Also touch soft-deleted tasks")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Only count the matching tasks")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	opts.Filters = filters
+	return TouchTasks(ctx, opts)
+}
+
+func runClaim(ctx context.Context, args []string) int {
+	opts := ClaimOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Date:       "Today",
+		Limit:      1,
+		IgnoreView: true,
+	}
+	var useView bool
+	var devices string
+	fs := flag.NewFlagSet("claim", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task claim --app <app> --scene <scene> (--device-serial <serial> | --batch 20 --devices d1,d2) [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter (required)")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter (required)")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.IntVar(&opts.Limit, "limit", opts.Limit, "Max tasks to claim (max 500)")
+	fs.IntVar(&opts.Limit, "batch", opts.Limit, "Alias of --limit")
+	fs.StringVar(&opts.DeviceSerial, "device-serial", "", "Device serial claiming the tasks (required unless --devices)")
+	fs.StringVar(&devices, "devices", "", "Comma-separated devices to claim for in one pass, spread per --partition-by")
+	fs.StringVar(&opts.PartitionBy, "partition-by", partitionDeviceSerial, "How --devices share the batch: device_serial (honor pinned DeviceSerial) or round_robin")
+	fs.DurationVar(&opts.LeaseTimeout, "lease-timeout", 0, "Also claim dispatched/running tasks whose heartbeat is older than this (e.g. 10m)")
+	fs.BoolVar(&opts.IncludeDeleted, "include-deleted", false, "Also claim soft-deleted tasks")
+	fs.StringVar(&opts.ControlURL, "control-url", os.Getenv("TASK_CONTROL_BITABLE_URL"), "Control table URL; claim nothing while the scene is paused there")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	fs.DurationVar(&opts.MaxAge, "max-age", 0, "Skip tasks created longer ago than this, e.g. 2h (CreatedTime column, else Date by day)")
+	fs.BoolVar(&opts.Expire, "expire", false, "With --max-age: mark the skipped pending tasks as expired")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.Expire && opts.MaxAge <= 0 {
+		errLogger.Error("--expire needs --max-age")
+		return 2
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	opts.App = strings.TrimSpace(opts.App)
+	opts.Scene = strings.TrimSpace(opts.Scene)
+	if opts.App == "" || opts.Scene == "" {
+		errLogger.Error("--app and --scene are required")
+		return 2
+	}
+	seen := map[string]bool{}
+	for _, d := range strings.Split(devices, ",") {
+		if d = strings.TrimSpace(d); d != "" && !seen[d] {
+			seen[d] = true
+			opts.Devices = append(opts.Devices, d)
+		}
+	}
+	opts.PartitionBy = strings.ToLower(strings.TrimSpace(opts.PartitionBy))
+	return ClaimTasks(ctx, opts)
+}
+
+func runHeartbeat(ctx context.Context, args []string) int {
+	opts := HeartbeatOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+	}
+	fs := flag.NewFlagSet("heartbeat", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task heartbeat --record-id <id> [--interval 30s] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Record id of the claimed task")
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Task id of the claimed task")
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id of the claimed task")
+	fs.StringVar(&opts.AttemptToken, "attempt-token", "", "Attempt token from claim; stop when the task is re-claimed")
+	fs.DurationVar(&opts.Interval, "interval", 0, "Beat every interval until interrupted (0 = beat once)")
+	if err := addJitterFlag(fs, &opts.Jitter); err != nil {
+		errLogger.Error("invalid jitter", "err", err)
+		return 2
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return HeartbeatTask(ctx, opts)
+}
+
+func runCanonicalizeURL(ctx context.Context, args []string) int {
+	opts := CanonicalizeOptions{}
+	fs := flag.NewFlagSet("canonicalize-url", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task canonicalize-url [--no-resolve] [url...]  (reads stdin when no URL is given)")
+	fs.BoolVar(&opts.NoResolve, "no-resolve", false, "Do not follow short-link redirects")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	opts.URLs = fs.Args()
+	return CanonicalizeURLs(ctx, opts)
+}
+
+func runComplete(ctx context.Context, args []string) int {
+	opts := CompleteOptions{
+		TaskURL:        os.Getenv("TASK_BITABLE_URL"),
+		Status:         "success",
+		ItemsCollected: -1,
+	}
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task complete --record-id <id> [--status success] [--items-collected N] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Record id to complete")
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Task id to complete")
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id to complete")
+	fs.StringVar(&opts.Status, "status", opts.Status, "Terminal status: success/failed/error/timeout/cancelled")
+	fs.IntVar(&opts.ItemsCollected, "items-collected", opts.ItemsCollected, "Items collected (-1 = leave unchanged)")
+	fs.StringVar(&opts.Logs, "logs", "", "Logs path or identifier; a path to an existing file is uploaded as --logs-file")
+	fs.StringVar(&opts.LogsFile, "logs-file", "", "Log file uploaded to Feishu; its file_token is written to Logs (and attached in LogsFile when the column exists)")
+	fs.StringVar(&opts.Screenshot, "screenshot", "", "Image file uploaded into LastScreenShot")
+	fs.StringVar(&opts.AttemptToken, "attempt-token", "", "Attempt token issued by claim")
+	fs.StringVar(&opts.RunsURL, "runs-url", os.Getenv("TASK_RUNS_BITABLE_URL"), "Runs history table URL; the finished attempt is appended there")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return CompleteTask(ctx, opts)
+}
+
+func runFlush(ctx context.Context, args []string) int {
+	var opts FlushOptions
+	fs := flag.NewFlagSet("flush", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task flush [--queue-dir <dir>]")
+	fs.StringVar(&opts.QueueDir, "queue-dir", os.Getenv("BITABLE_QUEUE_DIR"), "Queue directory written by update/work --queue-dir")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return FlushQueue(ctx, opts)
+}
+
+func runRetry(ctx context.Context, args []string) int {
+	opts := RetryOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Status:  "failed",
+		Date:    "Any",
+	}
+	fs := flag.NewFlagSet("retry", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task retry [--app <app>] [--scene <scene>] [--max-retries N] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.StringVar(&opts.Status, "status", opts.Status, "Status of tasks to retry")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.IntVar(&opts.Limit, "limit", 0, "Max tasks to retry (0 = no cap)")
+	fs.IntVar(&opts.MaxRetries, "max-retries", 0, "Mark tasks with RetryCount >= N as exhausted instead of requeueing (0 = no limit)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Report matching tasks without updating them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return RetryTasks(ctx, opts)
+}
+
+func runLock(ctx context.Context, args []string, unlock bool) int {
+	opts := LockOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Owner:   defaultLockOwner(),
+		Unlock:  unlock,
+	}
+	name := "lock"
+	if unlock {
+		name = "unlock"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if unlock {
+		setFlagUsage(fs, "bitable-task unlock --record-id <id> [--force] [flags]")
+	} else {
+		setFlagUsage(fs, "bitable-task lock --record-id <id> [--ttl 2h] [--reason text] [flags]")
+	}
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Record id to "+name)
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Task id to "+name)
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id to "+name)
+	fs.StringVar(&opts.Owner, "owner", opts.Owner, "Who holds the lock (default: BITABLE_OPERATOR or USER)")
+	fs.BoolVar(&opts.Force, "force", false, "Override a lock held by someone else")
+	if !unlock {
+		fs.DurationVar(&opts.TTL, "ttl", 2*time.Hour, "Lock expires after this long (0 = until unlocked)")
+		fs.StringVar(&opts.Reason, "reason", "", "Why the record is locked, shown in the cell and in skip logs")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return LockTask(ctx, opts)
+}
+
+func runPauseScene(ctx context.Context, args []string, resume bool) int {
+	opts := PauseOptions{
+		ControlURL: os.Getenv("TASK_CONTROL_BITABLE_URL"),
+		By:         defaultLockOwner(),
+		Resume:     resume,
+	}
+	name := "pause-scene"
+	if resume {
+		name = "resume-scene"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task "+name+" --app <app> --scene <scene|*> [flags]")
+	fs.StringVar(&opts.ControlURL, "control-url", opts.ControlURL, "Control table URL (default: TASK_CONTROL_BITABLE_URL)")
+	fs.StringVar(&opts.App, "app", "", "App of the scene (required)")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene to "+strings.TrimSuffix(name, "-scene")+", or * for every scene of the app (required)")
+	if !resume {
+		fs.StringVar(&opts.Reason, "reason", "", "Why the scene is paused, shown in worker logs")
+		fs.StringVar(&opts.By, "by", opts.By, "Who paused it (default: BITABLE_OPERATOR or USER)")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return PauseScene(ctx, opts)
+}
+
+func runExec(ctx context.Context, args []string) int {
+	opts := ExecOptions{
+		TaskURL:   os.Getenv("TASK_BITABLE_URL"),
+		EnvPrefix: defaultEnvPrefix,
+		Shell:     os.Getenv("TASK_EXEC_SHELL"),
+	}
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task exec --record-id <id> [--env-prefix TASK_] -- <command> [args...]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Record id of the task")
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Task id of the task")
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id of the task")
+	fs.StringVar(&opts.EnvPrefix, "env-prefix", opts.EnvPrefix, "Prefix of the injected env vars")
+	fs.BoolVar(&opts.PrintEnv, "print-env", false, "Print quoted env assignments for --shell instead of running a command")
+	fs.StringVar(&opts.Shell, "shell", opts.Shell, "Run the command through a shell: auto, sh, bash, cmd, powershell, pwsh (default: none)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	opts.Command = fs.Args()
+	return ExecTask(ctx, opts)
+}
+
+func runWork(ctx context.Context, args []string) int {
+	opts := WorkOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Date:       "Today",
+		IgnoreView: true,
+		Poll:       30 * time.Second,
+		EnvPrefix:  defaultEnvPrefix,
+		Shell:      os.Getenv("TASK_EXEC_SHELL"),
+		RunsURL:    os.Getenv("TASK_RUNS_BITABLE_URL"),
+	}
+	var useView bool
+	fs := flag.NewFlagSet("work", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task work --app <app> --scene <scene> --device-serial <serial> [flags] -- <command> [args...]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter (required)")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter (required)")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.StringVar(&opts.DeviceSerial, "device-serial", "", "Device serial claiming the tasks (required)")
+	fs.DurationVar(&opts.LeaseTimeout, "lease-timeout", 0, "Also claim dispatched/running tasks whose heartbeat is older than this (e.g. 10m)")
+	fs.DurationVar(&opts.Poll, "poll", opts.Poll, "Wait between claims when no task is pending")
+	fs.DurationVar(&opts.Heartbeat, "heartbeat", 0, "Refresh HeartbeatAt every interval while a handler runs (0 = off)")
+	fs.DurationVar(&opts.Timeout, "handler-timeout", 0, "Kill the handler and mark the task timeout after this long (0 = no limit)")
+	fs.IntVar(&opts.MaxTasks, "max-tasks", 0, "Exit after handling this many tasks (0 = no limit)")
+	fs.BoolVar(&opts.Once, "once", false, "Exit when no pending task is left instead of polling")
+	fs.StringVar(&opts.EnvPrefix, "env-prefix", opts.EnvPrefix, "Prefix of the injected env vars")
+	fs.StringVar(&opts.Shell, "shell", opts.Shell, "Run the handler through a shell: auto, sh, bash, cmd, powershell, pwsh (default: none)")
+	fs.StringVar(&opts.RunsURL, "runs-url", opts.RunsURL, "Runs history table URL; append one row per handled task")
+	fs.StringVar(&opts.ControlURL, "control-url", os.Getenv("TASK_CONTROL_BITABLE_URL"), "Control table URL; claim nothing while the scene is paused there")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	fs.StringVar(&opts.MetricsAddr, "metrics-addr", os.Getenv("BITABLE_METRICS_ADDR"), "Serve Prometheus metrics on this address, e.g. :9464")
+	fs.StringVar(&opts.QueueDir, "queue-dir", os.Getenv("BITABLE_QUEUE_DIR"), "Queue outcomes that cannot reach Feishu in this directory and replay them before each claim")
+	if err := addJitterFlag(fs, &opts.Jitter); err != nil {
+		errLogger.Error("invalid jitter", "err", err)
+		return 2
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	opts.App = strings.TrimSpace(opts.App)
+	opts.Scene = strings.TrimSpace(opts.Scene)
+	if opts.App == "" || opts.Scene == "" {
+		errLogger.Error("--app and --scene are required")
+		return 2
+	}
+	opts.Command = fs.Args()
+	return WorkTasks(ctx, opts)
+}
+
+func runStats(ctx context.Context, args []string) int {
+	opts := StatsOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Date:       "Any",
+		Format:     "json",
+		IgnoreView: true,
+	}
+	var groupBy string
+	var useView bool
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task stats [--group-by status,app,scene] [--fairness [--starve-after 30m]] [--output-format json|jsonl|table|csv|yaml] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.StringVar(&opts.Status, "status", "", "Task status filter (default: all)")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.StringVar(&groupBy, "group-by", "status", "Comma-separated dimensions: "+strings.Join(statsDimensionNames(), ", "))
+	fs.StringVar(&opts.Format, "output-format", opts.Format, "Output format: json, jsonl (one group per line), table, csv or yaml")
+	fs.StringVar(&opts.Format, "format", opts.Format, "Alias of --output-format")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	fs.StringVar(&opts.Where, "where", "", "Filter expression, e.g. \"Status in (pending,failed) and (App = xhs or App = dy)\"")
+	fs.StringVar(&opts.DateFrom, "date-from", "", "Only tasks whose Date is on/after this: YYYY-MM-DD, today, yesterday, -7d, ISO time or epoch")
+	fs.StringVar(&opts.DateTo, "date-to", "", "Only tasks whose Date is on/before this day (or before this instant); same forms as --date-from")
+	fs.BoolVar(&opts.Fairness, "fairness", false, "Add wait-time and pending-age percentiles per group and flag starving groups (default --group-by scene,priority)")
+	fs.DurationVar(&opts.StarveAfter, "starve-after", defaultStarveAfter, "With --fairness: pending age after which a group that others overtake is starving")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if code := dateRangePreset(fs, &opts.Date, opts.DateFrom, opts.DateTo); code != 0 {
+		return code
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if opts.Fairness && !explicit["group-by"] {
+		groupBy = "scene,priority"
+	}
+	if opts.StarveAfter <= 0 {
+		errLogger.Error("--starve-after must be positive")
+		return 2
+	}
+	opts.GroupBy = strings.Split(groupBy, ",")
+	return StatsTasks(ctx, opts)
+}
+
+func runReport(ctx context.Context, args []string) int {
+	opts := ReportOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Date:       "Any",
+		IgnoreView: true,
+		Top:        5,
+		PublishURL: os.Getenv("BITABLE_REPORT_DOC_URL"),
+	}
+	var useView bool
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task report [--publish <docx/wiki url> --block-id <id>] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.IntVar(&opts.Top, "top", opts.Top, "How many failing scenes to list")
+	fs.StringVar(&opts.PublishURL, "publish", opts.PublishURL, "Docx or wiki URL whose block is rewritten with the snapshot (default: BITABLE_REPORT_DOC_URL)")
+	fs.StringVar(&opts.BlockID, "block-id", "", "Block to rewrite (a callout or other container; default: the --publish URL fragment)")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	return ReportTasks(ctx, opts)
+}
+
+// runDevice dispatches the device subcommands.
+func runDevice(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] != "history" {
+		errLogger.Error("usage: bitable-task device history --serial <serial> [flags]")
+		return 2
+	}
+	opts := DeviceHistoryOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		RunsURL: os.Getenv("TASK_RUNS_BITABLE_URL"),
+		Since:   "7d",
+		Format:  outputJSON,
+	}
+	fs := flag.NewFlagSet("device history", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task device history --serial <serial> [--since 7d] [--output-format json|jsonl|table|csv|yaml]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RunsURL, "runs-url", opts.RunsURL, "Runs table URL (default: TASK_RUNS_BITABLE_URL)")
+	fs.StringVar(&opts.Serial, "serial", "", "Device serial (required)")
+	fs.StringVar(&opts.Since, "since", opts.Since, "Attempts started at/after this: 7d, 36h, YYYY-MM-DD, ISO time or epoch")
+	fs.StringVar(&opts.Until, "until", "", "Attempts started before this (default: now); same forms as --since")
+	fs.StringVar(&opts.Source, "source", "", "Where to read attempts: tasks, runs or all (default: all with a runs table, else tasks)")
+	fs.StringVar(&opts.Format, "output-format", opts.Format, "Output format: json, jsonl, table, csv or yaml")
+	fs.StringVar(&opts.Format, "format", opts.Format, "Alias of --output-format")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	return DeviceHistory(ctx, opts)
+}
+
+func runMirror(ctx context.Context, args []string) int {
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "sync":
+		opts := MirrorSyncOptions{
+			TaskURL: os.Getenv("TASK_BITABLE_URL"),
+			File:    os.Getenv("BITABLE_MIRROR_FILE"),
+		}
+		fs := flag.NewFlagSet("mirror sync", flag.ContinueOnError)
+		fs.SetOutput(os.Stderr)
+		setFlagUsage(fs, "bitable-task mirror sync --file <mirror.jsonl> [--full] [--diff]")
+		fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+		fs.StringVar(&opts.File, "file", opts.File, "Mirror file (JSONL, created on the first sync)")
+		fs.BoolVar(&opts.Full, "full", false, "Rescan the whole table and drop records deleted from it")
+		fs.BoolVar(&opts.Diff, "diff", false, "Print one JSONL line per added, changed or removed record")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		return SyncMirror(ctx, opts)
+	case "query":
+		opts := MirrorQueryOptions{
+			TaskURL: os.Getenv("TASK_BITABLE_URL"),
+			File:    os.Getenv("BITABLE_MIRROR_FILE"),
+			Date:    "Any",
+		}
+		var filters stringList
+		fs := flag.NewFlagSet("mirror query", flag.ContinueOnError)
+		fs.SetOutput(os.Stderr)
+		setFlagUsage(fs, "bitable-task mirror query --file <mirror.jsonl> [--app <app>] [--status <status>] [--where <expr>] [--sync]")
+		fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL (checked against the mirror; needed with --sync)")
+		fs.StringVar(&opts.File, "file", opts.File, "Mirror file written by mirror sync")
+		fs.BoolVar(&opts.Sync, "sync", false, "Sync the mirror incrementally before querying")
+		fs.StringVar(&opts.App, "app", "", "App value for filter")
+		fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+		fs.StringVar(&opts.Status, "status", "", "Task status filter")
+		fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+		fs.Var(&filters, "filter", "Condition on any column: Name=value, Name!=value, Name:op=value or Name:is_empty (repeatable; ops: "+strings.Join(filterOperatorNames(), ", ")+")")
+		fs.StringVar(&opts.Where, "where", "", "Filter expression, e.g. \"Status in (pending,failed) and (App = xhs or App = dy)\"")
+		fs.IntVar(&opts.Limit, "limit", 0, "Max tasks to print (0 = no cap)")
+		fs.BoolVar(&opts.IncludeDeleted, "include-deleted", false, "Also print soft-deleted tasks")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		opts.Filters = filters
+		return QueryMirror(ctx, opts)
+	}
+	errLogger.Error("usage: bitable-task mirror sync|query --file <mirror.jsonl> [flags]")
+	return 2
+}
+
+func runPerms(ctx context.Context, args []string) int {
+	opts := PermsOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+	}
+	fs := flag.NewFlagSet("perms", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task perms [--output-format table] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.Format, "output-format", outputJSON, "Output format: json, table, csv or yaml")
+	fs.StringVar(&opts.Format, "format", outputJSON, "Alias of --output-format")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return CheckPerms(ctx, opts)
+}
+
+func runInfer(ctx context.Context, args []string) int {
+	opts := InferOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+	}
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task infer [--output-format env] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.IntVar(&opts.Sample, "sample", defaultInferSample, "Records to sample (max 500)")
+	fs.StringVar(&opts.Format, "output-format", outputJSON, "Output format: json, env, table or yaml")
+	fs.StringVar(&opts.Format, "format", outputJSON, "Alias of --output-format")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return InferFields(ctx, opts)
+}
+
+func runProbe(ctx context.Context, args []string) int {
+	opts := ProbeOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Format:     "nagios",
+		IgnoreView: true,
+	}
+	var useView bool
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task probe [--max-age 10m] [--min-pending 1] [--format nagios|json] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.DurationVar(&opts.MaxAge, "max-age", 0, "CRITICAL when the newest task was created longer ago than this (0 = off)")
+	fs.IntVar(&opts.MinPending, "min-pending", 0, "WARNING when fewer tasks are pending")
+	fs.IntVar(&opts.MaxPending, "max-pending", 0, "WARNING when more tasks are pending (0 = off)")
+	fs.StringVar(&opts.Format, "format", opts.Format, "Output format: nagios or json")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return probeUnknown
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	return ProbeTasks(ctx, opts)
+}
+
+func runExport(ctx context.Context, args []string) int {
+	opts := ExportOptions{
+		TaskURL:    os.Getenv("TASK_BITABLE_URL"),
+		Date:       "Any",
+		IgnoreView: true,
+	}
+	var useView bool
+	var taskURLs stringList
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task export [--output tasks.xlsx | --dir <dir> --task-url <url>...] [--format csv|xlsx] [flags]")
+	fs.Var(&taskURLs, "task-url", "Bitable task table URL (repeatable with --dir; default: TASK_BITABLE_URL)")
+	fs.StringVar(&opts.Dir, "dir", "", "Export each table into this directory with a manifest.json")
+	fs.IntVar(&opts.Parallel, "parallel", 4, "Tables exported concurrently with --dir")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.StringVar(&opts.Status, "status", "", "Task status filter (default: all)")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.StringVar(&opts.Output, "output", "", "Output file (default: CSV to stdout)")
+	fs.StringVar(&opts.Format, "format", "", "Output format: csv or xlsx (default: from --output extension, else csv)")
+	fs.BoolVar(&useView, "use-view", false, "Use view_id from URL")
+	fs.StringVar(&opts.ViewID, "view-id", "", "Override view_id when searching")
+	fs.StringVar(&opts.Where, "where", "", "Filter expression, e.g. \"Status in (pending,failed) and (App = xhs or App = dy)\"")
+	fs.StringVar(&opts.DateFrom, "date-from", "", "Only tasks whose Date is on/after this: YYYY-MM-DD, today, yesterday, -7d, ISO time or epoch")
+	fs.StringVar(&opts.DateTo, "date-to", "", "Only tasks whose Date is on/before this day (or before this instant); same forms as --date-from")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if code := dateRangePreset(fs, &opts.Date, opts.DateFrom, opts.DateTo); code != 0 {
+		return code
+	}
+	if useView {
+		opts.IgnoreView = false
+	}
+	opts.TaskURLs = taskURLs
+	return ExportTasks(ctx, opts)
+}
+
+func runInitTable(ctx context.Context, args []string) int {
+	opts := InitTableOptions{TaskURL: os.Getenv("TASK_BITABLE_URL")}
+	fs := flag.NewFlagSet("init-table", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task init-table [--task-url <table or base url>] [--name Tasks] [--dry-run]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable table URL (add missing columns) or base URL (create a table)")
+	fs.StringVar(&opts.Name, "name", "", "Name of the table to create when the URL has no table")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Report the columns that would be created without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return InitTable(ctx, opts)
+}
+
+func runMigrateLegacy(ctx context.Context, args []string) int {
+	opts := MigrateLegacyOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Date:    "Any",
+	}
+	fs := flag.NewFlagSet("migrate-legacy", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task migrate-legacy [--dry-run] [flags]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.App, "app", "", "App value for filter")
+	fs.StringVar(&opts.Scene, "scene", "", "Scene value for filter")
+	fs.StringVar(&opts.Status, "status", "", "Task status filter (default: all)")
+	fs.StringVar(&opts.Date, "date", opts.Date, "Date preset: Today/Yesterday/Any")
+	fs.IntVar(&opts.Limit, "limit", 0, "Max records to migrate (0 = no cap)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Report legacy records without changing them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return MigrateLegacy(ctx, opts)
+}
+
+func runFields(ctx context.Context, args []string) int {
+	opts := FieldsOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Format:  "table",
+	}
+	fs := flag.NewFlagSet("fields", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task fields [--format table|json]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.Format, "format", opts.Format, "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return ListTableFields(ctx, opts)
+}
+
+func runValidate(ctx context.Context, args []string) int {
+	opts := ValidateOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Format:  "table",
+	}
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task validate [--format table|json]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.Format, "format", opts.Format, "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return ValidateFields(ctx, opts)
+}
+
+func runSelfUpdate(ctx context.Context, args []string) int {
+	opts := SelfUpdateOptions{
+		Repo:      common.Env("BITABLE_TASK_RELEASE_REPO", common.DefaultReleaseRepo),
+		Version:   "latest",
+		PublicKey: os.Getenv("BITABLE_TASK_UPDATE_PUBKEY"),
+	}
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task self-update [--version vX.Y.Z] [--check] [flags]")
+	fs.StringVar(&opts.Repo, "repo", opts.Repo, "GitHub repository (owner/name) publishing releases")
+	fs.StringVar(&opts.Version, "version", opts.Version, "Release tag to install")
+	fs.StringVar(&opts.PublicKey, "public-key", opts.PublicKey, "ed25519 public key (hex or base64) that must sign checksums.txt")
+	fs.BoolVar(&opts.Check, "check", false, "Only report the current and available versions")
+	fs.BoolVar(&opts.Force, "force", false, "Reinstall even when already on the requested version")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	return SelfUpdate(ctx, opts)
+}
+
+func runAttach(ctx context.Context, args []string) int {
+	opts := AttachOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+	}
+	var files stringList
+	fs := flag.NewFlagSet("attach", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task attach --record-id <id> --manifest --file <path> [--file <path>...]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Record id to attach to")
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Task id to attach to")
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id to attach to")
+	fs.Var(&files, "file", "File to upload (repeatable)")
+	fs.BoolVar(&opts.Manifest, "manifest", false, "Record uploaded files in the Artifacts manifest")
+	fs.BoolVar(&opts.Verify, "verify", false, "Re-download each upload and compare its sha256")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	opts.Files = files
+	return AttachArtifacts(ctx, opts)
+}
+
+func runDownload(ctx context.Context, args []string) int {
+	opts := DownloadOptions{
+		TaskURL: os.Getenv("TASK_BITABLE_URL"),
+		Dir:     ".",
+	}
+	var names stringList
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	setFlagUsage(fs, "bitable-task download --record-id <id> (--all-artifacts | --name <name>...) [--dir <dir>]")
+	fs.StringVar(&opts.TaskURL, "task-url", opts.TaskURL, "Bitable task table URL")
+	fs.StringVar(&opts.RecordID, "record-id", "", "Record id to download from")
+	fs.IntVar(&opts.TaskID, "task-id", 0, "Task id to download from")
+	fs.StringVar(&opts.BizTaskID, "biz-task-id", "", "Biz task id to download from")
+	fs.StringVar(&opts.Dir, "dir", opts.Dir, "Output directory")
+	fs.Var(&names, "name", "Artifact name to download (repeatable)")
+	fs.BoolVar(&opts.AllArtifacts, "all-artifacts", false, "Download every artifact in the manifest")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
-	return CreateTasks(opts)
+	opts.Names = names
+	return DownloadArtifacts(ctx, opts)
 }
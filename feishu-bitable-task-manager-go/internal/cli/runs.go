@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+// terminalStatuses end an attempt; an update setting one of them (or an end
+// time) is recorded as a row in the runs table.
+var terminalStatuses = func() map[string]bool {
+	out := map[string]bool{}
+	for _, s := range taskmodel.Statuses {
+		if s.IsTerminal() {
+			out[string(s)] = true
+		}
+	}
+	return out
+}()
+
+// runsTable is the execution-history table that receives one row per
+// finished attempt, linked back to the task record.
+type runsTable struct {
+	ref    common.BitableRef
+	fields map[string]string
+}
+
+func openRunsTable(ctx context.Context, baseURL, token, runsURL string) (*runsTable, error) {
+	ref, err := common.ParseBitableURL(runsURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse runs table URL: %w", err)
+	}
+	if ref.AppToken == "" {
+		if ref.WikiToken == "" {
+			return nil, fmt.Errorf("runs table URL missing app_token and wiki_token")
+		}
+		appTok, err := common.ResolveWikiAppToken(ctx, baseURL, token, ref.WikiToken)
+		if err != nil {
+			return nil, err
+		}
+		ref.AppToken = appTok
+	}
+	return &runsTable{ref: ref, fields: common.LoadRunFieldsFromEnv()}, nil
+}
+
+func isAttemptEnd(fieldsMap map[string]string, fields map[string]any) bool {
+	if col := fieldsMap["EndAt"]; col != "" {
+		if _, ok := fields[col]; ok {
+			return true
+		}
+	}
+	status := strings.ToLower(strings.TrimSpace(common.BitableValueToString(fields[fieldsMap["Status"]])))
+	return terminalStatuses[status]
+}
+
+// writeRuns appends one runs-table row per finished attempt. The row is
+// built from the task record as it looks after the update (current fields
+// overlaid with the written ones).
+func (r *runsTable) writeRuns(ctx context.Context, baseURL, token string, taskRef common.BitableRef, fieldsMap map[string]string, updates []recordUpdate) (int, error) {
+	finished := []recordUpdate{}
+	ids := []string{}
+	for _, u := range updates {
+		if isAttemptEnd(fieldsMap, u.Fields) {
+			finished = append(finished, u)
+			ids = append(ids, u.RecordID)
+		}
+	}
+	if len(finished) == 0 {
+		return 0, nil
+	}
+	current, err := batchGetRecordFields(ctx, baseURL, token, taskRef, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([]map[string]any, 0, len(finished))
+	for _, u := range finished {
+		merged := map[string]any{}
+		for k, v := range current[u.RecordID] {
+			merged[k] = v
+		}
+		for k, v := range u.Fields {
+			merged[k] = v
+		}
+		rows = append(rows, map[string]any{"fields": r.buildRunFields(fieldsMap, u.RecordID, merged)})
+	}
+	created := 0
+	for i := 0; i < len(rows); i += createMaxBatchSize {
+		j := minInt(i+createMaxBatchSize, len(rows))
+		if _, err := batchCreateRecords(ctx, baseURL, token, r.ref, rows[i:j]); err != nil {
+			return created, fmt.Errorf("write runs: %w", err)
+		}
+		created += j - i
+	}
+	return created, nil
+}
+
+func (r *runsTable) buildRunFields(fieldsMap map[string]string, recordID string, task map[string]any) map[string]any {
+	out := map[string]any{}
+	set := func(runKey string, v any) {
+		col := strings.TrimSpace(r.fields[runKey])
+		if col == "" || v == nil {
+			return
+		}
+		if s, ok := v.(string); ok && strings.TrimSpace(s) == "" {
+			return
+		}
+		out[col] = v
+	}
+	taskValue := func(key string) any { return task[fieldsMap[key]] }
+
+	set("Task", []string{recordID})
+	if id := common.FieldInt(task, fieldsMap["TaskID"]); id > 0 {
+		set("TaskID", id)
+	}
+	retry, _ := common.CoerceInt(common.BitableValueToString(taskValue("RetryCount")))
+	set("Attempt", retry+1)
+	set("Status", common.BitableValueToString(taskValue("Status")))
+	device := common.BitableValueToString(taskValue("DispatchedDevice"))
+	if device == "" {
+		device = common.BitableValueToString(taskValue("DeviceSerial"))
+	}
+	set("DeviceSerial", device)
+	for _, key := range []string{"StartAt", "EndAt"} {
+		if ms, ok := common.CoerceMillis(taskValue(key)); ok {
+			set(key, ms)
+		}
+	}
+	for _, key := range []string{"ElapsedSeconds", "ItemsCollected"} {
+		if n, ok := common.CoerceInt(common.BitableValueToString(taskValue(key))); ok {
+			set(key, n)
+		}
+	}
+	set("Logs", common.BitableValueToString(taskValue("Logs")))
+	return out
+}
@@ -0,0 +1,85 @@
+package cli
+
+import "feishu-bitable-task-manager-go/internal/common"
+
+// schemaField is the canonical column type of one logical Task field.
+// Accept lists other column types the tool also reads and writes correctly,
+// e.g. a Number TaskID filled in by create or a text Date. Required fields
+// are the ones fetch filters on; every other column is optional.
+type schemaField struct {
+	Logical  string
+	Type     int
+	Property map[string]any
+	Accept   []int
+	Required bool
+}
+
+// accepts reports whether a column of type t works for this field.
+func (f schemaField) accepts(t int) bool {
+	if t == f.Type {
+		return true
+	}
+	for _, a := range f.Accept {
+		if a == t {
+			return true
+		}
+	}
+	return false
+}
+
+var timestampTypes = []int{common.FieldTypeNumber, common.FieldTypeText, common.FieldTypeCreatedTime, common.FieldTypeModifiedTime}
+
+var (
+	dateTimeProperty = map[string]any{"date_formatter": "yyyy/MM/dd HH:mm", "auto_fill": false}
+	dateProperty     = map[string]any{"date_formatter": "yyyy/MM/dd", "auto_fill": false}
+	integerProperty  = map[string]any{"formatter": "0"}
+)
+
+// taskSchema is the canonical task table, in column order. BizTaskID comes
+// first because it becomes the primary column of a new table; TaskID is an
+// auto number so create does not have to allocate ids.
+var taskSchema = []schemaField{
+	{Logical: "BizTaskID", Type: common.FieldTypeText},
+	{Logical: "TaskID", Type: common.FieldTypeAutoNumber, Accept: []int{common.FieldTypeNumber, common.FieldTypeFormula}, Required: true},
+	{Logical: "ParentTaskID", Type: common.FieldTypeText},
+	{Logical: "App", Type: common.FieldTypeText, Required: true},
+	{Logical: "Scene", Type: common.FieldTypeText, Required: true},
+	{Logical: "Params", Type: common.FieldTypeText},
+	{Logical: "ItemID", Type: common.FieldTypeText},
+	{Logical: "BookID", Type: common.FieldTypeText},
+	{Logical: "URL", Type: common.FieldTypeText, Accept: []int{common.FieldTypeURL}},
+	{Logical: "UserID", Type: common.FieldTypeText, Accept: []int{common.FieldTypeUser}},
+	{Logical: "UserName", Type: common.FieldTypeText, Accept: []int{common.FieldTypeUser}},
+	{Logical: "Date", Type: common.FieldTypeDateTime, Property: dateProperty, Accept: []int{common.FieldTypeText}, Required: true},
+	{Logical: "Status", Type: common.FieldTypeSingleSelect, Property: statusOptionsProperty(), Accept: []int{common.FieldTypeText}, Required: true},
+	{Logical: "RetryCount", Type: common.FieldTypeNumber, Property: integerProperty},
+	{Logical: "GroupID", Type: common.FieldTypeText},
+	{Logical: "DeviceSerial", Type: common.FieldTypeText},
+	{Logical: "DispatchedDevice", Type: common.FieldTypeText},
+	{Logical: "DispatchedAt", Type: common.FieldTypeDateTime, Property: dateTimeProperty, Accept: timestampTypes},
+	{Logical: "HeartbeatAt", Type: common.FieldTypeDateTime, Property: dateTimeProperty, Accept: timestampTypes},
+	{Logical: "StartAt", Type: common.FieldTypeDateTime, Property: dateTimeProperty, Accept: timestampTypes},
+	{Logical: "EndAt", Type: common.FieldTypeDateTime, Property: dateTimeProperty, Accept: timestampTypes},
+	{Logical: "ElapsedSeconds", Type: common.FieldTypeNumber, Property: integerProperty},
+	{Logical: "ItemsCollected", Type: common.FieldTypeNumber, Property: integerProperty},
+	{Logical: "Logs", Type: common.FieldTypeText},
+	{Logical: "LogsFile", Type: common.FieldTypeAttachment},
+	{Logical: "LastScreenShot", Type: common.FieldTypeAttachment},
+	{Logical: "Extra", Type: common.FieldTypeText},
+	{Logical: "Artifacts", Type: common.FieldTypeText},
+	{Logical: "AttemptToken", Type: common.FieldTypeText},
+	{Logical: "Fingerprint", Type: common.FieldTypeText},
+	{Logical: "TraceID", Type: common.FieldTypeText},
+	{Logical: "Deleted", Type: common.FieldTypeCheckbox},
+	{Logical: "EditLock", Type: common.FieldTypeText},
+	{Logical: "TouchedAt", Type: common.FieldTypeDateTime, Property: dateTimeProperty, Accept: timestampTypes},
+	{Logical: "Priority", Type: common.FieldTypeNumber, Property: integerProperty, Accept: []int{common.FieldTypeText, common.FieldTypeSingleSelect}},
+}
+
+func statusOptionsProperty() map[string]any {
+	options := make([]map[string]any, 0, len(knownStatuses))
+	for _, s := range knownStatuses {
+		options = append(options, map[string]any{"name": s})
+	}
+	return map[string]any{"options": options}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// selectCodec checks text values bound for single and multi select columns
+// against the column's options. A value matching an option up to case is
+// written with the option's spelling; a multi select value is split on
+// commas. Unknown values fail the row unless createOptions is set, in which
+// case they are added to the column first.
+type selectCodec struct {
+	schema        *tableSchema
+	createOptions bool
+}
+
+func newSelectCodec(schema *tableSchema, createOptions bool) *selectCodec {
+	return &selectCodec{schema: schema, createOptions: createOptions}
+}
+
+// value returns the payload for s in select column f.
+func (c *selectCodec) value(ctx context.Context, f *common.FieldInfo, s string) (any, error) {
+	if f.Type == common.FieldTypeSingleSelect {
+		return c.option(ctx, f, strings.TrimSpace(s))
+	}
+	names := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, err := c.option(ctx, f, part)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// option returns the option of f that value names, creating it when
+// allowed.
+func (c *selectCodec) option(ctx context.Context, f *common.FieldInfo, value string) (string, error) {
+	options := f.SelectOptions()
+	for _, o := range options {
+		if o == value {
+			return o, nil
+		}
+	}
+	for _, o := range options {
+		if strings.EqualFold(o, value) {
+			return o, nil
+		}
+	}
+	if !c.createOptions {
+		return "", fmt.Errorf("%q is not an option (have %s; pass --create-options to add it)", value, strings.Join(options, ", "))
+	}
+	property := map[string]any{}
+	for k, v := range f.Property {
+		property[k] = v
+	}
+	raw, _ := property["options"].([]any)
+	property["options"] = append(append([]any{}, raw...), map[string]any{"name": value})
+	updated := *f
+	updated.Property = property
+	s := c.schema
+	if err := common.UpdateField(ctx, s.baseURL, s.token, s.ref.AppToken, s.ref.TableID, updated); err != nil {
+		return "", fmt.Errorf("add option %q: %w", value, err)
+	}
+	*f = updated
+	errLogger.Warn("added select option", "column", f.FieldName, "option", value)
+	return value, nil
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// Version is the release this binary was built from, set at build time:
+//
+//	go build -ldflags "-X feishu-bitable-task-manager-go/internal/cli.Version=v1.2.0" ./cmd/bitable-task
+var Version = "dev"
+
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+)
+
+type SelfUpdateOptions struct {
+	Repo      string
+	Version   string
+	PublicKey string
+	Check     bool
+	Force     bool
+}
+
+type selfUpdateReport struct {
+	CurrentVersion string `json:"current_version"`
+	LatestVersion  string `json:"latest_version"`
+	Asset          string `json:"asset,omitempty"`
+	Path           string `json:"path,omitempty"`
+	Verified       string `json:"verified,omitempty"`
+	Updated        bool   `json:"updated"`
+}
+
+// SelfUpdate replaces the running binary with a GitHub release build. The
+// release must carry bitable-task_<os>_<arch>[.exe] and a checksums.txt in
+// sha256sum format; with a public key, checksums.txt.sig (an ed25519
+// signature of checksums.txt, raw or base64) must verify too. The new
+// binary is written next to the old one and renamed over it, so a failed
+// update never leaves a partial file in place.
+func SelfUpdate(ctx context.Context, opts SelfUpdateOptions) int {
+	repo := strings.TrimSpace(opts.Repo)
+	if repo == "" {
+		repo = common.DefaultReleaseRepo
+	}
+	var pub ed25519.PublicKey
+	if key := strings.TrimSpace(opts.PublicKey); key != "" {
+		b, err := decodeKey(key)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			errLogger.Error("invalid --public-key: want a hex or base64 ed25519 public key")
+			return 2
+		}
+		pub = ed25519.PublicKey(b)
+	}
+
+	rel, err := common.FetchRelease(ctx, repo, opts.Version)
+	if err != nil {
+		errLogger.Error("fetch release failed", "repo", repo, "err", err)
+		return 1
+	}
+	report := selfUpdateReport{CurrentVersion: Version, LatestVersion: rel.TagName}
+	if rel.TagName == Version && !opts.Force {
+		printJSON(report)
+		return 0
+	}
+	name := fmt.Sprintf("bitable-task_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	report.Asset = name
+	if opts.Check {
+		printJSON(report)
+		return 0
+	}
+
+	binAsset, ok := rel.Asset(name)
+	if !ok {
+		errLogger.Error("release has no build for this platform", "release", rel.TagName, "asset", name)
+		return 1
+	}
+	sumAsset, ok := rel.Asset(checksumsAsset)
+	if !ok {
+		errLogger.Error("release has no checksums; refusing to update", "release", rel.TagName)
+		return 1
+	}
+	sums, err := common.DownloadAsset(ctx, sumAsset)
+	if err != nil {
+		errLogger.Error("download checksums failed", "err", err)
+		return 1
+	}
+	report.Verified = "sha256"
+	if pub != nil {
+		sigAsset, ok := rel.Asset(signatureAsset)
+		if !ok {
+			errLogger.Error("release has no checksum signature", "release", rel.TagName)
+			return 1
+		}
+		sig, err := common.DownloadAsset(ctx, sigAsset)
+		if err != nil {
+			errLogger.Error("download signature failed", "err", err)
+			return 1
+		}
+		if !verifySignature(pub, sums, sig) {
+			errLogger.Error("checksum signature does not verify", "release", rel.TagName)
+			return 1
+		}
+		report.Verified = "sha256+ed25519"
+	} else {
+		errLogger.Warn("no --public-key; checksums are not signature-verified")
+	}
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		errLogger.Error("read checksums failed", "err", err)
+		return 1
+	}
+	bin, err := common.DownloadAsset(ctx, binAsset)
+	if err != nil {
+		errLogger.Error("download binary failed", "asset", name, "err", err)
+		return 1
+	}
+	if got := sha256.Sum256(bin); hex.EncodeToString(got[:]) != want {
+		errLogger.Error("checksum mismatch", "asset", name, "want", want, "got", hex.EncodeToString(got[:]))
+		return 1
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		errLogger.Error("locate current binary failed", "err", err)
+		return 1
+	}
+	if err := replaceBinary(exe, bin); err != nil {
+		errLogger.Error("replace binary failed", "path", exe, "err", err)
+		return 1
+	}
+	report.Path = exe
+	report.Updated = true
+	printJSON(report)
+	return 0
+}
+
+// replaceBinary writes data to a temp file in exe's directory and renames it
+// over exe. Windows cannot overwrite a running executable but can rename
+// it, so the old binary is moved aside to exe.old first.
+func replaceBinary(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".bitable-task-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			_ = os.Rename(old, exe)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), exe)
+}
+
+// checksumFor finds name in sha256sum-formatted output ("<hex>  <name>",
+// optionally "*<name>" for binary mode).
+func checksumFor(sums []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		parts := strings.Fields(sc.Text())
+		if len(parts) == 2 && strings.TrimPrefix(parts[1], "*") == name {
+			return strings.ToLower(parts[0]), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s is not listed in %s", name, checksumsAsset)
+}
+
+func verifySignature(pub ed25519.PublicKey, msg, sig []byte) bool {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return false
+		}
+		sig = decoded
+	}
+	return len(sig) == ed25519.SignatureSize && ed25519.Verify(pub, msg, sig)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return nil, errors.New("not hex or base64")
+}
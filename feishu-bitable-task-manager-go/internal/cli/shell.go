@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// shellCommand wraps argv for the selected shell. An empty shell runs argv
+// directly, except that a .ps1 script on Windows is run through PowerShell.
+// "auto" picks cmd on Windows and sh elsewhere. With a shell, argv is joined
+// into one command line, so `--shell cmd -- "adb devices && echo ok"` works.
+func shellCommand(shell string, argv []string) ([]string, error) {
+	shell = strings.ToLower(strings.TrimSpace(shell))
+	if shell == "auto" {
+		shell = "sh"
+		if runtime.GOOS == "windows" {
+			shell = "cmd"
+		}
+	}
+	line := strings.Join(argv, " ")
+	switch shell {
+	case "", "none":
+		if runtime.GOOS == "windows" && strings.EqualFold(filepath.Ext(argv[0]), ".ps1") {
+			return append([]string{"powershell", "-NoLogo", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}, argv...), nil
+		}
+		return argv, nil
+	case "sh", "bash":
+		return []string{shell, "-c", line}, nil
+	case "cmd":
+		return []string{"cmd.exe", "/d", "/s", "/c", line}, nil
+	case "powershell", "pwsh":
+		return []string{shell, "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", line}, nil
+	default:
+		return nil, fmt.Errorf("unknown shell %q (want auto, sh, bash, cmd, powershell or pwsh)", shell)
+	}
+}
+
+// envAssignment renders one variable as a statement for the selected shell
+// (sh-compatible export lines by default, PowerShell on Windows), quoted so
+// that any value is taken literally.
+func envAssignment(shell, name, value string) (string, error) {
+	shell = strings.ToLower(strings.TrimSpace(shell))
+	if shell == "auto" || shell == "" || shell == "none" {
+		shell = "sh"
+		if runtime.GOOS == "windows" {
+			shell = "powershell"
+		}
+	}
+	switch shell {
+	case "powershell", "pwsh":
+		return fmt.Sprintf("$env:%s = '%s'", name, strings.ReplaceAll(value, "'", "''")), nil
+	case "cmd":
+		// Inside set "NAME=value" only % needs escaping (for batch files); a
+		// double quote or line break cannot be represented at all.
+		if strings.ContainsAny(value, "\"\r\n") {
+			return "", fmt.Errorf("%s cannot be quoted for cmd; use exec or --shell powershell", name)
+		}
+		return fmt.Sprintf(`set "%s=%s"`, name, strings.ReplaceAll(value, "%", "%%")), nil
+	default:
+		return fmt.Sprintf("export %s=%s", name, shellQuote(value)), nil
+	}
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// A sliced fetch (--slice-by) runs one search per time window of the
+// --date-from / --date-to range, so each window is paged from its first
+// page and no single search pages deep into a large table.
+const (
+	sliceByDate    = "date"
+	sliceByCreated = "created"
+
+	defaultSliceSize = 24 * time.Hour
+)
+
+// timeSlice is one [From, To) window of a sliced fetch.
+type timeSlice struct {
+	From time.Time
+	To   time.Time
+}
+
+// splitTimeRange cuts [from, to) into consecutive windows of size; the last
+// one may be shorter.
+func splitTimeRange(from, to time.Time, size time.Duration) []timeSlice {
+	out := []timeSlice{}
+	for start := from; start.Before(to); start = start.Add(size) {
+		end := start.Add(size)
+		if end.After(to) {
+			end = to
+		}
+		out = append(out, timeSlice{From: start, To: end})
+	}
+	return out
+}
+
+// parseSliceBy validates a --slice-by value; "" disables slicing.
+func parseSliceBy(s string) (string, error) {
+	switch by := strings.ToLower(strings.TrimSpace(s)); by {
+	case "", sliceByDate, sliceByCreated:
+		return by, nil
+	}
+	return "", fmt.Errorf("--slice-by must be %s or %s", sliceByDate, sliceByCreated)
+}
+
+// sliceColumn returns the column a sliced fetch filters on and its date
+// kind: the Date field, or the table's first CreatedTime column.
+func sliceColumn(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]string, by string) (string, string, error) {
+	if by == sliceByDate {
+		col := strings.TrimSpace(fields["Date"])
+		if col == "" {
+			return "", "", fmt.Errorf("the Date field is not mapped")
+		}
+		return col, loadDateWriter(ctx, baseURL, token, ref, col).kind, nil
+	}
+	schema, err := common.ListFields(ctx, baseURL, token, ref.AppToken, ref.TableID)
+	if err != nil {
+		return "", "", fmt.Errorf("list fields: %w", err)
+	}
+	for _, f := range schema {
+		if f.Type == common.FieldTypeCreatedTime {
+			return f.FieldName, common.DateKindDateTime, nil
+		}
+	}
+	return "", "", fmt.Errorf("--slice-by %s needs a CreatedTime column in the table", sliceByCreated)
+}
+
+// fetchSlices resolves the windows of a sliced fetch and the filter for
+// each, built on filterObj (as built by buildFilter, not modified); the
+// caller folds --where into each. --date-to defaults to now. A text Date
+// column is matched by day, so its windows are whole days from midnight.
+func fetchSlices(ctx context.Context, baseURL, token string, ref common.BitableRef, fields map[string]string, by, from, to string, size time.Duration, filterObj map[string]any) ([]timeSlice, []map[string]any, error) {
+	col, kind, err := sliceColumn(ctx, baseURL, token, ref, fields, by)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc := common.TaskTimezone()
+	now := time.Now()
+	start, err := parseDateBound(from, loc, now, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("--date-from: %w", err)
+	}
+	end := now
+	if strings.TrimSpace(to) != "" {
+		if end, err = parseDateBound(to, loc, now, true); err != nil {
+			return nil, nil, fmt.Errorf("--date-to: %w", err)
+		}
+	}
+	if !start.Before(end) {
+		return nil, nil, fmt.Errorf("--date-from must be before --date-to")
+	}
+	if kind == common.DateKindText {
+		y, m, d := start.In(loc).Date()
+		start = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		if days := (size + 24*time.Hour - 1) / (24 * time.Hour); days > 0 {
+			size = days * 24 * time.Hour
+		}
+	}
+	slices := splitTimeRange(start, end, size)
+	filters := make([]map[string]any, 0, len(slices))
+	for _, s := range slices {
+		conds, child, err := dateRangeFilter(kind, col, s.From, s.To, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, withDateConds(filterObj, conds, child))
+	}
+	return slices, filters, nil
+}
+
+// withDateConds returns a copy of filterObj with the date range conditions
+// (and OR group, for text columns) from dateRangeFilter added.
+func withDateConds(filterObj map[string]any, conds []filterCond, child map[string]any) map[string]any {
+	out := map[string]any{"conjunction": "and"}
+	list := []map[string]any{}
+	var children []map[string]any
+	if filterObj != nil {
+		for k, v := range filterObj {
+			out[k] = v
+		}
+		existing, _ := filterObj["conditions"].([]map[string]any)
+		list = append(list, existing...)
+		prev, _ := filterObj["children"].([]map[string]any)
+		children = append(children, prev...)
+	}
+	for _, c := range conds {
+		list = append(list, whereCondition(c))
+	}
+	out["conditions"] = list
+	if child != nil {
+		children = append(children, child)
+	}
+	if len(children) > 0 {
+		out["children"] = children
+	}
+	return out
+}
+
+// remaining is what is left of limit after used, for caps where 0 means
+// none.
+func remaining(limit, used int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return limit - used
+}
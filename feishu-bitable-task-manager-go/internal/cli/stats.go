@@ -0,0 +1,447 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
+)
+
+// defaultStarveAfter is the --starve-after default of stats --fairness.
+const defaultStarveAfter = 30 * time.Minute
+
+// statsDimensions maps a --group-by name to the value it extracts from a
+// record. Date-derived dimensions read the Date column in TASK_TIMEZONE.
+var statsDimensions = map[string]func(fieldsRaw map[string]any, fields map[string]string, loc *time.Location) string{
+	"status":   statsField("Status"),
+	"app":      statsField("App"),
+	"scene":    statsField("Scene"),
+	"priority": statsField("Priority"),
+	"device": func(fieldsRaw map[string]any, fields map[string]string, loc *time.Location) string {
+		if d := statsField("DispatchedDevice")(fieldsRaw, fields, loc); d != "" {
+			return d
+		}
+		return statsField("DeviceSerial")(fieldsRaw, fields, loc)
+	},
+	"date":  statsDate("2006-01-02"),
+	"month": statsDate("2006-01"),
+	"week": func(fieldsRaw map[string]any, fields map[string]string, loc *time.Location) string {
+		t, ok := taskDay(fieldsRaw[fields["Date"]], loc)
+		if !ok {
+			return ""
+		}
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", y, w)
+	},
+}
+
+type StatsOptions struct {
+	TaskURL    string
+	App        string
+	Scene      string
+	Status     string
+	Date       string
+	GroupBy    []string
+	Format     string
+	IgnoreView bool
+	ViewID     string
+	// Where is a --where expression, see compileWhere.
+	Where string
+	// DateFrom and DateTo bound the Date column, see parseDateBound.
+	DateFrom string
+	DateTo   string
+	// Fairness adds wait-time and pending-age distributions per group and
+	// flags groups starving for longer than StarveAfter, see fairnessStats.
+	Fairness    bool
+	StarveAfter time.Duration
+}
+
+// metricStats summarizes one numeric column over the rows of a group that
+// have a value (Count is 0 when none do). Percentiles use the nearest-rank
+// method.
+type metricStats struct {
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+type statsGroup struct {
+	Key            map[string]string `json:"key"`
+	Count          int               `json:"count"`
+	ElapsedSeconds metricStats       `json:"elapsed_seconds"`
+	ItemsCollected metricStats       `json:"items_collected"`
+	Fairness       *fairnessStats    `json:"fairness,omitempty"`
+
+	elapsed []float64
+	items   []float64
+	waits   []float64
+	ages    []float64
+	// oldestPending is the creation time of the oldest pending task (ms).
+	oldestPending int64
+	// served holds the dispatch times (ms) of the group's tasks.
+	served []int64
+}
+
+// fairnessStats describes how a group is served. WaitSeconds runs from
+// creation to dispatch (DispatchedAt, else StartAt) over the dispatched
+// tasks; PendingAgeSeconds is the age of the tasks still pending. A group
+// is starving when its oldest pending task is older than --starve-after
+// while tasks of other groups were dispatched after it was created: the
+// queue moved, just not for this group.
+type fairnessStats struct {
+	WaitSeconds       metricStats `json:"wait_seconds"`
+	Pending           int         `json:"pending"`
+	PendingAgeSeconds metricStats `json:"pending_age_seconds"`
+	ServedMeanwhile   int         `json:"served_meanwhile"`
+	Starving          bool        `json:"starving"`
+}
+
+type statsReport struct {
+	GroupBy            []string            `json:"group_by"`
+	Groups             []statsGroup        `json:"groups"`
+	Total              int                 `json:"total"`
+	StarveAfterSeconds float64             `json:"starve_after_seconds,omitempty"`
+	Starving           []map[string]string `json:"starving,omitempty"`
+	ElapsedSeconds     float64             `json:"elapsed_seconds"`
+}
+
+func statsDimensionNames() []string {
+	names := make([]string, 0, len(statsDimensions))
+	for name := range statsDimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StatsTasks pages through every matching task and prints counts plus
+// ElapsedSeconds/ItemsCollected distributions per group.
+func StatsTasks(ctx context.Context, opts StatsOptions) int {
+	groupBy := []string{}
+	for _, g := range opts.GroupBy {
+		g = strings.ToLower(strings.TrimSpace(g))
+		if g == "iso-week" {
+			g = "week"
+		}
+		if g == "" {
+			continue
+		}
+		if _, ok := statsDimensions[g]; !ok {
+			errLogger.Error("unknown --group-by dimension", "dimension", g, "valid", strings.Join(statsDimensionNames(), ","))
+			return 2
+		}
+		groupBy = append(groupBy, g)
+	}
+	format, err := parseOutputFormat(opts.Format, outputJSON, outputJSONL, outputTable, outputCSV, outputYAML)
+	if err != nil {
+		errLogger.Error("invalid output format", "err", err)
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+
+	start := time.Now()
+	loc := common.TaskTimezone()
+	body := map[string]any{}
+	if opts.Fairness {
+		body["automatic_fields"] = true
+	}
+	filterObj, err := applyDateRange(ctx, tc.baseURL, tc.token, tc.ref, tc.fields, opts.DateFrom, opts.DateTo,
+		buildFilter(tc.fields, opts.App, opts.Scene, opts.Status, opts.Date))
+	if err != nil {
+		errLogger.Error("invalid date range", "err", err)
+		return 2
+	}
+	if filterObj, err = compileWhere(opts.Where, tc.fields, filterObj); err != nil {
+		errLogger.Error("invalid --where", "err", err)
+		return 2
+	}
+	if filterObj != nil {
+		body["filter"] = filterObj
+	}
+	viewID := strings.TrimSpace(opts.ViewID)
+	if viewID == "" {
+		viewID = tc.ref.ViewID
+	}
+	if !opts.IgnoreView && viewID != "" {
+		body["view_id"] = viewID
+	}
+
+	groups := map[string]*statsGroup{}
+	total := 0
+	err = scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		key := make(map[string]string, len(groupBy))
+		parts := make([]string, 0, len(groupBy))
+		for _, g := range groupBy {
+			v := statsDimensions[g](fieldsRaw, tc.fields, loc)
+			key[g] = v
+			parts = append(parts, v)
+		}
+		id := strings.Join(parts, "\x1f")
+		grp := groups[id]
+		if grp == nil {
+			grp = &statsGroup{Key: key}
+			groups[id] = grp
+		}
+		grp.Count++
+		total++
+		if v, ok := statsNumber(fieldsRaw[tc.fields["ElapsedSeconds"]]); ok {
+			grp.elapsed = append(grp.elapsed, v)
+		}
+		if v, ok := statsNumber(fieldsRaw[tc.fields["ItemsCollected"]]); ok {
+			grp.items = append(grp.items, v)
+		}
+		if opts.Fairness {
+			grp.observeWait(item, tc.fields, start)
+		}
+	})
+	if err != nil {
+		errLogger.Error("scan tasks failed", "err", err)
+		return 1
+	}
+
+	report := statsReport{GroupBy: groupBy, Groups: make([]statsGroup, 0, len(groups)), Total: total}
+	for _, grp := range groups {
+		grp.ElapsedSeconds = summarize(grp.elapsed)
+		grp.ItemsCollected = summarize(grp.items)
+	}
+	if opts.Fairness {
+		report.StarveAfterSeconds = opts.StarveAfter.Seconds()
+		markStarving(groups, opts.StarveAfter)
+	}
+	for _, grp := range groups {
+		report.Groups = append(report.Groups, *grp)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		for _, g := range groupBy {
+			if a.Key[g] != b.Key[g] {
+				return a.Key[g] < b.Key[g]
+			}
+		}
+		return false
+	})
+	for _, g := range report.Groups {
+		if g.Fairness != nil && g.Fairness.Starving {
+			report.Starving = append(report.Starving, g.Key)
+		}
+	}
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	switch format {
+	case outputTable:
+		printStatsTable(report)
+		return 0
+	case outputJSONL:
+		for _, g := range report.Groups {
+			logger.Info("group", "group", g)
+		}
+		return 0
+	}
+	rows := make([]any, 0, len(report.Groups))
+	for _, g := range report.Groups {
+		rows = append(rows, g)
+	}
+	return printFormatted(format, report, rows)
+}
+
+func printStatsTable(report statsReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{}
+	for _, g := range report.GroupBy {
+		header = append(header, strings.ToUpper(g))
+	}
+	header = append(header, "COUNT", "ELAPSED_P50", "ELAPSED_P90", "ELAPSED_MAX", "ITEMS_SUM", "ITEMS_P50")
+	fairness := report.StarveAfterSeconds > 0
+	if fairness {
+		header = append(header, "WAIT_P50", "WAIT_P90", "PENDING", "OLDEST_PENDING", "STARVING")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, grp := range report.Groups {
+		row := []string{}
+		for _, g := range report.GroupBy {
+			v := grp.Key[g]
+			if v == "" {
+				v = "-"
+			}
+			row = append(row, v)
+		}
+		row = append(row, strconv.Itoa(grp.Count))
+		if m := grp.ElapsedSeconds; m.Count > 0 {
+			row = append(row, formatStat(m.P50), formatStat(m.P90), formatStat(m.Max))
+		} else {
+			row = append(row, "-", "-", "-")
+		}
+		if m := grp.ItemsCollected; m.Count > 0 {
+			row = append(row, formatStat(m.Sum), formatStat(m.P50))
+		} else {
+			row = append(row, "-", "-")
+		}
+		if f := grp.Fairness; fairness && f != nil {
+			if f.WaitSeconds.Count > 0 {
+				row = append(row, formatStat(f.WaitSeconds.P50), formatStat(f.WaitSeconds.P90))
+			} else {
+				row = append(row, "-", "-")
+			}
+			row = append(row, strconv.Itoa(f.Pending))
+			if f.PendingAgeSeconds.Count > 0 {
+				row = append(row, formatStat(f.PendingAgeSeconds.Max))
+			} else {
+				row = append(row, "-")
+			}
+			starving := "-"
+			if f.Starving {
+				starving = "yes"
+			}
+			row = append(row, starving)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if len(report.GroupBy) > 0 {
+		total := append([]string{"TOTAL"}, make([]string, len(report.GroupBy)-1)...)
+		fmt.Fprintln(w, strings.Join(append(total, strconv.Itoa(report.Total)), "\t"))
+	}
+	w.Flush()
+}
+
+// observeWait records the wait of a dispatched task or the age of a pending
+// one. Tasks without a creation time are skipped.
+func (g *statsGroup) observeWait(item map[string]any, fields map[string]string, now time.Time) {
+	fieldsRaw, _ := item["fields"].(map[string]any)
+	created, ok := common.CoerceMillis(item["created_time"])
+	if !ok || created <= 0 {
+		return
+	}
+	status, _ := canonicalStatus(common.BitableValueToString(fieldsRaw[fields["Status"]]))
+	if status == string(taskmodel.StatusPending) {
+		if g.oldestPending == 0 || created < g.oldestPending {
+			g.oldestPending = created
+		}
+		g.ages = append(g.ages, millisToSeconds(now.UnixMilli()-created))
+		return
+	}
+	dispatched, ok := common.CoerceMillis(fieldsRaw[fields["DispatchedAt"]])
+	if !ok {
+		if dispatched, ok = common.CoerceMillis(fieldsRaw[fields["StartAt"]]); !ok {
+			return
+		}
+	}
+	if dispatched >= created {
+		g.waits = append(g.waits, millisToSeconds(dispatched-created))
+		g.served = append(g.served, dispatched)
+	}
+}
+
+// markStarving fills Fairness on every group. Dispatches of other groups
+// count as served meanwhile when they happened after this group's oldest
+// pending task was created.
+func markStarving(groups map[string]*statsGroup, starveAfter time.Duration) {
+	for id, grp := range groups {
+		f := &fairnessStats{
+			WaitSeconds:       summarize(grp.waits),
+			Pending:           len(grp.ages),
+			PendingAgeSeconds: summarize(grp.ages),
+		}
+		grp.Fairness = f
+		if f.Pending == 0 {
+			continue
+		}
+		for otherID, other := range groups {
+			if otherID == id {
+				continue
+			}
+			for _, t := range other.served {
+				if t > grp.oldestPending {
+					f.ServedMeanwhile++
+				}
+			}
+		}
+		f.Starving = f.PendingAgeSeconds.Max >= starveAfter.Seconds() && f.ServedMeanwhile > 0
+	}
+}
+
+func millisToSeconds(ms int64) float64 {
+	return float64(ms) / 1000
+}
+
+func summarize(values []float64) metricStats {
+	if len(values) == 0 {
+		return metricStats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	m := metricStats{Count: len(sorted), Min: sorted[0], Max: sorted[len(sorted)-1]}
+	for _, v := range sorted {
+		m.Sum += v
+	}
+	m.Avg = math.Round(m.Sum/float64(len(sorted))*1000) / 1000
+	m.P50 = percentile(sorted, 50)
+	m.P90 = percentile(sorted, 90)
+	m.P99 = percentile(sorted, 99)
+	return m
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func formatStat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func statsNumber(v any) (float64, bool) {
+	return common.ParseNumber(common.BitableValueToString(v))
+}
+
+func statsField(name string) func(map[string]any, map[string]string, *time.Location) string {
+	return func(fieldsRaw map[string]any, fields map[string]string, _ *time.Location) string {
+		return strings.TrimSpace(common.BitableValueToString(fieldsRaw[fields[name]]))
+	}
+}
+
+func statsDate(layout string) func(map[string]any, map[string]string, *time.Location) string {
+	return func(fieldsRaw map[string]any, fields map[string]string, loc *time.Location) string {
+		t, ok := taskDay(fieldsRaw[fields["Date"]], loc)
+		if !ok {
+			return ""
+		}
+		return t.Format(layout)
+	}
+}
+
+// taskDay reads a Date cell, which is either a "YYYY-MM-DD" text value or a
+// millisecond timestamp, as a day in loc.
+func taskDay(v any, loc *time.Location) (time.Time, bool) {
+	s := strings.TrimSpace(common.BitableValueToString(v))
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true
+	}
+	ms, ok := common.CoerceMillis(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms).In(loc), true
+}
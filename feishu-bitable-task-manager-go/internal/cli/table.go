@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// tableClient bundles what every command needs once it has authenticated
+// against the task table: base URL, tenant token, resolved ref and field map.
+type tableClient struct {
+	baseURL string
+	token   string
+	ref     common.BitableRef
+	fields  map[string]string
+}
+
+// openTable validates env, parses the table URL, fetches a tenant token and
+// resolves wiki URLs. On failure it logs the reason and returns the exit code.
+func openTable(ctx context.Context, taskURL string) (*tableClient, int) {
+	taskURL = strings.TrimSpace(taskURL)
+	if taskURL == "" {
+		errLogger.Error("TASK_BITABLE_URL is required")
+		return nil, 2
+	}
+	return openBitable(ctx, taskURL, common.ParseBitableURL)
+}
+
+// openBitable authenticates and resolves the app token of a Bitable link
+// parsed with parse; openTable requires a table, init-table does not.
+func openBitable(ctx context.Context, taskURL string, parse func(string) (common.BitableRef, error)) (*tableClient, int) {
+	appID := common.Env("FEISHU_APP_ID", "")
+	appSecret := common.Env("FEISHU_APP_SECRET", "")
+	if appID == "" || appSecret == "" {
+		errLogger.Error("FEISHU_APP_ID/FEISHU_APP_SECRET are required")
+		return nil, 2
+	}
+	baseURL := common.Env("FEISHU_BASE_URL", common.DefaultBaseURL)
+
+	ref, err := parse(taskURL)
+	if err != nil {
+		errLogger.Error("parse bitable URL failed", "err", err)
+		return nil, 2
+	}
+	token, err := common.GetTenantAccessToken(ctx, baseURL, appID, appSecret)
+	if err != nil {
+		errLogger.Error("get tenant access token failed", "err", err)
+		return nil, exitCodeFor(err, exitUsage)
+	}
+	if ref.AppToken == "" {
+		if ref.WikiToken == "" {
+			errLogger.Error("bitable URL missing app_token and wiki_token")
+			return nil, 2
+		}
+		appTok, err := common.ResolveWikiAppToken(ctx, baseURL, token, ref.WikiToken)
+		if err != nil {
+			errLogger.Error("resolve wiki app token failed", "err", err)
+			return nil, exitCodeFor(err, exitUsage)
+		}
+		ref.AppToken = appTok
+	}
+	return &tableClient{
+		baseURL: baseURL,
+		token:   token,
+		ref:     ref,
+		fields:  common.LoadTaskFieldsFromEnv(),
+	}, 0
+}
+
+// resolveRecordID returns recordID as-is, or looks it up by TaskID, then
+// BizTaskID.
+func (t *tableClient) resolveRecordID(ctx context.Context, recordID string, taskID int, bizTaskID string) (string, error) {
+	if recordID = strings.TrimSpace(recordID); recordID != "" {
+		return recordID, nil
+	}
+	if taskID > 0 {
+		m, _, err := resolveRecordIDsByTaskID(ctx, t.baseURL, t.token, t.ref, t.fields, []int{taskID}, true, "")
+		if err != nil {
+			return "", err
+		}
+		if rid := m[taskID]; rid != "" {
+			return rid, nil
+		}
+		return "", fmt.Errorf("task %d not found", taskID)
+	}
+	if bizTaskID = strings.TrimSpace(bizTaskID); bizTaskID != "" {
+		m, _, err := resolveRecordIDsByBizTaskID(ctx, t.baseURL, t.token, t.ref, t.fields, []string{bizTaskID}, true, "")
+		if err != nil {
+			return "", err
+		}
+		if rid := m[bizTaskID]; rid != "" {
+			return rid, nil
+		}
+		return "", fmt.Errorf("biz task %s not found", bizTaskID)
+	}
+	return "", errors.New("one of --record-id, --task-id or --biz-task-id is required")
+}
+
+func (t *tableClient) getRecordFields(ctx context.Context, recordID string) (map[string]any, error) {
+	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/%s",
+		strings.TrimRight(t.baseURL, "/"), t.ref.AppToken, t.ref.TableID, url.PathEscape(recordID),
+	)
+	var resp getRecordResp
+	if err := common.RequestJSON(ctx, "GET", urlStr, t.token, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("get record failed: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	if resp.Data.Record.Fields == nil {
+		return map[string]any{}, nil
+	}
+	return resp.Data.Record.Fields, nil
+}
+
+type batchGetResp struct {
+	common.FeishuResp
+	Data struct {
+		Records []struct {
+			RecordID string         `json:"record_id"`
+			Fields   map[string]any `json:"fields"`
+		} `json:"records"`
+	} `json:"data"`
+}
+
+// batchGetRecordFields reads the current fields of many records, 100 per
+// request.
+func batchGetRecordFields(ctx context.Context, baseURL, token string, ref common.BitableRef, recordIDs []string) (map[string]map[string]any, error) {
+	out := map[string]map[string]any{}
+	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/batch_get",
+		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID,
+	)
+	for _, batch := range chunkStrings(recordIDs, 100) {
+		var resp batchGetResp
+		if err := common.RequestJSON(ctx, "POST", urlStr, token, map[string]any{"record_ids": batch}, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("batch get records failed: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+		for _, rec := range resp.Data.Records {
+			out[rec.RecordID] = rec.Fields
+		}
+	}
+	return out, nil
+}
+
+// loadTask resolves and decodes a single task.
+func (t *tableClient) loadTask(ctx context.Context, recordID string, taskID int, bizTaskID string) (Task, error) {
+	rid, err := t.resolveRecordID(ctx, recordID, taskID, bizTaskID)
+	if err != nil {
+		return Task{}, err
+	}
+	fields, err := t.getRecordFields(ctx, rid)
+	if err != nil {
+		return Task{}, err
+	}
+	task, ok := decodeTask(fields, t.fields)
+	if !ok {
+		return Task{}, fmt.Errorf("record %s is not a valid task", rid)
+	}
+	task.RecordID = rid
+	return task, nil
+}
+
+func (t *tableClient) updateRecord(ctx context.Context, recordID string, fields map[string]any) error {
+	return updateRecord(ctx, t.baseURL, t.token, t.ref, recordID, fields)
+}
+
+// dateWriter coerces values written to the Date column according to the
+// column type (text, date-only or datetime) and TASK_TIMEZONE.
+type dateWriter struct {
+	kind string
+	loc  *time.Location
+}
+
+func (w dateWriter) payload(v any) (any, bool) {
+	return common.CoerceDatePayloadFor(v, w.kind, w.loc)
+}
+
+// loadDateWriter looks up the Date column type. If the schema cannot be read
+// it falls back to the legacy epoch-millis coercion.
+func loadDateWriter(ctx context.Context, baseURL, token string, ref common.BitableRef, dateCol string) dateWriter {
+	w := dateWriter{kind: common.DateKindUnknown, loc: common.TaskTimezone()}
+	if strings.TrimSpace(dateCol) == "" {
+		return w
+	}
+	fields, err := common.ListFields(ctx, baseURL, token, ref.AppToken, ref.TableID)
+	if err != nil {
+		errLogger.Warn("list fields failed; writing Date without schema awareness", "err", err)
+		return w
+	}
+	if f, ok := common.FieldsByName(fields)[dateCol]; ok {
+		w.kind = common.DateKindOf(f)
+	}
+	return w
+}
+
+// tableHasColumn reports whether the table has a column named col. A failed
+// schema lookup counts as absent so optional columns are simply skipped.
+func tableHasColumn(ctx context.Context, baseURL, token string, ref common.BitableRef, col string) bool {
+	fields, err := common.ListFields(ctx, baseURL, token, ref.AppToken, ref.TableID)
+	if err != nil {
+		errLogger.Warn("list fields failed", "err", err)
+		return false
+	}
+	_, ok := common.FieldsByName(fields)[col]
+	return ok
+}
+
+func itemsHaveValue(items []map[string]any, key string) bool {
+	for _, it := range items {
+		if strings.TrimSpace(common.BitableValueToString(it[key])) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const defaultEnvPrefix = "TASK_"
+
+// taskEnv builds the environment contract for executor commands. Every
+// variable starts with prefix (default TASK_):
+//
+//	ID, BIZ_ID, RECORD_ID, APP, SCENE, PARAMS, URL, DEVICE_SERIAL,
+//	ATTEMPT_TOKEN, TRACE_ID, RESULT_FILE, EXTRA_<KEY> (one per top-level
+//	Extra key)
+//
+// Empty values are omitted so executors can test for presence.
+func taskEnv(t Task, prefix, resultFile string) []string {
+	if prefix == "" {
+		prefix = defaultEnvPrefix
+	}
+	device := t.DispatchedDevice
+	if device == "" {
+		device = t.DeviceSerial
+	}
+	vars := [][2]string{
+		{"ID", strconv.Itoa(t.TaskID)},
+		{"BIZ_ID", t.BizTaskID},
+		{"RECORD_ID", t.RecordID},
+		{"APP", t.App},
+		{"SCENE", t.Scene},
+		{"PARAMS", t.Params},
+		{"URL", t.URL},
+		{"DEVICE_SERIAL", device},
+		{"ATTEMPT_TOKEN", t.AttemptToken},
+		{"TRACE_ID", t.TraceID},
+		{"RESULT_FILE", resultFile},
+	}
+	env := []string{}
+	for _, kv := range vars {
+		if kv[1] != "" {
+			env = append(env, prefix+kv[0]+"="+envSafe(kv[1]))
+		}
+	}
+	return append(env, extraEnv(t.Extra, prefix+"EXTRA_")...)
+}
+
+// extraEnv flattens the top level of the Extra JSON object. Keys are
+// upper-cased with non-alphanumerics replaced by "_"; string values are
+// passed as-is and everything else as JSON.
+func extraEnv(extra, prefix string) []string {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(extra)), &obj); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := []string{}
+	for _, k := range keys {
+		name := envName(k)
+		if name == "" {
+			continue
+		}
+		var val string
+		switch v := obj[k].(type) {
+		case nil:
+			continue
+		case string:
+			val = v
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			val = string(b)
+		}
+		out = append(out, prefix+name+"="+envSafe(val))
+	}
+	return out
+}
+
+func envName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(key)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// envSafe drops NUL bytes, which cannot be carried in an environment
+// variable.
+func envSafe(v string) string {
+	return strings.ReplaceAll(v, "\x00", "")
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
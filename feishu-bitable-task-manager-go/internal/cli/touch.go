@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TouchOptions struct {
+	TaskURL string
+	App     string
+	Scene   string
+	Status  string
+	Date    string
+	// Filters are --filter specs on any column, see parseFieldFilter.
+	Filters []string
+	Where   string
+
+	IncludeDeleted bool
+	DryRun         bool
+}
+
+type touchReport struct {
+	Matched        int           `json:"matched"`
+	Touched        int           `json:"touched"`
+	Locked         int           `json:"locked"`
+	DryRun         bool          `json:"dry_run,omitempty"`
+	TouchedAt      int64         `json:"touched_at"`
+	Chunks         []deleteChunk `json:"chunks"`
+	Failed         int           `json:"failed"`
+	Errors         []string      `json:"errors"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+}
+
+// TouchTasks writes the current time into TouchedAt on every matching
+// record. Nothing else changes, but each record's last-modified time moves,
+// so consumers syncing by modified time pick the records up again. Records
+// with an active edit lock are counted in Locked and left alone. At least
+// one condition is required so a bare "touch" cannot rewrite the table.
+func TouchTasks(ctx context.Context, opts TouchOptions) int {
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	col := strings.TrimSpace(tc.fields["TouchedAt"])
+	if col == "" || !tableHasColumn(ctx, tc.baseURL, tc.token, tc.ref, col) {
+		errLogger.Error("touch needs a TouchedAt column (run init-table or set TASK_FIELD_TOUCHED_AT)", "column", col)
+		return 2
+	}
+	conds := []filterCond{}
+	for _, spec := range opts.Filters {
+		cond, err := parseFieldFilter(spec, tc.fields)
+		if err != nil {
+			errLogger.Error("invalid --filter", "err", err)
+			return 2
+		}
+		conds = append(conds, cond)
+	}
+	filterObj := buildFilter(tc.fields, opts.App, opts.Scene, opts.Status, strings.TrimSpace(opts.Date), conds...)
+	filterObj, err := compileWhere(opts.Where, tc.fields, filterObj)
+	if err != nil {
+		errLogger.Error("invalid --where", "err", err)
+		return 2
+	}
+	if filterObj == nil {
+		errLogger.Error("touch needs a condition: --app, --scene, --status, --date, --filter or --where")
+		return 2
+	}
+
+	start := time.Now()
+	fieldNames := []string{col}
+	if deletedCol := strings.TrimSpace(tc.fields["Deleted"]); deletedCol != "" && !opts.IncludeDeleted && tableHasColumn(ctx, tc.baseURL, tc.token, tc.ref, deletedCol) {
+		fieldNames = append(fieldNames, deletedCol)
+	}
+	if lockCol := strings.TrimSpace(tc.fields["EditLock"]); lockCol != "" && tableHasColumn(ctx, tc.baseURL, tc.token, tc.ref, lockCol) {
+		fieldNames = append(fieldNames, lockCol)
+	}
+	body := map[string]any{"filter": filterObj, "field_names": fieldNames}
+	ids := []string{}
+	locked := 0
+	err = scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(item map[string]any) {
+		fieldsRaw, _ := item["fields"].(map[string]any)
+		if !opts.IncludeDeleted && isSoftDeleted(fieldsRaw, tc.fields) {
+			return
+		}
+		rid, _ := item["record_id"].(string)
+		if rid == "" {
+			return
+		}
+		if l, ok := activeEditLock(fieldsRaw, tc.fields, time.Now()); ok {
+			logSkippedLocked(rid, l, "touch")
+			locked++
+			return
+		}
+		ids = append(ids, rid)
+	})
+	if err != nil {
+		errLogger.Error("scan tasks failed", "err", err)
+		return 2
+	}
+
+	now := time.Now().UnixMilli()
+	report := touchReport{Matched: len(ids) + locked, Locked: locked, DryRun: opts.DryRun, TouchedAt: now, Chunks: []deleteChunk{}, Errors: []string{}}
+	if !opts.DryRun {
+		for i, batch := range chunkStrings(ids, updateMaxBatchSize) {
+			records := make([]map[string]any, 0, len(batch))
+			for _, rid := range batch {
+				records = append(records, map[string]any{"record_id": rid, "fields": map[string]any{col: now}})
+			}
+			chunk := deleteChunk{Chunk: i + 1, Records: len(batch), First: batch[0], Last: batch[len(batch)-1]}
+			if err := batchUpdateRecords(ctx, tc.baseURL, tc.token, tc.ref, records); err != nil {
+				chunk.Error = err.Error()
+				report.Errors = append(report.Errors, fmt.Sprintf("chunk %d (%s..%s): %v", chunk.Chunk, chunk.First, chunk.Last, err))
+			} else {
+				report.Touched += len(batch)
+			}
+			report.Chunks = append(report.Chunks, chunk)
+		}
+	}
+	report.Failed = len(report.Errors)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+	printJSON(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"context"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// traceBatch starts a span for one batch write of op ("create", "update")
+// and a child span per record in it, tagged with that record's attrs. end
+// closes them all with the call's error: a failed batch fails every record
+// in it. ctx carries the batch span for the API call.
+func traceBatch(ctx context.Context, op string, records [][]any) (_ context.Context, end func(error)) {
+	if !common.TracingEnabled() {
+		return ctx, func(error) {}
+	}
+	ctx, batch := common.StartSpan(ctx, op+" batch", "bitable.records", len(records))
+	spans := make([]*common.Span, 0, len(records))
+	for _, attrs := range records {
+		_, s := common.StartSpan(ctx, op+" record", attrs...)
+		spans = append(spans, s)
+	}
+	return ctx, func(err error) {
+		for _, s := range spans {
+			s.End(err)
+		}
+		batch.End(err)
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// maxTransformLine bounds one JSONL line read back from a transformer.
+const maxTransformLine = 16 << 20
+
+// transformItems pipes items as JSONL through command (run by the platform
+// shell) and returns the JSONL it prints. The transformer may rewrite, drop
+// or add rows; BITABLE_TRANSFORM_STAGE tells it which path it runs in
+// (fetch, create or update). Its stderr passes through. A non-zero exit or
+// a line that is not a JSON object fails the whole command, so a broken
+// transformer never writes partial data.
+func transformItems(ctx context.Context, command, stage string, items []map[string]any) ([]map[string]any, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return items, nil
+	}
+	argv, err := shellCommand("auto", []string{command})
+	if err != nil {
+		return nil, err
+	}
+	var in bytes.Buffer
+	enc := json.NewEncoder(&in)
+	enc.SetEscapeHTML(false)
+	for _, it := range items {
+		if err := enc.Encode(it); err != nil {
+			return nil, err
+		}
+	}
+	var out bytes.Buffer
+	code, err := runTaskCommand(ctx, argv, []string{"BITABLE_TRANSFORM_STAGE=" + stage}, &in, &out, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("run transformer: %w", err)
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("transformer exited with code %d", code)
+	}
+
+	result := []map[string]any{}
+	sc := bufio.NewScanner(&out)
+	sc.Buffer(make([]byte, 0, 64*1024), maxTransformLine)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var it map[string]any
+		if err := common.DecodeJSON([]byte(text), &it); err != nil || it == nil {
+			return nil, fmt.Errorf("transformer output line %d is not a JSON object", line)
+		}
+		result = append(result, it)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read transformer output: %w", err)
+	}
+	return result, nil
+}
+
+// transformTasks runs fetched tasks through the transformer. Rows come back
+// as tasks; keys that are not task fields are dropped.
+func transformTasks(ctx context.Context, command string, tasks []Task) ([]Task, error) {
+	if strings.TrimSpace(command) == "" {
+		return tasks, nil
+	}
+	items := make([]map[string]any, 0, len(tasks))
+	for _, t := range tasks {
+		raw, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		var it map[string]any
+		if err := common.DecodeJSON(raw, &it); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	items, err := transformItems(ctx, command, "fetch", items)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Task, 0, len(items))
+	for i, it := range items {
+		raw, err := json.Marshal(it)
+		if err != nil {
+			return nil, err
+		}
+		var t Task
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, fmt.Errorf("transformer output row %d is not a task: %w", i+1, err)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
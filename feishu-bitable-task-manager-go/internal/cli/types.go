@@ -21,11 +21,15 @@ type Task struct {
 	DeviceSerial     string `json:"device_serial"`
 	DispatchedDevice string `json:"dispatched_device"`
 	DispatchedAt     string `json:"dispatched_at"`
+	HeartbeatAt      string `json:"heartbeat_at"`
 	StartAt          string `json:"start_at"`
 	EndAt            string `json:"end_at"`
 	ElapsedSeconds   string `json:"elapsed_seconds"`
 	ItemsCollected   string `json:"items_collected"`
 	RetryCount       string `json:"retry_count"`
+	Artifacts        string `json:"artifacts"`
+	AttemptToken     string `json:"attempt_token,omitempty"`
+	TraceID          string `json:"trace_id,omitempty"`
 	RecordID         string `json:"record_id"`
 	RawFields        any    `json:"raw_fields,omitempty"`
 }
@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -33,18 +35,49 @@ type UpdateOptions struct {
 	ElapsedSeconds string
 	ItemsCollected string
 	Logs           string
+	// LogsFile is uploaded and its file_token written to Logs.
+	LogsFile       string
 	RetryCount     string
+	LastScreenshot string
 	Extra          string
+	AttemptToken   string
 	SkipStatus     string
+	// ExpectStatus aborts a record's update when its status, re-read just
+	// before writing, is not one of these (comma-separated).
+	ExpectStatus string
 
 	IgnoreView bool
 	ViewID     string
+
+	RunsURL string
+	// Transform pipes the updates through an external command first.
+	Transform string
+	// CreateSelectOptions adds unknown select values as new options, see
+	// selectCodec.
+	CreateSelectOptions bool
+	// JSONResult prints the per-row outcome (rowResults) on stdout instead
+	// of the report.
+	JSONResult bool
+	// QueueDir keeps updates that cannot reach Feishu for FlushQueue.
+	QueueDir string
+
+	// replay is the queue entry FlushQueue is sending again.
+	replay *queueEntry
+}
+
+type recordUpdate struct {
+	RecordID string
+	Fields   map[string]any
 }
 
 type updateReport struct {
 	Updated        int      `json:"updated"`
 	Requested      int      `json:"requested"`
 	Skipped        int      `json:"skipped"`
+	Rejected       int      `json:"rejected,omitempty"`
+	Conflicts      int      `json:"conflicts,omitempty"`
+	Queued         int      `json:"queued,omitempty"`
+	RunsCreated    int      `json:"runs_created,omitempty"`
 	Failed         int      `json:"failed"`
 	Errors         []string `json:"errors"`
 	ElapsedSeconds float64  `json:"elapsed_seconds"`
@@ -66,7 +99,7 @@ type getRecordResp struct {
 	} `json:"data"`
 }
 
-func UpdateTasks(opts UpdateOptions) int {
+func UpdateTasks(ctx context.Context, opts UpdateOptions) int {
 	taskURL := strings.TrimSpace(opts.TaskURL)
 	if taskURL == "" {
 		errLogger.Error("TASK_BITABLE_URL is required")
@@ -82,35 +115,68 @@ func UpdateTasks(opts UpdateOptions) int {
 	baseURL := common.Env("FEISHU_BASE_URL", common.DefaultBaseURL)
 	fieldsMap := common.LoadTaskFieldsFromEnv()
 
-	updates, err := loadUpdates(opts, fieldsMap)
-	if err != nil {
-		errLogger.Error("load updates failed", "err", err)
-		return 2
+	var updates []map[string]any
+	if opts.replay != nil {
+		updates = opts.replay.Updates
+	} else {
+		var err error
+		if updates, err = loadUpdates(opts, fieldsMap); err != nil {
+			errLogger.Error("load updates failed", "err", err)
+			return 2
+		}
+		if updates, err = transformItems(ctx, opts.Transform, "update", updates); err != nil {
+			errLogger.Error("transform updates failed", "err", err)
+			return 2
+		}
 	}
 	if len(updates) == 0 {
 		errLogger.Error("no updates provided")
 		return 2
 	}
+	results := newRowResults(opts.JSONResult)
+	printReport := func(report updateReport) {
+		switch {
+		case opts.replay != nil:
+			errLogger.Info("replay report", "entry", filepath.Base(opts.replay.path), "report", report)
+		case results != nil:
+			results.print(report)
+		default:
+			printJSON(report)
+		}
+	}
+	queue := newMutationQueue(opts)
+	// unreachable handles a failure before anything was written: when
+	// Feishu is out of reach every update is queued and the run succeeds.
+	unreachable := func(err error) int {
+		if !queue.offer(err, updates) {
+			return exitCodeFor(err, exitUsage)
+		}
+		for _, upd := range updates {
+			results.queue(upd, err.Error())
+		}
+		printReport(updateReport{Requested: len(updates), Queued: len(updates), Errors: []string{}})
+		return exitOK
+	}
 
 	ref, err := common.ParseBitableURL(taskURL)
 	if err != nil {
 		errLogger.Error("parse bitable URL failed", "err", err)
 		return 2
 	}
-	token, err := common.GetTenantAccessToken(baseURL, appID, appSecret)
+	token, err := common.GetTenantAccessToken(ctx, baseURL, appID, appSecret)
 	if err != nil {
 		errLogger.Error("get tenant access token failed", "err", err)
-		return 2
+		return unreachable(err)
 	}
 	if ref.AppToken == "" {
 		if ref.WikiToken == "" {
 			errLogger.Error("bitable URL missing app_token and wiki_token")
 			return 2
 		}
-		appTok, err := common.ResolveWikiAppToken(baseURL, token, ref.WikiToken)
+		appTok, err := common.ResolveWikiAppToken(ctx, baseURL, token, ref.WikiToken)
 		if err != nil {
 			errLogger.Error("resolve wiki app token failed", "err", err)
-			return 2
+			return unreachable(err)
 		}
 		ref.AppToken = appTok
 	}
@@ -139,10 +205,10 @@ func UpdateTasks(opts UpdateOptions) int {
 	statusByRecord := map[string]string{}
 
 	if len(taskIDsToResolve) > 0 {
-		m, st, err := resolveRecordIDsByTaskID(baseURL, token, ref, fieldsMap, taskIDsToResolve, opts.IgnoreView, viewID)
+		m, st, err := resolveRecordIDsByTaskID(ctx, baseURL, token, ref, fieldsMap, taskIDsToResolve, opts.IgnoreView, viewID)
 		if err != nil {
 			errLogger.Error("resolve record IDs by task id failed", "err", err)
-			return 2
+			return unreachable(err)
 		}
 		resolvedTask = m
 		for k, v := range st {
@@ -150,10 +216,10 @@ func UpdateTasks(opts UpdateOptions) int {
 		}
 	}
 	if len(bizIDsToResolve) > 0 {
-		m, st, err := resolveRecordIDsByBizTaskID(baseURL, token, ref, fieldsMap, bizIDsToResolve, opts.IgnoreView, viewID)
+		m, st, err := resolveRecordIDsByBizTaskID(ctx, baseURL, token, ref, fieldsMap, bizIDsToResolve, opts.IgnoreView, viewID)
 		if err != nil {
 			errLogger.Error("resolve record IDs by biz task id failed", "err", err)
-			return 2
+			return unreachable(err)
 		}
 		resolvedBiz = m
 		for k, v := range st {
@@ -161,42 +227,109 @@ func UpdateTasks(opts UpdateOptions) int {
 		}
 	}
 
+	enc := newFieldEncoder(baseURL, token, ref, fieldsMap, opts.CreateSelectOptions)
 	skipStatuses := parseCSVSet(opts.SkipStatus)
-	if len(skipStatuses) > 0 {
-		recordIDsNeeded := []string{}
-		for _, upd := range updates {
-			recordID := resolveUpdateRecordID(upd, resolvedTask, resolvedBiz)
-			if recordID != "" {
-				if _, ok := statusByRecord[recordID]; !ok {
-					recordIDsNeeded = append(recordIDsNeeded, recordID)
-				}
+	// The token and lock columns are optional; one the table lacks is
+	// neither read nor checked.
+	tokenCol := strings.TrimSpace(fieldsMap["AttemptToken"])
+	if tokenCol != "" && !enc.schema.has(ctx, tokenCol) {
+		tokenCol = ""
+	}
+	lockCol := strings.TrimSpace(fieldsMap["EditLock"])
+	if lockCol != "" && !enc.schema.has(ctx, lockCol) {
+		lockCol = ""
+	}
+	recordIDs := []string{}
+	seen := map[string]bool{}
+	needStatus := false
+	for _, upd := range updates {
+		if recordID := resolveUpdateRecordID(upd, resolvedTask, resolvedBiz); recordID != "" && !seen[recordID] {
+			seen[recordID] = true
+			recordIDs = append(recordIDs, recordID)
+			if _, ok := statusByRecord[recordID]; !ok && len(skipStatuses) > 0 {
+				needStatus = true
 			}
 		}
-		if len(recordIDsNeeded) > 0 {
-			fetched, err := fetchRecordStatuses(baseURL, token, ref, recordIDsNeeded, fieldsMap["Status"])
-			if err != nil {
-				errLogger.Error("fetch record statuses failed", "err", err)
-				return 2
+	}
+	// One batch_get serves --skip-status, the attempt tokens and the edit
+	// locks, and is skipped when none of them applies. A record whose
+	// token cell is empty reads back as "no token" and is not checked.
+	tokenByRecord := map[string]string{}
+	locks := map[string]editLock{}
+	if len(recordIDs) > 0 && (needStatus || tokenCol != "" || lockCol != "") {
+		current, err := batchGetRecordFields(ctx, baseURL, token, ref, recordIDs)
+		if err != nil {
+			errLogger.Error("read records failed", "err", err)
+			return unreachable(err)
+		}
+		now := time.Now()
+		for recordID, fields := range current {
+			if status := strings.TrimSpace(common.BitableValueToString(fields[fieldsMap["Status"]])); status != "" {
+				statusByRecord[recordID] = status
+			}
+			if tokenCol != "" {
+				if v := strings.TrimSpace(common.BitableValueToString(fields[tokenCol])); v != "" {
+					tokenByRecord[recordID] = v
+				}
 			}
-			for k, v := range fetched {
-				statusByRecord[k] = v
+			if l, ok := activeEditLock(fields, fieldsMap, now); ok {
+				locks[recordID] = l
 			}
 		}
 	}
 
-	type recordUpdate struct {
-		RecordID string
-		Fields   map[string]any
+	dates := dateWriter{kind: common.DateKindUnknown, loc: common.TaskTimezone()}
+	if itemsHaveValue(updates, "date") {
+		dates = enc.schema.dateWriter(ctx, fieldsMap["Date"])
 	}
 
 	records := []recordUpdate{}
+	pending := map[string]int{}
 	errorsList := []string{}
 	skipped := 0
+	rejected := 0
+	// failed counts input rows, so the report adds up to requested.
+	failed := 0
+	var outcome writeOutcome
+	// inputsByRecord lists the input rows merged into each record's write,
+	// so a failed write is reported against every one of them.
+	inputsByRecord := map[string][]map[string]any{}
+	failRecord := func(recordID, msg string) {
+		for _, in := range inputsByRecord[recordID] {
+			failed++
+			results.fail(in, msg)
+		}
+	}
+	queued := 0
+	// queueRecords queues the input rows of records when err left Feishu
+	// out of reach, and reports whether it did.
+	queueRecords := func(err error, records []recordUpdate) bool {
+		rows := []map[string]any{}
+		for _, r := range records {
+			rows = append(rows, inputsByRecord[r.RecordID]...)
+		}
+		if !queue.offer(err, rows) {
+			return false
+		}
+		queued += len(rows)
+		for _, in := range rows {
+			results.queue(in, err.Error())
+		}
+		return true
+	}
+	var logsFiles *logsFileUploader
 
 	for _, upd := range updates {
 		recordID := resolveUpdateRecordID(upd, resolvedTask, resolvedBiz)
 		if recordID == "" {
+			if common.BitableValueToString(upd["task_id"]) != "" || common.BitableValueToString(upd["biz_task_id"]) != "" {
+				outcome.notFound++
+			} else {
+				outcome.invalid++
+			}
+			failed++
 			errorsList = append(errorsList, "missing record_id for update")
+			results.fail(upd, "missing record_id for update")
 			continue
 		}
 
@@ -204,63 +337,191 @@ func UpdateTasks(opts UpdateOptions) int {
 			cur := strings.ToLower(strings.TrimSpace(statusByRecord[recordID]))
 			if cur != "" && skipStatuses[cur] {
 				skipped++
+				results.skip(upd, recordID, "status is "+cur)
 				continue
 			}
 		}
 
-		fields := buildUpdateFields(fieldsMap, upd)
-		if len(fields) == 0 {
+		if l, ok := locks[recordID]; ok {
+			logSkippedLocked(recordID, l, "update")
+			skipped++
+			results.skip(upd, recordID, fmt.Sprintf("locked for manual edits by %s", l))
+			continue
+		}
+
+		fields := buildUpdateFields(fieldsMap, upd, dates)
+		logsFile := strings.TrimSpace(common.BitableValueToString(upd["logs_file"]))
+		if len(fields) == 0 && logsFile == "" {
+			outcome.invalid++
+			failed++
 			errorsList = append(errorsList, fmt.Sprintf("record %s: no fields to update", recordID))
+			results.fail(upd, "no fields to update")
 			continue
 		}
+		if tokenCol != "" {
+			if err := checkAttemptToken(upd, fields, fieldsMap, tokenByRecord[recordID]); err != nil {
+				rejected++
+				failed++
+				errorsList = append(errorsList, fmt.Sprintf("record %s: %v", recordID, err))
+				results.fail(upd, err.Error())
+				continue
+			}
+			if isAttemptEnd(fieldsMap, fields) && tokenByRecord[recordID] != "" {
+				// Retire the token so a duplicate of this report is rejected.
+				fields[tokenCol] = ""
+			}
+		}
+		if logsFile != "" {
+			if logsFiles == nil {
+				logsFiles = newLogsFileUploader(ctx, baseURL, token, ref, fieldsMap)
+			}
+			if err := logsFiles.apply(ctx, fields, logsFile); err != nil {
+				outcome.failed = append(outcome.failed, err)
+				failed++
+				errorsList = append(errorsList, fmt.Sprintf("record %s: %v", recordID, err))
+				results.fail(upd, err.Error())
+				continue
+			}
+		}
+		inputsByRecord[recordID] = append(inputsByRecord[recordID], upd)
+		if k, ok := pending[recordID]; ok {
+			// batch_update rejects a chunk naming a record twice; later
+			// rows for the same record win field by field.
+			for col, v := range fields {
+				records[k].Fields[col] = v
+			}
+			continue
+		}
+		pending[recordID] = len(records)
 		records = append(records, recordUpdate{RecordID: recordID, Fields: fields})
 	}
+	encoded := records[:0]
+	for _, r := range records {
+		if err := enc.encode(ctx, r.Fields); err != nil {
+			outcome.invalid++
+			errorsList = append(errorsList, fmt.Sprintf("record %s: %v", r.RecordID, err))
+			failRecord(r.RecordID, err.Error())
+			continue
+		}
+		encoded = append(encoded, r)
+	}
+	records = encoded
+
+	var runs *runsTable
+	if runsURL := strings.TrimSpace(opts.RunsURL); runsURL != "" {
+		runs, err = openRunsTable(ctx, baseURL, token, runsURL)
+		if err != nil {
+			errLogger.Error("open runs table failed", "err", err)
+			return unreachable(err)
+		}
+	}
+
+	conflicts := 0
+	if expected := parseCSVSet(opts.ExpectStatus); len(expected) > 0 && len(records) > 0 {
+		// Read as late as possible so the window for a concurrent worker
+		// to change the status is one round trip, not the whole run.
+		ids := make([]string, 0, len(records))
+		for _, r := range records {
+			ids = append(ids, r.RecordID)
+		}
+		current, err := fetchRecordStatuses(ctx, baseURL, token, ref, ids, fieldsMap["Status"])
+		if err != nil {
+			errLogger.Error("re-read record statuses failed", "err", err)
+			return unreachable(err)
+		}
+		kept := records[:0]
+		for _, r := range records {
+			cur := strings.ToLower(current[r.RecordID])
+			if !expected[cur] {
+				conflicts += len(inputsByRecord[r.RecordID])
+				errorsList = append(errorsList, fmt.Sprintf("record %s: conflict: status is %q, expected %s", r.RecordID, cur, opts.ExpectStatus))
+				failRecord(r.RecordID, fmt.Sprintf("conflict: status is %q, expected %s", cur, opts.ExpectStatus))
+				continue
+			}
+			kept = append(kept, r)
+		}
+		records = kept
+	}
 
 	start := time.Now()
-	updated := 0
-	if len(records) > 0 {
-		if len(records) == 1 {
-			if err := updateRecord(baseURL, token, ref, records[0].RecordID, records[0].Fields); err != nil {
-				errorsList = append(errorsList, err.Error())
-			} else {
-				updated = 1
+	written := []recordUpdate{}
+	if len(records) == 1 {
+		tctx, endTrace := traceBatch(ctx, "update", [][]any{{"bitable.record_id", records[0].RecordID}})
+		err := updateRecord(tctx, baseURL, token, ref, records[0].RecordID, records[0].Fields)
+		endTrace(err)
+		switch {
+		case queueRecords(err, records):
+		case err != nil:
+			outcome.failed = append(outcome.failed, err)
+			errorsList = append(errorsList, err.Error())
+			failRecord(records[0].RecordID, err.Error())
+		default:
+			written = records
+		}
+	} else {
+		// One batch_update call per updateMaxBatchSize records. A failed
+		// chunk is reported and the remaining chunks are still sent.
+		for i := 0; i < len(records); i += updateMaxBatchSize {
+			j := minInt(i+updateMaxBatchSize, len(records))
+			batch := make([]map[string]any, 0, j-i)
+			traced := make([][]any, 0, j-i)
+			for _, r := range records[i:j] {
+				batch = append(batch, map[string]any{
+					"record_id": r.RecordID,
+					"fields":    r.Fields,
+				})
+				traced = append(traced, []any{"bitable.record_id", r.RecordID})
 			}
-		} else {
-			for i := 0; i < len(records); i += updateMaxBatchSize {
-				j := i + updateMaxBatchSize
-				if j > len(records) {
-					j = len(records)
-				}
-				batch := make([]map[string]any, 0, j-i)
+			tctx, endTrace := traceBatch(ctx, "update", traced)
+			err := batchUpdateRecords(tctx, baseURL, token, ref, batch)
+			endTrace(err)
+			if queueRecords(err, records[i:j]) {
+				continue
+			}
+			if err != nil {
+				outcome.failed = append(outcome.failed, err)
+				errorsList = append(errorsList, fmt.Sprintf("records %d-%d (%s..%s): %v", i+1, j, records[i].RecordID, records[j-1].RecordID, err))
 				for _, r := range records[i:j] {
-					batch = append(batch, map[string]any{
-						"record_id": r.RecordID,
-						"fields":    r.Fields,
-					})
-				}
-				if err := batchUpdateRecords(baseURL, token, ref, batch); err != nil {
-					errorsList = append(errorsList, err.Error())
-					break
+					failRecord(r.RecordID, err.Error())
 				}
-				updated += (j - i)
+				continue
 			}
+			written = append(written, records[i:j]...)
+		}
+	}
+	updated := 0
+	for _, r := range written {
+		updated += len(inputsByRecord[r.RecordID])
+		results.updated(r.RecordID)
+	}
+
+	runsCreated := 0
+	if runs != nil && updated > 0 {
+		n, err := runs.writeRuns(ctx, baseURL, token, ref, fieldsMap, written)
+		runsCreated = n
+		if err != nil {
+			outcome.failed = append(outcome.failed, err)
+			errorsList = append(errorsList, err.Error())
 		}
 	}
 
 	elapsed := time.Since(start).Seconds()
 	report := updateReport{
 		Updated:        updated,
-		Requested:      len(records),
+		Requested:      len(updates),
 		Skipped:        skipped,
-		Failed:         len(errorsList),
+		Rejected:       rejected,
+		Conflicts:      conflicts,
+		Queued:         queued,
+		RunsCreated:    runsCreated,
+		Failed:         failed,
 		Errors:         errorsList,
 		ElapsedSeconds: float64(int(elapsed*1000)) / 1000,
 	}
-	printJSON(report)
-	if len(errorsList) > 0 {
-		return 1
-	}
-	return 0
+	printReport(report)
+	outcome.written = updated
+	outcome.conflicts = rejected + conflicts
+	return outcome.exitCode()
 }
 
 func resolveUpdateRecordID(upd map[string]any, resolvedTask map[int]string, resolvedBiz map[string]string) string {
@@ -285,12 +546,7 @@ func loadUpdates(opts UpdateOptions, fieldsMap map[string]string) ([]map[string]
 		if err != nil {
 			return nil, err
 		}
-		mode := detectInputFormat(opts.InputPath, raw)
-		if mode == "jsonl" {
-			items, err = parseJSONLItems(raw)
-		} else {
-			items, err = parseJSONItems(raw)
-		}
+		items, err = parseInputItems(opts.InputPath, raw)
 		if err != nil {
 			return nil, err
 		}
@@ -309,9 +565,12 @@ func loadUpdates(opts UpdateOptions, fieldsMap map[string]string) ([]map[string]
 				"elapsed_seconds": opts.ElapsedSeconds,
 				"items_collected": opts.ItemsCollected,
 				"logs":            opts.Logs,
+				"logs_file":       opts.LogsFile,
 				"retry_count":     opts.RetryCount,
+				"last_screenshot": opts.LastScreenshot,
 				"extra":           opts.Extra,
 				"date":            opts.Date,
+				"attempt_token":   opts.AttemptToken,
 			},
 		}
 	}
@@ -337,8 +596,11 @@ func loadUpdates(opts UpdateOptions, fieldsMap map[string]string) ([]map[string]
 		"elapsed_seconds": true,
 		"items_collected": true,
 		"logs":            true,
+		"logs_file":       true,
 		"retry_count":     true,
+		"last_screenshot": true,
 		"extra":           true,
+		"attempt_token":   true,
 		"fields":          true,
 		"CDNURL":          true,
 		"cdn_url":         true,
@@ -412,9 +674,12 @@ func loadUpdates(opts UpdateOptions, fieldsMap map[string]string) ([]map[string]
 			"elapsed_seconds": pick(item, "elapsed_seconds", opts.ElapsedSeconds),
 			"items_collected": pick(item, "items_collected", opts.ItemsCollected),
 			"logs":            pick(item, "logs", opts.Logs),
+			"logs_file":       pick(item, "logs_file", opts.LogsFile),
 			"retry_count":     pick(item, "retry_count", opts.RetryCount),
+			"last_screenshot": pick(item, "last_screenshot", opts.LastScreenshot),
 			"extra":           extra,
 			"force_extra":     forceExtra,
+			"attempt_token":   pick(item, "attempt_token", opts.AttemptToken),
 			"fields":          extraFields,
 		}
 		out = append(out, merged)
@@ -422,7 +687,7 @@ func loadUpdates(opts UpdateOptions, fieldsMap map[string]string) ([]map[string]
 	return out, nil
 }
 
-func resolveRecordIDsByTaskID(baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, taskIDs []int, ignoreView bool, viewID string) (map[int]string, map[string]string, error) {
+func resolveRecordIDsByTaskID(ctx context.Context, baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, taskIDs []int, ignoreView bool, viewID string) (map[int]string, map[string]string, error) {
 	result := map[int]string{}
 	statuses := map[string]string{}
 	values := []string{}
@@ -442,7 +707,7 @@ func resolveRecordIDsByTaskID(baseURL, token string, ref common.BitableRef, fiel
 		if filterObj == nil {
 			continue
 		}
-		items, err := searchItems(baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)), ignoreView, viewID)
+		items, err := searchItems(ctx, baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)), ignoreView, viewID)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -463,7 +728,7 @@ func resolveRecordIDsByTaskID(baseURL, token string, ref common.BitableRef, fiel
 	return result, statuses, nil
 }
 
-func resolveRecordIDsByBizTaskID(baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, bizIDs []string, ignoreView bool, viewID string) (map[string]string, map[string]string, error) {
+func resolveRecordIDsByBizTaskID(ctx context.Context, baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, bizIDs []string, ignoreView bool, viewID string) (map[string]string, map[string]string, error) {
 	result := map[string]string{}
 	statuses := map[string]string{}
 	values := []string{}
@@ -483,7 +748,7 @@ func resolveRecordIDsByBizTaskID(baseURL, token string, ref common.BitableRef, f
 		if filterObj == nil {
 			continue
 		}
-		items, err := searchItems(baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)), ignoreView, viewID)
+		items, err := searchItems(ctx, baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)), ignoreView, viewID)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -517,34 +782,48 @@ func extractStatusesFromItems(items []map[string]any, statusField string) map[st
 	return out
 }
 
-func fetchRecordStatuses(baseURL, token string, ref common.BitableRef, recordIDs []string, statusField string) (map[string]string, error) {
+// fetchRecordStatuses reads the Status of each record with batch_get, 100
+// records per call.
+func fetchRecordStatuses(ctx context.Context, baseURL, token string, ref common.BitableRef, recordIDs []string, statusField string) (map[string]string, error) {
 	out := map[string]string{}
+	ids := []string{}
+	seen := map[string]bool{}
 	for _, recordID := range recordIDs {
-		recordID = strings.TrimSpace(recordID)
-		if recordID == "" {
-			continue
-		}
-		if _, ok := out[recordID]; ok {
-			continue
+		if recordID = strings.TrimSpace(recordID); recordID != "" && !seen[recordID] {
+			seen[recordID] = true
+			ids = append(ids, recordID)
 		}
-		urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/%s",
-			strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID, url.PathEscape(recordID),
-		)
-		var resp getRecordResp
-		if err := common.RequestJSON("GET", urlStr, token, nil, &resp); err != nil {
-			return nil, err
-		}
-		if resp.Code != 0 {
-			return nil, fmt.Errorf("get record failed: code=%d msg=%s", resp.Code, resp.Msg)
-		}
-		status := strings.TrimSpace(common.BitableValueToString(resp.Data.Record.Fields[statusField]))
-		if status != "" {
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	current, err := batchGetRecordFields(ctx, baseURL, token, ref, ids)
+	if err != nil {
+		return nil, err
+	}
+	for recordID, fields := range current {
+		if status := strings.TrimSpace(common.BitableValueToString(fields[statusField])); status != "" {
 			out[recordID] = status
 		}
 	}
 	return out, nil
 }
 
+// checkAttemptToken rejects reports that belong to a different attempt than
+// the one currently assigned. A report carrying a token must match the stored
+// one; a report completing an attempt must carry the token when one is
+// stored. Records claimed before tokens existed are not checked.
+func checkAttemptToken(upd map[string]any, fields map[string]any, fieldsMap map[string]string, stored string) error {
+	given := strings.TrimSpace(common.BitableValueToString(upd["attempt_token"]))
+	if given != "" && given != stored {
+		return fmt.Errorf("stale attempt token %s", given)
+	}
+	if given == "" && stored != "" && isAttemptEnd(fieldsMap, fields) {
+		return fmt.Errorf("attempt token required to complete this attempt")
+	}
+	return nil
+}
+
 func buildIDFilter(fieldName string, values []string) map[string]any {
 	fieldName = strings.TrimSpace(fieldName)
 	if fieldName == "" {
@@ -566,7 +845,7 @@ func buildIDFilter(fieldName string, values []string) map[string]any {
 	return map[string]any{"conjunction": "or", "conditions": conds}
 }
 
-func searchItems(baseURL, token string, ref common.BitableRef, filterObj map[string]any, pageSize int, ignoreView bool, viewID string) ([]map[string]any, error) {
+func searchItems(ctx context.Context, baseURL, token string, ref common.BitableRef, filterObj map[string]any, pageSize int, ignoreView bool, viewID string) ([]map[string]any, error) {
 	pageSize = common.ClampPageSize(pageSize)
 	q := url.Values{}
 	q.Set("page_size", fmt.Sprintf("%d", pageSize))
@@ -584,12 +863,13 @@ func searchItems(baseURL, token string, ref common.BitableRef, filterObj map[str
 		}
 	}
 	var resp searchItemsResp
-	if err := common.RequestJSON("POST", urlStr, token, body, &resp); err != nil {
+	if err := common.RequestJSON(ctx, "POST", urlStr, token, body, &resp); err != nil {
 		return nil, err
 	}
 	if resp.Code != 0 {
 		return nil, fmt.Errorf("search records failed: code=%d msg=%s", resp.Code, resp.Msg)
 	}
+	common.CountTasks(common.TasksFetched, len(resp.Data.Items))
 	return resp.Data.Items, nil
 }
 
@@ -609,7 +889,7 @@ func hasCdnURL(extra any) bool {
 	return strings.TrimSpace(v) != ""
 }
 
-func buildUpdateFields(fieldsMap map[string]string, upd map[string]any) map[string]any {
+func buildUpdateFields(fieldsMap map[string]string, upd map[string]any, dates dateWriter) map[string]any {
 	out := map[string]any{}
 
 	status := strings.TrimSpace(common.BitableValueToString(upd["status"]))
@@ -619,7 +899,7 @@ func buildUpdateFields(fieldsMap map[string]string, upd map[string]any) map[stri
 
 	if fieldsMap["Date"] != "" {
 		if v, ok := upd["date"]; ok && v != nil {
-			if payload, ok := common.CoerceDatePayload(v); ok {
+			if payload, ok := dates.payload(v); ok {
 				out[fieldsMap["Date"]] = payload
 			}
 		}
@@ -692,6 +972,11 @@ func buildUpdateFields(fieldsMap map[string]string, upd map[string]any) map[stri
 		out[fieldsMap["RetryCount"]] = retryCount
 	}
 
+	screenshot := strings.TrimSpace(common.BitableValueToString(upd["last_screenshot"]))
+	if screenshot != "" && fieldsMap["LastScreenShot"] != "" {
+		out[fieldsMap["LastScreenShot"]] = screenshot
+	}
+
 	extra := upd["extra"]
 	forceExtra, _ := upd["force_extra"].(bool)
 	if fieldsMap["Extra"] != "" && extra != nil {
@@ -715,33 +1000,40 @@ func buildUpdateFields(fieldsMap map[string]string, upd map[string]any) map[stri
 	return out
 }
 
-func updateRecord(baseURL, token string, ref common.BitableRef, recordID string, fields map[string]any) error {
+func updateRecord(ctx context.Context, baseURL, token string, ref common.BitableRef, recordID string, fields map[string]any) error {
 	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/%s",
 		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID, url.PathEscape(recordID),
 	)
 	payload := map[string]any{"fields": fields}
 	var resp common.FeishuResp
-	if err := common.RequestJSON("PUT", urlStr, token, payload, &resp); err != nil {
+	if err := common.RequestJSON(ctx, "PUT", urlStr, token, payload, &resp); err != nil {
 		return err
 	}
 	if resp.Code != 0 {
 		return fmt.Errorf("update record failed: code=%d msg=%s", resp.Code, resp.Msg)
 	}
+	common.EmitRecordUpdated(ctx, ref.TableID, recordID, len(fields))
 	return nil
 }
 
-func batchUpdateRecords(baseURL, token string, ref common.BitableRef, records []map[string]any) error {
+func batchUpdateRecords(ctx context.Context, baseURL, token string, ref common.BitableRef, records []map[string]any) error {
 	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/records/batch_update",
 		strings.TrimRight(baseURL, "/"), ref.AppToken, ref.TableID,
 	)
 	payload := map[string]any{"records": records}
 	var resp common.FeishuResp
-	if err := common.RequestJSON("POST", urlStr, token, payload, &resp); err != nil {
+	if err := common.RequestJSON(ctx, "POST", urlStr, token, payload, &resp); err != nil {
 		return err
 	}
 	if resp.Code != 0 {
 		return fmt.Errorf("batch update failed: code=%d msg=%s", resp.Code, resp.Msg)
 	}
+	if common.EventsEnabled() {
+		for _, r := range records {
+			fields, _ := r["fields"].(map[string]any)
+			common.EmitRecordUpdated(ctx, ref.TableID, common.BitableValueToString(r["record_id"]), len(fields))
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// attachmentUploader turns a value naming a local file into an attachment
+// for an Attachment column: the file is uploaded to Drive under the task
+// table's app and the cell gets its file_token. LastScreenShot files go up
+// as images, others as files. Any other value is written as given.
+type attachmentUploader struct {
+	baseURL       string
+	token         string
+	ref           common.BitableRef
+	screenshotCol string
+	// uploaded maps a path to its file_token so rows sharing a file upload
+	// it once.
+	uploaded map[string]string
+}
+
+func newAttachmentUploader(baseURL, token string, ref common.BitableRef, fieldsMap map[string]string) *attachmentUploader {
+	return &attachmentUploader{
+		baseURL:       baseURL,
+		token:         token,
+		ref:           ref,
+		screenshotCol: strings.TrimSpace(fieldsMap["LastScreenShot"]),
+		uploaded:      map[string]string{},
+	}
+}
+
+// value returns the attachment payload for path in column f.
+func (u *attachmentUploader) value(ctx context.Context, f *common.FieldInfo, path string) (any, error) {
+	path = strings.TrimSpace(path)
+	if info, err := os.Stat(path); path == "" || err != nil || info.IsDir() {
+		return path, nil
+	}
+	fileToken, ok := u.uploaded[path]
+	if !ok {
+		var err error
+		if f.FieldName == u.screenshotCol {
+			fileToken, err = uploadScreenshot(ctx, u.baseURL, u.token, u.ref, path)
+		} else {
+			fileToken, err = uploadTaskFile(ctx, u.baseURL, u.token, u.ref, common.MediaParentBitableFile, "attachment", path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		u.uploaded[path] = fileToken
+	}
+	return []map[string]any{{"file_token": fileToken}}, nil
+}
+
+// uploadScreenshot uploads the image at path as media of the table's app and
+// returns its file_token.
+func uploadScreenshot(ctx context.Context, baseURL, token string, ref common.BitableRef, path string) (string, error) {
+	return uploadTaskFile(ctx, baseURL, token, ref, common.MediaParentBitableImage, "screenshot", path)
+}
+
+// uploadTaskFile uploads the file at path as media of the table's app.
+// what names the file in errors.
+func uploadTaskFile(ctx context.Context, baseURL, token string, ref common.BitableRef, parentType, what, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s %s: %w", what, path, err)
+	}
+	fileToken, err := common.UploadMedia(ctx, baseURL, token, parentType, ref.AppToken, filepath.Base(path), data)
+	if err != nil {
+		return "", fmt.Errorf("upload %s %s: %w", what, path, err)
+	}
+	return fileToken, nil
+}
+
+// logsFileUploader uploads --logs-file files. The file_token goes into
+// Logs and, when the table has a LogsFile attachment column, the file is
+// attached there too so it opens from the Bitable UI.
+type logsFileUploader struct {
+	baseURL string
+	token   string
+	ref     common.BitableRef
+	logsCol string
+	fileCol string
+	// uploaded maps a path to its file_token.
+	uploaded map[string]string
+}
+
+func newLogsFileUploader(ctx context.Context, baseURL, token string, ref common.BitableRef, fieldsMap map[string]string) *logsFileUploader {
+	u := &logsFileUploader{
+		baseURL:  baseURL,
+		token:    token,
+		ref:      ref,
+		logsCol:  strings.TrimSpace(fieldsMap["Logs"]),
+		uploaded: map[string]string{},
+	}
+	if col := strings.TrimSpace(fieldsMap["LogsFile"]); col != "" && tableHasColumn(ctx, baseURL, token, ref, col) {
+		u.fileCol = col
+	}
+	return u
+}
+
+// apply uploads path and sets the Logs (and LogsFile) values in fields.
+func (u *logsFileUploader) apply(ctx context.Context, fields map[string]any, path string) error {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil
+	}
+	if u.logsCol == "" && u.fileCol == "" {
+		return errors.New("--logs-file needs the Logs or LogsFile field mapped")
+	}
+	fileToken, ok := u.uploaded[path]
+	if !ok {
+		var err error
+		if fileToken, err = uploadTaskFile(ctx, u.baseURL, u.token, u.ref, common.MediaParentBitableFile, "logs file", path); err != nil {
+			return err
+		}
+		u.uploaded[path] = fileToken
+	}
+	if u.logsCol != "" {
+		fields[u.logsCol] = fileToken
+	}
+	if u.fileCol != "" {
+		fields[u.fileCol] = []map[string]any{{"file_token": fileToken}}
+	}
+	return nil
+}
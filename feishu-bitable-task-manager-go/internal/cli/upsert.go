@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// upsertTarget is an existing record matched by the upsert key.
+type upsertTarget struct {
+	RecordID string
+	Fields   map[string]any
+}
+
+// upsertIndex maps upsert key values found in the input to the existing
+// records carrying them.
+type upsertIndex struct {
+	field    string
+	existing map[string]upsertTarget
+}
+
+// newUpsertIndex resolves the records matching the key field (a logical
+// field name such as BizTaskID, or RecordID) of every input item. When
+// several records share a key value the first one found is updated.
+func newUpsertIndex(ctx context.Context, baseURL, token string, ref common.BitableRef, fieldsMap map[string]string, key string, items []map[string]any) (*upsertIndex, error) {
+	keys := normalizeSkipFields(key)
+	if len(keys) != 1 {
+		return nil, errors.New("upsert key must be exactly one field")
+	}
+	idx := &upsertIndex{field: keys[0], existing: map[string]upsertTarget{}}
+
+	values := []string{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		if v := idx.key(item); v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return idx, nil
+	}
+
+	if idx.field == "RecordID" {
+		current, err := batchGetRecordFields(ctx, baseURL, token, ref, values)
+		if err != nil {
+			return nil, err
+		}
+		for rid, fields := range current {
+			idx.existing[rid] = upsertTarget{RecordID: rid, Fields: fields}
+		}
+		return idx, nil
+	}
+
+	col := strings.TrimSpace(fieldsMap[idx.field])
+	if col == "" {
+		col = idx.field
+	}
+	existing, err := findByColumn(ctx, baseURL, token, ref, col, values)
+	if err != nil {
+		return nil, err
+	}
+	idx.existing = existing
+	return idx, nil
+}
+
+// findByColumn searches the records whose col holds one of values and maps
+// each value to the first record found with it.
+func findByColumn(ctx context.Context, baseURL, token string, ref common.BitableRef, col string, values []string) (map[string]upsertTarget, error) {
+	existing := map[string]upsertTarget{}
+	for _, batch := range chunkStrings(values, createMaxFilterValues) {
+		filterObj := buildIDFilter(col, batch)
+		if filterObj == nil {
+			continue
+		}
+		found, err := fetchRecordsForCreate(ctx, baseURL, token, ref, filterObj, minInt(common.MaxPageSize, maxInt(len(batch), 1)))
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range found {
+			rid := strings.TrimSpace(common.BitableValueToString(it["record_id"]))
+			fields, _ := it["fields"].(map[string]any)
+			v := strings.TrimSpace(common.BitableValueToString(fields[col]))
+			if rid == "" || v == "" {
+				continue
+			}
+			if _, dup := existing[v]; !dup {
+				existing[v] = upsertTarget{RecordID: rid, Fields: fields}
+			}
+		}
+	}
+	return existing, nil
+}
+
+// key returns the item's upsert key value ("" when it has none).
+func (u *upsertIndex) key(item map[string]any) string {
+	return extractItemValue(item, u.field)
+}
+
+// match returns the existing record for the item's key.
+func (u *upsertIndex) match(item map[string]any) (upsertTarget, bool) {
+	k := u.key(item)
+	if k == "" {
+		return upsertTarget{}, false
+	}
+	t, ok := u.existing[k]
+	return t, ok
+}
+
+// fieldsUnchanged reports whether writing fields would leave current as it
+// is, comparing normalized string values.
+func fieldsUnchanged(fields, current map[string]any) bool {
+	for k, v := range fields {
+		if common.BitableValueToString(v) != common.BitableValueToString(current[k]) {
+			return false
+		}
+	}
+	return true
+}
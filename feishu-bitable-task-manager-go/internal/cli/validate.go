@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+type ValidateOptions struct {
+	TaskURL string
+	Format  string
+}
+
+// mappingIssue is a logical field whose mapped column is missing or has a
+// type the tool cannot use.
+type mappingIssue struct {
+	Logical    string `json:"logical"`
+	Column     string `json:"column"`
+	Env        string `json:"env"`
+	Overridden bool   `json:"overridden,omitempty"`
+	Required   bool   `json:"required,omitempty"`
+	Want       string `json:"want,omitempty"`
+	Got        string `json:"got,omitempty"`
+}
+
+// unknownOverride is a TASK_FIELD_* variable that maps to no logical field.
+type unknownOverride struct {
+	Env     string `json:"env"`
+	Value   string `json:"value"`
+	Suggest string `json:"suggest,omitempty"`
+}
+
+type validateReport struct {
+	Checked          int               `json:"checked"`
+	Missing          []mappingIssue    `json:"missing"`
+	Mismatched       []mappingIssue    `json:"mismatched"`
+	NonCanonical     []mappingIssue    `json:"non_canonical"`
+	UnknownOverrides []unknownOverride `json:"unknown_overrides"`
+	OptionalMissing  []mappingIssue    `json:"optional_missing"`
+	Problems         int               `json:"problems"`
+	ElapsedSeconds   float64           `json:"elapsed_seconds"`
+}
+
+// ValidateFields checks the TASK_FIELD_* mapping against the live table and
+// exits 1 when it finds a problem: a required or explicitly overridden
+// column that does not exist, a column whose type the tool cannot read or
+// write, or a TASK_FIELD_* variable that matches no logical field. Optional
+// columns left at their default name and columns of an accepted but
+// non-canonical type (e.g. a text Date) are reported without failing.
+func ValidateFields(ctx context.Context, opts ValidateOptions) int {
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	if format != "json" && format != "table" {
+		errLogger.Error("--format must be json or table", "format", opts.Format)
+		return 2
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	start := time.Now()
+	schema, err := common.ListFields(ctx, tc.baseURL, tc.token, tc.ref.AppToken, tc.ref.TableID)
+	if err != nil {
+		errLogger.Error("list fields failed", "err", err)
+		return 2
+	}
+	byName := common.FieldsByName(schema)
+
+	envByLogical := map[string]string{}
+	for env, logical := range common.TaskFieldEnvMap {
+		envByLogical[logical] = env
+	}
+	report := validateReport{
+		Missing:          []mappingIssue{},
+		Mismatched:       []mappingIssue{},
+		NonCanonical:     []mappingIssue{},
+		UnknownOverrides: unknownFieldOverrides(os.Environ()),
+		OptionalMissing:  []mappingIssue{},
+	}
+	for _, sf := range taskSchema {
+		col := strings.TrimSpace(tc.fields[sf.Logical])
+		if col == "" {
+			continue
+		}
+		report.Checked++
+		env := envByLogical[sf.Logical]
+		issue := mappingIssue{
+			Logical:    sf.Logical,
+			Column:     col,
+			Env:        env,
+			Overridden: strings.TrimSpace(common.Env(env, "")) != "",
+			Required:   sf.Required,
+		}
+		cur, ok := byName[col]
+		if !ok {
+			if issue.Required || issue.Overridden {
+				report.Missing = append(report.Missing, issue)
+			} else {
+				report.OptionalMissing = append(report.OptionalMissing, issue)
+			}
+			continue
+		}
+		if cur.Type == sf.Type {
+			continue
+		}
+		issue.Want = common.FieldInfo{Type: sf.Type}.TypeName()
+		issue.Got = cur.TypeName()
+		if sf.accepts(cur.Type) {
+			report.NonCanonical = append(report.NonCanonical, issue)
+		} else {
+			report.Mismatched = append(report.Mismatched, issue)
+		}
+	}
+	report.Problems = len(report.Missing) + len(report.Mismatched) + len(report.UnknownOverrides)
+	report.ElapsedSeconds = float64(int(time.Since(start).Seconds()*1000)) / 1000
+
+	if format == "table" {
+		printValidateTable(report)
+	} else {
+		printJSON(report)
+	}
+	if report.Problems > 0 {
+		return 1
+	}
+	return 0
+}
+
+// unknownFieldOverrides returns the TASK_FIELD_* entries of environ that are
+// not in TaskFieldEnvMap, with the known name they most likely meant when
+// the two differ only in underscores or case (TASK_FIELD_TASK_ID).
+func unknownFieldOverrides(environ []string) []unknownOverride {
+	squash := func(s string) string { return strings.ToUpper(strings.ReplaceAll(s, "_", "")) }
+	known := map[string]string{}
+	for env := range common.TaskFieldEnvMap {
+		known[squash(env)] = env
+	}
+	out := []unknownOverride{}
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "TASK_FIELD_") || strings.TrimSpace(value) == "" {
+			continue
+		}
+		if _, ok := common.TaskFieldEnvMap[name]; ok {
+			continue
+		}
+		out = append(out, unknownOverride{Env: name, Value: value, Suggest: known[squash(name)]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Env < out[j].Env })
+	return out
+}
+
+func printValidateTable(report validateReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROBLEM\tFIELD\tCOLUMN\tDETAIL")
+	for _, m := range report.Missing {
+		detail := "required column"
+		if m.Overridden {
+			detail = "set by " + m.Env
+		}
+		fmt.Fprintf(w, "missing\t%s\t%s\t%s\n", m.Logical, m.Column, detail)
+	}
+	for _, m := range report.Mismatched {
+		fmt.Fprintf(w, "type\t%s\t%s\twant %s, got %s\n", m.Logical, m.Column, m.Want, m.Got)
+	}
+	for _, u := range report.UnknownOverrides {
+		detail := "unknown variable"
+		if u.Suggest != "" {
+			detail = "did you mean " + u.Suggest + "?"
+		}
+		fmt.Fprintf(w, "override\t%s\t%s\t%s\n", u.Env, u.Value, detail)
+	}
+	for _, m := range report.NonCanonical {
+		fmt.Fprintf(w, "note\t%s\t%s\t%s works, %s preferred\n", m.Logical, m.Column, m.Got, m.Want)
+	}
+	for _, m := range report.OptionalMissing {
+		fmt.Fprintf(w, "note\t%s\t%s\toptional column not in table\n", m.Logical, m.Column)
+	}
+	w.Flush()
+	fmt.Printf("\nchecked %d fields, %d problems\n", report.Checked, report.Problems)
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+const (
+	watchByCreatedTime = "created-time"
+	watchByRecordID    = "record-id"
+)
+
+type WatchOptions struct {
+	TaskURL    string
+	App        string
+	Scene      string
+	Status     string
+	Date       string
+	Interval   time.Duration
+	By         string
+	FromStart  bool
+	IgnoreView bool
+	ViewID     string
+	// IncludeDeleted also emits soft-deleted tasks.
+	IncludeDeleted bool
+	// Jitter randomizes each poll interval.
+	Jitter jitter
+	// MetricsAddr serves Prometheus metrics on this address while watching.
+	MetricsAddr string
+}
+
+// taskWatcher remembers what has been emitted across polls. In created-time
+// mode it keeps a watermark plus the record ids at exactly that millisecond;
+// in record-id mode it keeps every record id seen.
+type taskWatcher struct {
+	by        string
+	watermark int64
+	atMark    map[string]bool
+	seen      map[string]bool
+}
+
+// fresh returns the items not emitted yet, oldest first, and advances the
+// watcher's state past them.
+func (w *taskWatcher) fresh(items []map[string]any) []map[string]any {
+	out := []map[string]any{}
+	for _, it := range items {
+		recordID := strings.TrimSpace(common.BitableValueToString(it["record_id"]))
+		if recordID == "" {
+			continue
+		}
+		if w.by == watchByRecordID {
+			if !w.seen[recordID] {
+				w.seen[recordID] = true
+				out = append(out, it)
+			}
+			continue
+		}
+		created, _ := common.CoerceMillis(it["created_time"])
+		if created > w.watermark || (created == w.watermark && !w.atMark[recordID]) {
+			out = append(out, it)
+		}
+	}
+	if w.by == watchByCreatedTime {
+		sortByCreatedTime(out)
+		for _, it := range out {
+			created, _ := common.CoerceMillis(it["created_time"])
+			recordID := strings.TrimSpace(common.BitableValueToString(it["record_id"]))
+			if created > w.watermark {
+				w.watermark = created
+				w.atMark = map[string]bool{}
+			}
+			w.atMark[recordID] = true
+		}
+	}
+	return out
+}
+
+func sortByCreatedTime(items []map[string]any) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, _ := common.CoerceMillis(items[i]["created_time"])
+		b, _ := common.CoerceMillis(items[j]["created_time"])
+		return a < b
+	})
+}
+
+// WatchTasks polls the table every Interval and streams newly appearing
+// tasks as JSONL until ctx is cancelled.
+func WatchTasks(ctx context.Context, opts WatchOptions) int {
+	by := strings.TrimSpace(opts.By)
+	if by != watchByCreatedTime && by != watchByRecordID {
+		errLogger.Error("--by must be created-time or record-id", "by", by)
+		return 2
+	}
+	if opts.Interval <= 0 {
+		errLogger.Error("--interval must be positive")
+		return 2
+	}
+	if addr := strings.TrimSpace(opts.MetricsAddr); addr != "" {
+		stop, err := serveMetrics(addr)
+		if err != nil {
+			errLogger.Error("start metrics server failed", "addr", addr, "err", err)
+			return 2
+		}
+		defer stop()
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	viewID := strings.TrimSpace(opts.ViewID)
+	if viewID == "" {
+		viewID = tc.ref.ViewID
+	}
+	body := map[string]any{"automatic_fields": true}
+	if filterObj := buildFilter(tc.fields, opts.App, opts.Scene, opts.Status, opts.Date); filterObj != nil {
+		body["filter"] = filterObj
+	}
+	if !opts.IgnoreView && viewID != "" {
+		body["view_id"] = viewID
+	}
+
+	w := &taskWatcher{by: by, atMark: map[string]bool{}, seen: map[string]bool{}}
+	if !opts.FromStart {
+		w.watermark = time.Now().UnixMilli()
+		if by == watchByRecordID {
+			// Everything already in the table counts as seen.
+			if _, err := w.poll(ctx, tc, body); err != nil {
+				errLogger.Error("initial scan failed", "err", err)
+				return 2
+			}
+		}
+	}
+
+	failures := 0
+	for {
+		pollStart := time.Now()
+		items, err := w.poll(ctx, tc, body)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return 0
+		case err != nil:
+			failures++
+			errLogger.Warn("poll failed", "err", err)
+			common.EmitRetry(ctx, "watch_poll", failures, opts.Interval, err)
+		default:
+			failures = 0
+			for _, it := range items {
+				fieldsRaw, _ := it["fields"].(map[string]any)
+				if !opts.IncludeDeleted && isSoftDeleted(fieldsRaw, tc.fields) {
+					continue
+				}
+				t, ok := decodeTask(fieldsRaw, tc.fields)
+				if !ok {
+					continue
+				}
+				t.RecordID = strings.TrimSpace(common.BitableValueToString(it["record_id"]))
+				logger.Info("task", "task", t)
+			}
+		}
+		if !sleepContext(ctx, opts.Jitter.apply(opts.Interval)-time.Since(pollStart)) {
+			return 0
+		}
+	}
+}
+
+func (w *taskWatcher) poll(ctx context.Context, tc *tableClient, body map[string]any) ([]map[string]any, error) {
+	items := []map[string]any{}
+	err := scanRecords(ctx, tc.baseURL, tc.token, tc.ref, body, func(it map[string]any) {
+		items = append(items, it)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w.fresh(items), nil
+}
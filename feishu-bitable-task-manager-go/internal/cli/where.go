@@ -0,0 +1,379 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// whereNode is a parsed --where expression: an and/or group of nodes, or a
+// single condition when Op is empty.
+type whereNode struct {
+	Op   string
+	Kids []*whereNode
+	Cond filterCond
+}
+
+// compileWhere parses a --where expression and merges it into filterObj
+// (as built by buildFilter, nil when there are no other conditions).
+//
+//	expr  := and { "or" and }
+//	and   := term { "and" term }
+//	term  := "(" expr ")" | cond
+//	cond  := name ( "=" | "!=" | ">" | ">=" | "<" | "<=" | "contains" | "not contains" ) value
+//	       | name [ "not" ] "in" "(" value { "," value } ")"
+//	       | name "is" [ "not" ] "empty"
+//
+// Keywords are case-insensitive; names and values may be quoted with ' or ".
+// Names resolve like --filter: logical task fields through TASK_FIELD_*,
+// anything else verbatim. Bitable filters nest one level deep, so groups
+// may hold conditions and groups of conditions but nothing deeper.
+func compileWhere(expr string, fields map[string]string, filterObj map[string]any) (map[string]any, error) {
+	if strings.TrimSpace(expr) == "" {
+		return filterObj, nil
+	}
+	toks, err := tokenizeWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{toks: toks, fields: fields}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("where: unexpected %q", p.peek().text)
+	}
+	root = flattenWhere(root)
+
+	// Fold the existing AND filter and the expression into one filter.
+	top := &whereNode{Op: "and"}
+	if filterObj != nil {
+		conds, _ := filterObj["conditions"].([]map[string]any)
+		top.Kids = append(top.Kids, conditionNodes(conds)...)
+		children, _ := filterObj["children"].([]map[string]any)
+		for _, ch := range children {
+			conds, _ := ch["conditions"].([]map[string]any)
+			top.Kids = append(top.Kids, &whereNode{Op: fmt.Sprint(ch["conjunction"]), Kids: conditionNodes(conds)})
+		}
+	}
+	if len(top.Kids) == 0 {
+		if root.Op == "" {
+			top.Kids = []*whereNode{root}
+		} else {
+			top = root
+		}
+	} else if root.Op == "and" {
+		top.Kids = append(top.Kids, root.Kids...)
+	} else {
+		top.Kids = append(top.Kids, root)
+	}
+
+	out := map[string]any{"conjunction": top.Op}
+	conds := []map[string]any{}
+	children := []map[string]any{}
+	for _, k := range top.Kids {
+		if k.Op == "" {
+			conds = append(conds, whereCondition(k.Cond))
+			continue
+		}
+		group := []map[string]any{}
+		for _, g := range k.Kids {
+			if g.Op != "" {
+				return nil, fmt.Errorf("where: groups nest too deeply for a Bitable filter (one level of parentheses mixing and/or)")
+			}
+			group = append(group, whereCondition(g.Cond))
+		}
+		children = append(children, map[string]any{"conjunction": k.Op, "conditions": group})
+	}
+	out["conditions"] = conds
+	if len(children) > 0 {
+		out["children"] = children
+	}
+	return out, nil
+}
+
+func conditionNodes(conds []map[string]any) []*whereNode {
+	out := make([]*whereNode, 0, len(conds))
+	for _, c := range conds {
+		out = append(out, &whereNode{Cond: filterCond{
+			Column:   fmt.Sprint(c["field_name"]),
+			Operator: fmt.Sprint(c["operator"]),
+			Value:    toStrings(c["value"]),
+		}})
+	}
+	return out
+}
+
+func whereCondition(c filterCond) map[string]any {
+	value := c.Value
+	if value == nil {
+		value = []string{}
+	}
+	return map[string]any{"field_name": c.Column, "operator": c.Operator, "value": value}
+}
+
+func toStrings(v any) []string {
+	switch x := v.(type) {
+	case []string:
+		return x
+	case []any:
+		out := make([]string, 0, len(x))
+		for _, it := range x {
+			out = append(out, fmt.Sprint(it))
+		}
+		return out
+	}
+	return nil
+}
+
+// flattenWhere merges nested groups with the same operator, so
+// "a and (b and c)" is one group of three.
+func flattenWhere(n *whereNode) *whereNode {
+	if n.Op == "" {
+		return n
+	}
+	kids := []*whereNode{}
+	for _, k := range n.Kids {
+		k = flattenWhere(k)
+		if k.Op == n.Op {
+			kids = append(kids, k.Kids...)
+		} else {
+			kids = append(kids, k)
+		}
+	}
+	if len(kids) == 1 {
+		return kids[0]
+	}
+	return &whereNode{Op: n.Op, Kids: kids}
+}
+
+type whereToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeWhere(s string) ([]whereToken, error) {
+	toks := []whereToken{}
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',':
+			toks = append(toks, whereToken{text: string(r)})
+			i++
+		case r == '\'' || r == '"':
+			j := i + 1
+			var b strings.Builder
+			for j < len(rs) && rs[j] != r {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				b.WriteRune(rs[j])
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("where: unterminated quote at offset %d", i)
+			}
+			toks = append(toks, whereToken{text: b.String(), quoted: true})
+			i = j + 1
+		case strings.ContainsRune("=!<>", r):
+			j := i + 1
+			for j < len(rs) && strings.ContainsRune("=<>", rs[j]) {
+				j++
+			}
+			toks = append(toks, whereToken{text: string(rs[i:j])})
+			i = j
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune("(),=!<>'\"", rs[j]) {
+				j++
+			}
+			toks = append(toks, whereToken{text: string(rs[i:j])})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+var whereComparisons = map[string]string{
+	"=": "is", "==": "is", "!=": "isNot", "<>": "isNot",
+	">": "isGreater", ">=": "isGreaterEqual", "<": "isLess", "<=": "isLessEqual",
+}
+
+type whereParser struct {
+	toks   []whereToken
+	pos    int
+	fields map[string]string
+}
+
+func (p *whereParser) done() bool { return p.pos >= len(p.toks) }
+
+func (p *whereParser) peek() whereToken {
+	if p.done() {
+		return whereToken{}
+	}
+	return p.toks[p.pos]
+}
+
+// keyword reports whether the next token is the unquoted keyword kw, and
+// consumes it if so.
+func (p *whereParser) keyword(kw string) bool {
+	t := p.peek()
+	if p.done() || t.quoted || !strings.EqualFold(t.text, kw) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *whereParser) expect(text string) error {
+	if p.done() {
+		return fmt.Errorf("where: expected %q at end of expression", text)
+	}
+	if t := p.peek(); t.quoted || t.text != text {
+		return fmt.Errorf("where: expected %q, got %q", text, t.text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *whereParser) parseOr() (*whereNode, error) {
+	return p.parseGroup("or", p.parseAnd)
+}
+
+func (p *whereParser) parseAnd() (*whereNode, error) {
+	return p.parseGroup("and", p.parseTerm)
+}
+
+func (p *whereParser) parseGroup(op string, next func() (*whereNode, error)) (*whereNode, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	kids := []*whereNode{first}
+	for p.keyword(op) {
+		n, err := next()
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, n)
+	}
+	if len(kids) == 1 {
+		return first, nil
+	}
+	return &whereNode{Op: op, Kids: kids}, nil
+}
+
+func (p *whereParser) parseTerm() (*whereNode, error) {
+	if t := p.peek(); !t.quoted && t.text == "(" {
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return p.parseCond()
+}
+
+func (p *whereParser) value() (string, error) {
+	t := p.peek()
+	if p.done() || (!t.quoted && strings.ContainsAny(t.text, "(),")) {
+		return "", fmt.Errorf("where: expected a value, got %q", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *whereParser) parseCond() (*whereNode, error) {
+	name, err := p.value()
+	if err != nil {
+		return nil, fmt.Errorf("where: expected a field name, got %q", p.peek().text)
+	}
+	leaf := func(operator string, value []string) *whereNode {
+		cond := filterCond{Column: name, Operator: operator, Value: value}
+		if col := strings.TrimSpace(p.fields[name]); col != "" {
+			cond.Column = col
+		}
+		return &whereNode{Cond: cond}
+	}
+
+	if t := p.peek(); !t.quoted && whereComparisons[t.text] != "" {
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return leaf(whereComparisons[t.text], []string{v}), nil
+	}
+	switch {
+	case p.keyword("contains"):
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return leaf("contains", []string{v}), nil
+	case p.keyword("is"):
+		operator := "isEmpty"
+		if p.keyword("not") {
+			operator = "isNotEmpty"
+		}
+		if !p.keyword("empty") {
+			return nil, fmt.Errorf("where: expected \"empty\" after %q is", name)
+		}
+		return leaf(operator, nil), nil
+	case p.keyword("not"):
+		if p.keyword("contains") {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			return leaf("doesNotContain", []string{v}), nil
+		}
+		if !p.keyword("in") {
+			return nil, fmt.Errorf("where: expected \"in\" or \"contains\" after %q not", name)
+		}
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		n := &whereNode{Op: "and"}
+		for _, v := range values {
+			n.Kids = append(n.Kids, leaf("isNot", []string{v}))
+		}
+		return n, nil
+	case p.keyword("in"):
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		n := &whereNode{Op: "or"}
+		for _, v := range values {
+			n.Kids = append(n.Kids, leaf("is", []string{v}))
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("where: expected an operator after %q, got %q", name, p.peek().text)
+}
+
+func (p *whereParser) list() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	values := []string{}
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if t := p.peek(); !t.quoted && t.text == "," {
+			p.pos++
+			continue
+		}
+		return values, p.expect(")")
+	}
+}
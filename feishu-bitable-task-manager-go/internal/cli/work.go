@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"feishu-bitable-task-manager-go/internal/common"
+)
+
+// maxHandlerLogs bounds how much handler stdout is kept for the Logs field.
+const maxHandlerLogs = 4000
+
+type WorkOptions struct {
+	TaskURL      string
+	App          string
+	Scene        string
+	Date         string
+	DeviceSerial string
+	IgnoreView   bool
+	ViewID       string
+	LeaseTimeout time.Duration
+
+	Poll      time.Duration
+	Heartbeat time.Duration
+	// Jitter randomizes the poll and heartbeat intervals.
+	Jitter    jitter
+	Timeout   time.Duration
+	MaxTasks  int
+	Once      bool
+	EnvPrefix string
+	Shell     string
+	RunsURL   string
+	// ControlURL is the control table checked before every claim.
+	ControlURL string
+	// MetricsAddr serves Prometheus metrics on this address while working.
+	MetricsAddr string
+	// QueueDir keeps outcomes that cannot reach Feishu; they are replayed
+	// before each claim.
+	QueueDir string
+	Command  []string
+}
+
+// handlerResult is the optional JSON object a handler writes to
+// $TASK_RESULT_FILE to override what the worker derives from its exit code
+// and output.
+type handlerResult struct {
+	Status         string `json:"status"`
+	ItemsCollected *int   `json:"items_collected"`
+	Logs           string `json:"logs"`
+	Screenshot     string `json:"screenshot"`
+}
+
+// WorkTasks claims pending tasks one at a time and runs the handler command
+// for each: the task is marked running, injected through the exec env
+// contract and as JSON on stdin, and completed from the handler's exit code
+// (0 = success, else failed; timeout when --timeout kills it), with its
+// stdout tail as Logs. SIGINT/SIGTERM stop claiming; the running handler is
+// allowed to finish and is reported.
+func WorkTasks(ctx context.Context, opts WorkOptions) int {
+	if len(opts.Command) == 0 {
+		errLogger.Error("a handler command is required: bitable-task work [flags] -- <command> [args...]")
+		return 2
+	}
+	if strings.TrimSpace(opts.DeviceSerial) == "" {
+		errLogger.Error("--device-serial is required")
+		return 2
+	}
+	argv, err := shellCommand(opts.Shell, opts.Command)
+	if err != nil {
+		errLogger.Error("invalid --shell", "err", err)
+		return 2
+	}
+	opts.Command = argv
+	if addr := strings.TrimSpace(opts.MetricsAddr); addr != "" {
+		stop, err := serveMetrics(addr)
+		if err != nil {
+			errLogger.Error("start metrics server failed", "addr", addr, "err", err)
+			return 2
+		}
+		defer stop()
+	}
+	tc, code := openTable(ctx, opts.TaskURL)
+	if tc == nil {
+		return code
+	}
+	var runs *runsTable
+	if runsURL := strings.TrimSpace(opts.RunsURL); runsURL != "" {
+		runs, err = openRunsTable(ctx, tc.baseURL, tc.token, runsURL)
+		if err != nil {
+			errLogger.Error("open runs table failed", "err", err)
+			return 2
+		}
+	}
+	var control *controlTable
+	if controlURL := strings.TrimSpace(opts.ControlURL); controlURL != "" {
+		control, err = openControlTable(ctx, tc.baseURL, tc.token, controlURL)
+		if err != nil {
+			errLogger.Error("open control table failed", "err", err)
+			return 2
+		}
+	}
+	poll := opts.Poll
+	if poll <= 0 {
+		poll = 30 * time.Second
+	}
+	w := &worker{tc: tc, runs: runs, opts: opts, queue: newMutationQueue(UpdateOptions{
+		TaskURL:    opts.TaskURL,
+		IgnoreView: opts.IgnoreView,
+		ViewID:     opts.ViewID,
+		// A queued outcome is only written to a task still held by a
+		// worker, so it cannot undo a requeue or a later completion.
+		ExpectStatus: "dispatched,running",
+		RunsURL:      opts.RunsURL,
+		QueueDir:     opts.QueueDir,
+	})}
+
+	done, exit := 0, 0
+	for ctx.Err() == nil && (opts.MaxTasks <= 0 || done < opts.MaxTasks) {
+		if w.queue != nil {
+			// errQueueBusy: another worker sharing the directory is
+			// replaying it.
+			if _, err := flushQueue(ctx, w.queue.dir); err != nil && !errors.Is(err, errQueueBusy) {
+				errLogger.Warn("replay queued outcomes failed", "dir", w.queue.dir, "err", err)
+			}
+		}
+		tasks, code := claimTasks(ctx, tc, ClaimOptions{
+			App:          opts.App,
+			Scene:        opts.Scene,
+			Date:         opts.Date,
+			Limit:        1,
+			DeviceSerial: opts.DeviceSerial,
+			IgnoreView:   opts.IgnoreView,
+			ViewID:       opts.ViewID,
+			LeaseTimeout: opts.LeaseTimeout,
+			control:      control,
+		})
+		if code == 2 && done == 0 && ctx.Err() == nil {
+			// A failing first claim is a setup problem (mapping, auth), not
+			// a transient one.
+			return 2
+		}
+		if len(tasks) == 0 {
+			if opts.Once {
+				break
+			}
+			sleepContext(ctx, opts.Jitter.apply(poll))
+			continue
+		}
+		tctx, span := common.StartSpan(ctx, "work task",
+			"bitable.record_id", tasks[0].RecordID, "bitable.task_id", tasks[0].TaskID, "bitable.trace_id", tasks[0].TraceID)
+		err := w.run(tctx, tasks[0])
+		span.End(err)
+		if err != nil {
+			errLogger.Error("report task failed", taskAttrs(tasks[0].RecordID, tasks[0].TraceID, "err", err)...)
+			exit = 1
+		}
+		done++
+	}
+	return exit
+}
+
+type worker struct {
+	tc    *tableClient
+	runs  *runsTable
+	opts  WorkOptions
+	queue *mutationQueue
+}
+
+// run executes the handler for one claimed task and writes its outcome. The
+// handler and the writes ignore ctx cancellation so a shutdown does not
+// leave the task half-reported.
+func (w *worker) run(ctx context.Context, t Task) error {
+	tc := w.tc
+	wctx := context.WithoutCancel(ctx)
+	// An operator may have locked the task since it was claimed; leave it
+	// dispatched and let its lease expire rather than run it.
+	if current, err := tc.getRecordFields(wctx, t.RecordID); err != nil {
+		errLogger.Warn("re-read claimed task failed", taskAttrs(t.RecordID, t.TraceID, "err", err)...)
+	} else if l, ok := activeEditLock(current, tc.fields, time.Now()); ok {
+		logSkippedLocked(t.RecordID, l, "work")
+		return nil
+	}
+	start := time.Now()
+	running := buildUpdateFields(tc.fields, map[string]any{"status": "running", "start_at": start.UnixMilli()}, dateWriter{})
+	if err := tc.updateRecord(wctx, t.RecordID, running); err != nil {
+		errLogger.Warn("mark task running failed", taskAttrs(t.RecordID, t.TraceID, "err", err)...)
+	}
+
+	hctx, cancel := context.WithCancel(wctx)
+	defer cancel()
+	if w.opts.Timeout > 0 {
+		hctx, cancel = context.WithTimeout(hctx, w.opts.Timeout)
+		defer cancel()
+	}
+	leaseLost := make(chan struct{})
+	if w.opts.Heartbeat > 0 && strings.TrimSpace(tc.fields["HeartbeatAt"]) != "" {
+		go w.heartbeat(hctx, t, cancel, leaseLost)
+	}
+
+	dir, err := os.MkdirTemp("", "bitable-task-work-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	resultFile := filepath.Join(dir, "result.json")
+	payload, _ := json.Marshal(t)
+	logs := &tailBuffer{max: maxHandlerLogs}
+	exitCode, runErr := runTaskCommand(hctx, w.opts.Command, taskEnv(t, w.opts.EnvPrefix, resultFile),
+		strings.NewReader(string(payload)), io.MultiWriter(os.Stderr, logs), os.Stderr)
+
+	select {
+	case <-leaseLost:
+		errLogger.Warn("lease lost; not reporting task", taskAttrs(t.RecordID, t.TraceID)...)
+		return nil
+	default:
+	}
+
+	status, logText := "success", logs.String()
+	switch {
+	case errors.Is(hctx.Err(), context.DeadlineExceeded):
+		status = "timeout"
+	case runErr != nil:
+		status, logText = "error", runErr.Error()
+	case exitCode != 0:
+		status = "failed"
+	}
+	opts := CompleteOptions{Status: status, ItemsCollected: -1, Logs: logText, AttemptToken: t.AttemptToken}
+	if res, ok := readHandlerResult(resultFile); ok {
+		if terminalStatuses[strings.ToLower(strings.TrimSpace(res.Status))] {
+			opts.Status = strings.TrimSpace(res.Status)
+		}
+		if res.ItemsCollected != nil {
+			opts.ItemsCollected = *res.ItemsCollected
+		}
+		if strings.TrimSpace(res.Logs) != "" {
+			opts.Logs = res.Logs
+		}
+		if shot := strings.TrimSpace(res.Screenshot); shot != "" {
+			// Handlers on Windows hosts often write forward-slash paths.
+			opts.Screenshot = filepath.Clean(filepath.FromSlash(shot))
+		}
+	}
+
+	current, err := tc.getRecordFields(wctx, t.RecordID)
+	if err != nil {
+		return w.queueOutcome(t, opts, start, exitCode, err)
+	}
+	fields, _, err := completeFields(wctx, tc, t.RecordID, current, opts, time.Now().UnixMilli())
+	if err != nil {
+		return w.queueOutcome(t, opts, start, exitCode, err)
+	}
+	if err := tc.updateRecord(wctx, t.RecordID, fields); err != nil {
+		return w.queueOutcome(t, opts, start, exitCode, err)
+	}
+	if w.runs != nil {
+		if _, err := w.runs.writeRuns(wctx, tc.baseURL, tc.token, tc.ref, tc.fields, []recordUpdate{{RecordID: t.RecordID, Fields: fields}}); err != nil {
+			errLogger.Warn("write runs failed", taskAttrs(t.RecordID, t.TraceID, "err", err)...)
+		}
+	}
+	logger.Info("work", taskAttrs(t.RecordID, t.TraceID,
+		"task_id", t.TaskID,
+		"status", opts.Status,
+		"exit_code", exitCode,
+		"elapsed_seconds", float64(int(time.Since(start).Seconds()*1000))/1000,
+	)...)
+	return nil
+}
+
+// queueOutcome queues the completion of t as an update row when err left
+// Feishu out of reach, and returns err when it could not. The screenshot
+// is not queued.
+func (w *worker) queueOutcome(t Task, opts CompleteOptions, start time.Time, exitCode int, err error) error {
+	now := time.Now()
+	row := map[string]any{
+		"record_id":       t.RecordID,
+		"status":          opts.Status,
+		"completed_at":    now.UnixMilli(),
+		"elapsed_seconds": int(now.Sub(start).Seconds()),
+		"attempt_token":   opts.AttemptToken,
+	}
+	if opts.ItemsCollected >= 0 {
+		row["items_collected"] = opts.ItemsCollected
+	}
+	if logs := strings.TrimSpace(opts.Logs); logs != "" {
+		row["logs"] = logs
+	}
+	if !w.queue.offer(err, []map[string]any{row}) {
+		return err
+	}
+	logger.Info("work", taskAttrs(t.RecordID, t.TraceID,
+		"task_id", t.TaskID,
+		"status", opts.Status,
+		"exit_code", exitCode,
+		"elapsed_seconds", float64(int(now.Sub(start).Seconds()*1000))/1000,
+		"queued", true,
+	)...)
+	return nil
+}
+
+// heartbeat beats until ctx ends. When the lease is lost it closes lost and
+// cancels the handler, since another worker now owns the task.
+func (w *worker) heartbeat(ctx context.Context, t Task, cancel context.CancelFunc, lost chan struct{}) {
+	h := &heartbeater{tc: w.tc, recordID: t.RecordID, attemptToken: t.AttemptToken}
+	failures := 0
+	for {
+		if !sleepContext(ctx, w.opts.Jitter.apply(w.opts.Heartbeat)) {
+			return
+		}
+		if _, err := h.beat(ctx); errors.Is(err, errLeaseLost) {
+			close(lost)
+			cancel()
+			return
+		} else if err != nil && ctx.Err() == nil {
+			failures++
+			errLogger.Warn("heartbeat failed", taskAttrs(t.RecordID, t.TraceID, "err", err)...)
+			common.EmitRetry(ctx, "heartbeat", failures, w.opts.Heartbeat, err)
+		} else if err == nil {
+			failures = 0
+		}
+	}
+}
+
+func readHandlerResult(path string) (handlerResult, bool) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return handlerResult{}, false
+	}
+	var res handlerResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return handlerResult{}, false
+	}
+	return res, true
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+// String returns the kept output as valid UTF-8 with Windows line endings
+// normalized.
+func (b *tailBuffer) String() string {
+	s := strings.ToValidUTF8(string(b.buf), "")
+	return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,7 +12,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"feishu-bitable-task-manager-go/pkg/taskmodel"
 )
 
 const (
@@ -20,32 +24,39 @@ const (
 	MaxPageSize     = 500
 )
 
-var TaskFieldEnvMap = map[string]string{
-	"TASK_FIELD_TASKID":            "TaskID",
-	"TASK_FIELD_BIZ_TASK_ID":       "BizTaskID",
-	"TASK_FIELD_PARENT_TASK_ID":    "ParentTaskID",
-	"TASK_FIELD_APP":               "App",
-	"TASK_FIELD_SCENE":             "Scene",
-	"TASK_FIELD_PARAMS":            "Params",
-	"TASK_FIELD_ITEMID":            "ItemID",
-	"TASK_FIELD_BOOKID":            "BookID",
-	"TASK_FIELD_URL":               "URL",
-	"TASK_FIELD_USERID":            "UserID",
-	"TASK_FIELD_USERNAME":          "UserName",
-	"TASK_FIELD_DATE":              "Date",
-	"TASK_FIELD_STATUS":            "Status",
-	"TASK_FIELD_LOGS":              "Logs",
-	"TASK_FIELD_LAST_SCREEN_SHOT":  "LastScreenShot",
-	"TASK_FIELD_GROUPID":           "GroupID",
-	"TASK_FIELD_DEVICE_SERIAL":     "DeviceSerial",
-	"TASK_FIELD_DISPATCHED_DEVICE": "DispatchedDevice",
-	"TASK_FIELD_DISPATCHED_AT":     "DispatchedAt",
-	"TASK_FIELD_START_AT":          "StartAt",
-	"TASK_FIELD_END_AT":            "EndAt",
-	"TASK_FIELD_ELAPSED_SECONDS":   "ElapsedSeconds",
-	"TASK_FIELD_ITEMS_COLLECTED":   "ItemsCollected",
-	"TASK_FIELD_EXTRA":             "Extra",
-	"TASK_FIELD_RETRYCOUNT":        "RetryCount",
+// TaskFieldEnvMap maps TASK_FIELD_* overrides to logical task fields; the
+// vocabulary lives in pkg/taskmodel.
+var TaskFieldEnvMap = func() map[string]string {
+	out := make(map[string]string, len(taskmodel.FieldEnv))
+	for env, f := range taskmodel.FieldEnv {
+		out[env] = string(f)
+	}
+	return out
+}()
+
+// RunFieldEnvMap maps RUN_FIELD_* overrides to logical runs-table fields.
+var RunFieldEnvMap = map[string]string{
+	"RUN_FIELD_TASK":            "Task",
+	"RUN_FIELD_TASKID":          "TaskID",
+	"RUN_FIELD_ATTEMPT":         "Attempt",
+	"RUN_FIELD_STATUS":          "Status",
+	"RUN_FIELD_DEVICE_SERIAL":   "DeviceSerial",
+	"RUN_FIELD_START_AT":        "StartAt",
+	"RUN_FIELD_END_AT":          "EndAt",
+	"RUN_FIELD_ELAPSED_SECONDS": "ElapsedSeconds",
+	"RUN_FIELD_ITEMS_COLLECTED": "ItemsCollected",
+	"RUN_FIELD_LOGS":            "Logs",
+}
+
+// ControlFieldEnvMap maps CONTROL_FIELD_* overrides to logical
+// control-table fields.
+var ControlFieldEnvMap = map[string]string{
+	"CONTROL_FIELD_APP":       "App",
+	"CONTROL_FIELD_SCENE":     "Scene",
+	"CONTROL_FIELD_PAUSED":    "Paused",
+	"CONTROL_FIELD_REASON":    "Reason",
+	"CONTROL_FIELD_PAUSED_BY": "PausedBy",
+	"CONTROL_FIELD_PAUSED_AT": "PausedAt",
 }
 
 type BitableRef struct {
@@ -88,6 +99,19 @@ func firstQueryValue(q url.Values, keys ...string) string {
 }
 
 func ParseBitableURL(raw string) (BitableRef, error) {
+	ref, err := ParseBitableBaseURL(raw)
+	if err != nil {
+		return BitableRef{}, err
+	}
+	if ref.TableID == "" {
+		return BitableRef{}, errors.New("missing table_id in bitable url query")
+	}
+	return ref, nil
+}
+
+// ParseBitableBaseURL is ParseBitableURL for links that may point at a
+// whole base rather than one table; TableID is empty then.
+func ParseBitableBaseURL(raw string) (BitableRef, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
 		return BitableRef{}, errors.New("bitable url is empty")
@@ -127,9 +151,6 @@ func ParseBitableURL(raw string) (BitableRef, error) {
 	q := u.Query()
 	tableID := firstQueryValue(q, "table", "tableId", "table_id")
 	viewID := firstQueryValue(q, "view", "viewId", "view_id")
-	if tableID == "" {
-		return BitableRef{}, errors.New("missing table_id in bitable url query")
-	}
 	return BitableRef{
 		RawURL:    raw,
 		AppToken:  appToken,
@@ -152,51 +173,169 @@ func LoadTaskFieldsFromEnv() map[string]string {
 	return fields
 }
 
+func LoadRunFieldsFromEnv() map[string]string {
+	fields := map[string]string{}
+	for envName, defName := range RunFieldEnvMap {
+		fields[defName] = Env(envName, defName)
+	}
+	return fields
+}
+
+func LoadControlFieldsFromEnv() map[string]string {
+	fields := map[string]string{}
+	for envName, defName := range ControlFieldEnvMap {
+		fields[defName] = Env(envName, defName)
+	}
+	return fields
+}
+
 type httpClient struct {
-	c *http.Client
+	c       *http.Client
+	limiter *rateLimiter
+	// hold is the UnixNano time before which no request is sent, set when
+	// Feishu reports a rate limit.
+	hold atomic.Int64
 }
 
+var defaultClient = newHTTPClient()
+
 func newHTTPClient() *httpClient {
-	return &httpClient{c: &http.Client{Timeout: 30 * time.Second}}
+	return &httpClient{
+		c:       &http.Client{Timeout: defaultRequestTimeout},
+		limiter: newRateLimiter(QPSFromEnv()),
+	}
 }
 
-func RequestJSON(method, urlStr, token string, payload any, out any) error {
-	return newHTTPClient().RequestJSON(method, urlStr, token, payload, out)
+func RequestJSON(ctx context.Context, method, urlStr, token string, payload any, out any) error {
+	return defaultClient.RequestJSON(ctx, method, urlStr, token, payload, out)
 }
 
-func (h *httpClient) RequestJSON(method, urlStr, token string, payload any, out any) error {
-	var body io.Reader
+func (h *httpClient) RequestJSON(ctx context.Context, method, urlStr, token string, payload any, out any) error {
+	var body []byte
 	if payload != nil {
 		b, err := json.Marshal(payload)
 		if err != nil {
 			return err
 		}
-		body = bytes.NewReader(b)
+		body = b
 	}
-	req, err := http.NewRequest(method, urlStr, body)
+	raw, err := h.do(ctx, method, urlStr, token, "application/json; charset=utf-8", body)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if out == nil {
+		return nil
+	}
+	return DecodeJSON(raw, out)
+}
+
+// DecodeJSON is json.Unmarshal with numbers in untyped values (any,
+// map[string]any) decoded as json.Number, so record IDs, TaskIDs and epoch
+// milliseconds keep every digit instead of rounding through float64.
+func DecodeJSON(raw []byte, out any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid JSON: unexpected data after top-level value")
+	}
+	return nil
+}
+
+// do sends a request and returns the raw response body; non-2xx statuses
+// are reported as errors. A response refused by a frequency limit is sent
+// again after the wait it names (see retryAfter), up to
+// BITABLE_RATE_LIMIT_RETRIES times. A tenant token Feishu reports as
+// invalid is dropped from the cache and the request is sent once more with
+// a fresh one.
+func (h *httpClient) do(ctx context.Context, method, urlStr, token, contentType string, payload []byte) ([]byte, error) {
+	retries, maxWait := rateLimitRetries(), rateLimitMaxWait()
+	reauthed := false
+	for attempt := 1; ; attempt++ {
+		raw, resp, err := h.send(ctx, method, urlStr, token, contentType, payload)
+		if resp != nil && token != "" && !reauthed && tokenInvalid(raw) {
+			if fresh, ok := tenantTokens.renewed(ctx, token); ok {
+				if l := warnLogger.Load(); l != nil {
+					l.Warn("tenant token rejected; retrying with a fresh one", "endpoint", method+" "+apiEndpoint(resp.Request.URL.Path), "code", feishuCode(raw))
+				}
+				token, reauthed = fresh, true
+				attempt--
+				continue
+			}
+		}
+		code, limited := -1, false
+		if resp != nil && attempt <= retries {
+			code, limited = rateLimited(resp.StatusCode, raw)
+		}
+		wait, source, ok := time.Duration(0), "", false
+		if limited {
+			if wait, source, ok = retryAfter(resp.Header, time.Now()); !ok {
+				wait, source = rateLimitFallbackWait<<(attempt-1), "backoff"
+			}
+		}
+		if !limited || wait > maxWait {
+			if err != nil {
+				return nil, err
+			}
+			return raw, nil
+		}
+		h.holdUntil(time.Now().Add(wait))
+		logRateLimited(ctx, method, resp.Request.URL.Path, resp.StatusCode, code, attempt, wait, source)
+	}
+}
+
+// send makes one attempt; resp is nil when no response was received. The
+// body is returned with the error of a non-2xx status.
+func (h *httpClient) send(ctx context.Context, method, urlStr, token, contentType string, payload []byte) ([]byte, *http.Response, error) {
+	if err := h.waitHold(ctx); err != nil {
+		return nil, nil, err
+	}
+	if err := h.limiter.Wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	token = tenantTokens.refreshed(ctx, token)
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
+	if id := CorrelationID(); id != "" {
+		req.Header.Set(CorrelationHeader, id)
+	}
+	start := time.Now()
 	resp, err := h.c.Do(req)
 	if err != nil {
-		return err
+		err = explainTLSError(err)
+		emitAPICall(ctx, method, req.URL.Path, 0, nil, time.Since(start), err)
+		observeAPICall(method, req.URL.Path, 0, time.Since(start))
+		traceAPICall(ctx, req, nil, nil, start, err)
+		debugHTTP(ctx, req, payload, nil, nil, time.Since(start), err)
+		return nil, nil, withCorrelation(err)
 	}
 	defer resp.Body.Close()
 	raw, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	if resp.StatusCode/100 != 2 {
-		return fmt.Errorf("http %d: %s", resp.StatusCode, string(raw))
+	if err == nil && resp.StatusCode/100 != 2 {
+		err = &HTTPError{Status: resp.StatusCode, Body: string(raw)}
 	}
-	if out == nil {
-		return nil
+	emitAPICall(ctx, method, req.URL.Path, resp.StatusCode, raw, time.Since(start), err)
+	observeAPICall(method, req.URL.Path, resp.StatusCode, time.Since(start))
+	traceAPICall(ctx, req, resp, raw, start, err)
+	debugHTTP(ctx, req, payload, resp, raw, time.Since(start), err)
+	if err != nil {
+		return raw, resp, withCorrelation(err)
 	}
-	return json.Unmarshal(raw, out)
+	return raw, resp, nil
 }
 
 type FeishuResp struct {
@@ -204,26 +343,56 @@ type FeishuResp struct {
 	Msg  string `json:"msg"`
 }
 
+// HTTPError is a non-2xx response; Feishu usually still sends a JSON body
+// with its error code.
+type HTTPError struct {
+	Status int
+	Body   string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http %d: %s", e.Status, e.Body)
+}
+
+// FeishuCode returns the code and msg of the response body, if it has one.
+func (e *HTTPError) FeishuCode() (FeishuResp, bool) {
+	var resp struct {
+		Code *int   `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal([]byte(e.Body), &resp) != nil || resp.Code == nil {
+		return FeishuResp{}, false
+	}
+	return FeishuResp{Code: *resp.Code, Msg: resp.Msg}, true
+}
+
 type tenantTokenResp struct {
 	FeishuResp
 	TenantAccessToken string `json:"tenant_access_token"`
+	Expire            int    `json:"expire"`
+}
+
+// GetTenantAccessToken returns a cached token for appID when it is still
+// valid, otherwise requests a new one. See token.go for cache behavior.
+func GetTenantAccessToken(ctx context.Context, baseURL, appID, appSecret string) (string, error) {
+	return tenantTokens.get(ctx, baseURL, appID, appSecret)
 }
 
-func GetTenantAccessToken(baseURL, appID, appSecret string) (string, error) {
+func requestTenantAccessToken(ctx context.Context, baseURL, appID, appSecret string) (string, time.Duration, error) {
 	urlStr := strings.TrimRight(baseURL, "/") + "/open-apis/auth/v3/tenant_access_token/internal"
 	payload := map[string]string{"app_id": appID, "app_secret": appSecret}
 	var resp tenantTokenResp
-	if err := RequestJSON(http.MethodPost, urlStr, "", payload, &resp); err != nil {
-		return "", err
+	if err := RequestJSON(ctx, http.MethodPost, urlStr, "", payload, &resp); err != nil {
+		return "", 0, err
 	}
 	if resp.Code != 0 {
-		return "", fmt.Errorf("tenant token error: code=%d msg=%s", resp.Code, resp.Msg)
+		return "", 0, fmt.Errorf("tenant token error: code=%d msg=%s", resp.Code, resp.Msg)
 	}
 	tok := strings.TrimSpace(resp.TenantAccessToken)
 	if tok == "" {
-		return "", errors.New("tenant token missing in response")
+		return "", 0, errors.New("tenant token missing in response")
 	}
-	return tok, nil
+	return tok, time.Duration(resp.Expire) * time.Second, nil
 }
 
 type wikiNodeResp struct {
@@ -236,26 +405,37 @@ type wikiNodeResp struct {
 	} `json:"data"`
 }
 
-func ResolveWikiAppToken(baseURL, token, wikiToken string) (string, error) {
+func ResolveWikiAppToken(ctx context.Context, baseURL, token, wikiToken string) (string, error) {
+	return ResolveWikiObjToken(ctx, baseURL, token, wikiToken, "bitable")
+}
+
+// ResolveWikiObjToken returns the token of the document a wiki node wraps,
+// which must be of objType (bitable, sheet, ...). Resolutions are cached;
+// see wikiCache.
+func ResolveWikiObjToken(ctx context.Context, baseURL, token, wikiToken, objType string) (string, error) {
 	wikiToken = strings.TrimSpace(wikiToken)
 	if wikiToken == "" {
 		return "", errors.New("wiki token is empty")
 	}
+	if objToken, ok := wikiNodes.get(objType, wikiToken); ok {
+		return objToken, nil
+	}
 	urlStr := strings.TrimRight(baseURL, "/") + "/open-apis/wiki/v2/spaces/get_node?token=" + url.QueryEscape(wikiToken)
 	var resp wikiNodeResp
-	if err := RequestJSON(http.MethodGet, urlStr, token, nil, &resp); err != nil {
+	if err := RequestJSON(ctx, http.MethodGet, urlStr, token, nil, &resp); err != nil {
 		return "", err
 	}
 	if resp.Code != 0 {
 		return "", fmt.Errorf("wiki node error: code=%d msg=%s", resp.Code, resp.Msg)
 	}
-	if strings.TrimSpace(resp.Data.Node.ObjType) != "bitable" {
-		return "", fmt.Errorf("wiki node obj_type is %s, not bitable", resp.Data.Node.ObjType)
+	if strings.TrimSpace(resp.Data.Node.ObjType) != objType {
+		return "", fmt.Errorf("wiki node obj_type is %s, not %s", resp.Data.Node.ObjType, objType)
 	}
 	objToken := strings.TrimSpace(resp.Data.Node.ObjToken)
 	if objToken == "" {
 		return "", errors.New("wiki node obj_token missing")
 	}
+	wikiNodes.put(objType, wikiToken, objToken)
 	return objToken, nil
 }
 
@@ -286,6 +466,18 @@ func NormalizeBitableValue(v any) string {
 			return strconv.FormatInt(int64(x), 10)
 		}
 		return strconv.FormatFloat(x, 'f', -1, 64)
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return strconv.FormatInt(n, 10)
+		}
+		if !strings.ContainsAny(x.String(), ".eE") {
+			// Integers beyond int64 keep their digits.
+			return x.String()
+		}
+		if f, err := x.Float64(); err == nil {
+			return NormalizeBitableValue(f)
+		}
+		return x.String()
 	case []any:
 		// python behavior:
 		// - rich text arrays join with " "
@@ -301,6 +493,13 @@ func NormalizeBitableValue(v any) string {
 		}
 		return strings.Join(parts, ",")
 	case map[string]any:
+		// A hyperlink cell ({"link", "text"}) reads as its URL; rich-text
+		// segments carry a "type" and keep their text.
+		if link, ok := x["link"].(string); ok && x["type"] == nil {
+			if s := strings.TrimSpace(link); s != "" {
+				return s
+			}
+		}
 		for _, k := range []string{"value", "values", "elements", "content"} {
 			if nv, ok := x[k]; ok {
 				if s := strings.TrimSpace(NormalizeBitableValue(nv)); s != "" {
@@ -381,15 +580,8 @@ func joinRichText(items []any) string {
 }
 
 func FieldInt(fields map[string]any, name string) int {
-	raw := strings.TrimSpace(BitableValueToString(fields[name]))
-	if raw == "" {
-		return 0
-	}
-	f, err := strconv.ParseFloat(raw, 64)
-	if err != nil {
-		return 0
-	}
-	return int(f)
+	n, _ := CoerceInt(BitableValueToString(fields[name]))
+	return n
 }
 
 func CoerceInt(v any) (int, bool) {
@@ -405,13 +597,14 @@ func CoerceInt(v any) (int, bool) {
 		return int(x), true
 	case float64:
 		return int(x), true
+	case json.Number:
+		return CoerceInt(x.String())
 	case string:
-		s := strings.TrimSpace(x)
-		if s == "" {
-			return 0, false
+		if n, ok := ParseInteger(x); ok {
+			return int(n), true
 		}
-		f, err := strconv.ParseFloat(s, 64)
-		if err != nil {
+		f, ok := ParseNumber(x)
+		if !ok {
 			return 0, false
 		}
 		return int(f), true
@@ -420,6 +613,200 @@ func CoerceInt(v any) (int, bool) {
 	}
 }
 
+// ParseNumber parses a number as operators paste it from spreadsheets or as
+// formula fields format it: full-width digits and signs, thousands
+// separators ("1,234,567", "1 234", "1'234", "1.234.567", or "1.234,5" when
+// the comma comes last) and a trailing percent sign, which makes it a
+// percentage ("45%" is 0.45). Only decimal digits count: NaN, Inf and hex
+// floats are rejected, and so is ambiguous input such as "1,5" rather than
+// guessed.
+func ParseNumber(raw string) (float64, bool) {
+	num, ok := numberText(raw)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// ParseInteger is ParseNumber for whole numbers, parsed exactly: IDs and
+// epoch milliseconds above 2^53 do not round through float64. A percentage
+// is not a whole number.
+func ParseInteger(raw string) (int64, bool) {
+	num, ok := numberText(raw)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// numberText rewrites a localized number as plain decimal syntax, with a
+// percentage already divided by 100.
+func numberText(raw string) (string, bool) {
+	s, percent := strings.CutSuffix(localizedNumber(raw), "%")
+	num, ok := groupedNumber(strings.TrimSpace(s))
+	if !ok || !percent {
+		return num, ok
+	}
+	return percentOf(num)
+}
+
+// localizedNumber maps full-width digits, signs and marks to ASCII.
+func localizedNumber(raw string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９':
+			return '0' + (r - '０')
+		case r == '，':
+			return ','
+		case r == '．':
+			return '.'
+		case r == '－' || r == '−':
+			return '-'
+		case r == '＋':
+			return '+'
+		case r == '％':
+			return '%'
+		}
+		return r
+	}, strings.TrimSpace(raw))
+}
+
+// groupedNumber removes the thousands separators of s.
+func groupedNumber(s string) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	sep, ok := groupSeparator(s)
+	if !ok {
+		return "", false
+	}
+	if sep == "" && plainDecimal(s) {
+		return s, true
+	}
+
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+	comma, dot := strings.LastIndexByte(s, ','), strings.LastIndexByte(s, '.')
+	group, decimal := ",", "."
+	if sep != "" {
+		// Spaces, apostrophes and underscores only group digits, so the
+		// decimal mark is whichever of ',' and '.' appears, at most once.
+		if strings.Count(s, ",")+strings.Count(s, ".") > 1 {
+			return "", false
+		}
+		group = sep
+		if comma >= 0 {
+			decimal = ","
+		}
+	} else {
+		switch {
+		case comma >= 0 && dot > comma:
+		case dot >= 0 && comma > dot:
+			group, decimal = ".", ","
+		case comma >= 0:
+		case strings.Count(s, ".") > 1:
+			group = "."
+		default:
+			return "", false
+		}
+	}
+	intPart, frac, hasFrac := strings.Cut(s, decimal)
+	if group == "." && decimal == "." {
+		intPart, frac, hasFrac = s, "", false
+	}
+	if !digitGroups(intPart, group) || (hasFrac && !onlyDigits(frac)) {
+		return "", false
+	}
+	num := sign + strings.ReplaceAll(intPart, group, "")
+	if hasFrac {
+		num += "." + frac
+	}
+	return num, true
+}
+
+// plainDecimal reports whether s is a decimal number without grouping: an
+// optional sign, digits with at most one '.', and an optional exponent.
+// Unlike strconv.ParseFloat it refuses NaN, Inf, hex floats and
+// underscores.
+func plainDecimal(s string) bool {
+	if s[0] == '+' || s[0] == '-' {
+		s = s[1:]
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp := s[i+1:]
+		if exp != "" && (exp[0] == '+' || exp[0] == '-') {
+			exp = exp[1:]
+		}
+		if !onlyDigits(exp) {
+			return false
+		}
+		s = s[:i]
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	return (intPart != "" || frac != "") && (intPart == "" || onlyDigits(intPart)) && (frac == "" || onlyDigits(frac))
+}
+
+// percentOf divides the decimal num by 100 by moving its point, so "45"
+// becomes "0.45" without a float rounding step.
+func percentOf(num string) (string, bool) {
+	if strings.ContainsAny(num, "eE") {
+		return "", false
+	}
+	sign := ""
+	if num[0] == '-' || num[0] == '+' {
+		sign, num = num[:1], num[1:]
+	}
+	intPart, frac, _ := strings.Cut(num, ".")
+	if len(intPart) < 3 {
+		intPart = strings.Repeat("0", 3-len(intPart)) + intPart
+	}
+	cut := len(intPart) - 2
+	return sign + intPart[:cut] + "." + intPart[cut:] + frac, true
+}
+
+// groupSeparators never mark a decimal: "1 234", "1'234", "1_234" and the
+// no-break and thin spaces of formatted output.
+var groupSeparators = []string{" ", "'", "’", "_", "\u00a0", "\u202f", "\u2009", "\u3000"}
+
+// groupSeparator returns the one of groupSeparators found in s; ok is false
+// when s mixes several.
+func groupSeparator(s string) (sep string, ok bool) {
+	for _, g := range groupSeparators {
+		if strings.Contains(s, g) {
+			if sep != "" {
+				return sep, false
+			}
+			sep = g
+		}
+	}
+	return sep, true
+}
+
+// digitGroups reports whether s is digits grouped in threes by sep, with a
+// leading group of one to three digits ("1,234,567").
+func digitGroups(s, sep string) bool {
+	parts := strings.Split(s, sep)
+	if len(parts) < 2 || len(parts[0]) == 0 || len(parts[0]) > 3 || !onlyDigits(parts[0]) {
+		return false
+	}
+	for _, p := range parts[1:] {
+		if len(p) != 3 || !onlyDigits(p) {
+			return false
+		}
+	}
+	return true
+}
+
 func ParseDatetime(raw string) (time.Time, bool) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -461,6 +848,15 @@ func CoerceMillis(v any) (int64, bool) {
 		return normalizeEpochMillis(x), true
 	case float64:
 		return normalizeEpochMillis(int64(x)), true
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return normalizeEpochMillis(n), true
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return normalizeEpochMillis(int64(f)), true
 	case string:
 		s := strings.TrimSpace(x)
 		if s == "" {
@@ -503,6 +899,11 @@ func CoerceDatePayload(v any) (any, bool) {
 		return normalizeEpochMillis(x), true
 	case float64:
 		return normalizeEpochMillis(int64(x)), true
+	case json.Number:
+		if ms, ok := CoerceMillis(x); ok {
+			return ms, true
+		}
+		return nil, false
 	case string:
 		s := strings.TrimSpace(x)
 		if s == "" {
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// profileEnvKeys maps profile keys to the env vars they provide.
+var profileEnvKeys = map[string]string{
+	"app_id":     "FEISHU_APP_ID",
+	"app_secret": "FEISHU_APP_SECRET",
+	"base_url":   "FEISHU_BASE_URL",
+	"task_url":   "TASK_BITABLE_URL",
+}
+
+// dedupeEnvKeys maps keys of a profile's dedupe block to env vars.
+var dedupeEnvKeys = map[string]string{
+	"fields":    "TASK_DEDUPE_FIELDS",
+	"normalize": "TASK_DEDUPE_NORMALIZE",
+	"hash":      "TASK_DEDUPE_HASH",
+}
+
+// DefaultConfigPath returns $XDG_CONFIG_HOME/bitable-task/config.yaml,
+// falling back to ~/.config/bitable-task/config.yaml.
+func DefaultConfigPath() string {
+	if p := Env("BITABLE_TASK_CONFIG", ""); p != "" {
+		return p
+	}
+	dir := Env("XDG_CONFIG_HOME", "")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "bitable-task", "config.yaml")
+}
+
+// ApplyProfile loads the named profile from the config file and exports its
+// settings as env vars that are not already set, so the environment always
+// takes precedence. An empty name selects BITABLE_PROFILE, then
+// default_profile. A missing config file is only an error when a profile was
+// requested explicitly.
+//
+// Config layout:
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    app_id: cli_xxx
+//	    app_secret: xxx
+//	    base_url: https://open.feishu.cn
+//	    task_url: https://.../base/APP?table=TBL
+//	    fields:
+//	      Status: 状态
+//	    dedupe:
+//	      fields: URL,UserID
+//	      normalize: trim,URL:url
+//	      hash: sha256
+func ApplyProfile(path, name string) (string, error) {
+	explicit := strings.TrimSpace(name) != ""
+	if !explicit {
+		name = Env("BITABLE_PROFILE", "")
+		explicit = name != ""
+	}
+	if path == "" {
+		path = DefaultConfigPath()
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return "", nil
+		}
+		return "", fmt.Errorf("read config %s: %w", path, err)
+	}
+	cfg, err := ParseSimpleYAML(raw)
+	if err != nil {
+		return "", fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if name == "" {
+		name = YAMLString(cfg, "default_profile")
+	}
+	if name == "" {
+		return "", nil
+	}
+	profiles := YAMLMap(cfg, "profiles")
+	profile := YAMLMap(profiles, name)
+	if profile == nil {
+		names := make([]string, 0, len(profiles))
+		for k := range profiles {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("profile %q not found in %s (available: %s)", name, path, strings.Join(names, ", "))
+	}
+
+	for key, envName := range profileEnvKeys {
+		setEnvDefault(envName, YAMLString(profile, key))
+	}
+	dedupe := YAMLMap(profile, "dedupe")
+	for key, envName := range dedupeEnvKeys {
+		setEnvDefault(envName, YAMLString(dedupe, key))
+	}
+	fieldEnv := map[string]string{}
+	for envName, logical := range TaskFieldEnvMap {
+		fieldEnv[logical] = envName
+	}
+	for logical, v := range YAMLMap(profile, "fields") {
+		envName, ok := fieldEnv[logical]
+		if !ok {
+			return "", fmt.Errorf("profile %q: unknown field %q", name, logical)
+		}
+		col, _ := v.(string)
+		setEnvDefault(envName, col)
+	}
+	return name, nil
+}
+
+// setEnvDefault sets name=value unless name already has a non-empty value.
+func setEnvDefault(name, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" || Env(name, "") != "" {
+		return
+	}
+	_ = os.Setenv(name, value)
+}
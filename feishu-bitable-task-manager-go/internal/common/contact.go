@@ -0,0 +1,152 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// contactBatchSize is the most emails (and mobiles) batch_get_id accepts.
+const contactBatchSize = 50
+
+type batchGetIDResp struct {
+	FeishuResp
+	Data struct {
+		UserList []struct {
+			UserID string `json:"user_id"`
+			Email  string `json:"email"`
+			Mobile string `json:"mobile"`
+		} `json:"user_list"`
+	} `json:"data"`
+}
+
+// defaultMobileCountryCode is assumed for mobiles written without one;
+// batch_get_id reads those as mainland China numbers too.
+const defaultMobileCountryCode = "86"
+
+// NormalizeEmail is the form emails are looked up and keyed by: trimmed
+// and lower-cased, since Feishu matches them case-insensitively.
+func NormalizeEmail(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// NormalizeMobile is the form mobiles are looked up and keyed by: "+",
+// the country code, then the digits. Spaces, dashes and parentheses are
+// dropped, a leading 00 counts as +, and a number without a country code
+// gets +86.
+func NormalizeMobile(s string) string {
+	s = strings.TrimSpace(s)
+	intl := strings.HasPrefix(s, "+")
+	digits := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, byte(r))
+		}
+	}
+	d := string(digits)
+	switch {
+	case d == "":
+		return ""
+	case intl:
+	case strings.HasPrefix(d, "00"):
+		d = d[2:]
+	default:
+		d = defaultMobileCountryCode + d
+	}
+	return "+" + d
+}
+
+// mobileRequestForm is how a normalized mobile is sent: mainland numbers
+// bare, others with their + country code, as batch_get_id documents.
+func mobileRequestForm(normalized string) string {
+	if rest, ok := strings.CutPrefix(normalized, "+"+defaultMobileCountryCode); ok {
+		return rest
+	}
+	return normalized
+}
+
+// LookupOpenIDs resolves emails and mobile numbers to open_ids with the
+// contact API. The result is keyed by NormalizeEmail and NormalizeMobile of
+// the entries, whatever form Feishu echoes them in; entries Feishu does
+// not know are left out.
+func LookupOpenIDs(ctx context.Context, baseURL, token string, emails, mobiles []string) (map[string]string, error) {
+	out := map[string]string{}
+	emails = normalizedUnique(emails, NormalizeEmail)
+	mobiles = normalizedUnique(mobiles, NormalizeMobile)
+	urlStr := strings.TrimRight(baseURL, "/") + "/open-apis/contact/v3/users/batch_get_id?user_id_type=open_id"
+	for len(emails) > 0 || len(mobiles) > 0 {
+		e, m := emails, mobiles
+		if len(e) > contactBatchSize {
+			e = e[:contactBatchSize]
+		}
+		if len(m) > contactBatchSize {
+			m = m[:contactBatchSize]
+		}
+		emails, mobiles = emails[len(e):], mobiles[len(m):]
+		sent := make([]string, 0, len(m))
+		for _, v := range m {
+			sent = append(sent, mobileRequestForm(v))
+		}
+		var resp batchGetIDResp
+		body := map[string]any{"emails": e, "mobiles": sent}
+		if err := RequestJSON(ctx, http.MethodPost, urlStr, token, body, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("lookup users failed: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+		for _, u := range resp.Data.UserList {
+			if u.UserID == "" {
+				continue
+			}
+			if k := NormalizeEmail(u.Email); k != "" {
+				out[k] = u.UserID
+			}
+			if k := NormalizeMobile(u.Mobile); k != "" {
+				out[k] = u.UserID
+			}
+		}
+	}
+	return out, nil
+}
+
+// normalizedUnique applies norm to values, dropping empty results and
+// repeats.
+func normalizedUnique(values []string, norm func(string) string) []string {
+	out := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, v := range values {
+		if k := norm(v); k != "" && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// PersonValue reads a Person cell ([{"id": "ou_...", "name": ...}, ...])
+// into its ids and names. ok is false for any other value.
+func PersonValue(v any) (ids, names []string, ok bool) {
+	items, isList := v.([]any)
+	if !isList || len(items) == 0 {
+		return nil, nil, false
+	}
+	for _, it := range items {
+		m, isMap := it.(map[string]any)
+		if !isMap {
+			return nil, nil, false
+		}
+		id, _ := m["id"].(string)
+		if strings.TrimSpace(id) == "" {
+			return nil, nil, false
+		}
+		name, _ := m["name"].(string)
+		if name == "" {
+			name, _ = m["en_name"].(string)
+		}
+		ids = append(ids, strings.TrimSpace(id))
+		names = append(names, strings.TrimSpace(name))
+	}
+	return ids, names, true
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// CorrelationHeader carries the command's correlation ID on every Feishu
+// request, so proxy and gateway logs can be joined with the CLI's own.
+const CorrelationHeader = "X-Request-Id"
+
+var correlationID atomic.Value
+
+// SetCorrelationID sets the ID sent with every request of this process.
+func SetCorrelationID(id string) {
+	correlationID.Store(id)
+}
+
+// CorrelationID returns the ID set by SetCorrelationID, or "".
+func CorrelationID() string {
+	id, _ := correlationID.Load().(string)
+	return id
+}
+
+// withCorrelation annotates a request error with the correlation ID.
+func withCorrelation(err error) error {
+	if id := CorrelationID(); err != nil && id != "" {
+		return fmt.Errorf("%w (correlation_id=%s)", err, id)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the tz database so TASK_TIMEZONE works on minimal images.
+	_ "time/tzdata"
+)
+
+// How a Date column stores values, derived from the table schema.
+const (
+	DateKindUnknown  = ""
+	DateKindText     = "text"
+	DateKindDate     = "date"
+	DateKindDateTime = "datetime"
+)
+
+// DateKindOf classifies a field: text columns get "YYYY-MM-DD" strings,
+// DateTime columns whose formatter has no time part are date-only.
+func DateKindOf(f FieldInfo) string {
+	switch f.Type {
+	case FieldTypeText:
+		return DateKindText
+	case FieldTypeDateTime:
+		formatter, _ := f.Property["date_formatter"].(string)
+		if formatter != "" && !strings.Contains(formatter, "HH") && !strings.Contains(formatter, "hh") {
+			return DateKindDate
+		}
+		return DateKindDateTime
+	default:
+		return DateKindUnknown
+	}
+}
+
+// TaskTimezone returns TASK_TIMEZONE (IANA name) or the local zone.
+func TaskTimezone() *time.Location {
+	name := Env("TASK_TIMEZONE", "")
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// CoerceDatePayloadFor converts v into the value a Date column of the given
+// kind expects, interpreting calendar dates in loc:
+//   - text: "YYYY-MM-DD" of the instant in loc (presets pass through)
+//   - date: epoch ms of midnight in loc of that calendar day
+//   - datetime: epoch ms; bare dates mean midnight in loc
+//
+// Unknown kinds fall back to CoerceDatePayload.
+func CoerceDatePayloadFor(v any, kind string, loc *time.Location) (any, bool) {
+	if kind == DateKindUnknown {
+		return CoerceDatePayload(v)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	t, isDay, ok := parseDateInput(v, loc)
+	if !ok {
+		if s, isStr := v.(string); isStr && strings.TrimSpace(s) != "" && kind == DateKindText {
+			return strings.TrimSpace(s), true
+		}
+		return CoerceDatePayload(v)
+	}
+	switch kind {
+	case DateKindText:
+		return t.In(loc).Format("2006-01-02"), true
+	case DateKindDate:
+		y, m, d := t.In(loc).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, loc).UnixMilli(), true
+	default:
+		if isDay {
+			y, m, d := t.Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, loc).UnixMilli(), true
+		}
+		return t.UnixMilli(), true
+	}
+}
+
+// parseDateInput parses epoch seconds/ms, "now", ISO timestamps and bare
+// dates. isDay reports a bare calendar date, which is read in loc.
+func parseDateInput(v any, loc *time.Location) (time.Time, bool, bool) {
+	switch x := v.(type) {
+	case int:
+		return time.UnixMilli(normalizeEpochMillis(int64(x))), false, true
+	case int64:
+		return time.UnixMilli(normalizeEpochMillis(x)), false, true
+	case float64:
+		return time.UnixMilli(normalizeEpochMillis(int64(x))), false, true
+	case json.Number:
+		ms, ok := CoerceMillis(x)
+		return time.UnixMilli(ms), false, ok
+	case string:
+		s := strings.TrimSpace(x)
+		if s == "" {
+			return time.Time{}, false, false
+		}
+		if strings.EqualFold(s, "now") {
+			return time.Now(), false, true
+		}
+		if onlyDigits(s) {
+			n, _ := strconv.ParseInt(s, 10, 64)
+			return time.UnixMilli(normalizeEpochMillis(n)), false, true
+		}
+		for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+			if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return t, true, true
+			}
+		}
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+			if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return t, false, true
+			}
+		}
+		if t, ok := ParseDatetime(s); ok {
+			return t, false, true
+		}
+	}
+	return time.Time{}, false, false
+}
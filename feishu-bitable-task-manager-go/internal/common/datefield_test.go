@@ -0,0 +1,94 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestCoerceDatePayloadFor(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	newYork := mustLoadLocation(t, "America/New_York")
+	cases := []struct {
+		name string
+		loc  *time.Location
+		kind string
+		in   any
+		want any
+	}{
+		// Asia/Shanghai is UTC+8 all year.
+		{"shanghai text from instant after local midnight", shanghai, DateKindText, int64(1772901000000), "2026-03-08"},
+		{"shanghai text from date", shanghai, DateKindText, "2026-03-08", "2026-03-08"},
+		{"shanghai text passes other strings through", shanghai, DateKindText, "下周一", "下周一"},
+		{"shanghai date from date", shanghai, DateKindDate, "2026-03-08", int64(1772899200000)},
+		{"shanghai date truncates instant to local day", shanghai, DateKindDate, int64(1772933400000), int64(1772899200000)},
+		{"shanghai datetime bare date is local midnight", shanghai, DateKindDateTime, "2026-03-08", int64(1772899200000)},
+		{"shanghai datetime local wall time", shanghai, DateKindDateTime, "2026-03-08 09:30:00", int64(1772933400000)},
+		{"shanghai datetime with offset", shanghai, DateKindDateTime, "2026-03-08T10:00:00+08:00", int64(1772935200000)},
+		{"shanghai datetime epoch seconds", shanghai, DateKindDateTime, int64(1772933400), int64(1772933400000)},
+
+		// 2026-03-08: New York springs forward from EST (-5) to EDT (-4).
+		{"new york spring date is EST midnight", newYork, DateKindDate, "2026-03-08", int64(1772946000000)},
+		{"new york spring late evening stays on the day", newYork, DateKindDate, int64(1773028740000), int64(1772946000000)},
+		{"new york spring text late evening", newYork, DateKindText, int64(1773028740000), "2026-03-08"},
+		{"new york spring wall time after the gap", newYork, DateKindDateTime, "2026-03-08 03:30:00", int64(1772955000000)},
+		{"new york day after spring is EDT midnight", newYork, DateKindDateTime, "2026-03-09", int64(1773028800000)},
+
+		// 2026-11-01: New York falls back from EDT (-4) to EST (-5).
+		{"new york fall date is EDT midnight", newYork, DateKindDate, "2026-11-01", int64(1793505600000)},
+		{"new york fall wall time after the repeat", newYork, DateKindDateTime, "2026-11-01 12:00:00", int64(1793552400000)},
+		{"new york fall text late evening", newYork, DateKindText, int64(1793593800000), "2026-11-01"},
+		{"new york fall date late evening", newYork, DateKindDate, int64(1793593800000), int64(1793505600000)},
+		{"new york day after fall is EST midnight", newYork, DateKindDateTime, "2026-11-02", int64(1793595600000)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := CoerceDatePayloadFor(tc.in, tc.kind, tc.loc)
+			if !ok {
+				t.Fatalf("CoerceDatePayloadFor(%v, %q) not ok", tc.in, tc.kind)
+			}
+			if got != tc.want {
+				t.Fatalf("CoerceDatePayloadFor(%v, %q) = %#v, want %#v", tc.in, tc.kind, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDateInput(t *testing.T) {
+	shanghai := mustLoadLocation(t, "Asia/Shanghai")
+	cases := []struct {
+		in    any
+		ok    bool
+		isDay bool
+		ms    int64
+	}{
+		{"2026-03-08", true, true, 1772899200000},
+		{"2026/03/08", true, true, 1772899200000},
+		{"2026-03-08 09:30", true, false, 1772933400000},
+		{"2026-03-08T09:30:00", true, false, 1772933400000},
+		{"1772933400000", true, false, 1772933400000},
+		{"", false, false, 0},
+		{"not a date", false, false, 0},
+	}
+	for _, tc := range cases {
+		got, isDay, ok := parseDateInput(tc.in, shanghai)
+		if ok != tc.ok || isDay != tc.isDay {
+			t.Errorf("parseDateInput(%q) ok=%v isDay=%v, want ok=%v isDay=%v", tc.in, ok, isDay, tc.ok, tc.isDay)
+			continue
+		}
+		if ok && got.UnixMilli() != tc.ms {
+			t.Errorf("parseDateInput(%q) = %d, want %d", tc.in, got.UnixMilli(), tc.ms)
+		}
+	}
+	if _, isDay, ok := parseDateInput("now", shanghai); !ok || isDay {
+		t.Errorf("parseDateInput(now) ok=%v isDay=%v, want an instant", ok, isDay)
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// docxBlockTypeText is the docx block type of a plain text paragraph.
+const docxBlockTypeText = 2
+
+// docxMaxChildren is how many blocks one create-children request accepts.
+const docxMaxChildren = 50
+
+// DocRef points at a block of a Feishu document.
+type DocRef struct {
+	RawURL     string
+	DocumentID string
+	WikiToken  string
+	// BlockID is the URL fragment, if any.
+	BlockID string
+}
+
+// ParseDocURL reads a document link, e.g.
+// https://example.feishu.cn/docx/doxcnXXX#doxcnBLOCK, or a wiki link to one
+// (resolve WikiToken with ResolveWikiObjToken).
+func ParseDocURL(raw string) (DocRef, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DocRef{}, errors.New("document url is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return DocRef{}, err
+	}
+	if u.Scheme == "" {
+		return DocRef{}, errors.New("document url missing scheme")
+	}
+	ref := DocRef{RawURL: raw, BlockID: strings.TrimPrefix(u.Fragment, "share-")}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i < len(segments)-1; i++ {
+		switch segments[i] {
+		case "docx":
+			ref.DocumentID = segments[i+1]
+		case "wiki":
+			ref.WikiToken = segments[i+1]
+		}
+	}
+	if ref.DocumentID == "" && ref.WikiToken == "" {
+		return DocRef{}, errors.New("document url must contain /docx/<id> or /wiki/<token>")
+	}
+	return ref, nil
+}
+
+type docxBlockResp struct {
+	FeishuResp
+	Data struct {
+		Block struct {
+			BlockID  string   `json:"block_id"`
+			Children []string `json:"children"`
+		} `json:"block"`
+	} `json:"data"`
+}
+
+// ReplaceBlockText replaces the children of a container block (a callout,
+// quote or grid cell) with one text paragraph per line. The new paragraphs
+// are inserted before the old children, which are deleted only once every
+// line is in, so a failure part way leaves the old text in place. The
+// document root is refused: replacing its children would wipe the page.
+func ReplaceBlockText(ctx context.Context, baseURL, token, documentID, blockID string, lines []string) error {
+	if strings.TrimSpace(blockID) == "" || blockID == documentID {
+		return errors.New("refusing to replace the whole document; name a container block such as a callout")
+	}
+	blockURL := fmt.Sprintf("%s/open-apis/docx/v1/documents/%s/blocks/%s",
+		strings.TrimRight(baseURL, "/"), url.PathEscape(documentID), url.PathEscape(blockID),
+	)
+	var block docxBlockResp
+	if err := RequestJSON(ctx, http.MethodGet, blockURL, token, nil, &block); err != nil {
+		return err
+	}
+	if block.Code != 0 {
+		return fmt.Errorf("get block failed: code=%d msg=%s", block.Code, block.Msg)
+	}
+	for i := 0; i < len(lines); i += docxMaxChildren {
+		j := min(i+docxMaxChildren, len(lines))
+		children := make([]map[string]any, 0, j-i)
+		for _, line := range lines[i:j] {
+			children = append(children, map[string]any{
+				"block_type": docxBlockTypeText,
+				"text": map[string]any{
+					"elements": []map[string]any{{"text_run": map[string]any{"content": line}}},
+				},
+			})
+		}
+		var resp FeishuResp
+		body := map[string]any{"index": i, "children": children}
+		if err := RequestJSON(ctx, http.MethodPost, blockURL+"/children", token, body, &resp); err != nil {
+			return err
+		}
+		if resp.Code != 0 {
+			return fmt.Errorf("create block children failed: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+	}
+	// The old children now follow the new lines.
+	if n := len(block.Data.Block.Children); n > 0 {
+		var resp FeishuResp
+		body := map[string]any{"start_index": len(lines), "end_index": len(lines) + n}
+		if err := RequestJSON(ctx, http.MethodDelete, blockURL+"/children/batch_delete", token, body, &resp); err != nil {
+			return err
+		}
+		if resp.Code != 0 {
+			return fmt.Errorf("delete block children failed: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dotenvPrefixes limits which variables a .env file may set.
+var dotenvPrefixes = []string{"FEISHU_", "TASK_", "BITABLE_", "RUN_FIELD_"}
+
+// LoadDotEnv reads KEY=VALUE lines from path and sets FEISHU_*, TASK_* and
+// BITABLE_* variables that are not already set. When required is false a
+// missing file is ignored. It returns the names of the variables it set.
+func LoadDotEnv(path string, required bool) ([]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil, nil
+		}
+		return nil, err
+	}
+	set := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return set, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		val, err = parseDotEnvValue(strings.TrimSpace(val))
+		if err != nil {
+			return set, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if !hasDotEnvPrefix(key) {
+			continue
+		}
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		if err := os.Setenv(key, val); err != nil {
+			return set, err
+		}
+		set = append(set, key)
+	}
+	return set, scanner.Err()
+}
+
+func hasDotEnvPrefix(key string) bool {
+	for _, p := range dotenvPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDotEnvValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, "\""):
+		end := strings.LastIndex(v, "\"")
+		if end == 0 {
+			return "", errors.New("unterminated double-quoted value")
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.LastIndex(v, "'")
+		if end == 0 {
+			return "", errors.New("unterminated single-quoted value")
+		}
+		return v[1:end], nil
+	default:
+		if idx := strings.Index(v, " #"); idx >= 0 {
+			v = v[:idx]
+		}
+		return strings.TrimSpace(v), nil
+	}
+}
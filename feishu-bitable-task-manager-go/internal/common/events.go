@@ -0,0 +1,149 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Machine-readable log events. Each event is one log line whose message and
+// "event" attribute are the event name, followed by that event's fixed keys
+// (always present, zero-valued when unknown). Keys are only ever added, never
+// renamed, so log pipelines can build metrics without parsing messages.
+const (
+	// api_call: method, endpoint, status, code, duration_ms, error
+	EventAPICall = "api_call"
+	// page_fetched: table_id, page, items, has_more, duration_ms
+	EventPageFetched = "page_fetched"
+	// record_updated: table_id, record_id, fields
+	EventRecordUpdated = "record_updated"
+	// claim_conflict: table_id, record_id, device, status, owner
+	EventClaimConflict = "claim_conflict"
+	// retry: op, attempt, wait_ms, error
+	EventRetry = "retry"
+	// rate_limited: method, endpoint, status, code, attempt, wait_ms, source
+	EventRateLimited = "rate_limited"
+)
+
+var eventLogger atomic.Pointer[slog.Logger]
+
+// SetEventLogger enables event emission through l; nil disables it.
+func SetEventLogger(l *slog.Logger) {
+	eventLogger.Store(l)
+}
+
+// EventsEnabled reports whether events are being emitted, so callers can
+// skip work only needed to build them.
+func EventsEnabled() bool {
+	return eventLogger.Load() != nil
+}
+
+func emitEvent(ctx context.Context, name string, attrs ...slog.Attr) {
+	l := eventLogger.Load()
+	if l == nil {
+		return
+	}
+	l.LogAttrs(ctx, slog.LevelInfo, name, append([]slog.Attr{slog.String("event", name)}, attrs...)...)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// EmitPageFetched records one page of a records search.
+func EmitPageFetched(ctx context.Context, tableID string, page, items int, hasMore bool, elapsed time.Duration) {
+	CountTasks(TasksFetched, items)
+	emitEvent(ctx, EventPageFetched,
+		slog.String("table_id", tableID),
+		slog.Int("page", page),
+		slog.Int("items", items),
+		slog.Bool("has_more", hasMore),
+		slog.Int64("duration_ms", elapsed.Milliseconds()),
+	)
+}
+
+// EmitRecordUpdated records a successful write of fields columns to a record.
+func EmitRecordUpdated(ctx context.Context, tableID, recordID string, fields int) {
+	CountTasks(TasksUpdated, 1)
+	emitEvent(ctx, EventRecordUpdated,
+		slog.String("table_id", tableID),
+		slog.String("record_id", recordID),
+		slog.Int("fields", fields),
+	)
+}
+
+// EmitClaimConflict records a claim lost to another worker: status and owner
+// are what the re-read found on the record.
+func EmitClaimConflict(ctx context.Context, tableID, recordID, device, status, owner string) {
+	emitEvent(ctx, EventClaimConflict,
+		slog.String("table_id", tableID),
+		slog.String("record_id", recordID),
+		slog.String("device", device),
+		slog.String("status", status),
+		slog.String("owner", owner),
+	)
+}
+
+// EmitRetry records a failed operation that will be tried again after wait.
+func EmitRetry(ctx context.Context, op string, attempt int, wait time.Duration, err error) {
+	observeRetry(op)
+	emitEvent(ctx, EventRetry,
+		slog.String("op", op),
+		slog.Int("attempt", attempt),
+		slog.Int64("wait_ms", wait.Milliseconds()),
+		slog.String("error", errString(err)),
+	)
+}
+
+// emitAPICall records one HTTP request. code is the Feishu response code
+// when the body carries one, else -1.
+func emitAPICall(ctx context.Context, method, path string, status int, raw []byte, elapsed time.Duration, err error) {
+	if !EventsEnabled() {
+		return
+	}
+	emitEvent(ctx, EventAPICall,
+		slog.String("method", method),
+		slog.String("endpoint", apiEndpoint(path)),
+		slog.Int("status", status),
+		slog.Int("code", feishuCode(raw)),
+		slog.Int64("duration_ms", elapsed.Milliseconds()),
+		slog.String("error", errString(err)),
+	)
+}
+
+// feishuCode returns the code of a Feishu response body, or -1 when it has
+// none.
+func feishuCode(raw []byte) int {
+	var resp struct {
+		Code *int `json:"code"`
+	}
+	if json.Unmarshal(raw, &resp) == nil && resp.Code != nil {
+		return *resp.Code
+	}
+	return -1
+}
+
+// apiIDParents are path segments followed by an identifier.
+var apiIDParents = map[string]bool{"apps": true, "tables": true, "records": true, "fields": true, "views": true, "medias": true}
+
+// apiVerbs are segments that take an identifier's place but name an action.
+var apiVerbs = map[string]bool{"search": true, "batch_create": true, "batch_update": true, "batch_delete": true, "batch_get": true, "upload_all": true, "batch_get_tmp_download_url": true}
+
+// apiEndpoint replaces the identifiers in an Open API path with ":id" so the
+// endpoint has low cardinality, e.g.
+// /open-apis/bitable/v1/apps/:id/tables/:id/records/search.
+func apiEndpoint(path string) string {
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		if apiIDParents[parts[i-1]] && parts[i] != "" && !apiVerbs[parts[i]] {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
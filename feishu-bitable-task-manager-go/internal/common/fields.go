@@ -0,0 +1,214 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Bitable field type codes (subset used by this tool).
+const (
+	FieldTypeText         = 1
+	FieldTypeNumber       = 2
+	FieldTypeSingleSelect = 3
+	FieldTypeMultiSelect  = 4
+	FieldTypeDateTime     = 5
+	FieldTypeCheckbox     = 7
+	FieldTypeUser         = 11
+	FieldTypePhone        = 13
+	FieldTypeURL          = 15
+	FieldTypeAttachment   = 17
+	FieldTypeLink         = 18
+	FieldTypeFormula      = 20
+	FieldTypeCreatedTime  = 1001
+	FieldTypeModifiedTime = 1002
+	FieldTypeAutoNumber   = 1005
+)
+
+var fieldTypeNames = map[int]string{
+	FieldTypeText:         "Text",
+	FieldTypeNumber:       "Number",
+	FieldTypeSingleSelect: "SingleSelect",
+	FieldTypeMultiSelect:  "MultiSelect",
+	FieldTypeDateTime:     "DateTime",
+	FieldTypeCheckbox:     "Checkbox",
+	FieldTypeUser:         "User",
+	FieldTypePhone:        "Phone",
+	FieldTypeURL:          "Url",
+	FieldTypeAttachment:   "Attachment",
+	FieldTypeLink:         "SingleLink",
+	19:                    "Lookup",
+	FieldTypeFormula:      "Formula",
+	21:                    "DuplexLink",
+	22:                    "Location",
+	23:                    "GroupChat",
+	FieldTypeCreatedTime:  "CreatedTime",
+	FieldTypeModifiedTime: "ModifiedTime",
+	1003:                  "CreatedUser",
+	1004:                  "ModifiedUser",
+	FieldTypeAutoNumber:   "AutoNumber",
+}
+
+// TypeName returns the UI type name of the field, falling back to a name
+// derived from the numeric type code.
+func (f FieldInfo) TypeName() string {
+	if f.UIType != "" {
+		return f.UIType
+	}
+	if name, ok := fieldTypeNames[f.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%d)", f.Type)
+}
+
+// SelectOptions returns the option names of a single/multi select field.
+func (f FieldInfo) SelectOptions() []string {
+	raw, _ := f.Property["options"].([]any)
+	out := make([]string, 0, len(raw))
+	for _, o := range raw {
+		if m, ok := o.(map[string]any); ok {
+			if name, ok := m["name"].(string); ok {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// FieldInfo is one column returned by the field list API.
+type FieldInfo struct {
+	FieldID   string         `json:"field_id"`
+	FieldName string         `json:"field_name"`
+	Type      int            `json:"type"`
+	UIType    string         `json:"ui_type,omitempty"`
+	IsPrimary bool           `json:"is_primary,omitempty"`
+	Property  map[string]any `json:"property,omitempty"`
+}
+
+type listFieldsResp struct {
+	FeishuResp
+	Data struct {
+		Items     []FieldInfo `json:"items"`
+		HasMore   bool        `json:"has_more"`
+		PageToken string      `json:"page_token"`
+	} `json:"data"`
+}
+
+// ListFields returns every field of a table, following pagination.
+func ListFields(ctx context.Context, baseURL, token, appToken, tableID string) ([]FieldInfo, error) {
+	out := []FieldInfo{}
+	pageToken := ""
+	for {
+		q := url.Values{}
+		q.Set("page_size", "100")
+		if pageToken != "" {
+			q.Set("page_token", pageToken)
+		}
+		urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/fields?%s",
+			strings.TrimRight(baseURL, "/"), appToken, tableID, q.Encode(),
+		)
+		var resp listFieldsResp
+		if err := RequestJSON(ctx, http.MethodGet, urlStr, token, nil, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("list fields failed: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+		out = append(out, resp.Data.Items...)
+		pageToken = strings.TrimSpace(resp.Data.PageToken)
+		if !resp.Data.HasMore || pageToken == "" {
+			return out, nil
+		}
+	}
+}
+
+// FieldsByName indexes fields by column name.
+func FieldsByName(fields []FieldInfo) map[string]FieldInfo {
+	out := make(map[string]FieldInfo, len(fields))
+	for _, f := range fields {
+		out[f.FieldName] = f
+	}
+	return out
+}
+
+type createFieldResp struct {
+	FeishuResp
+	Data struct {
+		Field FieldInfo `json:"field"`
+	} `json:"data"`
+}
+
+// CreateField adds a column to a table; FieldName, Type and Property of f
+// are sent.
+func CreateField(ctx context.Context, baseURL, token, appToken, tableID string, f FieldInfo) (FieldInfo, error) {
+	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/fields",
+		strings.TrimRight(baseURL, "/"), appToken, tableID,
+	)
+	payload := map[string]any{"field_name": f.FieldName, "type": f.Type}
+	if len(f.Property) > 0 {
+		payload["property"] = f.Property
+	}
+	var resp createFieldResp
+	if err := RequestJSON(ctx, http.MethodPost, urlStr, token, payload, &resp); err != nil {
+		return FieldInfo{}, err
+	}
+	if resp.Code != 0 {
+		return FieldInfo{}, fmt.Errorf("create field %s failed: code=%d msg=%s", f.FieldName, resp.Code, resp.Msg)
+	}
+	return resp.Data.Field, nil
+}
+
+// UpdateField rewrites a column's name, type and property; the API
+// replaces the property as a whole, so f must carry all of it.
+func UpdateField(ctx context.Context, baseURL, token, appToken, tableID string, f FieldInfo) error {
+	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables/%s/fields/%s",
+		strings.TrimRight(baseURL, "/"), appToken, tableID, f.FieldID,
+	)
+	payload := map[string]any{"field_name": f.FieldName, "type": f.Type}
+	if len(f.Property) > 0 {
+		payload["property"] = f.Property
+	}
+	var resp FeishuResp
+	if err := RequestJSON(ctx, http.MethodPut, urlStr, token, payload, &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("update field %s failed: code=%d msg=%s", f.FieldName, resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+type createTableResp struct {
+	FeishuResp
+	Data struct {
+		TableID       string `json:"table_id"`
+		DefaultViewID string `json:"default_view_id"`
+	} `json:"data"`
+}
+
+// CreateTable creates a table with the given columns in a base; the first
+// field becomes the primary column. It returns the new table id.
+func CreateTable(ctx context.Context, baseURL, token, appToken, name string, fields []FieldInfo) (string, error) {
+	urlStr := fmt.Sprintf("%s/open-apis/bitable/v1/apps/%s/tables",
+		strings.TrimRight(baseURL, "/"), appToken,
+	)
+	cols := make([]map[string]any, 0, len(fields))
+	for _, f := range fields {
+		col := map[string]any{"field_name": f.FieldName, "type": f.Type}
+		if len(f.Property) > 0 {
+			col["property"] = f.Property
+		}
+		cols = append(cols, col)
+	}
+	payload := map[string]any{"table": map[string]any{"name": name, "fields": cols}}
+	var resp createTableResp
+	if err := RequestJSON(ctx, http.MethodPost, urlStr, token, payload, &resp); err != nil {
+		return "", err
+	}
+	if resp.Code != 0 {
+		return "", fmt.Errorf("create table %s failed: code=%d msg=%s", name, resp.Code, resp.Msg)
+	}
+	return resp.Data.TableID, nil
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fingerprinter computes the dedupe key of a task from its logical field
+// values (e.g. "URL", "UserID"). An empty key means the task cannot be
+// deduplicated, typically because a selected field is empty.
+type Fingerprinter interface {
+	Fingerprint(values map[string]string) string
+}
+
+// Normalizer rewrites one field value before it becomes part of a
+// fingerprint.
+type Normalizer func(string) string
+
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[string]Normalizer{
+		"trim":  strings.TrimSpace,
+		"lower": strings.ToLower,
+		"url":   CanonicalURL,
+	}
+)
+
+// RegisterNormalizer makes a normalizer available to fingerprint specs by
+// name, replacing any existing one.
+func RegisterNormalizer(name string, fn Normalizer) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	normalizers[strings.ToLower(strings.TrimSpace(name))] = fn
+}
+
+func lookupNormalizer(name string) (Normalizer, bool) {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	fn, ok := normalizers[strings.ToLower(strings.TrimSpace(name))]
+	return fn, ok
+}
+
+// FingerprintSpec selects the fields of a fingerprint, the normalizers
+// applied to them and the final hash ("" keeps the normalized values,
+// "sha256" hashes them).
+type FingerprintSpec struct {
+	Fields []string
+	// Normalize maps a field to its normalizers; the "" entry applies to
+	// every field before the field-specific ones.
+	Normalize map[string][]string
+	Hash      string
+}
+
+// ParseFingerprintSpec parses the CLI/env form of a spec. normalize is a
+// comma-separated list where "name" applies to every field and
+// "Field:name" to one field, e.g. "trim,URL:url,UserID:lower".
+func ParseFingerprintSpec(fields []string, normalize, hash string) (FingerprintSpec, error) {
+	spec := FingerprintSpec{Fields: fields, Normalize: map[string][]string{}, Hash: strings.ToLower(strings.TrimSpace(hash))}
+	for _, part := range strings.Split(normalize, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, name := "", part
+		if i := strings.Index(part, ":"); i >= 0 {
+			field, name = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		if _, ok := lookupNormalizer(name); !ok {
+			return FingerprintSpec{}, fmt.Errorf("unknown normalizer %q", name)
+		}
+		spec.Normalize[field] = append(spec.Normalize[field], name)
+	}
+	switch spec.Hash {
+	case "", "none":
+		spec.Hash = ""
+	case "sha256":
+	default:
+		return FingerprintSpec{}, fmt.Errorf("unknown fingerprint hash %q", hash)
+	}
+	return spec, nil
+}
+
+// IsDefault reports whether the spec only trims values and does not hash,
+// i.e. it matches plain string equality.
+func (s FingerprintSpec) IsDefault() bool {
+	if s.Hash != "" {
+		return false
+	}
+	for _, names := range s.Normalize {
+		for _, n := range names {
+			if strings.ToLower(n) != "trim" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewFingerprinter builds a Fingerprinter from spec.
+func NewFingerprinter(spec FingerprintSpec) (Fingerprinter, error) {
+	if len(spec.Fields) == 0 {
+		return nil, fmt.Errorf("fingerprint needs at least one field")
+	}
+	fp := &specFingerprinter{fields: spec.Fields, hash: spec.Hash, norm: map[string][]Normalizer{}}
+	for _, field := range spec.Fields {
+		names := append(append([]string{}, spec.Normalize[""]...), spec.Normalize[field]...)
+		for _, n := range names {
+			fn, ok := lookupNormalizer(n)
+			if !ok {
+				return nil, fmt.Errorf("unknown normalizer %q", n)
+			}
+			fp.norm[field] = append(fp.norm[field], fn)
+		}
+	}
+	return fp, nil
+}
+
+type specFingerprinter struct {
+	fields []string
+	norm   map[string][]Normalizer
+	hash   string
+}
+
+func (f *specFingerprinter) Fingerprint(values map[string]string) string {
+	parts := make([]string, 0, len(f.fields))
+	for _, field := range f.fields {
+		v := strings.TrimSpace(values[field])
+		for _, fn := range f.norm[field] {
+			v = fn(v)
+		}
+		if v == "" {
+			return ""
+		}
+		parts = append(parts, v)
+	}
+	key := strings.Join(parts, "\x1f")
+	if f.hash == "sha256" {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])
+	}
+	return key
+}
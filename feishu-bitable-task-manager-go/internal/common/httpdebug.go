@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// httpDebugBodyLimit caps how much of a request or response body one debug
+// line carries; record searches can return megabytes.
+const httpDebugBodyLimit = 4096
+
+var httpDebugLogger atomic.Pointer[slog.Logger]
+
+// SetHTTPDebugLogger logs every Feishu API call in full through l: method,
+// URL, headers, payload, status, latency, Feishu log id and response body,
+// with credentials masked. nil turns it off.
+func SetHTTPDebugLogger(l *slog.Logger) {
+	httpDebugLogger.Store(l)
+}
+
+// secretJSONKeys are masked wherever they appear in a logged body.
+var secretJSONKeys = regexp.MustCompile(`("(?:app_secret|tenant_access_token|app_access_token|user_access_token|refresh_token|access_token)"\s*:\s*)"[^"]*"`)
+
+// sensitiveHeaders are logged with their value masked.
+var sensitiveHeaders = map[string]bool{"Authorization": true, "Cookie": true, "Set-Cookie": true}
+
+func debugHTTP(ctx context.Context, req *http.Request, payload []byte, resp *http.Response, raw []byte, elapsed time.Duration, err error) {
+	l := httpDebugLogger.Load()
+	if l == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.String("request_headers", debugHeaders(req.Header)),
+		slog.String("request_body", debugBody(req.Header.Get("Content-Type"), payload)),
+		slog.Int64("duration_ms", elapsed.Milliseconds()),
+	}
+	if resp != nil {
+		attrs = append(attrs,
+			slog.Int("status", resp.StatusCode),
+			slog.String("log_id", resp.Header.Get("X-Tt-Logid")),
+			slog.String("response_body", debugBody(resp.Header.Get("Content-Type"), raw)),
+		)
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.LogAttrs(ctx, slog.LevelInfo, "http", attrs...)
+}
+
+func debugHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(h[name], ", ")
+		if sensitiveHeaders[name] {
+			value = maskSecret(value)
+		}
+		parts = append(parts, name+": "+value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// maskSecret keeps an Authorization scheme and the last four characters so
+// two tokens can still be told apart.
+func maskSecret(v string) string {
+	scheme, secret, ok := strings.Cut(v, " ")
+	if !ok {
+		scheme, secret = "", v
+	} else {
+		scheme += " "
+	}
+	if len(secret) <= 8 {
+		return scheme + "***"
+	}
+	return scheme + "***" + secret[len(secret)-4:]
+}
+
+// debugBody renders a JSON or text body with secrets masked, and anything
+// else (multipart uploads, downloads) by its size only.
+func debugBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	ct := strings.ToLower(contentType)
+	if ct != "" && !strings.Contains(ct, "json") && !strings.HasPrefix(ct, "text/") {
+		return fmt.Sprintf("<%d bytes %s>", len(body), strings.TrimSpace(strings.Split(ct, ";")[0]))
+	}
+	s := secretJSONKeys.ReplaceAllString(string(body), `$1"***"`)
+	if len(s) > httpDebugBodyLimit {
+		s = fmt.Sprintf("%s...(%d bytes)", s[:httpDebugBodyLimit], len(body))
+	}
+	return s
+}
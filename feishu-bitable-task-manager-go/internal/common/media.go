@@ -0,0 +1,180 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Parent types accepted by drive/v1/medias/upload_all for Bitable records.
+const (
+	MediaParentBitableFile  = "bitable_file"
+	MediaParentBitableImage = "bitable_image"
+)
+
+type uploadMediaResp struct {
+	FeishuResp
+	Data struct {
+		FileToken string `json:"file_token"`
+	} `json:"data"`
+}
+
+// mediaUploadAllLimit is the largest file upload_all accepts. Larger files
+// go up in parts through upload_prepare, upload_part and upload_finish.
+const mediaUploadAllLimit = 20 << 20
+
+// UploadMedia uploads data as a Drive media file attached to the given
+// Bitable app (parentNode = app_token) and returns its file_token. Files
+// over 20 MB are uploaded in parts.
+func UploadMedia(ctx context.Context, baseURL, token, parentType, parentNode, fileName string, data []byte) (string, error) {
+	if len(data) > mediaUploadAllLimit {
+		return uploadMediaInParts(ctx, baseURL, token, parentType, parentNode, fileName, data)
+	}
+	body, contentType, err := multipartFile([][2]string{
+		{"file_name", fileName},
+		{"parent_type", parentType},
+		{"parent_node", parentNode},
+		{"size", strconv.Itoa(len(data))},
+	}, fileName, data)
+	if err != nil {
+		return "", err
+	}
+	urlStr := strings.TrimRight(baseURL, "/") + "/open-apis/drive/v1/medias/upload_all"
+	raw, err := defaultClient.do(ctx, http.MethodPost, urlStr, token, contentType, body)
+	if err != nil {
+		return "", err
+	}
+	var resp uploadMediaResp
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return "", err
+	}
+	if resp.Code != 0 {
+		return "", fmt.Errorf("upload media failed: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	fileToken := strings.TrimSpace(resp.Data.FileToken)
+	if fileToken == "" {
+		return "", errors.New("upload media: file_token missing in response")
+	}
+	return fileToken, nil
+}
+
+// multipartFile builds a multipart/form-data body of fields followed by
+// data as the "file" part, and returns it with its content type.
+func multipartFile(fields [][2]string, fileName string, data []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, kv := range fields {
+		if err := w.WriteField(kv[0], kv[1]); err != nil {
+			return nil, "", err
+		}
+	}
+	part, err := w.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+type uploadPrepareResp struct {
+	FeishuResp
+	Data struct {
+		UploadID  string `json:"upload_id"`
+		BlockSize int    `json:"block_size"`
+		BlockNum  int    `json:"block_num"`
+	} `json:"data"`
+}
+
+// uploadMediaInParts uploads data in the block size upload_prepare hands
+// out. Each part carries its Adler-32 checksum so Feishu rejects a part
+// damaged on the way; a failed part fails the whole upload, and the
+// unfinished upload expires on Feishu's side.
+func uploadMediaInParts(ctx context.Context, baseURL, token, parentType, parentNode, fileName string, data []byte) (string, error) {
+	base := strings.TrimRight(baseURL, "/") + "/open-apis/drive/v1/medias/"
+	var prep uploadPrepareResp
+	if err := RequestJSON(ctx, http.MethodPost, base+"upload_prepare", token, map[string]any{
+		"file_name":   fileName,
+		"parent_type": parentType,
+		"parent_node": parentNode,
+		"size":        len(data),
+	}, &prep); err != nil {
+		return "", err
+	}
+	if prep.Code != 0 {
+		return "", fmt.Errorf("prepare media upload failed: code=%d msg=%s", prep.Code, prep.Msg)
+	}
+	uploadID, blockSize := strings.TrimSpace(prep.Data.UploadID), prep.Data.BlockSize
+	if uploadID == "" || blockSize <= 0 {
+		return "", errors.New("prepare media upload: upload_id or block_size missing in response")
+	}
+	blocks := (len(data) + blockSize - 1) / blockSize
+	if prep.Data.BlockNum > 0 && prep.Data.BlockNum != blocks {
+		return "", fmt.Errorf("prepare media upload: %d blocks of %d bytes expected, Feishu wants %d", blocks, blockSize, prep.Data.BlockNum)
+	}
+	for seq := 0; seq < blocks; seq++ {
+		chunk := data[seq*blockSize : min((seq+1)*blockSize, len(data))]
+		body, contentType, err := multipartFile([][2]string{
+			{"upload_id", uploadID},
+			{"seq", strconv.Itoa(seq)},
+			{"size", strconv.Itoa(len(chunk))},
+			{"checksum", strconv.FormatUint(uint64(adler32.Checksum(chunk)), 10)},
+		}, fileName, chunk)
+		if err != nil {
+			return "", err
+		}
+		raw, err := defaultClient.do(ctx, http.MethodPost, base+"upload_part", token, contentType, body)
+		if err != nil {
+			return "", fmt.Errorf("upload part %d/%d: %w", seq+1, blocks, err)
+		}
+		var resp FeishuResp
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return "", err
+		}
+		if resp.Code != 0 {
+			return "", fmt.Errorf("upload part %d/%d failed: code=%d msg=%s", seq+1, blocks, resp.Code, resp.Msg)
+		}
+	}
+	var fin uploadMediaResp
+	if err := RequestJSON(ctx, http.MethodPost, base+"upload_finish", token, map[string]any{
+		"upload_id": uploadID,
+		"block_num": blocks,
+	}, &fin); err != nil {
+		return "", err
+	}
+	if fin.Code != 0 {
+		return "", fmt.Errorf("finish media upload failed: code=%d msg=%s", fin.Code, fin.Msg)
+	}
+	fileToken := strings.TrimSpace(fin.Data.FileToken)
+	if fileToken == "" {
+		return "", errors.New("finish media upload: file_token missing in response")
+	}
+	return fileToken, nil
+}
+
+// DownloadMedia fetches the content of a Drive media file. tableID scopes
+// the bitable permission check for attachments stored in records.
+func DownloadMedia(ctx context.Context, baseURL, token, fileToken, tableID string) ([]byte, error) {
+	fileToken = strings.TrimSpace(fileToken)
+	if fileToken == "" {
+		return nil, errors.New("file token is empty")
+	}
+	urlStr := strings.TrimRight(baseURL, "/") + "/open-apis/drive/v1/medias/" + url.PathEscape(fileToken) + "/download"
+	if tableID != "" {
+		extra := marshalJSONNoEscape(map[string]any{"bitablePerm": map[string]any{"tableId": tableID}})
+		urlStr += "?extra=" + url.QueryEscape(extra)
+	}
+	return defaultClient.do(ctx, http.MethodGet, urlStr, token, "", nil)
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Task counters recorded with CountTasks.
+const (
+	TasksFetched = "fetched"
+	TasksCreated = "created"
+	TasksUpdated = "updated"
+)
+
+// Metrics receives the client's measurements so any backend (Prometheus,
+// statsd, in-house telemetry) can count them. Calls come from concurrent
+// requests, so implementations must be safe for concurrent use.
+type Metrics interface {
+	// APICall observes one Feishu request: endpoint is the path with ids
+	// replaced by :id, status 0 means a transport error.
+	APICall(method, endpoint string, status int, elapsed time.Duration)
+	// Retry counts an operation retried after a failure.
+	Retry(op string)
+	// RateLimited counts a request refused by the frequency limit.
+	RateLimited(endpoint string)
+	// Tasks adds n records to the TasksFetched, TasksCreated or
+	// TasksUpdated counter.
+	Tasks(kind string, n int)
+}
+
+type metricsHolder struct{ m Metrics }
+
+var metricsHook atomic.Pointer[metricsHolder]
+
+// SetMetrics sends the client's measurements to m from now on; nil stops
+// recording.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		metricsHook.Store(nil)
+		return
+	}
+	metricsHook.Store(&metricsHolder{m: m})
+}
+
+func currentMetrics() Metrics {
+	if h := metricsHook.Load(); h != nil {
+		return h.m
+	}
+	return nil
+}
+
+func observeAPICall(method, path string, status int, elapsed time.Duration) {
+	if m := currentMetrics(); m != nil {
+		m.APICall(method, apiEndpoint(path), status, elapsed)
+	}
+}
+
+func observeRetry(op string) {
+	if m := currentMetrics(); m != nil {
+		m.Retry(op)
+	}
+}
+
+func observeRateLimited(path string) {
+	if m := currentMetrics(); m != nil {
+		m.RateLimited(apiEndpoint(path))
+	}
+}
+
+// CountTasks adds n records to the TasksFetched, TasksCreated or
+// TasksUpdated counter.
+func CountTasks(kind string, n int) {
+	if m := currentMetrics(); m != nil && n > 0 {
+		m.Tasks(kind, n)
+	}
+}
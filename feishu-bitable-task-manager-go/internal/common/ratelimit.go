@@ -0,0 +1,79 @@
+package common
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every request issued through the
+// package-level HTTP client, so all commands pace their Feishu calls the same
+// way regardless of how many helpers they go through.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(qps float64) *rateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	burst := qps
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: qps, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// SetQPS caps the request rate of all Feishu API calls. qps <= 0 disables
+// pacing. It overrides BITABLE_QPS.
+func SetQPS(qps float64) {
+	defaultClient.limiter = newRateLimiter(qps)
+}
+
+// QPSFromEnv returns BITABLE_QPS, or 0 when unset or invalid.
+func QPSFromEnv() float64 {
+	raw := Env("BITABLE_QPS", "")
+	if raw == "" {
+		return 0
+	}
+	qps, err := strconv.ParseFloat(raw, 64)
+	if err != nil || qps < 0 {
+		return 0
+	}
+	return qps
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultReleaseRepo is the GitHub repository self-update downloads from.
+const DefaultReleaseRepo = "httprunner/adb-skill"
+
+// releaseClient talks to GitHub, not Feishu, so it bypasses the Feishu rate
+// limiter and token handling.
+var releaseClient = &http.Client{Timeout: 5 * time.Minute}
+
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+type Release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+// Asset returns the release asset with the given name.
+func (r Release) Asset(name string) (ReleaseAsset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// FetchRelease reads a GitHub release of repo ("owner/name"). tag "" or
+// "latest" selects the latest published release. GITHUB_API_URL points at a
+// GitHub Enterprise host; GITHUB_TOKEN, when set, is sent to lift the
+// anonymous rate limit.
+func FetchRelease(ctx context.Context, repo, tag string) (Release, error) {
+	tag = strings.TrimSpace(tag)
+	path := "releases/latest"
+	if tag != "" && tag != "latest" {
+		path = "releases/tags/" + url.PathEscape(tag)
+	}
+	api := strings.TrimRight(Env("GITHUB_API_URL", "https://api.github.com"), "/")
+	urlStr := fmt.Sprintf("%s/repos/%s/%s", api, strings.Trim(repo, "/"), path)
+	raw, err := releaseGet(ctx, urlStr, "application/vnd.github+json")
+	if err != nil {
+		return Release{}, err
+	}
+	var rel Release
+	if err := json.Unmarshal(raw, &rel); err != nil {
+		return Release{}, fmt.Errorf("decode release: %w", err)
+	}
+	return rel, nil
+}
+
+// DownloadAsset returns the content of a release asset.
+func DownloadAsset(ctx context.Context, asset ReleaseAsset) ([]byte, error) {
+	return releaseGet(ctx, asset.URL, "application/octet-stream")
+}
+
+func releaseGet(ctx context.Context, urlStr, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if tok := strings.TrimSpace(Env("GITHUB_TOKEN", "")); tok != "" {
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	resp, err := releaseClient.Do(req)
+	if err != nil {
+		return nil, explainTLSError(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s: http %d: %s", urlStr, resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return raw, nil
+}
@@ -0,0 +1,144 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitCodes are Feishu codes for a request refused by a frequency
+// limit; they may arrive with HTTP 200 as well as 429.
+var rateLimitCodes = map[int]bool{
+	99991400: true, // open platform: request trigger frequency limit
+	1254290:  true, // bitable: too many requests
+}
+
+const (
+	defaultRateLimitRetries = 5
+	defaultRateLimitMaxWait = time.Minute
+	// rateLimitFallbackWait is the first wait when a limited response names
+	// no reset time; it doubles per attempt.
+	rateLimitFallbackWait = time.Second
+)
+
+var warnLogger atomic.Pointer[slog.Logger]
+
+// SetWarnLogger sets where rate-limit waits are logged when events are off;
+// nil disables those lines.
+func SetWarnLogger(l *slog.Logger) {
+	warnLogger.Store(l)
+}
+
+// rateLimited reports whether a response was refused by a frequency limit.
+func rateLimited(status int, raw []byte) (int, bool) {
+	head := raw
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	if status != http.StatusTooManyRequests && !bytes.Contains(head, []byte("99991400")) && !bytes.Contains(head, []byte("1254290")) {
+		return -1, false
+	}
+	var resp struct {
+		Code *int `json:"code"`
+	}
+	code := -1
+	if json.Unmarshal(raw, &resp) == nil && resp.Code != nil {
+		code = *resp.Code
+	}
+	return code, status == http.StatusTooManyRequests || rateLimitCodes[code]
+}
+
+// retryAfter reads the wait a limited response asks for: Feishu's
+// x-ogw-ratelimit-reset (seconds until the quota resets), else a standard
+// Retry-After (seconds or an HTTP date). ok is false when neither is set.
+func retryAfter(h http.Header, now time.Time) (wait time.Duration, source string, ok bool) {
+	for _, name := range []string{"X-Ogw-Ratelimit-Reset", "Retry-After"} {
+		v := strings.TrimSpace(h.Get(name))
+		if v == "" {
+			continue
+		}
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+			return time.Duration(secs * float64(time.Second)), strings.ToLower(name), true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			if wait = at.Sub(now); wait < 0 {
+				wait = 0
+			}
+			return wait, strings.ToLower(name), true
+		}
+	}
+	return 0, "", false
+}
+
+// rateLimitRetries is BITABLE_RATE_LIMIT_RETRIES: how many times one
+// request is re-sent after a rate limit (0 disables).
+func rateLimitRetries() int {
+	if n, err := strconv.Atoi(Env("BITABLE_RATE_LIMIT_RETRIES", "")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultRateLimitRetries
+}
+
+// rateLimitMaxWait is BITABLE_RATE_LIMIT_MAX_WAIT: a limited request whose
+// wait would be longer fails instead.
+func rateLimitMaxWait() time.Duration {
+	if d, err := time.ParseDuration(Env("BITABLE_RATE_LIMIT_MAX_WAIT", "")); err == nil && d > 0 {
+		return d
+	}
+	return defaultRateLimitMaxWait
+}
+
+// holdUntil makes every request of the client wait until t, so concurrent
+// callers (prefetching pages, parallel chunks) stop together instead of
+// each burning a request on the same exhausted quota.
+func (h *httpClient) holdUntil(t time.Time) {
+	for {
+		cur := h.hold.Load()
+		if cur >= t.UnixNano() || h.hold.CompareAndSwap(cur, t.UnixNano()) {
+			return
+		}
+	}
+}
+
+func (h *httpClient) waitHold(ctx context.Context) error {
+	until := time.Unix(0, h.hold.Load())
+	wait := time.Until(until)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// logRateLimited reports a wait as a rate_limited event, or as a warning
+// when events are off.
+func logRateLimited(ctx context.Context, method, path string, status, code, attempt int, wait time.Duration, source string) {
+	observeRateLimited(path)
+	if EventsEnabled() {
+		emitEvent(ctx, EventRateLimited,
+			slog.String("method", method),
+			slog.String("endpoint", apiEndpoint(path)),
+			slog.Int("status", status),
+			slog.Int("code", code),
+			slog.Int("attempt", attempt),
+			slog.Int64("wait_ms", wait.Milliseconds()),
+			slog.String("source", source),
+		)
+		return
+	}
+	if l := warnLogger.Load(); l != nil {
+		l.Warn("rate limited; waiting before retry", "endpoint", method+" "+apiEndpoint(path), "code", code, "attempt", attempt, "wait", wait.String(), "source", source)
+	}
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// StoreKeyEnv names the secret that encrypts the files the tool keeps on
+// disk (queue entries, the task mirror). It is hashed into an AES-256 key
+// as is, without a password KDF, so it should be random (for example
+// `openssl rand -hex 32`), not a memorable password.
+const StoreKeyEnv = "BITABLE_STORE_KEY"
+
+// ErrStoreKey marks sealed data that cannot be opened with the configured
+// key: none is set, or it is not the key the data was sealed with. Unlike
+// a failed Open under the right key, this is a setup problem, not damage.
+var ErrStoreKey = errors.New("store key mismatch")
+
+// SealKey encrypts and decrypts local files with AES-256-GCM.
+type SealKey struct {
+	aead cipher.AEAD
+	id   string
+}
+
+// Sealed is data encrypted by a SealKey. KeyID tells which key sealed it,
+// so a wrong key is reported as such instead of as corruption.
+type Sealed struct {
+	KeyID string `json:"key_id"`
+	Data  string `json:"data"`
+}
+
+// StoreKey returns the key configured in BITABLE_STORE_KEY, or nil when it
+// is unset and files are written in the clear.
+func StoreKey() *SealKey {
+	secret := Env(StoreKeyEnv, "")
+	if secret == "" {
+		return nil
+	}
+	return NewSealKey(secret)
+}
+
+// NewSealKey derives a key from secret.
+func NewSealKey(secret string) *SealKey {
+	sum := sha256.Sum256([]byte("bitable-task store key\x00" + secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		panic(err) // a 32-byte key is always valid
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	id := sha256.Sum256(append([]byte("key id\x00"), sum[:]...))
+	return &SealKey{aead: aead, id: hex.EncodeToString(id[:4])}
+}
+
+// Seal encrypts plain under a fresh random nonce.
+func (k *SealKey) Seal(plain []byte) (*Sealed, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := k.aead.Seal(nonce, nonce, plain, []byte(k.id))
+	return &Sealed{KeyID: k.id, Data: base64.StdEncoding.EncodeToString(out)}, nil
+}
+
+// OpenSealed decrypts s with k. It returns an error wrapping ErrStoreKey
+// when k is nil or another key sealed s, and a plain error when the data
+// does not authenticate.
+func OpenSealed(k *SealKey, s *Sealed) ([]byte, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w: data is encrypted and %s is not set", ErrStoreKey, StoreKeyEnv)
+	}
+	if s.KeyID != k.id {
+		return nil, fmt.Errorf("%w: data was sealed with key %s, %s is key %s", ErrStoreKey, s.KeyID, StoreKeyEnv, k.id)
+	}
+	raw, err := base64.StdEncoding.DecodeString(s.Data)
+	if err != nil {
+		return nil, fmt.Errorf("sealed data: %w", err)
+	}
+	n := k.aead.NonceSize()
+	if len(raw) < n {
+		return nil, errors.New("sealed data: too short")
+	}
+	plain, err := k.aead.Open(nil, raw[:n], raw[n:], []byte(k.id))
+	if err != nil {
+		return nil, fmt.Errorf("sealed data: %w", err)
+	}
+	return plain, nil
+}
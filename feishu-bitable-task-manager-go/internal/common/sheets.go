@@ -0,0 +1,168 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sheetReadRows is how many rows one values request reads; the API caps a
+// response at 5000 rows.
+const sheetReadRows = 5000
+
+// SheetRef points at a Feishu spreadsheet and optionally one of its sheets.
+type SheetRef struct {
+	RawURL           string
+	SpreadsheetToken string
+	// SheetID is empty when the link names no sheet; the first one is read.
+	SheetID   string
+	WikiToken string
+}
+
+// ParseSheetURL reads a spreadsheet link, e.g.
+// https://example.feishu.cn/sheets/shtcnXXX?sheet=0b1c2d, or a wiki link to
+// one (resolve WikiToken with ResolveWikiObjToken).
+func ParseSheetURL(raw string) (SheetRef, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return SheetRef{}, errors.New("sheet url is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return SheetRef{}, err
+	}
+	if u.Scheme == "" {
+		return SheetRef{}, errors.New("sheet url missing scheme")
+	}
+	ref := SheetRef{RawURL: raw, SheetID: firstQueryValue(u.Query(), "sheet", "sheetId", "sheet_id")}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i < len(segments)-1; i++ {
+		switch segments[i] {
+		case "sheets":
+			ref.SpreadsheetToken = segments[i+1]
+		case "wiki":
+			ref.WikiToken = segments[i+1]
+		}
+	}
+	if ref.SpreadsheetToken == "" && ref.WikiToken == "" {
+		return SheetRef{}, errors.New("sheet url must contain /sheets/<token> or /wiki/<token>")
+	}
+	return ref, nil
+}
+
+// SheetInfo is one sheet returned by the sheets query API.
+type SheetInfo struct {
+	SheetID        string `json:"sheet_id"`
+	Title          string `json:"title"`
+	Index          int    `json:"index"`
+	GridProperties struct {
+		RowCount    int `json:"row_count"`
+		ColumnCount int `json:"column_count"`
+	} `json:"grid_properties"`
+}
+
+type querySheetsResp struct {
+	FeishuResp
+	Data struct {
+		Sheets []SheetInfo `json:"sheets"`
+	} `json:"data"`
+}
+
+type sheetValuesResp struct {
+	FeishuResp
+	Data struct {
+		ValueRange struct {
+			Values [][]any `json:"values"`
+		} `json:"valueRange"`
+	} `json:"data"`
+}
+
+// QuerySheets lists the sheets of a spreadsheet in tab order.
+func QuerySheets(ctx context.Context, baseURL, token, spreadsheetToken string) ([]SheetInfo, error) {
+	urlStr := fmt.Sprintf("%s/open-apis/sheets/v3/spreadsheets/%s/sheets/query",
+		strings.TrimRight(baseURL, "/"), url.PathEscape(spreadsheetToken),
+	)
+	var resp querySheetsResp
+	if err := RequestJSON(ctx, http.MethodGet, urlStr, token, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("query sheets failed: code=%d msg=%s", resp.Code, resp.Msg)
+	}
+	return resp.Data.Sheets, nil
+}
+
+// ReadSheetRows returns every row of the sheet ref names (or the first
+// sheet) as displayed text, reading sheetReadRows rows per request. Trailing
+// empty rows are dropped.
+func ReadSheetRows(ctx context.Context, baseURL, token string, ref SheetRef) ([][]any, error) {
+	sheets, err := QuerySheets(ctx, baseURL, token, ref.SpreadsheetToken)
+	if err != nil {
+		return nil, err
+	}
+	var sheet *SheetInfo
+	for i := range sheets {
+		if ref.SheetID == "" || sheets[i].SheetID == ref.SheetID {
+			sheet = &sheets[i]
+			break
+		}
+	}
+	if sheet == nil {
+		return nil, fmt.Errorf("sheet %q not found in spreadsheet", ref.SheetID)
+	}
+	rows, cols := sheet.GridProperties.RowCount, sheet.GridProperties.ColumnCount
+	out := [][]any{}
+	if rows == 0 || cols == 0 {
+		return out, nil
+	}
+	lastCol := SheetColumnName(cols)
+	for first := 1; first <= rows; first += sheetReadRows {
+		last := first + sheetReadRows - 1
+		if last > rows {
+			last = rows
+		}
+		q := url.Values{}
+		q.Set("valueRenderOption", "ToString")
+		q.Set("dateTimeRenderOption", "FormattedString")
+		rng := fmt.Sprintf("%s!A%d:%s%d", sheet.SheetID, first, lastCol, last)
+		urlStr := fmt.Sprintf("%s/open-apis/sheets/v2/spreadsheets/%s/values/%s?%s",
+			strings.TrimRight(baseURL, "/"), url.PathEscape(ref.SpreadsheetToken), rng, q.Encode(),
+		)
+		var resp sheetValuesResp
+		if err := RequestJSON(ctx, http.MethodGet, urlStr, token, nil, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("read sheet values failed: code=%d msg=%s", resp.Code, resp.Msg)
+		}
+		out = append(out, resp.Data.ValueRange.Values...)
+	}
+	for len(out) > 0 && sheetRowEmpty(out[len(out)-1]) {
+		out = out[:len(out)-1]
+	}
+	return out, nil
+}
+
+// SheetColumnName returns the letters of the n-th (1-based) column: A, Z,
+// AA, ...
+func SheetColumnName(n int) string {
+	name := ""
+	for n > 0 {
+		n--
+		name = string(rune('A'+n%26)) + name
+		n /= 26
+	}
+	return name
+}
+
+func sheetRowEmpty(row []any) bool {
+	for _, v := range row {
+		if BitableValueToString(v) != "" {
+			return false
+		}
+	}
+	return true
+}
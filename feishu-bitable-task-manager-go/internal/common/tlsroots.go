@@ -0,0 +1,98 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+)
+
+// mozillaRootsPEM is the Mozilla CA bundle as packaged by Debian
+// ca-certificates (/etc/ssl/certs/ca-certificates.crt). Refresh it from an
+// up-to-date host when cutting a release.
+//
+//go:embed certs/mozilla-roots.pem
+var mozillaRootsPEM []byte
+
+var embeddedRootsActive bool
+
+// UseEmbeddedRoots makes every HTTPS request of the process verify servers
+// against the embedded Mozilla bundle instead of the host CA store. It is
+// meant for minimal images without ca-certificates.
+func UseEmbeddedRoots() error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(mozillaRootsPEM) {
+		return errors.New("embedded CA bundle has no certificates")
+	}
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.New("default HTTP transport is not an *http.Transport")
+	}
+	t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	embeddedRootsActive = true
+	return nil
+}
+
+// systemCertFiles and systemCertDirs are where Go looks for roots on Linux
+// (crypto/x509/root_linux.go), plus the SSL_CERT_* overrides.
+var (
+	systemCertFiles = []string{
+		"/etc/ssl/certs/ca-certificates.crt",
+		"/etc/pki/tls/certs/ca-bundle.crt",
+		"/etc/ssl/ca-bundle.pem",
+		"/etc/pki/tls/cacert.pem",
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"/etc/ssl/cert.pem",
+	}
+	systemCertDirs = []string{
+		"/etc/ssl/certs",
+		"/etc/pki/tls/certs",
+		"/system/etc/security/cacerts",
+	}
+)
+
+// SystemRootsMissing reports whether the host has no CA certificates for Go
+// to load, in which case every TLS handshake fails with "certificate signed
+// by unknown authority". Only Linux and Android read roots from files; other
+// platforms always report false.
+func SystemRootsMissing() bool {
+	if runtime.GOOS != "linux" && runtime.GOOS != "android" {
+		return false
+	}
+	files, dirs := systemCertFiles, systemCertDirs
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		files = []string{f}
+	}
+	if d := os.Getenv("SSL_CERT_DIR"); d != "" {
+		dirs = []string{d}
+	}
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil && st.Size() > 0 {
+			return false
+		}
+	}
+	for _, d := range dirs {
+		if entries, err := os.ReadDir(d); err == nil && len(entries) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// explainTLSError adds a remedy to certificate verification failures, the
+// usual symptom of an image without ca-certificates. Other errors are
+// returned unchanged.
+func explainTLSError(err error) error {
+	var unknown x509.UnknownAuthorityError
+	if err == nil || embeddedRootsActive || !errors.As(err, &unknown) {
+		return err
+	}
+	if SystemRootsMissing() {
+		return fmt.Errorf("%w (this host has no CA certificates: install ca-certificates or run with --use-embedded-roots)", err)
+	}
+	return fmt.Errorf("%w (the system CA store does not trust this server: update ca-certificates or run with --use-embedded-roots)", err)
+}
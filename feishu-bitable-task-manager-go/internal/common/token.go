@@ -0,0 +1,217 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tenantTokenRefreshMargin refreshes tokens this long before they expire
+	// so a request never goes out with a token about to lapse.
+	tenantTokenRefreshMargin = 5 * time.Minute
+	// defaultTenantTokenTTL is assumed when the response omits expire.
+	defaultTenantTokenTTL = 2 * time.Hour
+)
+
+type cachedTenantToken struct {
+	baseURL   string
+	appID     string
+	appSecret string
+	token     string
+	expiresAt time.Time
+}
+
+func (c *cachedTenantToken) valid(now time.Time) bool {
+	return c.token != "" && now.Add(tenantTokenRefreshMargin).Before(c.expiresAt)
+}
+
+// tenantTokenCache keeps tenant tokens per app_id in memory and, when
+// FEISHU_TOKEN_CACHE_DIR is set, in a per-app file so that repeated CLI
+// invocations reuse the same token. Tokens handed out earlier are
+// remembered so the HTTP client can swap them for a refreshed one when a
+// long-running command outlives the TTL.
+type tenantTokenCache struct {
+	mu      sync.Mutex
+	byApp   map[string]*cachedTenantToken
+	byToken map[string]*cachedTenantToken
+}
+
+var tenantTokens = &tenantTokenCache{
+	byApp:   map[string]*cachedTenantToken{},
+	byToken: map[string]*cachedTenantToken{},
+}
+
+func (c *tenantTokenCache) get(ctx context.Context, baseURL, appID, appSecret string) (string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry := c.byApp[appID]
+	if entry == nil {
+		entry = &cachedTenantToken{baseURL: baseURL, appID: appID, appSecret: appSecret}
+		if tok, exp, ok := readTokenFile(appID); ok {
+			entry.token, entry.expiresAt = tok, exp
+			c.byToken[tok] = entry
+		}
+		c.byApp[appID] = entry
+	}
+	entry.baseURL, entry.appSecret = baseURL, appSecret
+	if entry.valid(now) {
+		tok := entry.token
+		c.mu.Unlock()
+		return tok, nil
+	}
+	c.mu.Unlock()
+
+	tok, ttl, err := requestTenantAccessToken(ctx, baseURL, appID, appSecret)
+	if err != nil {
+		return "", err
+	}
+	if ttl <= 0 {
+		ttl = defaultTenantTokenTTL
+	}
+	expiresAt := now.Add(ttl)
+
+	c.mu.Lock()
+	entry.token, entry.expiresAt = tok, expiresAt
+	c.byToken[tok] = entry
+	c.mu.Unlock()
+	writeTokenFile(appID, tok, expiresAt)
+	return tok, nil
+}
+
+// refreshed returns token itself unless it is a tenant token issued by this
+// cache that is about to expire, in which case a fresh token is fetched.
+func (c *tenantTokenCache) refreshed(ctx context.Context, token string) string {
+	if token == "" {
+		return token
+	}
+	c.mu.Lock()
+	entry := c.byToken[token]
+	if entry == nil || entry.valid(time.Now()) {
+		if entry != nil {
+			token = entry.token
+		}
+		c.mu.Unlock()
+		return token
+	}
+	baseURL, appID, appSecret := entry.baseURL, entry.appID, entry.appSecret
+	c.mu.Unlock()
+
+	fresh, err := c.get(ctx, baseURL, appID, appSecret)
+	if err != nil {
+		// Let the request go out with the old token; Feishu reports the
+		// auth error and the caller surfaces it.
+		return token
+	}
+	return fresh
+}
+
+// tokenInvalidCodes are the Feishu codes for a tenant token it no longer
+// accepts, which a fresh token fixes (revoked early, or the app secret was
+// rotated).
+var tokenInvalidCodes = map[int]bool{99991663: true, 99991668: true}
+
+// tokenInvalid reports whether raw is a response refusing the token.
+func tokenInvalid(raw []byte) bool {
+	head := raw
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	return bytes.Contains(head, []byte("9999166")) && tokenInvalidCodes[feishuCode(raw)]
+}
+
+// renewed drops token, a tenant token Feishu refused, and fetches a new one
+// for its app. ok is false for tokens this cache did not issue. When
+// another request already renewed it, the current token is returned
+// without fetching again.
+func (c *tenantTokenCache) renewed(ctx context.Context, token string) (string, bool) {
+	c.mu.Lock()
+	entry := c.byToken[token]
+	if entry == nil {
+		c.mu.Unlock()
+		return "", false
+	}
+	if entry.token != token && entry.valid(time.Now()) {
+		fresh := entry.token
+		c.mu.Unlock()
+		return fresh, true
+	}
+	baseURL, appID, appSecret := entry.baseURL, entry.appID, entry.appSecret
+	c.mu.Unlock()
+
+	InvalidateTenantToken(appID)
+	fresh, err := c.get(ctx, baseURL, appID, appSecret)
+	if err != nil {
+		return "", false
+	}
+	return fresh, true
+}
+
+// InvalidateTenantToken drops the cached token for appID (memory and file).
+func InvalidateTenantToken(appID string) {
+	tenantTokens.mu.Lock()
+	if entry := tenantTokens.byApp[appID]; entry != nil {
+		entry.token = ""
+		entry.expiresAt = time.Time{}
+	}
+	tenantTokens.mu.Unlock()
+	if path := tokenFilePath(appID); path != "" {
+		_ = os.Remove(path)
+	}
+}
+
+type tokenFile struct {
+	TenantAccessToken string `json:"tenant_access_token"`
+	ExpiresAt         int64  `json:"expires_at"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+func tokenFilePath(appID string) string {
+	dir := Env("FEISHU_TOKEN_CACHE_DIR", "")
+	if dir == "" || strings.TrimSpace(appID) == "" {
+		return ""
+	}
+	return filepath.Join(dir, "tenant_token_"+unsafeFileChars.ReplaceAllString(appID, "_")+".json")
+}
+
+func readTokenFile(appID string) (string, time.Time, bool) {
+	path := tokenFilePath(appID)
+	if path == "" {
+		return "", time.Time{}, false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	var f tokenFile
+	if err := json.Unmarshal(raw, &f); err != nil || strings.TrimSpace(f.TenantAccessToken) == "" {
+		return "", time.Time{}, false
+	}
+	return f.TenantAccessToken, time.Unix(f.ExpiresAt, 0), true
+}
+
+func writeTokenFile(appID, token string, expiresAt time.Time) {
+	path := tokenFilePath(appID)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	raw, err := json.Marshal(tokenFile{TenantAccessToken: token, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
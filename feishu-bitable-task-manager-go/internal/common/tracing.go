@@ -0,0 +1,495 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span kinds and status codes of the OTLP data model.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+// tracesBatchSize is how many ended spans trigger an export before the
+// schedule delay is up.
+const tracesBatchSize = 512
+
+// Span is one traced operation. A nil *Span, which StartSpan returns while
+// tracing is off, ignores every call.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	name     string
+	kind     int
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	ended bool
+}
+
+type spanContextKey struct{}
+
+type tracer struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	resource map[string]any
+	ratio    float64
+	parent   *Span // from TRACEPARENT
+	warn     *slog.Logger
+
+	mu      sync.Mutex
+	pending []otlpSpan
+	kick    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	failed  atomic.Bool
+}
+
+var activeTracer atomic.Pointer[tracer]
+
+// TracingEnabled reports whether spans are being exported.
+func TracingEnabled() bool {
+	return activeTracer.Load() != nil
+}
+
+// StartTracing reads the standard OTEL_* variables and, when an OTLP
+// endpoint is set, starts exporting spans in the background to an OTLP/HTTP
+// collector in the JSON encoding:
+//
+//   - OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (used as is) or
+//     OTEL_EXPORTER_OTLP_ENDPOINT (+ "/v1/traces")
+//   - OTEL_EXPORTER_OTLP_HEADERS, OTEL_EXPORTER_OTLP_TIMEOUT (ms) and their
+//     _TRACES_ forms
+//   - OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES
+//   - OTEL_TRACES_SAMPLER (always_on, always_off, traceidratio and their
+//     parentbased_ forms) with OTEL_TRACES_SAMPLER_ARG
+//   - OTEL_BSP_SCHEDULE_DELAY (ms between exports)
+//   - OTEL_SDK_DISABLED=true or OTEL_TRACES_EXPORTER=none turn it off
+//
+// TRACEPARENT, when set, becomes the parent of root spans. Export failures
+// are logged once through warn. shutdown exports what is left; it is a
+// no-op when tracing is off.
+func StartTracing(warn *slog.Logger) (shutdown func(context.Context), err error) {
+	noop := func(context.Context) {}
+	if strings.EqualFold(Env("OTEL_SDK_DISABLED", ""), "true") || strings.EqualFold(Env("OTEL_TRACES_EXPORTER", "otlp"), "none") {
+		return noop, nil
+	}
+	endpoint := Env("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if endpoint == "" {
+		base := Env("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		if base == "" {
+			return noop, nil
+		}
+		endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+	}
+	protocol := Env("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", Env("OTEL_EXPORTER_OTLP_PROTOCOL", ""))
+	if protocol == "grpc" {
+		return noop, errors.New("OTLP over gRPC is not supported, use an http/json (port 4318) endpoint")
+	}
+	ratio, err := samplerRatio(Env("OTEL_TRACES_SAMPLER", "parentbased_always_on"), Env("OTEL_TRACES_SAMPLER_ARG", ""))
+	if err != nil {
+		return noop, err
+	}
+	headers := parseOTELList(Env("OTEL_EXPORTER_OTLP_HEADERS", ""))
+	for k, v := range parseOTELList(Env("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "")) {
+		headers[k] = v
+	}
+	timeout := otelMillis("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", otelMillis("OTEL_EXPORTER_OTLP_TIMEOUT", 10*time.Second))
+	resource := map[string]any{}
+	for k, v := range parseOTELList(Env("OTEL_RESOURCE_ATTRIBUTES", "")) {
+		resource[k] = v
+	}
+	if name := Env("OTEL_SERVICE_NAME", ""); name != "" {
+		resource["service.name"] = name
+	} else if resource["service.name"] == nil {
+		resource["service.name"] = "bitable-task"
+	}
+
+	t := &tracer{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: timeout},
+		resource: resource,
+		ratio:    ratio,
+		parent:   parseTraceparent(Env("TRACEPARENT", "")),
+		warn:     warn,
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.loop(otelMillis("OTEL_BSP_SCHEDULE_DELAY", 5*time.Second))
+	activeTracer.Store(t)
+	return func(ctx context.Context) {
+		if !activeTracer.CompareAndSwap(t, nil) {
+			return
+		}
+		close(t.done)
+		select {
+		case <-t.stopped:
+		case <-ctx.Done():
+			return
+		}
+		t.export(ctx)
+	}, nil
+}
+
+// StartSpan starts a span named name as a child of the span in ctx (or of
+// TRACEPARENT for a root span) and returns a context carrying it. attrs are
+// key/value pairs, as for slog. Without tracing it returns ctx and nil.
+func StartSpan(ctx context.Context, name string, attrs ...any) (context.Context, *Span) {
+	return startSpan(ctx, name, spanKindInternal, attrs)
+}
+
+func startSpan(ctx context.Context, name string, kind int, attrs []any) (context.Context, *Span) {
+	t := activeTracer.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now(), attrs: map[string]any{}}
+	parent, _ := ctx.Value(spanContextKey{}).(*Span)
+	if parent == nil {
+		parent = t.parent
+	}
+	if parent != nil {
+		s.traceID, s.parentID, s.sampled = parent.traceID, parent.spanID, parent.sampled
+	} else {
+		randomBytes(s.traceID[:])
+		s.sampled = sampleTrace(s.traceID, t.ratio)
+	}
+	randomBytes(s.spanID[:])
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// SetAttributes adds key/value pairs to the span.
+func (s *Span) SetAttributes(attrs ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if k, ok := attrs[i].(string); ok {
+			s.attrs[k] = attrs[i+1]
+		}
+	}
+}
+
+// End finishes the span, marking it failed when err is not nil. Only the
+// first call counts.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attrs),
+	}
+	s.mu.Unlock()
+	if s.parentID != ([8]byte{}) {
+		out.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		out.Status = &otlpStatus{Code: spanStatusError, Message: err.Error()}
+	}
+	if t := activeTracer.Load(); t != nil && s.sampled {
+		t.add(out)
+	}
+}
+
+// traceAPICall records one Feishu request attempt as a client span.
+func traceAPICall(ctx context.Context, req *http.Request, resp *http.Response, raw []byte, start time.Time, err error) {
+	endpoint := apiEndpoint(req.URL.Path)
+	_, s := startSpan(ctx, req.Method+" "+endpoint, spanKindClient, []any{
+		"http.request.method", req.Method,
+		"url.full", req.URL.String(),
+		"server.address", req.URL.Hostname(),
+		"feishu.endpoint", endpoint,
+	})
+	if s == nil {
+		return
+	}
+	s.start = start
+	if resp != nil {
+		s.SetAttributes("http.response.status_code", resp.StatusCode)
+		if logID := resp.Header.Get("X-Tt-Logid"); logID != "" {
+			s.SetAttributes("feishu.log_id", logID)
+		}
+	}
+	if code := feishuCode(raw); code >= 0 {
+		s.SetAttributes("feishu.code", code)
+		if code != 0 && err == nil {
+			err = fmt.Errorf("feishu code %d", code)
+		}
+	}
+	s.End(err)
+}
+
+func (t *tracer) add(s otlpSpan) {
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	full := len(t.pending) >= tracesBatchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *tracer) loop(delay time.Duration) {
+	defer close(t.stopped)
+	ticker := time.NewTicker(delay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.client.Timeout)
+		t.export(ctx)
+		cancel()
+	}
+}
+
+// export sends the pending spans in one request. Spans that cannot be sent
+// are dropped rather than piling up in a long-running worker.
+func (t *tracer) export(ctx context.Context) {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.post(ctx, spans); err != nil && t.warn != nil && t.failed.CompareAndSwap(false, true) {
+		t.warn.Warn("export traces failed, dropping spans", "endpoint", t.endpoint, "err", err)
+	}
+}
+
+func (t *tracer) post(ctx context.Context, spans []otlpSpan) error {
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(t.resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "bitable-task"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return &HTTPError{Status: resp.StatusCode, Body: string(raw)}
+	}
+	return nil
+}
+
+// samplerRatio turns OTEL_TRACES_SAMPLER into the share of new traces to
+// keep. Child spans always follow their parent's decision.
+func samplerRatio(sampler, arg string) (float64, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(sampler)), "parentbased_") {
+	case "", "always_on":
+		return 1, nil
+	case "always_off":
+		return 0, nil
+	case "traceidratio":
+		if strings.TrimSpace(arg) == "" {
+			return 1, nil
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil || r < 0 || r > 1 {
+			return 0, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG %q: want a number from 0 to 1", arg)
+		}
+		return r, nil
+	}
+	return 0, fmt.Errorf("unsupported OTEL_TRACES_SAMPLER %q", sampler)
+}
+
+// sampleTrace keeps a trace when the low 8 bytes of its id fall under
+// ratio, the same rule as the SDKs' TraceIDRatioBased sampler.
+func sampleTrace(id [16]byte, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	return binary.BigEndian.Uint64(id[8:])>>1 < uint64(ratio*(1<<63))
+}
+
+// parseTraceparent reads a W3C traceparent ("00-<trace>-<span>-<flags>").
+func parseTraceparent(v string) *Span {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil
+	}
+	s := &Span{}
+	if _, err := hex.Decode(s.traceID[:], []byte(parts[1])); err != nil {
+		return nil
+	}
+	if _, err := hex.Decode(s.spanID[:], []byte(parts[2])); err != nil {
+		return nil
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || s.traceID == ([16]byte{}) || s.spanID == ([8]byte{}) {
+		return nil
+	}
+	s.sampled = flags[0]&1 == 1
+	return s
+}
+
+// parseOTELList reads the "k1=v1,k2=v2" form of OTEL_RESOURCE_ATTRIBUTES
+// and OTEL_EXPORTER_OTLP_HEADERS; values are percent-decoded.
+func parseOTELList(v string) map[string]string {
+	out := map[string]string{}
+	for _, part := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		if dec, err := url.PathUnescape(strings.TrimSpace(val)); err == nil {
+			val = dec
+		}
+		out[k] = strings.TrimSpace(val)
+	}
+	return out
+}
+
+func otelMillis(name string, def time.Duration) time.Duration {
+	if n, err := strconv.Atoi(Env(name, "")); err == nil && n > 0 {
+		return time.Duration(n) * time.Millisecond
+	}
+	return def
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+}
+
+// OTLP/HTTP JSON payload. Ids are hex and 64-bit integers are strings, as
+// the OTLP JSON mapping requires.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, k := range sortedKeys(attrs, func(k string) string { return k }) {
+		var v map[string]any
+		switch x := attrs[k].(type) {
+		case string:
+			v = map[string]any{"stringValue": x}
+		case bool:
+			v = map[string]any{"boolValue": x}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": x}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: v})
+	}
+	return out
+}
+
+// sortedKeys returns m's keys ordered by name(key), so the output is stable.
+func sortedKeys[K comparable, V any](m map[K]V, name func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return name(keys[i]) < name(keys[j]) })
+	return keys
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRequestTimeout bounds one Feishu API request, retries excluded.
+const defaultRequestTimeout = 30 * time.Second
+
+// HTTPTuning adjusts the client used for Feishu API calls. Zero fields keep
+// the defaults: a 30s request timeout and net/http's transport settings
+// (30s dial, 10s TLS handshake, 100 idle connections of which 2 per host,
+// 90s idle keep-alive).
+type HTTPTuning struct {
+	RequestTimeout      time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// MaxIdleConns is the idle connection pool size. Every request goes to
+	// the same host, so it also sets the per-host limit.
+	MaxIdleConns int
+	// KeepAlive is how long an idle connection stays open for reuse.
+	KeepAlive time.Duration
+}
+
+// HTTPTuningFromEnv reads BITABLE_HTTP_TIMEOUT, BITABLE_DIAL_TIMEOUT,
+// BITABLE_TLS_HANDSHAKE_TIMEOUT, BITABLE_MAX_IDLE_CONNS and
+// BITABLE_KEEP_ALIVE. Unset or invalid values are left zero.
+func HTTPTuningFromEnv() HTTPTuning {
+	return HTTPTuning{
+		RequestTimeout:      envDuration("BITABLE_HTTP_TIMEOUT"),
+		DialTimeout:         envDuration("BITABLE_DIAL_TIMEOUT"),
+		TLSHandshakeTimeout: envDuration("BITABLE_TLS_HANDSHAKE_TIMEOUT"),
+		MaxIdleConns:        envPositiveInt("BITABLE_MAX_IDLE_CONNS"),
+		KeepAlive:           envDuration("BITABLE_KEEP_ALIVE"),
+	}
+}
+
+func envDuration(name string) time.Duration {
+	if d, err := time.ParseDuration(Env(name, "")); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+func envPositiveInt(name string) int {
+	if n, err := strconv.Atoi(Env(name, "")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// SetHTTPTuning applies t to every Feishu API request of the process. Like
+// UseEmbeddedRoots it changes http.DefaultTransport, which the API client
+// shares.
+func SetHTTPTuning(t HTTPTuning) error {
+	tr, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.New("default HTTP transport is not an *http.Transport")
+	}
+	if t.RequestTimeout > 0 {
+		defaultClient.c.Timeout = t.RequestTimeout
+	}
+	if t.DialTimeout > 0 {
+		tr.DialContext = (&net.Dialer{Timeout: t.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if t.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = t.TLSHandshakeTimeout
+	}
+	if t.MaxIdleConns > 0 {
+		tr.MaxIdleConns = t.MaxIdleConns
+		tr.MaxIdleConnsPerHost = t.MaxIdleConns
+	}
+	if t.KeepAlive > 0 {
+		tr.IdleConnTimeout = t.KeepAlive
+	}
+	return nil
+}
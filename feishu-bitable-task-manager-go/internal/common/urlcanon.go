@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxShortLinkHops = 5
+
+// ShortLinkHosts are share-link domains that only redirect to the real
+// content URL.
+var ShortLinkHosts = map[string]bool{
+	"v.douyin.com":   true,
+	"v.kuaishou.com": true,
+}
+
+// shortLinkClient never follows redirects so each hop's Location can be
+// inspected; it does not go through the Feishu rate limiter.
+var shortLinkClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// trackingParams are query parameters that never change what a URL points
+// to; utm_* and share_* are matched by prefix.
+var trackingParams = map[string]bool{
+	"spm":    true,
+	"fbclid": true,
+	"gclid":  true,
+}
+
+// CanonicalURL lowercases scheme and host, drops the fragment and tracking
+// query parameters, and sorts the remaining query. Values that do not parse
+// as absolute URLs are returned trimmed.
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	q := u.Query()
+	for k := range q {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "utm_") || strings.HasPrefix(lk, "share_") || trackingParams[lk] {
+			q.Del(k)
+		}
+	}
+	// Encode sorts by key.
+	u.RawQuery = q.Encode()
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	return u.String()
+}
+
+// CanonicalizeURL resolves short share links (when resolve is set) with HEAD
+// requests and then applies CanonicalURL.
+func CanonicalizeURL(ctx context.Context, raw string, resolve bool) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if resolve {
+		resolved, err := ResolveShortURL(ctx, raw)
+		if err != nil {
+			return CanonicalURL(raw), err
+		}
+		raw = resolved
+	}
+	return CanonicalURL(raw), nil
+}
+
+// ResolveShortURL follows redirects of URLs on ShortLinkHosts and returns
+// the first URL outside them. Other URLs are returned unchanged.
+func ResolveShortURL(ctx context.Context, raw string) (string, error) {
+	cur := strings.TrimSpace(raw)
+	for hop := 0; hop < maxShortLinkHops; hop++ {
+		u, err := url.Parse(cur)
+		if err != nil || !ShortLinkHosts[strings.ToLower(u.Hostname())] {
+			return cur, nil
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, cur, nil)
+		if err != nil {
+			return raw, err
+		}
+		resp, err := shortLinkClient.Do(req)
+		if err != nil {
+			return raw, fmt.Errorf("resolve %s: %w", cur, explainTLSError(err))
+		}
+		resp.Body.Close()
+		loc := resp.Header.Get("Location")
+		if resp.StatusCode/100 != 3 || loc == "" {
+			if hop > 0 && resp.StatusCode/100 == 2 {
+				// Redirected to a page on the same short-link host.
+				return cur, nil
+			}
+			return raw, fmt.Errorf("resolve %s: http %d without redirect", cur, resp.StatusCode)
+		}
+		next, err := u.Parse(loc)
+		if err != nil {
+			return raw, fmt.Errorf("resolve %s: bad Location %q", cur, loc)
+		}
+		cur = next.String()
+	}
+	return raw, fmt.Errorf("resolve %s: too many redirects", raw)
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWikiCacheTTL is how long a resolved wiki node is trusted when
+// FEISHU_WIKI_CACHE_TTL is unset. A node keeps its obj_token for life, so
+// the TTL only bounds how long a deleted or moved node goes unnoticed.
+const defaultWikiCacheTTL = 24 * time.Hour
+
+type wikiCacheEntry struct {
+	ObjToken  string `json:"obj_token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// wikiCache remembers wiki_token → obj_token resolutions in memory and,
+// when FEISHU_TOKEN_CACHE_DIR is set, in wiki_nodes.json next to the tenant
+// token file, so CLI invocations from workers skip the get_node round trip.
+type wikiCache struct {
+	mu      sync.Mutex
+	entries map[string]wikiCacheEntry
+	loaded  bool
+}
+
+var wikiNodes = &wikiCache{entries: map[string]wikiCacheEntry{}}
+
+func wikiCacheKey(objType, wikiToken string) string {
+	return objType + ":" + wikiToken
+}
+
+func wikiCacheTTL() time.Duration {
+	raw := Env("FEISHU_WIKI_CACHE_TTL", "")
+	if raw == "" {
+		return defaultWikiCacheTTL
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return defaultWikiCacheTTL
+	}
+	return d
+}
+
+func wikiCacheFilePath() string {
+	dir := Env("FEISHU_TOKEN_CACHE_DIR", "")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "wiki_nodes.json")
+}
+
+// load reads the cache file once; c.mu must be held.
+func (c *wikiCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	path := wikiCacheFilePath()
+	if path == "" {
+		return
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var stored map[string]wikiCacheEntry
+	if json.Unmarshal(raw, &stored) != nil {
+		return
+	}
+	for k, e := range stored {
+		if _, ok := c.entries[k]; !ok {
+			c.entries[k] = e
+		}
+	}
+}
+
+func (c *wikiCache) get(objType, wikiToken string) (string, bool) {
+	if wikiCacheTTL() <= 0 {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	e, ok := c.entries[wikiCacheKey(objType, wikiToken)]
+	if !ok || strings.TrimSpace(e.ObjToken) == "" || time.Now().Unix() >= e.ExpiresAt {
+		return "", false
+	}
+	return e.ObjToken, true
+}
+
+// put stores a resolution and rewrites the cache file without the entries
+// that have expired.
+func (c *wikiCache) put(objType, wikiToken, objToken string) {
+	ttl := wikiCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	now := time.Now()
+	c.entries[wikiCacheKey(objType, wikiToken)] = wikiCacheEntry{ObjToken: objToken, ExpiresAt: now.Add(ttl).Unix()}
+	for k, e := range c.entries {
+		if now.Unix() >= e.ExpiresAt {
+			delete(c.entries, k)
+		}
+	}
+	path := wikiCacheFilePath()
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	raw, err := json.Marshal(c.entries)
+	if err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
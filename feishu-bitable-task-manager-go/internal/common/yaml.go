@@ -0,0 +1,144 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSimpleYAML parses the small YAML subset used by config files: nested
+// mappings by indentation, scalar values (plain, single- or double-quoted)
+// and # comments. Sequences, anchors and multi-line scalars are not
+// supported; the module stays dependency-free.
+func ParseSimpleYAML(data []byte) (map[string]any, error) {
+	type frame struct {
+		indent int
+		m      map[string]any
+	}
+	root := map[string]any{}
+	stack := []frame{{indent: -1, m: root}}
+	// pendingKey is a key with an empty value whose children may follow.
+	var pendingKey string
+	var pendingParent map[string]any
+	pendingIndent := -1
+
+	for lineNo, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if strings.Contains(line[:len(line)-len(strings.TrimLeft(line, " \t"))], "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", lineNo+1)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("yaml line %d: sequences are not supported", lineNo+1)
+		}
+
+		if pendingParent != nil {
+			if indent > pendingIndent {
+				child := map[string]any{}
+				pendingParent[pendingKey] = child
+				stack = append(stack, frame{indent: indent, m: child})
+			}
+			pendingParent = nil
+		}
+		for len(stack) > 1 && indent < stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		cur := stack[len(stack)-1]
+		if indent != cur.indent && cur.indent >= 0 {
+			return nil, fmt.Errorf("yaml line %d: unexpected indentation", lineNo+1)
+		}
+		if cur.indent < 0 {
+			stack[len(stack)-1].indent = indent
+		}
+
+		key, rest, ok := splitYAMLKey(trimmed)
+		if !ok {
+			return nil, fmt.Errorf("yaml line %d: expected \"key: value\"", lineNo+1)
+		}
+		rest = stripYAMLComment(rest)
+		if rest == "" {
+			cur.m[key] = ""
+			pendingKey, pendingParent, pendingIndent = key, cur.m, indent
+			continue
+		}
+		val, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %w", lineNo+1, err)
+		}
+		cur.m[key] = val
+	}
+	return root, nil
+}
+
+func splitYAMLKey(s string) (string, string, bool) {
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		q := s[:1]
+		end := strings.Index(s[1:], q)
+		if end < 0 {
+			return "", "", false
+		}
+		key := s[1 : end+1]
+		rest := strings.TrimSpace(s[end+2:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest[1:]), true
+	}
+	idx := strings.Index(s, ": ")
+	if idx < 0 {
+		if strings.HasSuffix(s, ":") {
+			return strings.TrimSpace(strings.TrimSuffix(s, ":")), "", true
+		}
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:idx]), strings.TrimSpace(s[idx+2:]), true
+}
+
+func stripYAMLComment(s string) string {
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		return s
+	}
+	if idx := strings.Index(s, " #"); idx >= 0 {
+		return strings.TrimSpace(s[:idx])
+	}
+	return strings.TrimSpace(s)
+}
+
+func parseYAMLScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		end := strings.LastIndex(s, "\"")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", err
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	default:
+		return s, nil
+	}
+}
+
+// YAMLString returns m[key] as a string when it is a scalar.
+func YAMLString(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return strings.TrimSpace(s)
+}
+
+// YAMLMap returns m[key] as a nested mapping, or nil.
+func YAMLMap(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
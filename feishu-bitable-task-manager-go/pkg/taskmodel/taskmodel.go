@@ -0,0 +1,179 @@
+// Package taskmodel is the task vocabulary shared by bitable-task and the
+// services around it: the Status values the CLI writes, the logical task
+// fields and their TASK_FIELD_* overrides, and the status transitions the
+// CLI performs. Import it instead of hardcoding strings like "dispatched".
+package taskmodel
+
+import "strings"
+
+// Status is a value of the Status column.
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusDispatched Status = "dispatched"
+	StatusRunning    Status = "running"
+	StatusSuccess    Status = "success"
+	StatusFailed     Status = "failed"
+	StatusError      Status = "error"
+	StatusTimeout    Status = "timeout"
+	StatusCancelled  Status = "cancelled"
+	// StatusExhausted is set by retry --max-retries instead of requeueing.
+	StatusExhausted Status = "exhausted"
+	// StatusExpired is set by fetch/claim --expire on pending tasks older
+	// than --max-age.
+	StatusExpired Status = "expired"
+)
+
+// Statuses lists every status in lifecycle order; init-table creates the
+// Status select options in this order.
+var Statuses = []Status{
+	StatusPending,
+	StatusDispatched,
+	StatusRunning,
+	StatusSuccess,
+	StatusFailed,
+	StatusError,
+	StatusTimeout,
+	StatusCancelled,
+	StatusExhausted,
+	StatusExpired,
+}
+
+// ParseStatus returns the status matching s case-insensitively, the way the
+// CLI normalizes labels written by hand or by older tools.
+func ParseStatus(s string) (Status, bool) {
+	s = strings.TrimSpace(s)
+	for _, st := range Statuses {
+		if strings.EqualFold(s, string(st)) {
+			return st, true
+		}
+	}
+	return "", false
+}
+
+// IsTerminal reports whether s ends an attempt: complete and work accept
+// only these, and the runs table records an attempt when one is written.
+func (s Status) IsTerminal() bool {
+	switch s {
+	case StatusSuccess, StatusFailed, StatusError, StatusTimeout, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// HoldsLease reports whether a worker owns the task in status s, so its
+// lease (HeartbeatAt) can expire and the task be reclaimed.
+func (s Status) HoldsLease() bool {
+	return s == StatusDispatched || s == StatusRunning
+}
+
+// Transitions are the status changes the CLI makes:
+//
+//   - claim: pending -> dispatched, and dispatched/running -> dispatched
+//     when the lease expired;
+//   - fetch and claim --expire: pending -> expired;
+//   - work: dispatched -> running;
+//   - complete and work: dispatched/running -> any terminal status;
+//   - retry: failed/error/timeout/cancelled -> pending or exhausted.
+var Transitions = map[Status][]Status{
+	StatusPending:    {StatusDispatched, StatusExpired},
+	StatusDispatched: {StatusDispatched, StatusRunning, StatusSuccess, StatusFailed, StatusError, StatusTimeout, StatusCancelled},
+	StatusRunning:    {StatusDispatched, StatusSuccess, StatusFailed, StatusError, StatusTimeout, StatusCancelled},
+	StatusFailed:     {StatusPending, StatusExhausted},
+	StatusError:      {StatusPending, StatusExhausted},
+	StatusTimeout:    {StatusPending, StatusExhausted},
+	StatusCancelled:  {StatusPending, StatusExhausted},
+}
+
+// CanTransition reports whether the CLI moves a task from one status to
+// the other. update writes any status; this describes the automated flow.
+func CanTransition(from, to Status) bool {
+	for _, s := range Transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Field is a logical task field. The column it maps to defaults to the
+// same name and is overridden by its TASK_FIELD_* variable.
+type Field string
+
+const (
+	FieldTaskID           Field = "TaskID"
+	FieldBizTaskID        Field = "BizTaskID"
+	FieldParentTaskID     Field = "ParentTaskID"
+	FieldApp              Field = "App"
+	FieldScene            Field = "Scene"
+	FieldParams           Field = "Params"
+	FieldItemID           Field = "ItemID"
+	FieldBookID           Field = "BookID"
+	FieldURL              Field = "URL"
+	FieldUserID           Field = "UserID"
+	FieldUserName         Field = "UserName"
+	FieldDate             Field = "Date"
+	FieldStatus           Field = "Status"
+	FieldLogs             Field = "Logs"
+	FieldLastScreenShot   Field = "LastScreenShot"
+	FieldGroupID          Field = "GroupID"
+	FieldDeviceSerial     Field = "DeviceSerial"
+	FieldDispatchedDevice Field = "DispatchedDevice"
+	FieldDispatchedAt     Field = "DispatchedAt"
+	FieldStartAt          Field = "StartAt"
+	FieldEndAt            Field = "EndAt"
+	FieldElapsedSeconds   Field = "ElapsedSeconds"
+	FieldItemsCollected   Field = "ItemsCollected"
+	FieldExtra            Field = "Extra"
+	FieldRetryCount       Field = "RetryCount"
+	FieldArtifacts        Field = "Artifacts"
+	FieldAttemptToken     Field = "AttemptToken"
+	FieldHeartbeatAt      Field = "HeartbeatAt"
+	FieldFingerprint      Field = "Fingerprint"
+	FieldTraceID          Field = "TraceID"
+	FieldDeleted          Field = "Deleted"
+	FieldEditLock         Field = "EditLock"
+	FieldTouchedAt        Field = "TouchedAt"
+	FieldPriority         Field = "Priority"
+	FieldLogsFile         Field = "LogsFile"
+)
+
+// FieldEnv maps each TASK_FIELD_* override to the logical field it renames.
+var FieldEnv = map[string]Field{
+	"TASK_FIELD_TASKID":            FieldTaskID,
+	"TASK_FIELD_BIZ_TASK_ID":       FieldBizTaskID,
+	"TASK_FIELD_PARENT_TASK_ID":    FieldParentTaskID,
+	"TASK_FIELD_APP":               FieldApp,
+	"TASK_FIELD_SCENE":             FieldScene,
+	"TASK_FIELD_PARAMS":            FieldParams,
+	"TASK_FIELD_ITEMID":            FieldItemID,
+	"TASK_FIELD_BOOKID":            FieldBookID,
+	"TASK_FIELD_URL":               FieldURL,
+	"TASK_FIELD_USERID":            FieldUserID,
+	"TASK_FIELD_USERNAME":          FieldUserName,
+	"TASK_FIELD_DATE":              FieldDate,
+	"TASK_FIELD_STATUS":            FieldStatus,
+	"TASK_FIELD_LOGS":              FieldLogs,
+	"TASK_FIELD_LAST_SCREEN_SHOT":  FieldLastScreenShot,
+	"TASK_FIELD_GROUPID":           FieldGroupID,
+	"TASK_FIELD_DEVICE_SERIAL":     FieldDeviceSerial,
+	"TASK_FIELD_DISPATCHED_DEVICE": FieldDispatchedDevice,
+	"TASK_FIELD_DISPATCHED_AT":     FieldDispatchedAt,
+	"TASK_FIELD_START_AT":          FieldStartAt,
+	"TASK_FIELD_END_AT":            FieldEndAt,
+	"TASK_FIELD_ELAPSED_SECONDS":   FieldElapsedSeconds,
+	"TASK_FIELD_ITEMS_COLLECTED":   FieldItemsCollected,
+	"TASK_FIELD_EXTRA":             FieldExtra,
+	"TASK_FIELD_RETRYCOUNT":        FieldRetryCount,
+	"TASK_FIELD_ARTIFACTS":         FieldArtifacts,
+	"TASK_FIELD_ATTEMPT_TOKEN":     FieldAttemptToken,
+	"TASK_FIELD_HEARTBEAT_AT":      FieldHeartbeatAt,
+	"TASK_FIELD_FINGERPRINT":       FieldFingerprint,
+	"TASK_FIELD_TRACE_ID":          FieldTraceID,
+	"TASK_FIELD_DELETED":           FieldDeleted,
+	"TASK_FIELD_EDIT_LOCK":         FieldEditLock,
+	"TASK_FIELD_TOUCHED_AT":        FieldTouchedAt,
+	"TASK_FIELD_PRIORITY":          FieldPriority,
+	"TASK_FIELD_LOGS_FILE":         FieldLogsFile,
+}
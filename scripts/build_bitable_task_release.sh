@@ -0,0 +1,53 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Cross-compile bitable-task for every release platform into <OUT_DIR> with
+# the asset names self-update expects, plus checksums.txt (sha256sum format).
+# Binaries are static (CGO_ENABLED=0) and carry the Mozilla CA bundle, so
+# they also run on minimal images (--use-embedded-roots).
+
+version="${1:-}"
+out_dir="${2:-dist}"
+
+if [[ -z "${version}" ]]; then
+  echo "Usage: $0 <VERSION> [OUT_DIR]" >&2
+  exit 2
+fi
+
+platforms=(
+  linux/amd64
+  linux/arm64
+  linux/arm
+  android/arm64
+  darwin/amd64
+  darwin/arm64
+  windows/amd64
+  windows/arm64
+)
+
+repo_root="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+module_dir="${repo_root}/feishu-bitable-task-manager-go"
+mkdir -p "${out_dir}"
+out_dir="$(cd "${out_dir}" && pwd)"
+
+for platform in "${platforms[@]}"; do
+  goos="${platform%/*}"
+  goarch="${platform#*/}"
+  name="bitable-task_${goos}_${goarch}"
+  if [[ "${goos}" == "windows" ]]; then
+    name="${name}.exe"
+  fi
+  echo "building ${name}"
+  (
+    cd "${module_dir}"
+    CGO_ENABLED=0 GOOS="${goos}" GOARCH="${goarch}" go build -trimpath \
+      -ldflags "-s -w -X feishu-bitable-task-manager-go/internal/cli.Version=${version}" \
+      -o "${out_dir}/${name}" ./cmd/bitable-task
+  )
+done
+
+(
+  cd "${out_dir}"
+  sha256sum bitable-task_* > checksums.txt
+)
+echo "wrote ${out_dir}/checksums.txt"